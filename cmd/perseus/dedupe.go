@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// dedupeCmd represents the "dedupe" command for the CLI interface.
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Stores the objects of forks in a shared object pool",
+	Long: `The dedupe command detects mirrors that share at least one root commit (e.g. several forks of the same upstream project).
+Instead of storing the full history in every fork, those mirrors store their objects in a common object pool
+and borrow them from there via objects/info/alternates.
+
+Object pools are located in the directory "_pool" inside of the repository directory.
+Objects of a pool are never pruned, because git doesn't know which mirrors borrow them.
+Never delete an object pool, because all mirrors of the pool would be broken afterwards.
+
+The bytes saved per mirror and pool are logged and part of the run report.
+
+With "dry-run", the mirrors that would share an object pool will be printed, but nothing will be changed.
+`,
+	Example: `  perseus dedupe --dry-run
+  perseus dedupe
+  perseus dedupe /var/config/medusa.json`,
+	ValidArgs: []string{"config"},
+	RunE:      cmdDedupeRun,
+}
+
+// cmdDedupeRun is the CLI interface for the "dedupe" command
+func cmdDedupeRun(cmd *cobra.Command, args []string) error {
+	l, err := newLogger()
+	if err != nil {
+		return err
+	}
+
+	// Check if we got minimum 1 argument.
+	// We will only use the first argument here. The rest will be ignored.
+	// First argument is the configuration file, but it is optional.
+	configFileArg := ""
+	if len(args) >= 1 {
+		configFileArg = args[0]
+	}
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"path": viper.ConfigFileUsed(),
+	}).Info("Using configuration file")
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"dry-run\" flag: %s\n", err)
+	}
+
+	// Determine number of concurrent workers
+	nOfWorkers, err := cmd.Flags().GetInt("numOfWorkers")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
+	}
+
+	l.WithFields(logrus.Fields{
+		"command": "dedupe",
+		"dry-run": dryRun,
+	}).Info("Running command")
+	// Setup command and run it
+	c := &controller.DedupeController{
+		DryRun:      dryRun,
+		Config:      m,
+		Log:         logrus.FieldLogger(l),
+		NumOfWorker: nOfWorkers,
+	}
+	err = c.Run()
+	if err != nil {
+		return fmt.Errorf("Error during execution of \"dedupe\" command: %s\n", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// gcCmd represents the "gc" command for the CLI interface.
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Runs the garbage collection of all mirrored repositories",
+	Long: `The gc command runs the garbage collection (incl. repacking) of all mirrors concurrently.
+
+Every fetch adds loose objects and packs to a mirror. Over time this slows down
+the update runs and the delivery of the mirror and wastes disk space.
+
+A garbage collection runs only for mirrors that exceed at least one threshold:
+ * loose-objects: Number of loose objects
+ * packs: Number of pack files
+ * max-age-days: Days since the last garbage collection
+
+The thresholds are read from the "gc" section of the configuration file and can be overwritten by flags.
+When "force" is given, the garbage collection runs for every mirror, regardless of the thresholds.
+
+The bytes reclaimed per mirror are logged and part of the run report.
+`,
+	Example: `  perseus gc
+  perseus gc --force
+  perseus gc --loose-objects=1000 --max-age-days=30 /var/config/medusa.json`,
+	ValidArgs: []string{"config"},
+	RunE:      cmdGCRun,
+}
+
+// cmdGCRun is the CLI interface for the "gc" command
+func cmdGCRun(cmd *cobra.Command, args []string) error {
+	l, err := newLogger()
+	if err != nil {
+		return err
+	}
+
+	// Check if we got minimum 1 argument.
+	// We will only use the first argument here. The rest will be ignored.
+	// First argument is the configuration file, but it is optional.
+	configFileArg := ""
+	if len(args) >= 1 {
+		configFileArg = args[0]
+	}
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"path": viper.ConfigFileUsed(),
+	}).Info("Using configuration file")
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"force\" flag: %s\n", err)
+	}
+
+	// Flags overwrite the thresholds of the configuration
+	t := m.GetGCThresholds()
+	if cmd.Flags().Changed("loose-objects") {
+		if t.LooseObjects, err = cmd.Flags().GetInt("loose-objects"); err != nil {
+			return fmt.Errorf("Couldn't determine \"loose-objects\" flag: %s\n", err)
+		}
+	}
+	if cmd.Flags().Changed("packs") {
+		if t.Packs, err = cmd.Flags().GetInt("packs"); err != nil {
+			return fmt.Errorf("Couldn't determine \"packs\" flag: %s\n", err)
+		}
+	}
+	if cmd.Flags().Changed("max-age-days") {
+		days, err := cmd.Flags().GetInt("max-age-days")
+		if err != nil {
+			return fmt.Errorf("Couldn't determine \"max-age-days\" flag: %s\n", err)
+		}
+		t.MaxAge = time.Duration(days) * 24 * time.Hour
+	}
+
+	// Determine number of concurrent workers
+	nOfWorkers, err := cmd.Flags().GetInt("numOfWorkers")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
+	}
+
+	l.WithFields(logrus.Fields{
+		"command":       "gc",
+		"force":         force,
+		"loose_objects": t.LooseObjects,
+		"packs":         t.Packs,
+		"max_age":       t.MaxAge.String(),
+	}).Info("Running command")
+	// Setup command and run it
+	c := &controller.GCController{
+		Force:       force,
+		Thresholds:  t,
+		Config:      m,
+		Log:         logrus.FieldLogger(l),
+		NumOfWorker: nOfWorkers,
+	}
+	err = c.Run()
+	if err != nil {
+		return fmt.Errorf("Error during execution of \"gc\" command: %s\n", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// graphCmd represents the "graph" command for the CLI interface.
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Renders the dependency graph of one package or of all configured packages",
+	Long: `The graph command resolves the dependencies of one package (or of all packages from the configuration file) and prints the dependency graph.
+
+Every edge of the graph is a requirement of a package incl. the version constraints that introduced it.
+Supported output formats are DOT (Graphviz), JSON and Mermaid.
+The graph is written to stdout, log messages to stderr.
+`,
+	Example: `  perseus graph
+  perseus graph --package="symfony/console" | dot -Tsvg > console.svg
+  perseus graph --format=mermaid /var/config/medusa.json`,
+	ValidArgs: []string{"config"},
+	RunE:      cmdGraphRun,
+}
+
+// cmdGraphRun is the CLI interface for the "graph" command
+func cmdGraphRun(cmd *cobra.Command, args []string) error {
+	l, err := newLogger()
+	if err != nil {
+		return err
+	}
+
+	// Check if we got minimum 1 argument.
+	// We will only use the first argument here. The rest will be ignored.
+	// First argument is the configuration file, but it is optional.
+	configFileArg := ""
+	if len(args) >= 1 {
+		configFileArg = args[0]
+	}
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"path": viper.ConfigFileUsed(),
+	}).Info("Using configuration file")
+
+	packet, err := cmd.Flags().GetString("package")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"package\" flag: %s\n", err)
+	}
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"format\" flag: %s\n", err)
+	}
+
+	// Determine number of concurrent workers
+	nOfWorkers, err := cmd.Flags().GetInt("numOfWorkers")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
+	}
+
+	resolverOptions, err := getResolverOptions(cmd, m)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"command": "graph",
+		"package": packet,
+		"format":  format,
+	}).Info("Running command")
+	// Setup command and run it
+	c := &controller.GraphController{
+		Package:         packet,
+		Format:          format,
+		Config:          m,
+		Log:             logrus.FieldLogger(l),
+		NumOfWorker:     nOfWorkers,
+		ResolverOptions: resolverOptions,
+	}
+	err = c.Run()
+	if err != nil {
+		return fmt.Errorf("Error during execution of \"graph\" command: %s\n", err)
+	}
+
+	return nil
+}
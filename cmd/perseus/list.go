@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// listCmd represents the "list" command for the CLI interface.
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists all mirrored repositories",
+	Long: `The list command enumerates all mirrors in the repository directory.
+
+For every mirror the package name, the upstream URL, the size on disk, the number of branches and tags,
+the last successful fetch and whether the package is part of the Satis and the medusa configuration will be printed.
+`,
+	Example: `  perseus list
+  perseus list --orphaned
+  perseus list --not-in-satis --format=json
+  perseus list --stale=7 /var/config/medusa.json`,
+	ValidArgs: []string{"config"},
+	RunE:      cmdListRun,
+}
+
+// cmdListRun is the CLI interface for the "list" command
+func cmdListRun(cmd *cobra.Command, args []string) error {
+	l, err := newLogger()
+	if err != nil {
+		return err
+	}
+
+	// Check if we got minimum 1 argument.
+	// We will only use the first argument here. The rest will be ignored.
+	// First argument is the configuration file, but it is optional.
+	configFileArg := ""
+	if len(args) >= 1 {
+		configFileArg = args[0]
+	}
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"path": viper.ConfigFileUsed(),
+	}).Info("Using configuration file")
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"format\" flag: %s\n", err)
+	}
+	orphaned, err := cmd.Flags().GetBool("orphaned")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"orphaned\" flag: %s\n", err)
+	}
+	notInSatis, err := cmd.Flags().GetBool("not-in-satis")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"not-in-satis\" flag: %s\n", err)
+	}
+	stale, err := cmd.Flags().GetInt("stale")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"stale\" flag: %s\n", err)
+	}
+
+	// Setup command and run it
+	c := &controller.ListController{
+		Format:     format,
+		Orphaned:   orphaned,
+		NotInSatis: notInSatis,
+		StaleDays:  stale,
+		Config:     m,
+		Log:        logrus.FieldLogger(l),
+	}
+	err = c.Run()
+	if err != nil {
+		return fmt.Errorf("Error during execution of \"list\" command: %s\n", err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
@@ -10,6 +11,10 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/andygrunwald/perseus/config"
 	"github.com/andygrunwald/perseus/controller"
+	"github.com/andygrunwald/perseus/credentials"
+	"github.com/andygrunwald/perseus/dependency"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/metrics"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -31,6 +36,21 @@ var (
 
 	// numOfWorkers reflects the number of workers used for concurrent processes
 	numOfWorkers int
+
+	// metricsTextfile is the file where metrics will be written to after a command run
+	metricsTextfile string
+
+	// logFormat is the format of log messages (text or json)
+	logFormat string
+
+	// logLevel is the minimum level of log messages (like debug, info, warn or error)
+	logLevel string
+
+	// logFile is the file where log messages will be written to (default: stderr)
+	logFile string
+
+	// masker masks secrets (like the credentials of the configuration) in log messages
+	masker = credentials.NewMasker()
 )
 
 // RootCmd represents the base command when called without any subcommands.
@@ -53,25 +73,102 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "medusa.json", "Medusa configuration file")
-	RootCmd.PersistentFlags().IntVar(&numOfWorkers, "numOfWorkers", runtime.GOMAXPROCS(0), "Number of worker used for concurrent operations (e.g. resolving a dependency tree or downloads)")
+	RootCmd.PersistentFlags().IntVar(&numOfWorkers, "numOfWorkers", runtime.GOMAXPROCS(0), "Number of worker used for concurrent operations (e.g. resolving a dependency tree or downloads). The configuration key \"limits\" can overwrite it for resolving and git transfers. Its bandwidth limit throttles git transfers via HTTP(S) only, not via SSH")
+	RootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Format of log messages: text or json")
+	RootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Minimum level of log messages: debug, info, warn or error")
+	RootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Write log messages to this file instead of stderr")
+	RootCmd.PersistentFlags().StringVar(&metricsTextfile, "metrics-textfile", "", "Write metrics in the Prometheus text format to this file after the command run (for the textfile collector of the node exporter)")
 
 	// Original medusa command
 	// 	medusa add [--with-deps] package [config]
 	RootCmd.AddCommand(addCmd)
 	addCmd.Flags().Bool("with-deps", false, "If set, the package dependencies will be downloaded, too")
+	addResolverFlags(addCmd)
+	addProgressFlag(addCmd)
 
 	// Original medusa command
 	// 	medusa mirror [config]
 	RootCmd.AddCommand(mirrorCmd)
+	addResolverFlags(mirrorCmd)
+	addProgressFlag(mirrorCmd)
 
 	// Original medusa command
-	// 	medusa update [config]
+	// 	medusa update [--follow-url-changes] [--gc] [config]
 	RootCmd.AddCommand(updateCmd)
+	updateCmd.Flags().Bool("follow-url-changes", false, "If set, the upstream URL of every mirror will be determined from the configuration file or Packagist and changed if the package moved")
+	updateCmd.Flags().Bool("gc", false, "If set, a garbage collection runs after every successful update of a mirror that exceeds a gc threshold")
+	addProgressFlag(updateCmd)
 
 	// Custom perseus command
 	// 	perseus version
 	RootCmd.AddCommand(versionCmd)
 
+	// Custom perseus command
+	// 	perseus graph [--package=...] [--format=dot|json|mermaid] [config]
+	RootCmd.AddCommand(graphCmd)
+	graphCmd.Flags().String("package", "", "Package to render the dependency graph for. If empty, all configured packages will be used")
+	graphCmd.Flags().String("format", "dot", "Output format of the graph: dot, json or mermaid")
+	addResolverFlags(graphCmd)
+
+	// Custom perseus command
+	// 	perseus why [--limit=...] package [config]
+	RootCmd.AddCommand(whyCmd)
+	whyCmd.Flags().Int("limit", 10, "Maximum number of require chains to print (0 = unlimited)")
+	addResolverFlags(whyCmd)
+
+	// Custom perseus command
+	// 	perseus serve [--listen=...] [--interval=...] [--with-mirror] [--follow-url-changes] [--gc] [config]
+	RootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().String("listen", ":9117", "Address of the HTTP server that exposes the metrics")
+	serveCmd.Flags().Duration("interval", time.Hour, "Time between two update runs")
+	serveCmd.Flags().Bool("with-mirror", false, "If set, the \"mirror\" command runs before every update run")
+	serveCmd.Flags().Bool("follow-url-changes", false, "If set, the upstream URL of every mirror will be determined from the configuration file or Packagist and changed if the package moved")
+	serveCmd.Flags().Bool("gc", false, "If set, a garbage collection runs after every successful update of a mirror that exceeds a gc threshold")
+	addResolverFlags(serveCmd)
+
+	// Custom perseus command
+	// 	perseus status [--format=text|json] [config]
+	RootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().String("format", controller.OutputFormatText, "Output format of the status: text or json")
+
+	// Custom perseus command
+	// 	perseus list [--format=text|json] [--orphaned] [--not-in-satis] [--stale=N] [config]
+	RootCmd.AddCommand(listCmd)
+	listCmd.Flags().String("format", controller.OutputFormatText, "Output format of the list: text or json")
+	listCmd.Flags().Bool("orphaned", false, "List only mirrors that are neither part of the Satis nor of the medusa configuration")
+	listCmd.Flags().Bool("not-in-satis", false, "List only mirrors that are not part of the Satis configuration")
+	listCmd.Flags().Int("stale", 0, "List only mirrors without a successful fetch for more than N days (0 = no filter)")
+
+	// Custom perseus command
+	// 	perseus reconcile [--dry-run] [--prune] [config]
+	RootCmd.AddCommand(reconcileCmd)
+	reconcileCmd.Flags().Bool("dry-run", false, "Print the differences without changing anything")
+	reconcileCmd.Flags().Bool("prune", false, "Delete mirrors that are not referenced by the configuration (incl. dependencies)")
+	addResolverFlags(reconcileCmd)
+
+	// Custom perseus command
+	// 	perseus verify [--repair] [--rotate=N] [config]
+	RootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().Bool("repair", false, "Repair broken mirrors by regenerating info/refs or cloning them again from upstream")
+	verifyCmd.Flags().Int("rotate", 0, "Verify only 1/N of all mirrors, the ones with the oldest verification first (0 = all mirrors)")
+
+	// Custom perseus command
+	// 	perseus gc [--force] [--loose-objects=N] [--packs=N] [--max-age-days=N] [config]
+	RootCmd.AddCommand(gcCmd)
+	gcCmd.Flags().Bool("force", false, "Run the garbage collection for every mirror, regardless of the thresholds")
+	gcCmd.Flags().Int("loose-objects", 0, "Garbage collect mirrors with more than N loose objects (0 = disabled). Overrides the configuration")
+	gcCmd.Flags().Int("packs", 0, "Garbage collect mirrors with more than N pack files (0 = disabled). Overrides the configuration")
+	gcCmd.Flags().Int("max-age-days", 0, "Garbage collect mirrors without a garbage collection for more than N days (0 = disabled). Overrides the configuration")
+
+	// Custom perseus command
+	// 	perseus dedupe [--dry-run] [config]
+	RootCmd.AddCommand(dedupeCmd)
+	dedupeCmd.Flags().Bool("dry-run", false, "Print the mirrors that would share an object pool without changing anything")
+
+	// Custom perseus command
+	// 	perseus url package [config]
+	RootCmd.AddCommand(urlCmd)
+
 	// Cobra is only able to define flags, but no arguments
 	// If we were able to define arguments we would implement those:
 	//
@@ -82,11 +179,204 @@ func init() {
 }
 
 func main() {
-	if err := RootCmd.Execute(); err != nil {
+	err := RootCmd.Execute()
+
+	// Metrics are written even if the command failed.
+	// Especially failures are interesting for alerting.
+	if len(metricsTextfile) > 0 {
+		if mErr := metrics.WriteTextfile(metricsTextfile, metrics.Default); mErr != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't write metrics to %s: %s\n", metricsTextfile, mErr)
+		}
+	}
+
+	if err != nil {
 		os.Exit(-1)
 	}
 }
 
+// newLogger returns the logger used by all commands.
+// Format, level and output are configured by the global flags "log-format", "log-level" and "log-file".
+// Without a log file, logs are written to stderr to keep stdout free for the command output.
+func newLogger() (*logrus.Logger, error) {
+	level, err := logrus.ParseLevel(logLevel)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't determine \"log-level\" flag: %s\n", err)
+	}
+
+	var formatter logrus.Formatter
+	switch strings.ToLower(logFormat) {
+	case "text":
+		formatter = &logrus.TextFormatter{
+			TimestampFormat: time.RFC3339,
+			FullTimestamp:   true,
+		}
+	case "json":
+		formatter = &logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339,
+		}
+	default:
+		return nil, fmt.Errorf("Unknown log format \"%s\". Supported formats: text, json\n", logFormat)
+	}
+
+	var out io.Writer = os.Stderr
+	if len(logFile) > 0 {
+		// The file stays open until the process ends.
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't open log file %s: %s\n", logFile, err)
+		}
+		out = f
+	}
+
+	l := &logrus.Logger{
+		Out:       out,
+		Formatter: formatter,
+		Hooks:     make(logrus.LevelHooks),
+		Level:     level,
+	}
+	l.Hooks.Add(&maskHook{masker: masker})
+	return l, nil
+}
+
+// maskHook is a logrus.Hook that masks secrets in the message and fields of every log entry.
+type maskHook struct {
+	masker *credentials.Masker
+}
+
+// Levels implements logrus.Hook.
+func (h *maskHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook.
+// Fields that are strings, errors or fmt.Stringer (like *url.URL) are masked.
+// A field is only replaced if it contains a secret.
+func (h *maskHook) Fire(e *logrus.Entry) error {
+	e.Message = h.masker.Mask(e.Message)
+	for k, v := range e.Data {
+		var s string
+		switch t := v.(type) {
+		case string:
+			s = t
+		case error:
+			s = t.Error()
+		case fmt.Stringer:
+			s = t.String()
+		default:
+			continue
+		}
+		if masked := h.masker.Mask(s); masked != s {
+			e.Data[k] = masked
+		}
+	}
+	return nil
+}
+
+// loadMedusaConfiguration reads the medusa configuration and creates the configuration object.
+// configFileArg is the optional configuration file that was applied as an argument.
+// If it is set, it overwrites the configuration that viper found before.
+func loadMedusaConfiguration(configFileArg string) (*config.Medusa, error) {
+	if len(configFileArg) > 0 {
+		if _, err := os.Stat(configFileArg); os.IsNotExist(err) {
+			return nil, fmt.Errorf("Configuration file %s applied, but doesn't exists", configFileArg)
+		}
+		viper.SetConfigFile(configFileArg)
+	}
+
+	// If a config file is found, read it in.
+	// If an error happen, quit.
+	if err := viper.ReadInConfig(); err != nil {
+		s := fmt.Errorf("Error while reading the configuration file \"%s\": %s\nPlease checkout https://github.com/andygrunwald/perseus#configuration for further details.", viper.ConfigFileUsed(), err)
+		return nil, s
+	}
+
+	// Create viper based configuration provider for Medusa
+	p, err := config.NewViperProvider(viper.GetViper())
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't create a viper configuration provider: %s\n", err)
+	}
+
+	m, err := config.NewMedusa(p)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't create medusa configuration object: %s\n", err)
+	}
+	metrics.SetRepoDir(m.GetString("repodir"))
+
+	creds, err := m.GetCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read credentials: %s\n", err)
+	}
+	masker.Add(creds.Secrets()...)
+
+	rules, err := m.GetRewriteRules()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read rewrite rules: %s\n", err)
+	}
+	dependency.SetRewriteRules(rules)
+
+	return m, nil
+}
+
+// addResolverFlags adds the flags to influence the dependency resolver to command cmd.
+// If a flag is set, it overwrites the "resolver" settings of the configuration file.
+func addResolverFlags(cmd *cobra.Command) {
+	cmd.Flags().String("require-dev", dependency.FollowNone, "Resolve require-dev dependencies: none, root (only of the required packages) or all")
+	cmd.Flags().String("suggest", dependency.FollowNone, "Resolve suggested packages: none, root (only of the required packages) or all")
+	cmd.Flags().Int("max-depth", 0, "Maximum depth of dependencies to resolve (0 = unlimited)")
+	cmd.Flags().String("minimum-stability", "", "Minimum stability of versions whose dependencies are resolved: stable, RC, beta, alpha or dev")
+}
+
+// getResolverOptions returns the settings for the dependency resolver.
+// Settings from the configuration m are overwritten by the flags of command cmd (if they are set).
+func getResolverOptions(cmd *cobra.Command, m *config.Medusa) (*dependency.ResolverOptions, error) {
+	o := m.GetResolverOptions()
+
+	var err error
+	if cmd.Flags().Changed("require-dev") {
+		if o.RequireDev, err = cmd.Flags().GetString("require-dev"); err != nil {
+			return nil, fmt.Errorf("Couldn't determine \"require-dev\" flag: %s\n", err)
+		}
+	}
+	if cmd.Flags().Changed("suggest") {
+		if o.Suggest, err = cmd.Flags().GetString("suggest"); err != nil {
+			return nil, fmt.Errorf("Couldn't determine \"suggest\" flag: %s\n", err)
+		}
+	}
+	if cmd.Flags().Changed("max-depth") {
+		if o.MaxDepth, err = cmd.Flags().GetInt("max-depth"); err != nil {
+			return nil, fmt.Errorf("Couldn't determine \"max-depth\" flag: %s\n", err)
+		}
+	}
+	if cmd.Flags().Changed("minimum-stability") {
+		stability, err := cmd.Flags().GetString("minimum-stability")
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't determine \"minimum-stability\" flag: %s\n", err)
+		}
+		// The flag only overwrites the minimum stability of the global policy.
+		// Include and exclude patterns of the configuration are kept.
+		if o.Versions == nil {
+			o.Versions = &dependency.VersionPolicy{}
+		}
+		o.Versions.MinimumStability = stability
+	}
+
+	return o, nil
+}
+
+// getGCAfterUpdate returns the thresholds for a garbage collection after every update.
+// If neither the "gc" flag of command cmd nor "gc.after-update" of the configuration m is set, nil is returned.
+func getGCAfterUpdate(cmd *cobra.Command, m *config.Medusa) (*downloader.GCThresholds, error) {
+	gc, err := cmd.Flags().GetBool("gc")
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't determine \"gc\" flag: %s\n", err)
+	}
+	if !gc && !m.GetGCAfterUpdate() {
+		return nil, nil
+	}
+
+	return m.GetGCThresholds(), nil
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	viper.SetConfigName("medusa")
@@ -125,34 +415,21 @@ func cmdAddRun(cmd *cobra.Command, args []string) error {
 	}
 	packet := args[0]
 
-	// Initialize logger with structured logging
-	l := &logrus.Logger{
-		Out: os.Stderr,
-		Formatter: &logrus.TextFormatter{
-			TimestampFormat: time.RFC3339,
-			FullTimestamp:   true,
-		},
-		Hooks: make(logrus.LevelHooks),
-		Level: logrus.InfoLevel,
+	l, err := newLogger()
+	if err != nil {
+		return err
 	}
 
 	// Check if we got minimum 2 arguments.
 	// We will only use the second argument here. The rest will be ignored.
 	// Second argument is the configuration file, but it is optional.
-	// When this is set, we have to overwrite the configuration that viper found before
+	configFileArg := ""
 	if len(args) >= 2 {
-		configFileArg := args[1]
-		if _, err := os.Stat(configFileArg); os.IsNotExist(err) {
-			return fmt.Errorf("Configuration file %s applied, but doesn't exists", configFileArg)
-		}
-		viper.SetConfigFile(configFileArg)
+		configFileArg = args[1]
 	}
-
-	// If a config file is found, read it in.
-	// If an error happen, quit.
-	if err := viper.ReadInConfig(); err != nil {
-		s := fmt.Errorf("Error while reading the configuration file \"%s\": %s\nPlease checkout https://github.com/andygrunwald/perseus#configuration for further details.", viper.ConfigFileUsed(), err)
-		return s
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
 	}
 
 	l.WithFields(logrus.Fields{
@@ -165,21 +442,20 @@ func cmdAddRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Couldn't determine \"with-deps\" flag: %s\n", err)
 	}
 
-	// Create viper based configuration provider for Medusa
-	p, err := config.NewViperProvider(viper.GetViper())
+	// Determine number of concurrent workers
+	nOfWorkers, err := cmd.Flags().GetInt("numOfWorkers")
 	if err != nil {
-		return fmt.Errorf("Couldn't create a viper configuration provider: %s\n", err)
+		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
 	}
 
-	m, err := config.NewMedusa(p)
+	resolverOptions, err := getResolverOptions(cmd, m)
 	if err != nil {
-		return fmt.Errorf("Couldn't create medusa configuration object: %s\n", err)
+		return err
 	}
 
-	// Determine number of concurrent workers
-	nOfWorkers, err := cmd.Flags().GetInt("numOfWorkers")
+	pub, stopProgress, err := startProgress(cmd, l)
 	if err != nil {
-		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
+		return err
 	}
 
 	l.WithFields(logrus.Fields{
@@ -193,8 +469,11 @@ func cmdAddRun(cmd *cobra.Command, args []string) error {
 		Config:           m,
 		Log:              logrus.FieldLogger(l),
 		NumOfWorker:      nOfWorkers,
+		ResolverOptions:  resolverOptions,
+		Progress:         pub,
 	}
 	err = c.Run()
+	stopProgress()
 	if err != nil {
 		return fmt.Errorf("Error during execution of \"add\" command: %s\n", err)
 	}
@@ -220,65 +499,56 @@ Repositories entered in the repositories section will be mirrors as is without r
 
 // cmdMirrorRun is the CLI interface for the "mirror" command
 func cmdMirrorRun(cmd *cobra.Command, args []string) error {
-	// Initialize logger with structured logging
-	l := &logrus.Logger{
-		Out: os.Stderr,
-		Formatter: &logrus.TextFormatter{
-			TimestampFormat: time.RFC3339,
-			FullTimestamp:   true,
-		},
-		Hooks: make(logrus.LevelHooks),
-		Level: logrus.InfoLevel,
+	l, err := newLogger()
+	if err != nil {
+		return err
 	}
 
 	// Check if we got minimum 1 argument.
 	// We will only use the first argument here. The rest will be ignored.
 	// First argument is the configuration file, but it is optional.
-	// When this is set, we have to overwrite the configuration that viper found before
+	configFileArg := ""
 	if len(args) >= 1 {
-		configFileArg := args[0]
-		if _, err := os.Stat(configFileArg); os.IsNotExist(err) {
-			return fmt.Errorf("Configuration file %s applied, but doesn't exists", configFileArg)
-		}
-		viper.SetConfigFile(configFileArg)
+		configFileArg = args[0]
 	}
-
-	// If a config file is found, read it in.
-	// If an error happen, quit.
-	if err := viper.ReadInConfig(); err != nil {
-		s := fmt.Errorf("Error while reading the configuration file \"%s\": %s\nPlease checkout https://github.com/andygrunwald/perseus#configuration for further details.", viper.ConfigFileUsed(), err)
-		return s
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
 	}
 
 	l.WithFields(logrus.Fields{
 		"path": viper.ConfigFileUsed(),
 	}).Info("Using configuration file")
 
-	// Create viper based configuration provider for Medusa
-	p, err := config.NewViperProvider(viper.GetViper())
+	// Determine number of concurrent workers
+	nOfWorkers, err := cmd.Flags().GetInt("numOfWorkers")
 	if err != nil {
-		return fmt.Errorf("Couldn't create a viper configuration provider: %s\n", err)
+		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
 	}
 
-	m, err := config.NewMedusa(p)
+	resolverOptions, err := getResolverOptions(cmd, m)
 	if err != nil {
-		return fmt.Errorf("Couldn't create medusa configuration object: %s\n", err)
+		return err
 	}
 
-	// Determine number of concurrent workers
-	nOfWorkers, err := cmd.Flags().GetInt("numOfWorkers")
+	pub, stopProgress, err := startProgress(cmd, l)
 	if err != nil {
-		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
+		return err
 	}
 
-	l.Println("Running \"mirror\" command")
+	l.WithFields(logrus.Fields{
+		"command": "mirror",
+	}).Info("Running command")
 	// Setup command and run it
 	c := &controller.MirrorController{
-		Config:      m,
-		Log:         logrus.FieldLogger(l),
-		NumOfWorker: nOfWorkers,
+		Config:          m,
+		Log:             logrus.FieldLogger(l),
+		NumOfWorker:     nOfWorkers,
+		ResolverOptions: resolverOptions,
+		Progress:        pub,
 	}
 	err = c.Run()
+	stopProgress()
 	if err != nil {
 		return fmt.Errorf("Error during execution of \"mirror\" command: %s\n", err)
 	}
@@ -299,8 +569,16 @@ If you add a new package to the configuration you need either call the "add" com
 Or you add the new package to the configuration and call the "mirror" command.
 
 The update command is useful to ensure that every branch, tag or change in the configured packages is mirrors downstream.
-Otherwise you would stuck with the version from the time you added the package.`,
+Otherwise you would stuck with the version from the time you added the package.
+
+When "follow-url-changes" is given, the upstream URL of every mirror will be determined from the configuration file or Packagist before the update.
+If a package moved (e.g. a renamed GitHub repository), the mirror follows the new URL.
+
+When "gc" is given (or "gc.after-update" is enabled in the configuration), a garbage collection runs
+after every successful update of a mirror that exceeds a threshold (see "gc" command).`,
 	Example: `  perseus update
+  perseus update --follow-url-changes
+  perseus update --gc
   perseus update /var/config/medusa.json`,
 	ValidArgs: []string{"config"},
 	RunE:      cmdUpdateRun,
@@ -308,49 +586,34 @@ Otherwise you would stuck with the version from the time you added the package.`
 
 // cmdUpdateRun is the CLI interface for the "update" command
 func cmdUpdateRun(cmd *cobra.Command, args []string) error {
-	// Initialize logger with structured logging
-	l := &logrus.Logger{
-		Out: os.Stderr,
-		Formatter: &logrus.TextFormatter{
-			TimestampFormat: time.RFC3339,
-			FullTimestamp:   true,
-		},
-		Hooks: make(logrus.LevelHooks),
-		Level: logrus.InfoLevel,
+	l, err := newLogger()
+	if err != nil {
+		return err
 	}
 
 	// Check if we got minimum 1 argument.
 	// We will only use the first argument here. The rest will be ignored.
 	// First argument is the configuration file, but it is optional.
-	// When this is set, we have to overwrite the configuration that viper found before
+	configFileArg := ""
 	if len(args) >= 1 {
-		configFileArg := args[0]
-		if _, err := os.Stat(configFileArg); os.IsNotExist(err) {
-			return fmt.Errorf("Configuration file %s applied, but doesn't exists", configFileArg)
-		}
-		viper.SetConfigFile(configFileArg)
+		configFileArg = args[0]
 	}
-
-	// If a config file is found, read it in.
-	// If an error happen, quit.
-	if err := viper.ReadInConfig(); err != nil {
-		s := fmt.Errorf("Error while reading the configuration file \"%s\": %s\nPlease checkout https://github.com/andygrunwald/perseus#configuration for further details.", viper.ConfigFileUsed(), err)
-		return s
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
 	}
 
 	l.WithFields(logrus.Fields{
 		"path": viper.ConfigFileUsed(),
 	}).Info("Using configuration file")
 
-	// Create viper based configuration provider for Medusa
-	p, err := config.NewViperProvider(viper.GetViper())
+	followURLChanges, err := cmd.Flags().GetBool("follow-url-changes")
 	if err != nil {
-		return fmt.Errorf("Couldn't create a viper configuration provider: %s\n", err)
+		return fmt.Errorf("Couldn't determine \"follow-url-changes\" flag: %s\n", err)
 	}
-
-	m, err := config.NewMedusa(p)
+	gc, err := getGCAfterUpdate(cmd, m)
 	if err != nil {
-		return fmt.Errorf("Couldn't create medusa configuration object: %s\n", err)
+		return err
 	}
 
 	// Determine number of concurrent workers
@@ -359,14 +622,25 @@ func cmdUpdateRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
 	}
 
-	l.Println("Running \"update\" command")
+	pub, stopProgress, err := startProgress(cmd, l)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"command": "update",
+	}).Info("Running command")
 	// Setup command and run it
 	c := &controller.UpdateController{
-		Config:      m,
-		Log:         logrus.FieldLogger(l),
-		NumOfWorker: nOfWorkers,
+		Config:           m,
+		Log:              logrus.FieldLogger(l),
+		NumOfWorker:      nOfWorkers,
+		FollowURLChanges: followURLChanges,
+		GC:               gc,
+		Progress:         pub,
 	}
 	err = c.Run()
+	stopProgress()
 	if err != nil {
 		return fmt.Errorf("Error during execution of \"update\" command: %s\n", err)
 	}
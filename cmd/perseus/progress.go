@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/progress"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// progressAuto renders the interactive view on a terminal and logs a periodic summary otherwise
+	progressAuto = "auto"
+	// progressTTY always renders the interactive view
+	progressTTY = "tty"
+	// progressLog always logs a periodic summary
+	progressLog = "log"
+	// progressNone disables the progress display
+	progressNone = "none"
+)
+
+// addProgressFlag adds the flag to choose the progress display to command cmd.
+func addProgressFlag(cmd *cobra.Command) {
+	cmd.Flags().String("progress", progressAuto, "Progress display: auto (interactive on a terminal, periodic log summary otherwise), tty, log or none")
+}
+
+// startProgress starts the progress display that is chosen by the "progress" flag of command cmd.
+// It returns the publisher for the controller (nil if disabled) and a function
+// that stops the display after the run and renders the final progress.
+// While the interactive view is rendered, the log lines of l are printed above it (see progress.View.LogWriter),
+// if they are written to the same terminal.
+func startProgress(cmd *cobra.Command, l *logrus.Logger) (progress.Publisher, func(), error) {
+	mode, err := cmd.Flags().GetString("progress")
+	if err != nil {
+		return nil, nil, fmt.Errorf("Couldn't determine \"progress\" flag: %s\n", err)
+	}
+
+	var tty bool
+	switch mode {
+	case progressAuto:
+		tty = progress.IsTerminal(os.Stdout)
+	case progressTTY:
+		tty = true
+	case progressLog:
+	case progressNone:
+		return nil, func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("Unknown progress display %q. Supported: %s, %s, %s or %s\n", mode, progressAuto, progressTTY, progressLog, progressNone)
+	}
+
+	bus := progress.NewBus()
+	view := progress.NewView(progress.NewTracker(), os.Stdout, l, tty)
+	events := bus.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		view.Run(events)
+		close(done)
+	}()
+
+	logOut := l.Out
+	if tty && progress.IsSameTerminal(logOut, os.Stdout) {
+		l.Out = view.LogWriter(logOut)
+	}
+	stop := func() {
+		bus.Close()
+		<-done
+		l.Out = logOut
+	}
+	return bus, stop, nil
+}
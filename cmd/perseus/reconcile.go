@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// reconcileCmd represents the "reconcile" command for the CLI interface.
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Syncs the mirrors on disk, the configuration file and the Satis configuration",
+	Long: `The reconcile command brings the repository directory, the medusa configuration and the Satis configuration back in sync.
+
+Configured packages that were never mirrored will be cloned.
+Mirrors that are missing in the Satis configuration will be added.
+Repositories in the Satis configuration that point to a not existing mirror will be removed.
+Repositories in the Satis configuration that are no mirrors of perseus will be kept.
+
+With "prune", mirrors that are not referenced by the configuration (incl. dependencies) will be deleted.
+Dependencies will be determined through API requests to packagist.org.
+
+With "dry-run", the differences will be printed, but nothing will be changed.
+`,
+	Example: `  perseus reconcile --dry-run
+  perseus reconcile
+  perseus reconcile --prune /var/config/medusa.json`,
+	ValidArgs: []string{"config"},
+	RunE:      cmdReconcileRun,
+}
+
+// cmdReconcileRun is the CLI interface for the "reconcile" command
+func cmdReconcileRun(cmd *cobra.Command, args []string) error {
+	l, err := newLogger()
+	if err != nil {
+		return err
+	}
+
+	// Check if we got minimum 1 argument.
+	// We will only use the first argument here. The rest will be ignored.
+	// First argument is the configuration file, but it is optional.
+	configFileArg := ""
+	if len(args) >= 1 {
+		configFileArg = args[0]
+	}
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"path": viper.ConfigFileUsed(),
+	}).Info("Using configuration file")
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"dry-run\" flag: %s\n", err)
+	}
+	prune, err := cmd.Flags().GetBool("prune")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"prune\" flag: %s\n", err)
+	}
+
+	// Determine number of concurrent workers
+	nOfWorkers, err := cmd.Flags().GetInt("numOfWorkers")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
+	}
+
+	resolverOptions, err := getResolverOptions(cmd, m)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"command": "reconcile",
+		"dry-run": dryRun,
+		"prune":   prune,
+	}).Info("Running command")
+	// Setup command and run it
+	c := &controller.ReconcileController{
+		DryRun:          dryRun,
+		Prune:           prune,
+		Config:          m,
+		Log:             logrus.FieldLogger(l),
+		NumOfWorker:     nOfWorkers,
+		ResolverOptions: resolverOptions,
+	}
+	err = c.Run()
+	if err != nil {
+		return fmt.Errorf("Error during execution of \"reconcile\" command: %s\n", err)
+	}
+
+	return nil
+}
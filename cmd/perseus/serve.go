@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// serveCmd represents the "serve" command for the CLI interface.
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Runs perseus as a daemon that updates all mirrored packages periodically and exposes metrics",
+	Long: `The serve command runs perseus as a daemon.
+
+All mirrored packages will be updated periodically (like the "update" command).
+With "with-mirror", the "mirror" command runs before every update, so new packages from the configuration file will be mirrored as well.
+
+Metrics about the health of the mirror are exposed in the Prometheus text format at /metrics.
+This includes the timestamp of the last successful fetch per repository, to alert when a mirror goes stale.
+`,
+	Example: `  perseus serve
+  perseus serve --listen=":9117" --interval="30m"
+  perseus serve --with-mirror /var/config/medusa.json`,
+	ValidArgs: []string{"config"},
+	RunE:      cmdServeRun,
+}
+
+// cmdServeRun is the CLI interface for the "serve" command
+func cmdServeRun(cmd *cobra.Command, args []string) error {
+	l, err := newLogger()
+	if err != nil {
+		return err
+	}
+
+	// Check if we got minimum 1 argument.
+	// We will only use the first argument here. The rest will be ignored.
+	// First argument is the configuration file, but it is optional.
+	configFileArg := ""
+	if len(args) >= 1 {
+		configFileArg = args[0]
+	}
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"path": viper.ConfigFileUsed(),
+	}).Info("Using configuration file")
+
+	listen, err := cmd.Flags().GetString("listen")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"listen\" flag: %s\n", err)
+	}
+	interval, err := cmd.Flags().GetDuration("interval")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"interval\" flag: %s\n", err)
+	}
+	withMirror, err := cmd.Flags().GetBool("with-mirror")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"with-mirror\" flag: %s\n", err)
+	}
+	followURLChanges, err := cmd.Flags().GetBool("follow-url-changes")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"follow-url-changes\" flag: %s\n", err)
+	}
+	gc, err := getGCAfterUpdate(cmd, m)
+	if err != nil {
+		return err
+	}
+
+	// Determine number of concurrent workers
+	nOfWorkers, err := cmd.Flags().GetInt("numOfWorkers")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
+	}
+
+	resolverOptions, err := getResolverOptions(cmd, m)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"command": "serve",
+	}).Info("Running command")
+	// Setup command and run it
+	c := &controller.ServeController{
+		Listen:           listen,
+		Interval:         interval,
+		WithMirror:       withMirror,
+		FollowURLChanges: followURLChanges,
+		GC:               gc,
+		Config:           m,
+		Log:              logrus.FieldLogger(l),
+		NumOfWorker:      nOfWorkers,
+		ResolverOptions:  resolverOptions,
+	}
+	err = c.Run()
+	if err != nil {
+		return fmt.Errorf("Error during execution of \"serve\" command: %s\n", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// statusCmd represents the "status" command for the CLI interface.
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Shows the state of all mirrored repositories",
+	Long: `The status command prints the history of every mirrored repository.
+
+perseus records for every repository the upstream URL, the command that added it, when it was added,
+the last fetch attempt and success, the last error and the number of refs.
+The state is stored in the file .perseus-state.json in the repository directory and updated by the "add", "mirror" and "update" commands.
+`,
+	Example: `  perseus status
+  perseus status --format=json /var/config/medusa.json`,
+	ValidArgs: []string{"config"},
+	RunE:      cmdStatusRun,
+}
+
+// cmdStatusRun is the CLI interface for the "status" command
+func cmdStatusRun(cmd *cobra.Command, args []string) error {
+	l, err := newLogger()
+	if err != nil {
+		return err
+	}
+
+	// Check if we got minimum 1 argument.
+	// We will only use the first argument here. The rest will be ignored.
+	// First argument is the configuration file, but it is optional.
+	configFileArg := ""
+	if len(args) >= 1 {
+		configFileArg = args[0]
+	}
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"path": viper.ConfigFileUsed(),
+	}).Info("Using configuration file")
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"format\" flag: %s\n", err)
+	}
+
+	// Setup command and run it
+	c := &controller.StatusController{
+		Format: format,
+		Config: m,
+		Log:    logrus.FieldLogger(l),
+	}
+	err = c.Run()
+	if err != nil {
+		return fmt.Errorf("Error during execution of \"status\" command: %s\n", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// urlCmd represents the "url" command for the CLI interface.
+var urlCmd = &cobra.Command{
+	Use:   "url",
+	Short: "Shows the effective repository URL of a package",
+	Long: `The url command shows the effective repository URL of a package and how it was determined.
+
+The URL is taken from the configuration file or, if not configured, requested from Packagist.
+Afterwards the rewrite rules of the configuration and the built-in rules are applied.
+The first matching rule wins.
+
+If the package is mirrored already, the URL of the mirror is shown as well.
+`,
+	Example: `  perseus url "symfony/console"
+  perseus url "myvendor/package" /var/config/medusa.json`,
+	ValidArgs: []string{"package", "config"},
+	RunE:      cmdURLRun,
+}
+
+// cmdURLRun is the CLI interface for the "url" command
+func cmdURLRun(cmd *cobra.Command, args []string) error {
+	// Check first argument: package
+	if len(args) == 0 {
+		return fmt.Errorf("No argument applied. Please apply one argument: package")
+	}
+	packet := args[0]
+
+	l, err := newLogger()
+	if err != nil {
+		return err
+	}
+
+	// Check if we got minimum 2 arguments.
+	// We will only use the second argument here. The rest will be ignored.
+	// Second argument is the configuration file, but it is optional.
+	configFileArg := ""
+	if len(args) >= 2 {
+		configFileArg = args[1]
+	}
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"path": viper.ConfigFileUsed(),
+	}).Info("Using configuration file")
+
+	l.WithFields(logrus.Fields{
+		"command": "url",
+		"package": packet,
+	}).Info("Running command for package")
+	// Setup command and run it
+	c := &controller.URLController{
+		Package: packet,
+		Config:  m,
+		Log:     logrus.FieldLogger(l),
+	}
+	err = c.Run()
+	if err != nil {
+		return fmt.Errorf("Error during execution of \"url\" command: %s\n", err)
+	}
+
+	return nil
+}
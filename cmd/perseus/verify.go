@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// verifyCmd represents the "verify" command for the CLI interface.
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Checks the integrity of all mirrored repositories",
+	Long: `The verify command checks the integrity of all mirrors concurrently.
+
+The following checks will be executed for every mirror:
+ * fsck: Integrity of all objects (git fsck)
+ * refs: The mirror has refs and all of them point to existing objects
+ * info-refs: info/refs (used by the dumb HTTP protocol) exists and is up to date
+ * objects: All objects reachable from refs are available
+
+When "repair" is given, broken mirrors will be repaired.
+A stale info/refs will be regenerated. All other failures will be repaired by cloning the mirror again from upstream.
+The new clone replaces the broken mirror only if it was successful.
+
+When "rotate" is given, only 1/N of all mirrors will be verified (the ones with the oldest verification first).
+Run it every night with --rotate=7 to verify every mirror once a week.
+
+The command fails if at least one mirror is broken (and couldn't be repaired).
+`,
+	Example: `  perseus verify
+  perseus verify --repair
+  perseus verify --repair --rotate=7 /var/config/medusa.json`,
+	ValidArgs: []string{"config"},
+	RunE:      cmdVerifyRun,
+}
+
+// cmdVerifyRun is the CLI interface for the "verify" command
+func cmdVerifyRun(cmd *cobra.Command, args []string) error {
+	l, err := newLogger()
+	if err != nil {
+		return err
+	}
+
+	// Check if we got minimum 1 argument.
+	// We will only use the first argument here. The rest will be ignored.
+	// First argument is the configuration file, but it is optional.
+	configFileArg := ""
+	if len(args) >= 1 {
+		configFileArg = args[0]
+	}
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"path": viper.ConfigFileUsed(),
+	}).Info("Using configuration file")
+
+	repair, err := cmd.Flags().GetBool("repair")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"repair\" flag: %s\n", err)
+	}
+	rotate, err := cmd.Flags().GetInt("rotate")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"rotate\" flag: %s\n", err)
+	}
+
+	// Determine number of concurrent workers
+	nOfWorkers, err := cmd.Flags().GetInt("numOfWorkers")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
+	}
+
+	l.WithFields(logrus.Fields{
+		"command": "verify",
+		"repair":  repair,
+		"rotate":  rotate,
+	}).Info("Running command")
+	// Setup command and run it
+	c := &controller.VerifyController{
+		Repair:      repair,
+		Rotate:      rotate,
+		Config:      m,
+		Log:         logrus.FieldLogger(l),
+		NumOfWorker: nOfWorkers,
+	}
+	err = c.Run()
+	if err != nil {
+		return fmt.Errorf("Error during execution of \"verify\" command: %s\n", err)
+	}
+
+	return nil
+}
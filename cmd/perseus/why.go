@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// whyCmd represents the "why" command for the CLI interface.
+var whyCmd = &cobra.Command{
+	Use:   "why",
+	Short: "Shows why a package is mirrored",
+	Long: `The why command resolves the dependencies of all configured packages and shows the require chains that lead to the given package.
+
+A require chain starts at a package from the configuration file and ends at the given package.
+Every step contains the version constraints that introduced the requirement.
+`,
+	Example: `  perseus why "psr/log"
+  perseus why --limit=0 "symfony/polyfill-mbstring" /var/config/medusa.json`,
+	ValidArgs: []string{"package", "config"},
+	RunE:      cmdWhyRun,
+}
+
+// cmdWhyRun is the CLI interface for the "why" command
+func cmdWhyRun(cmd *cobra.Command, args []string) error {
+	// Check first argument: package
+	if len(args) == 0 {
+		return fmt.Errorf("No argument applied. Please apply one argument: package")
+	}
+	packet := args[0]
+
+	l, err := newLogger()
+	if err != nil {
+		return err
+	}
+
+	// Check if we got minimum 2 arguments.
+	// We will only use the second argument here. The rest will be ignored.
+	// Second argument is the configuration file, but it is optional.
+	configFileArg := ""
+	if len(args) >= 2 {
+		configFileArg = args[1]
+	}
+	m, err := loadMedusaConfiguration(configFileArg)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"path": viper.ConfigFileUsed(),
+	}).Info("Using configuration file")
+
+	limit, err := cmd.Flags().GetInt("limit")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine \"limit\" flag: %s\n", err)
+	}
+
+	// Determine number of concurrent workers
+	nOfWorkers, err := cmd.Flags().GetInt("numOfWorkers")
+	if err != nil {
+		return fmt.Errorf("Couldn't determine number of concurrent workers. Please control the 'numOfWorkers' flag. Error message: %s\n", err)
+	}
+
+	resolverOptions, err := getResolverOptions(cmd, m)
+	if err != nil {
+		return err
+	}
+
+	l.WithFields(logrus.Fields{
+		"command": "why",
+		"package": packet,
+	}).Info("Running command for package")
+	// Setup command and run it
+	c := &controller.WhyController{
+		Package:         packet,
+		Limit:           limit,
+		Config:          m,
+		Log:             logrus.FieldLogger(l),
+		NumOfWorker:     nOfWorkers,
+		ResolverOptions: resolverOptions,
+	}
+	err = c.Run()
+	if err != nil {
+		return fmt.Errorf("Error during execution of \"why\" command: %s\n", err)
+	}
+
+	return nil
+}
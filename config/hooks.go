@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andygrunwald/perseus/hook"
+)
+
+// GetHooks returns the hooks of the configuration key "hooks".
+// Every entry runs a shell command and/or calls an HTTP callback.
+// "events" limits the hook to some event types (default: all events) and
+// "timeout" limits the duration of the command and of the callback (default: hook.DefaultTimeout):
+//
+//	"hooks": [
+//		{"name": "satis-build", "command": "bin/satis build satis.json web/", "events": ["run-finished"], "timeout": "10m"},
+//		{"name": "license-scan", "url": "https://scanner.company.tld/perseus", "events": ["package-added", "package-updated"]}
+//	]
+//
+// "name" identifies the hook in logs and in the run report. If empty, the command (or the URL) is used.
+// Invalid entries result in an error.
+func (m *Medusa) GetHooks() ([]*hook.Hook, error) {
+	entries, _ := m.config.Get("hooks").([]interface{})
+	l := make([]*hook.Hook, 0, len(entries))
+	for i, entry := range entries {
+		e, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Hook %d needs to be an object", i)
+		}
+		h, err := getHook(e)
+		if err != nil {
+			return nil, fmt.Errorf("Hook %d: %s", i, err)
+		}
+		l = append(l, h)
+	}
+	return l, nil
+}
+
+// getHook returns the hook of the entry e of the configuration key "hooks".
+func getHook(e map[string]interface{}) (*hook.Hook, error) {
+	name, _ := e["name"].(string)
+	command, _ := e["command"].(string)
+	url, _ := e["url"].(string)
+	if len(command) == 0 && len(url) == 0 {
+		return nil, fmt.Errorf("A hook needs a \"command\" and/or an \"url\"")
+	}
+	if len(name) == 0 {
+		name = command
+		if len(name) == 0 {
+			name = url
+		}
+	}
+
+	var timeout time.Duration
+	if s, ok := e["timeout"].(string); ok && len(s) > 0 {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("Invalid timeout %q. Expected a positive duration like \"30s\" or \"5m\"", s)
+		}
+		timeout = d
+	}
+
+	events := toStringSlice(e["events"])
+	for _, t := range events {
+		if err := hook.CheckEventType(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return hook.New(name, command, url, timeout, events...), nil
+}
@@ -0,0 +1,57 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/hook"
+)
+
+func TestMedusa_GetHooks(t *testing.T) {
+	m, _ := NewMedusa(&EmptyUnitTestProvider{})
+	l, err := m.GetHooks()
+	if err != nil || len(l) != 0 {
+		t.Errorf("Expected no hooks. Got %v (%v)", l, err)
+	}
+
+	m, _ = NewMedusa(&MapUnitTestProvider{Values: map[string]interface{}{
+		"hooks": []interface{}{
+			map[string]interface{}{"name": "satis-build", "command": "bin/satis build", "events": []interface{}{"run-finished"}, "timeout": "10m"},
+			map[string]interface{}{"url": "https://scanner.company.tld/perseus"},
+		},
+	}})
+	l, err = m.GetHooks()
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if len(l) != 2 {
+		t.Fatalf("Expected 2 hooks. Got %d", len(l))
+	}
+
+	expected := hook.New("satis-build", "bin/satis build", "", 10*time.Minute, hook.EventRunFinished)
+	if h := l[0]; h.Name != expected.Name || h.Command != expected.Command || h.Timeout != expected.Timeout || !h.Handles(hook.EventRunFinished) || h.Handles(hook.EventPackageAdded) {
+		t.Errorf("Expected %+v. Got %+v", expected, h)
+	}
+	if h := l[1]; h.Name != "https://scanner.company.tld/perseus" || h.Timeout != 0 || !h.Handles(hook.EventPackageFailed) {
+		t.Errorf("Expected a hook for all events named after its URL. Got %+v", h)
+	}
+}
+
+func TestMedusa_GetHooks_Invalid(t *testing.T) {
+	tests := []interface{}{
+		"bin/satis build",
+		map[string]interface{}{"name": "nothing"},
+		map[string]interface{}{"command": "true", "events": []interface{}{"package-deleted"}},
+		map[string]interface{}{"command": "true", "timeout": "soon"},
+		map[string]interface{}{"command": "true", "timeout": "-1s"},
+	}
+	for _, tt := range tests {
+		m, _ := NewMedusa(&MapUnitTestProvider{Values: map[string]interface{}{
+			"hooks": []interface{}{tt},
+		}})
+		if l, err := m.GetHooks(); err == nil {
+			t.Errorf("Expected an error for %+v. Got %v", tt, l)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/perseus/limit"
+)
+
+// byteUnits are the units of a bandwidth like "10MB"
+var byteUnits = []struct {
+	suffix string
+	factor float64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// GetLimits returns the configuration key "limits".
+// The limits protect upstream hosts against too many concurrent git transfers or requests:
+//
+//	"limits": {
+//		"resolver-workers": 8,
+//		"git-workers": 4,
+//		"hosts": {"github.com": 2, "gitlab.company.tld": 8, "*": 4},
+//		"bandwidth": "10MB",
+//		"metadata-rps": 5
+//	}
+//
+// "resolver-workers" and "git-workers" overwrite the number of workers of the command line (--numOfWorkers).
+// "hosts" caps the concurrent git transfers per host ("*" applies to all other hosts).
+// "bandwidth" limits the bytes per second of all git transfers via HTTP(S) (units B, KB, MB and GB).
+// "metadata-rps" limits the requests per second to Packagist.
+// Every key is optional, missing keys are unlimited.
+//
+// The limits are created only once, because all downloads and requests of the process share them.
+// Invalid values result in an error.
+func (m *Medusa) GetLimits() (*limit.Limits, error) {
+	m.limitsOnce.Do(func() {
+		m.limits, m.limitsErr = m.readLimits()
+	})
+	return m.limits, m.limitsErr
+}
+
+func (m *Medusa) readLimits() (*limit.Limits, error) {
+	l := &limit.Limits{}
+	c, ok := m.config.Get("limits").(map[string]interface{})
+	if !ok {
+		return l, nil
+	}
+
+	for k, workers := range map[string]*int{"resolver-workers": &l.ResolverWorkers, "git-workers": &l.GitWorkers} {
+		if n, ok := toInt(c[k]); ok {
+			if n < 0 {
+				return nil, fmt.Errorf("Limits %s must not be negative. Got %d", k, n)
+			}
+			*workers = n
+		}
+	}
+
+	if hosts, ok := c["hosts"].(map[string]interface{}); ok {
+		caps := map[string]int{}
+		for host, v := range hosts {
+			n, ok := toInt(v)
+			if !ok || n < 1 {
+				return nil, fmt.Errorf("Limits hosts: Cap of host %s needs to be a positive number. Got %v", host, v)
+			}
+			caps[host] = n
+		}
+		l.Hosts = limit.NewHosts(caps)
+	}
+
+	if v, ok := c["bandwidth"]; ok {
+		b, err := parseBandwidth(v)
+		if err != nil {
+			return nil, fmt.Errorf("Limits bandwidth: %s", err)
+		}
+		l.Bandwidth = limit.NewRate(b, 0)
+	}
+
+	if v, ok := c["metadata-rps"]; ok {
+		rps, ok := v.(float64)
+		if !ok || rps <= 0 {
+			return nil, fmt.Errorf("Limits metadata-rps needs to be a positive number. Got %v", v)
+		}
+		l.Metadata = limit.NewRate(rps, 1)
+	}
+
+	return l, nil
+}
+
+// parseBandwidth returns the bytes per second of v like "10MB", "512KB" or 1048576 (bytes).
+func parseBandwidth(v interface{}) (float64, error) {
+	b := 0.0
+	switch t := v.(type) {
+	case float64:
+		b = t
+	case string:
+		s := strings.ToUpper(strings.TrimSpace(t))
+		factor := 1.0
+		for _, u := range byteUnits {
+			if strings.HasSuffix(s, u.suffix) {
+				s, factor = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.factor
+				break
+			}
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid bandwidth %q", t)
+		}
+		b = f * factor
+	}
+	if b < 1 {
+		return 0, fmt.Errorf("Bandwidth needs to be at least 1 byte per second. Got %v", v)
+	}
+	return b, nil
+}
@@ -0,0 +1,66 @@
+package config_test
+
+import (
+	"testing"
+
+	. "github.com/andygrunwald/perseus/config"
+)
+
+func TestMedusa_GetLimits(t *testing.T) {
+	m, _ := NewMedusa(&EmptyUnitTestProvider{})
+	l, err := m.GetLimits()
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if l.ResolverWorkers != 0 || l.GitWorkers != 0 || l.Hosts != nil || l.Bandwidth != nil || l.Metadata != nil {
+		t.Errorf("Expected no limits. Got %+v", l)
+	}
+
+	m, _ = NewMedusa(&MapUnitTestProvider{Values: map[string]interface{}{
+		"limits": map[string]interface{}{
+			"resolver-workers": float64(8),
+			"git-workers":      float64(4),
+			"hosts":            map[string]interface{}{"github.com": float64(2), "*": float64(6)},
+			"bandwidth":        "1.5MB",
+			"metadata-rps":     float64(5),
+		},
+	}})
+	l, err = m.GetLimits()
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if l.ResolverWorkers != 8 || l.GitWorkers != 4 {
+		t.Errorf("Expected 8 resolver and 4 git workers. Got %d and %d", l.ResolverWorkers, l.GitWorkers)
+	}
+	if n := l.Hosts.Cap("github.com"); n != 2 {
+		t.Errorf("Expected a cap of 2 for github.com. Got %d", n)
+	}
+	if n := l.Hosts.Cap("gitlab.company.tld"); n != 6 {
+		t.Errorf("Expected a cap of 6 for all other hosts. Got %d", n)
+	}
+	if l.Bandwidth == nil || l.Metadata == nil {
+		t.Errorf("Expected a bandwidth and metadata limit. Got %+v", l)
+	}
+
+	// The limits are shared
+	if l2, _ := m.GetLimits(); l2 != l {
+		t.Error("Expected the same limits for every call")
+	}
+}
+
+func TestMedusa_GetLimits_Invalid(t *testing.T) {
+	tests := []map[string]interface{}{
+		{"git-workers": float64(-1)},
+		{"hosts": map[string]interface{}{"github.com": float64(0)}},
+		{"hosts": map[string]interface{}{"github.com": "two"}},
+		{"bandwidth": "10 parsecs"},
+		{"bandwidth": float64(0)},
+		{"metadata-rps": "fast"},
+	}
+	for _, tt := range tests {
+		m, _ := NewMedusa(&MapUnitTestProvider{Values: map[string]interface{}{"limits": tt}})
+		if l, err := m.GetLimits(); err == nil {
+			t.Errorf("Expected an error for %+v. Got %+v", tt, l)
+		}
+	}
+}
@@ -1,18 +1,33 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
-	"regexp"
+	"sync"
+	"time"
 
+	"github.com/andygrunwald/perseus/credentials"
 	"github.com/andygrunwald/perseus/dependency"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/limit"
 )
 
 // Medusa reflects the original Medusa configuration file.
 type Medusa struct {
 	// config is the configuration provider object that has read the medusa configuration file
 	config Provider
+
+	// credentials are read once (see GetCredentials)
+	credentials     *credentials.Store
+	credentialsErr  error
+	credentialsOnce sync.Once
+
+	// limits are created once (see GetLimits)
+	limits     *limit.Limits
+	limitsErr  error
+	limitsOnce sync.Once
 }
 
 // NewMedusa will create a new medusa configuration object.
@@ -30,88 +45,426 @@ func NewMedusa(c Provider) (*Medusa, error) {
 
 // GetRepositoryURLOfPackage will determine if package p is part of the configuration.
 // If p is part and a url is configured and this url is valid, this url will be returned.
+// The url is rewritten by the rewrite rules (see dependency.RewriteURL).
 // Otherwise an error.
 func (m *Medusa) GetRepositoryURLOfPackage(p *dependency.Package) (*url.URL, error) {
-	// TODO Is there a better solution? We cast here and cast and cast ...
-	// Yep, checkout https://github.com/spf13/viper#getting-values-from-viper
-	// Sadly they don't support a []map[string]string which is the "repositories" section (yet).
-	// And i don't know if it make sense to implement there.
-	// I raised this question here: https://github.com/spf13/cast/issues/36
-	// Lets wait for feedback.
-	repositoriesSlice, err := m.getRepositories()
+	u, err := m.GetRawRepositoryURLOfPackage(p)
 	if err != nil {
-		return nil, ErrNoRepositories
+		return nil, err
 	}
+	return dependency.ParseRepositoryURL(u)
+}
 
-	for _, repoEntry := range repositoriesSlice {
-		repoEntryMap := repoEntry.(map[string]interface{})
-		if val, ok := repoEntryMap["name"]; ok {
-			if r := val.(string); r == p.Name {
-				if v, ok := repoEntryMap["url"]; ok {
-					// Check if the url is empty
-					if u := v.(string); len(u) > 0 {
-
-						// Sanitize URL
-						// Not the best part here, i know
-						reg, err := regexp.Compile("^git@github.com:")
-						if err != nil {
-							return nil, err
-						}
-
-						u = reg.ReplaceAllString(u, "git://github.com/")
-						return url.Parse(u)
-					}
+// GetRawRepositoryURLOfPackage will determine if package p is part of the configuration.
+// If p is part and a url is configured, this url will be returned as it is configured (without any rewriting).
+// Otherwise an error.
+func (m *Medusa) GetRawRepositoryURLOfPackage(p *dependency.Package) (string, error) {
+	l, err := m.GetRepositoryConfigs()
+	if err != nil {
+		return "", err
+	}
 
-				}
-			}
+	for _, r := range l {
+		if r.Name == p.Name && len(r.URL) > 0 {
+			return r.URL, nil
 		}
 	}
 
-	return nil, fmt.Errorf("No repository url found for package %s", p.Name)
+	return "", fmt.Errorf("No repository url found for package %s", p.Name)
 }
 
 // GetNamesOfRepositories returns all Packages from the configuration
 // key "repositories". A repository will only be returned when
-// it is complete (means a name and an url exists)
+// it is complete (means a name and an url exists) and not disabled.
 func (m *Medusa) GetNamesOfRepositories() ([]*dependency.Package, error) {
-	repositoriesSlice, err := m.getRepositories()
+	l, err := m.GetRepositoryConfigs()
 	if err != nil {
-		return nil, ErrNoRepositories
+		return nil, err
 	}
 
 	r := []*dependency.Package{}
-	for _, repoEntry := range repositoriesSlice {
-		repoEntryMap := repoEntry.(map[string]interface{})
-		if val, ok := repoEntryMap["name"]; ok {
-			name := val.(string)
-
-			if v, ok := repoEntryMap["url"]; ok {
-				if u := v.(string); len(u) > 0 {
-					pack, err := dependency.NewPackage(name, u)
-					if err != nil {
-						continue
-					}
-					r = append(r, pack)
-				}
-			}
+	for _, repo := range l {
+		if len(repo.URL) == 0 || repo.Disabled {
+			continue
+		}
+		pack, err := dependency.NewPackage(repo.Name, repo.URL)
+		if err != nil {
+			continue
 		}
+		r = append(r, pack)
 	}
 
 	return r, nil
 }
 
+// getRepositories returns the raw entries of the configuration key "repositories".
+// The entries are maps, because this is the structure viper delivers for lists of objects.
+// Checkout https://github.com/spf13/cast/issues/36 for details.
 func (m *Medusa) getRepositories() ([]interface{}, error) {
-	repositories := m.config.Get("repositories")
-	if repositories == nil {
+	repositoriesSlice, ok := m.config.Get("repositories").([]interface{})
+	if !ok || len(repositoriesSlice) == 0 {
 		return nil, ErrNoRepositories
 	}
 
-	repositoriesSlice := repositories.([]interface{})
-	if len(repositoriesSlice) == 0 {
-		return nil, ErrNoRepositories
+	return repositoriesSlice, nil
+}
+
+// GetAliases returns the configuration key "aliases".
+// Aliases are a map of package names that will be replaced by other package names
+// during the dependency resolution (e.g. "symfony/translator" => "symfony/translation").
+// Entries that are no strings will be ignored.
+func (m *Medusa) GetAliases() map[string]string {
+	aliases := make(map[string]string)
+
+	a, ok := m.config.Get("aliases").(map[string]interface{})
+	if !ok {
+		return aliases
 	}
 
-	return repositoriesSlice, nil
+	for k, v := range a {
+		if s, ok := v.(string); ok && len(s) > 0 {
+			aliases[k] = s
+		}
+	}
+	return aliases
+}
+
+// GetIgnore returns the configuration key "ignore".
+// It is a list of glob patterns (like "my-vendor/*") of packages
+// that should not be resolved and mirrored.
+func (m *Medusa) GetIgnore() []string {
+	return m.config.GetStringSlice("ignore")
+}
+
+// GetResolverOptions returns the settings for the dependency resolver
+// that are defined in the configuration.
+// Next to "aliases" and "ignore", the configuration key "resolver" is respected:
+//
+//	"resolver": {
+//		"require-dev": "root",
+//		"suggest": "none",
+//		"max-depth": 0,
+//		"minimum-stability": "stable",
+//		"include-versions": ["v*"],
+//		"exclude-versions": ["dev-feature-*"],
+//		"packages": {
+//			"myvendor/package": {
+//				"minimum-stability": "dev"
+//			}
+//		}
+//	}
+//
+// Values of the wrong type will be ignored.
+func (m *Medusa) GetResolverOptions() *dependency.ResolverOptions {
+	o := &dependency.ResolverOptions{
+		Aliases: m.GetAliases(),
+		Ignore:  m.GetIgnore(),
+	}
+
+	r, ok := m.config.Get("resolver").(map[string]interface{})
+	if !ok {
+		return o
+	}
+
+	if s, ok := r["require-dev"].(string); ok {
+		o.RequireDev = s
+	}
+	if s, ok := r["suggest"].(string); ok {
+		o.Suggest = s
+	}
+	if d, ok := toInt(r["max-depth"]); ok {
+		o.MaxDepth = d
+	}
+
+	o.Versions = getVersionPolicy(r)
+	if packages, ok := r["packages"].(map[string]interface{}); ok {
+		o.PackageVersions = make(map[string]*dependency.VersionPolicy, len(packages))
+		for name, v := range packages {
+			if p, ok := v.(map[string]interface{}); ok {
+				o.PackageVersions[name] = getVersionPolicy(p)
+			}
+		}
+	}
+
+	return o
+}
+
+// getVersionPolicy returns the version policy of the configuration section m.
+// If the section contains no policy, nil will be returned.
+func getVersionPolicy(m map[string]interface{}) *dependency.VersionPolicy {
+	p := &dependency.VersionPolicy{
+		Include: toStringSlice(m["include-versions"]),
+		Exclude: toStringSlice(m["exclude-versions"]),
+	}
+	if s, ok := m["minimum-stability"].(string); ok {
+		p.MinimumStability = s
+	}
+
+	if len(p.MinimumStability) == 0 && len(p.Include) == 0 && len(p.Exclude) == 0 {
+		return nil
+	}
+	return p
+}
+
+// toInt returns v as int.
+// JSON numbers are decoded as float64, but other provider might deliver an int.
+// If v is no number, the second return value is false.
+func toInt(v interface{}) (int, bool) {
+	switch i := v.(type) {
+	case float64:
+		return int(i), true
+	case int:
+		return i, true
+	}
+	return 0, false
+}
+
+// GetGCThresholds returns the configuration key "gc".
+// The thresholds decide when a mirror needs a garbage collection (see "gc" command).
+// Without configuration the thresholds of git's automatic garbage collection
+// (gc.auto and gc.autoPackLimit) will be used:
+//
+//	"gc": {
+//		"loose-objects": 6700,
+//		"packs": 50,
+//		"max-age-days": 0,
+//		"after-update": false
+//	}
+//
+// Values of the wrong type will be ignored.
+func (m *Medusa) GetGCThresholds() *downloader.GCThresholds {
+	t := &downloader.GCThresholds{
+		LooseObjects: 6700,
+		Packs:        50,
+	}
+
+	g, ok := m.config.Get("gc").(map[string]interface{})
+	if !ok {
+		return t
+	}
+
+	if i, ok := toInt(g["loose-objects"]); ok {
+		t.LooseObjects = i
+	}
+	if i, ok := toInt(g["packs"]); ok {
+		t.Packs = i
+	}
+	if i, ok := toInt(g["max-age-days"]); ok {
+		t.MaxAge = time.Duration(i) * 24 * time.Hour
+	}
+
+	return t
+}
+
+// GetGCAfterUpdate returns the configuration key "after-update" of the section "gc".
+// If true, mirrors will be garbage collected after an update once a threshold is exceeded.
+func (m *Medusa) GetGCAfterUpdate() bool {
+	g, ok := m.config.Get("gc").(map[string]interface{})
+	if !ok {
+		return false
+	}
+	b, _ := g["after-update"].(bool)
+	return b
+}
+
+// GetTamperPolicy returns the configuration key "tamper-policy".
+// The policy decides what happens if a tag is moved or a protected branch is force pushed upstream
+// during an update. Actions are "allow", "warn" and "block". Without configuration, these defaults will be used:
+//
+//	"tamper-policy": {
+//		"tag-moved": "warn",
+//		"force-push": "warn",
+//		"protected-branches": ["refs/heads/master", "refs/heads/main"],
+//		"webhook": ""
+//	}
+//
+// Invalid actions or ref patterns result in an error.
+func (m *Medusa) GetTamperPolicy() (*downloader.TamperPolicy, error) {
+	p := &downloader.TamperPolicy{
+		TagMoved:          downloader.TamperWarn,
+		ForcePush:         downloader.TamperWarn,
+		ProtectedBranches: []string{"refs/heads/master", "refs/heads/main"},
+	}
+
+	t, ok := m.config.Get("tamper-policy").(map[string]interface{})
+	if !ok {
+		return p, nil
+	}
+
+	for k, action := range map[string]*string{"tag-moved": &p.TagMoved, "force-push": &p.ForcePush} {
+		if s, ok := t[k].(string); ok {
+			if err := downloader.CheckTamperAction(s); err != nil {
+				return nil, fmt.Errorf("Tamper policy %s: %s", k, err)
+			}
+			*action = s
+		}
+	}
+	if _, ok := t["protected-branches"]; ok {
+		p.ProtectedBranches = toStringSlice(t["protected-branches"])
+		for _, b := range p.ProtectedBranches {
+			if err := downloader.CheckRefPattern(b); err != nil {
+				return nil, fmt.Errorf("Tamper policy protected-branches: %s", err)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// GetTamperWebhook returns the URL of the webhook that is notified about changes
+// that violate the tamper policy (configuration key "tamper-policy.webhook").
+// It is a shorthand for a webhook notification of the events "tag-moved" and "force-push" (see GetNotifiers).
+// If not configured, an empty string will be returned.
+func (m *Medusa) GetTamperWebhook() string {
+	t, _ := m.config.Get("tamper-policy").(map[string]interface{})
+	s, _ := t["webhook"].(string)
+	return s
+}
+
+// GetRewriteRules returns the configuration key "rewrite".
+// It is an ordered list of rules that rewrite the repository URLs of all packages.
+// A rule matches either by "prefix" (like "url.<base>.insteadOf" of git) or by "regexp":
+//
+//	"rewrite": [
+//		{"prefix": "git@gitlab.company.tld:", "replacement": "https://gitlab.company.tld/"},
+//		{"regexp": "^https://bitbucket.org/(.+?)(\\.git)?$", "replacement": "ssh://git@bitbucket.org/$1.git"}
+//	]
+//
+// Invalid rules result in an error.
+func (m *Medusa) GetRewriteRules() ([]*dependency.RewriteRule, error) {
+	rules := []*dependency.RewriteRule{}
+	v := m.config.Get("rewrite")
+	if v == nil {
+		return rules, nil
+	}
+	l, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.New("Rewrite rules need to be a list")
+	}
+
+	for i, item := range l {
+		r, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Rewrite rule #%d needs to be an object", i+1)
+		}
+		replacement, _ := r["replacement"].(string)
+		prefix, _ := r["prefix"].(string)
+		expr, _ := r["regexp"].(string)
+
+		var rule *dependency.RewriteRule
+		var err error
+		switch {
+		case len(prefix) > 0 && len(expr) > 0:
+			err = errors.New("Only one of \"prefix\" and \"regexp\" is allowed")
+		case len(expr) > 0:
+			rule, err = dependency.NewRegexpRewriteRule(expr, replacement)
+		default:
+			rule, err = dependency.NewPrefixRewriteRule(prefix, replacement)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Rewrite rule #%d: %s", i+1, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// GetCredentials returns the configuration key "credentials".
+// Credentials are configured per host. The keys of a Composer auth.json
+// ("http-basic", "github-oauth", "gitlab-token" and "gitlab-oauth") are supported as well:
+//
+//	"credentials": {
+//		"auth-json": "/home/perseus/.composer/auth.json",
+//		"github-oauth": {
+//			"github.com": "<token>"
+//		},
+//		"hosts": {
+//			"git.company.tld": {
+//				"username": "perseus",
+//				"password": "<password>",
+//				"token": "<token>",
+//				"ssh-key": "/home/perseus/.ssh/id_rsa",
+//				"known-hosts": "/home/perseus/.ssh/known_hosts"
+//			}
+//		}
+//	}
+//
+// Credentials of "hosts" overwrite the Composer keys, the Composer keys overwrite the auth.json file.
+// The credentials are read only once.
+func (m *Medusa) GetCredentials() (*credentials.Store, error) {
+	m.credentialsOnce.Do(func() {
+		m.credentials, m.credentialsErr = m.readCredentials()
+	})
+	return m.credentials, m.credentialsErr
+}
+
+func (m *Medusa) readCredentials() (*credentials.Store, error) {
+	s := credentials.New()
+	c, ok := m.config.Get("credentials").(map[string]interface{})
+	if !ok {
+		return s, nil
+	}
+
+	if path, ok := c["auth-json"].(string); ok && len(path) > 0 {
+		if err := s.LoadAuthJSON(path); err != nil {
+			return nil, err
+		}
+	}
+
+	composer := map[string]interface{}{}
+	for _, k := range []string{"http-basic", "github-oauth", "gitlab-token", "gitlab-oauth"} {
+		if v, ok := c[k]; ok {
+			composer[k] = v
+		}
+	}
+	if len(composer) > 0 {
+		b, err := json.Marshal(composer)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.AddAuthJSON(b); err != nil {
+			return nil, fmt.Errorf("Couldn't parse credentials: %s", err)
+		}
+	}
+
+	hosts, _ := c["hosts"].(map[string]interface{})
+	for host, v := range hosts {
+		h, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Credentials of host %s need to be an object", host)
+		}
+		get := func(k string) string {
+			s, _ := h[k].(string)
+			return s
+		}
+		s.Set(host, &credentials.Host{
+			Username:   get("username"),
+			Password:   get("password"),
+			Token:      get("token"),
+			SSHKey:     get("ssh-key"),
+			KnownHosts: get("known-hosts"),
+		})
+	}
+
+	return s, nil
+}
+
+// toStringSlice returns all strings of the list v.
+// Entries that are no strings will be ignored.
+func toStringSlice(v interface{}) []string {
+	l := []string{}
+	switch list := v.(type) {
+	case []string:
+		l = append(l, list...)
+	case []interface{}:
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				l = append(l, s)
+			}
+		}
+	}
+	return l
 }
 
 // GetRequire returns all the configuration key "require"
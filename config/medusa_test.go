@@ -1,10 +1,14 @@
 package config_test
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	. "github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/credentials"
 	"github.com/andygrunwald/perseus/dependency"
+	"github.com/andygrunwald/perseus/downloader"
 )
 
 func TestNewMedusa(t *testing.T) {
@@ -177,3 +181,239 @@ func TestMedusa_GetString(t *testing.T) {
 		t.Errorf("Expected a non empty string. Got an empty string for key %s", key)
 	}
 }
+
+func TestMedusa_GetAliases(t *testing.T) {
+	tests := []struct {
+		provider Provider
+		num      int
+	}{
+		// No "aliases" configured at all
+		{&EmptyUnitTestProvider{}, 0},
+		// 2 valid "aliases" configured + an invalid one
+		{&MedusaUnitTestProvider{}, 2},
+	}
+
+	for _, tt := range tests {
+		m, err := NewMedusa(tt.provider)
+		if err != nil {
+			t.Errorf("NewMedusa(Provider) throws error: %s", err)
+		}
+
+		a := m.GetAliases()
+		if n := len(a); n != tt.num {
+			t.Errorf("Expected a different number of aliases. Got %d, expected %d for provider %T", n, tt.num, tt.provider)
+		}
+	}
+
+	m, _ := NewMedusa(&MedusaUnitTestProvider{})
+	if got := m.GetResolverOptions().Aliases["symfony/translator"]; got != "symfony/translation" {
+		t.Errorf("Expected alias symfony/translation in resolver options. Got %s", got)
+	}
+}
+
+func TestMedusa_GetIgnore(t *testing.T) {
+	m, err := NewMedusa(&MedusaUnitTestProvider{})
+	if err != nil {
+		t.Errorf("NewMedusa(Provider) throws error: %s", err)
+	}
+
+	if l := m.GetResolverOptions().Ignore; len(l) != 1 || l[0] != "my-vendor/*" {
+		t.Errorf("Expected ignore pattern my-vendor/* in resolver options. Got %+v", l)
+	}
+}
+
+func TestMedusa_GetResolverOptions(t *testing.T) {
+	tests := []struct {
+		provider   Provider
+		requireDev string
+		suggest    string
+		maxDepth   int
+	}{
+		// No "resolver" configured at all
+		{&EmptyUnitTestProvider{}, "", "", 0},
+		{&MedusaUnitTestProvider{}, "root", "all", 3},
+	}
+
+	for _, tt := range tests {
+		m, err := NewMedusa(tt.provider)
+		if err != nil {
+			t.Errorf("NewMedusa(Provider) throws error: %s", err)
+		}
+
+		o := m.GetResolverOptions()
+		if o.RequireDev != tt.requireDev || o.Suggest != tt.suggest || o.MaxDepth != tt.maxDepth {
+			t.Errorf("Got different resolver options than expected for provider %T: %+v", tt.provider, o)
+		}
+	}
+}
+
+func TestMedusa_GetResolverOptions_VersionPolicies(t *testing.T) {
+	m, err := NewMedusa(&EmptyUnitTestProvider{})
+	if err != nil {
+		t.Errorf("NewMedusa(Provider) throws error: %s", err)
+	}
+	if o := m.GetResolverOptions(); o.Versions != nil || len(o.PackageVersions) != 0 {
+		t.Errorf("Expected no version policies. Got %+v", o)
+	}
+
+	m, err = NewMedusa(&MedusaUnitTestProvider{})
+	if err != nil {
+		t.Errorf("NewMedusa(Provider) throws error: %s", err)
+	}
+	o := m.GetResolverOptions()
+
+	expected := &dependency.VersionPolicy{
+		MinimumStability: "stable",
+		Include:          []string{},
+		Exclude:          []string{"dev-feature-*"},
+	}
+	if !reflect.DeepEqual(o.Versions, expected) {
+		t.Errorf("Got different global version policy than expected. Expected %+v, got %+v", expected, o.Versions)
+	}
+
+	if n := len(o.PackageVersions); n != 1 {
+		t.Fatalf("Expected one version policy per package. Got %d: %+v", n, o.PackageVersions)
+	}
+	expected = &dependency.VersionPolicy{
+		MinimumStability: "dev",
+		Include:          []string{"dev-master"},
+		Exclude:          []string{},
+	}
+	if got := o.PackageVersions["myvendor/package"]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got different version policy for myvendor/package than expected. Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestMedusa_GetGCThresholds(t *testing.T) {
+	tests := []struct {
+		provider    Provider
+		expected    *downloader.GCThresholds
+		afterUpdate bool
+	}{
+		// No "gc" configured at all: git defaults
+		{&EmptyUnitTestProvider{}, &downloader.GCThresholds{LooseObjects: 6700, Packs: 50}, false},
+		{&MedusaUnitTestProvider{}, &downloader.GCThresholds{LooseObjects: 1000, Packs: 50, MaxAge: 30 * 24 * time.Hour}, true},
+	}
+
+	for _, tt := range tests {
+		m, err := NewMedusa(tt.provider)
+		if err != nil {
+			t.Errorf("NewMedusa(Provider) throws error: %s", err)
+		}
+
+		if got := m.GetGCThresholds(); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Expected gc thresholds %+v for provider %T. Got %+v", tt.expected, tt.provider, got)
+		}
+		if got := m.GetGCAfterUpdate(); got != tt.afterUpdate {
+			t.Errorf("Expected gc after update %v for provider %T. Got %v", tt.afterUpdate, tt.provider, got)
+		}
+	}
+}
+
+func TestMedusa_GetCredentials(t *testing.T) {
+	m, _ := NewMedusa(&EmptyUnitTestProvider{})
+	c, err := m.GetCredentials()
+	if err != nil || len(c.Hosts()) != 0 {
+		t.Errorf("Expected no credentials without configuration. Got %v (%v)", c.Hosts(), err)
+	}
+
+	m, _ = NewMedusa(&MedusaUnitTestProvider{})
+	c, err = m.GetCredentials()
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	expected := map[string]*credentials.Host{
+		"github.com":      {Username: "octocat", Token: "gh-token"},
+		"gitlab.com":      {Username: "oauth2", Token: "gl-token"},
+		"git.company.tld": {Username: "perseus", Password: "secret", SSHKey: "/home/perseus/.ssh/id_rsa", KnownHosts: "/home/perseus/.ssh/known_hosts"},
+	}
+	if hosts := c.Hosts(); len(hosts) != len(expected) {
+		t.Errorf("Expected %d hosts with credentials. Got %v", len(expected), hosts)
+	}
+	for host, e := range expected {
+		if got, _ := c.Get(host); !reflect.DeepEqual(got, e) {
+			t.Errorf("Expected credentials %+v for host %s. Got %+v", e, host, got)
+		}
+	}
+}
+
+func TestMedusa_GetRewriteRules(t *testing.T) {
+	m, _ := NewMedusa(&EmptyUnitTestProvider{})
+	rules, err := m.GetRewriteRules()
+	if err != nil || len(rules) != 0 {
+		t.Errorf("Expected no rewrite rules without configuration. Got %v (%v)", rules, err)
+	}
+
+	m, _ = NewMedusa(&MedusaUnitTestProvider{})
+	rules, err = m.GetRewriteRules()
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	tests := []struct {
+		repository string
+		want       string
+	}{
+		{"git@gitlab.company.tld:myvendor/package.git", "https://gitlab.company.tld/myvendor/package.git"},
+		{"https://bitbucket.org/myvendor/package.git", "ssh://git@bitbucket.org/myvendor/package.git"},
+	}
+	if len(rules) != len(tests) {
+		t.Fatalf("Expected %d rewrite rules. Got %d", len(tests), len(rules))
+	}
+	for i, tt := range tests {
+		if got, ok := rules[i].Rewrite(tt.repository); !ok || got != tt.want {
+			t.Errorf("Expected rule #%d to rewrite %s to %s. Got %s", i+1, tt.repository, tt.want, got)
+		}
+	}
+}
+
+func TestMedusa_GetTamperPolicy(t *testing.T) {
+	m, _ := NewMedusa(&EmptyUnitTestProvider{})
+	p, err := m.GetTamperPolicy()
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	expected := &downloader.TamperPolicy{
+		TagMoved:          downloader.TamperWarn,
+		ForcePush:         downloader.TamperWarn,
+		ProtectedBranches: []string{"refs/heads/master", "refs/heads/main"},
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("Expected default policy %+v. Got %+v", expected, p)
+	}
+	if w := m.GetTamperWebhook(); len(w) != 0 {
+		t.Errorf("Expected no webhook. Got %s", w)
+	}
+
+	m, _ = NewMedusa(&MapUnitTestProvider{Values: map[string]interface{}{
+		"tamper-policy": map[string]interface{}{
+			"tag-moved":          "block",
+			"protected-branches": []interface{}{"refs/heads/[0-9]*.x"},
+			"webhook":            "https://hooks.company.tld/perseus",
+		},
+	}})
+	p, err = m.GetTamperPolicy()
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	expected = &downloader.TamperPolicy{
+		TagMoved:          downloader.TamperBlock,
+		ForcePush:         downloader.TamperWarn,
+		ProtectedBranches: []string{"refs/heads/[0-9]*.x"},
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("Expected policy %+v. Got %+v", expected, p)
+	}
+	if w := m.GetTamperWebhook(); w != "https://hooks.company.tld/perseus" {
+		t.Errorf("Expected webhook. Got %s", w)
+	}
+
+	m, _ = NewMedusa(&MapUnitTestProvider{Values: map[string]interface{}{
+		"tamper-policy": map[string]interface{}{
+			"force-push": "ignore",
+		},
+	}})
+	if p, err := m.GetTamperPolicy(); err == nil {
+		t.Errorf("Expected an error for an invalid action. Got %+v", p)
+	}
+}
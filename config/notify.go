@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+
+	"github.com/andygrunwald/perseus/notify"
+)
+
+const (
+	// NotifierWebhook sends notifications as JSON to a generic webhook
+	NotifierWebhook = "webhook"
+	// NotifierSlack sends notifications to an incoming webhook of Slack or Mattermost
+	NotifierSlack = "slack"
+	// NotifierSMTP sends notifications as email
+	NotifierSMTP = "smtp"
+)
+
+// GetNotifiers returns the notifiers of the configuration key "notifications".
+// Every entry is a single sink. "events" limits the sink to some event types (default: all events):
+//
+//	"notifications": [
+//		{"type": "webhook", "url": "https://hooks.company.tld/perseus", "events": ["failure", "added", "tag-moved"]},
+//		{"type": "slack", "url": "https://hooks.slack.com/services/...", "events": ["failure"]},
+//		{
+//			"type": "smtp",
+//			"host": "smtp.company.tld:587",
+//			"from": "perseus@company.tld",
+//			"to": ["platform@company.tld"],
+//			"credentials": "smtp.company.tld",
+//			"events": ["tag-moved", "force-push"]
+//		}
+//	]
+//
+// "credentials" of the SMTP sink is the name of an entry of "credentials.hosts" with "username" and "password".
+// The "tamper-policy.webhook" (see GetTamperWebhook) is a webhook sink for the events "tag-moved" and "force-push".
+// Invalid entries result in an error.
+func (m *Medusa) GetNotifiers() ([]notify.Notifier, error) {
+	l := []notify.Notifier{}
+	if w := m.GetTamperWebhook(); len(w) > 0 {
+		l = append(l, notify.Filter(notify.NewWebhook(w, nil), notify.EventTagMoved, notify.EventForcePush))
+	}
+
+	entries, _ := m.config.Get("notifications").([]interface{})
+	for i, entry := range entries {
+		e, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Notification %d needs to be an object", i)
+		}
+		n, err := m.getNotifier(e)
+		if err != nil {
+			return nil, fmt.Errorf("Notification %d: %s", i, err)
+		}
+
+		events := toStringSlice(e["events"])
+		for _, t := range events {
+			if err := notify.CheckEventType(t); err != nil {
+				return nil, fmt.Errorf("Notification %d: %s", i, err)
+			}
+		}
+		l = append(l, notify.Filter(n, events...))
+	}
+
+	return l, nil
+}
+
+// getNotifier returns the sink of the entry e of the configuration key "notifications".
+func (m *Medusa) getNotifier(e map[string]interface{}) (notify.Notifier, error) {
+	get := func(k string) string {
+		s, _ := e[k].(string)
+		return s
+	}
+
+	t := get("type")
+	switch t {
+	case NotifierWebhook, NotifierSlack:
+		url := get("url")
+		if len(url) == 0 {
+			return nil, fmt.Errorf("Sink %q needs an \"url\"", t)
+		}
+		if t == NotifierSlack {
+			return notify.NewSlack(url, nil), nil
+		}
+		return notify.NewWebhook(url, nil), nil
+
+	case NotifierSMTP:
+		addr, from := get("host"), get("from")
+		if len(addr) == 0 || len(from) == 0 {
+			return nil, fmt.Errorf("Sink %q needs a \"host\" and a \"from\" address", t)
+		}
+		to := toStringSlice(e["to"])
+		if len(to) == 0 {
+			return nil, fmt.Errorf("Sink %q needs at least one \"to\" address", t)
+		}
+
+		var auth smtp.Auth
+		if name := get("credentials"); len(name) > 0 {
+			creds, err := m.GetCredentials()
+			if err != nil {
+				return nil, err
+			}
+			h, ok := creds.Get(name)
+			if !ok {
+				return nil, fmt.Errorf("Unknown credentials %q. They need to be configured in \"credentials.hosts\"", name)
+			}
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid SMTP host %q: %s", addr, err)
+			}
+			auth = smtp.PlainAuth("", h.Username, h.Password, host)
+		}
+		return notify.NewSMTP(addr, from, to, auth), nil
+	}
+
+	return nil, fmt.Errorf("Unknown sink type %q. Valid types are %q, %q and %q", t, NotifierWebhook, NotifierSlack, NotifierSMTP)
+}
@@ -0,0 +1,51 @@
+package config_test
+
+import (
+	"testing"
+
+	. "github.com/andygrunwald/perseus/config"
+)
+
+func TestMedusa_GetNotifiers(t *testing.T) {
+	m, _ := NewMedusa(&EmptyUnitTestProvider{})
+	l, err := m.GetNotifiers()
+	if err != nil || len(l) != 0 {
+		t.Errorf("Expected no notifiers. Got %v (%v)", l, err)
+	}
+
+	m, _ = NewMedusa(&MapUnitTestProvider{Values: map[string]interface{}{
+		"tamper-policy": map[string]interface{}{
+			"webhook": "https://hooks.company.tld/tamper",
+		},
+		"notifications": []interface{}{
+			map[string]interface{}{"type": "webhook", "url": "https://hooks.company.tld/perseus", "events": []interface{}{"failure", "added"}},
+			map[string]interface{}{"type": "slack", "url": "https://hooks.slack.com/services/T0/B0/X"},
+			map[string]interface{}{"type": "smtp", "host": "smtp.company.tld:587", "from": "perseus@company.tld", "to": []interface{}{"platform@company.tld"}},
+		},
+	}})
+	l, err = m.GetNotifiers()
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if len(l) != 4 {
+		t.Errorf("Expected 4 notifiers. Got %d", len(l))
+	}
+}
+
+func TestMedusa_GetNotifiers_Invalid(t *testing.T) {
+	tests := []map[string]interface{}{
+		{"type": "pager"},
+		{"type": "webhook"},
+		{"type": "webhook", "url": "https://hooks.company.tld/perseus", "events": []interface{}{"success"}},
+		{"type": "smtp", "host": "smtp.company.tld:587", "from": "perseus@company.tld"},
+		{"type": "smtp", "host": "smtp.company.tld:587", "from": "perseus@company.tld", "to": []interface{}{"platform@company.tld"}, "credentials": "unknown"},
+	}
+	for _, tt := range tests {
+		m, _ := NewMedusa(&MapUnitTestProvider{Values: map[string]interface{}{
+			"notifications": []interface{}{tt},
+		}})
+		if l, err := m.GetNotifiers(); err == nil {
+			t.Errorf("Expected an error for %+v. Got %v", tt, l)
+		}
+	}
+}
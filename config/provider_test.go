@@ -60,8 +60,20 @@ func (p *MedusaUnitTestProvider) Get(key string) interface{} {
 				"url":  "git@github.com:symfony/console.git",
 			},
 			map[string]interface{}{
-				"name": "symfony/polyfill",
-				"url":  "https://github.com/symfony/polyfill.git",
+				"name":            "symfony/polyfill",
+				"url":             "https://github.com/symfony/polyfill.git",
+				"skip-fsck":       true,
+				"depth":           float64(1),
+				"refspecs":        []interface{}{"refs/tags/*", "refs/heads/master"},
+				"update-interval": "6h",
+				"credentials":     "git.company.tld",
+				"notes":           "Tags only",
+				"immutable-tags":  false,
+			},
+			map[string]interface{}{
+				"name":     "disabled/package",
+				"url":      "https://github.com/disabled/package.git",
+				"disabled": true,
 			},
 			map[string]interface{}{
 				"name": "no/url",
@@ -76,6 +88,82 @@ func (p *MedusaUnitTestProvider) Get(key string) interface{} {
 			},
 		}
 	}
+	if key == "aliases" {
+		m = map[string]interface{}{
+			"symfony/translator":      "symfony/translation",
+			"symfony/doctrine-bundle": "doctrine/doctrine-bundle",
+			"invalid/alias":           42,
+		}
+	}
+	if key == "gc" {
+		m = map[string]interface{}{
+			"loose-objects": float64(1000),
+			"max-age-days":  float64(30),
+			"after-update":  true,
+		}
+	}
+
+	if key == "rewrite" {
+		m = []interface{}{
+			map[string]interface{}{
+				"prefix":      "git@gitlab.company.tld:",
+				"replacement": "https://gitlab.company.tld/",
+			},
+			map[string]interface{}{
+				"regexp":      "^https://bitbucket.org/(.+)$",
+				"replacement": "ssh://git@bitbucket.org/$1",
+			},
+		}
+	}
+
+	if key == "credentials" {
+		m = map[string]interface{}{
+			"github-oauth": map[string]interface{}{
+				"github.com": "gh-token",
+			},
+			"gitlab-token": map[string]interface{}{
+				"gitlab.com": "gl-token",
+			},
+			"hosts": map[string]interface{}{
+				"git.company.tld": map[string]interface{}{
+					"username":    "perseus",
+					"password":    "secret",
+					"ssh-key":     "/home/perseus/.ssh/id_rsa",
+					"known-hosts": "/home/perseus/.ssh/known_hosts",
+				},
+				// Hosts overwrite the Composer keys
+				"github.com": map[string]interface{}{
+					"username": "octocat",
+				},
+			},
+		}
+	}
+
+	if key == "immutable-tags" {
+		m = true
+	}
+
+	if key == "refspecs" {
+		m = []interface{}{"refs/tags/*", "refs/heads/[0-9]*.x"}
+	}
+
+	if key == "resolver" {
+		// viper decodes JSON numbers as float64
+		m = map[string]interface{}{
+			"require-dev":       "root",
+			"suggest":           "all",
+			"max-depth":         float64(3),
+			"minimum-stability": "stable",
+			"exclude-versions":  []interface{}{"dev-feature-*", 42},
+			"packages": map[string]interface{}{
+				"myvendor/package": map[string]interface{}{
+					"minimum-stability": "dev",
+					"include-versions":  []interface{}{"dev-master"},
+				},
+				"invalid/package": "dev",
+			},
+		}
+	}
 
 	return m
 }
@@ -99,6 +187,11 @@ func (p *MedusaUnitTestProvider) GetStringSlice(key string) []string {
 			"swiftmailer/swiftmailer",
 		}
 	}
+	if key == "ignore" {
+		s = []string{
+			"my-vendor/*",
+		}
+	}
 	return s
 }
 
@@ -109,3 +202,25 @@ func (p *MedusaUnitTestProvider) GetContentMap() map[string]interface{} {
 
 	return m
 }
+
+// MapUnitTestProvider represents a Provider implementation that returns the Values by key for unit testing.
+type MapUnitTestProvider struct {
+	Values map[string]interface{}
+}
+
+func (p *MapUnitTestProvider) Get(key string) interface{} {
+	return p.Values[key]
+}
+
+func (p *MapUnitTestProvider) GetString(key string) string {
+	s, _ := p.Values[key].(string)
+	return s
+}
+
+func (p *MapUnitTestProvider) GetStringSlice(key string) []string {
+	return []string{}
+}
+
+func (p *MapUnitTestProvider) GetContentMap() map[string]interface{} {
+	return p.Values
+}
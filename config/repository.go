@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andygrunwald/perseus/downloader"
+)
+
+// RepositoryConfig reflects a single entry of the configuration key "repositories":
+//
+//	"repositories": [
+//		{
+//			"name": "myvendor/package",
+//			"url": "git@git.company.tld:myvendor/package.git",
+//			"skip-fsck": false,
+//			"depth": 0,
+//			"refspecs": ["refs/tags/*", "refs/heads/master"],
+//			"update-interval": "24h",
+//			"credentials": "deploy-key-package",
+//			"immutable-tags": true,
+//			"disabled": false,
+//			"notes": "Maintained by the platform team"
+//		}
+//	]
+//
+// Only "name" is required. An entry without "url" only configures the options
+// of a package that is resolved via Packagist.
+type RepositoryConfig struct {
+	// Name is the name of the package like "symfony/console"
+	Name string
+	// URL is the URL of the upstream repository as it is configured (without any rewriting).
+	// If empty, the URL will be determined via Packagist.
+	URL string
+	// SkipFsck disables the file system check after the initial clone
+	SkipFsck bool
+	// Depth creates a shallow mirror with the history truncated to Depth commits.
+	// 0 mirrors the complete history.
+	Depth int
+	// Refspecs limits the refs that are mirrored like "refs/tags/*" or "refs/heads/[0-9]*.x" (see downloader.CheckRefPattern).
+	// If empty, the global "refspecs" apply (see GetRefspecs). Without global "refspecs", all refs are mirrored.
+	Refspecs []string
+	// UpdateInterval is the minimum time between two updates of the mirror.
+	// If 0, the mirror is updated during every run of the "update" command.
+	UpdateInterval time.Duration
+	// Credentials is the name of an entry of "credentials.hosts".
+	// If set, these credentials are used instead of the credentials of the host of URL.
+	Credentials string
+	// ImmutableTags protects the mirror against refs that are deleted or rewritten upstream (see downloader.Options).
+	// If not configured, the global "immutable-tags" applies (see GetImmutableTags).
+	ImmutableTags bool
+	// Disabled packages are neither mirrored nor updated
+	Disabled bool
+	// Notes are free text for humans (like the reason why a package is disabled)
+	Notes string
+}
+
+// DownloadOptions returns the options of the repository for clones and updates.
+func (r *RepositoryConfig) DownloadOptions() *downloader.Options {
+	return &downloader.Options{
+		SkipFsck:      r.SkipFsck,
+		Depth:         r.Depth,
+		Refspecs:      r.Refspecs,
+		Credentials:   r.Credentials,
+		ImmutableTags: r.ImmutableTags,
+	}
+}
+
+// GetRepositoryConfigs returns all entries of the configuration key "repositories".
+// Entries without a name and values of the wrong type will be ignored.
+// Invalid values (like an unknown credentials reference) result in an error.
+func (m *Medusa) GetRepositoryConfigs() ([]*RepositoryConfig, error) {
+	repositoriesSlice, err := m.getRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]*RepositoryConfig, 0, len(repositoriesSlice))
+	for _, repoEntry := range repositoriesSlice {
+		repoEntryMap, ok := repoEntry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		r, err := m.getRepositoryConfig(repoEntryMap)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			l = append(l, r)
+		}
+	}
+
+	return l, nil
+}
+
+// GetRepositoryConfig returns the entry of package name of the configuration key "repositories".
+// If there is no entry for name, nil will be returned.
+func (m *Medusa) GetRepositoryConfig(name string) (*RepositoryConfig, error) {
+	l, err := m.GetRepositoryConfigs()
+	if err != nil {
+		if IsNoRepositories(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, r := range l {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetRefspecs returns the configuration key "refspecs".
+// These ref patterns limit the refs of all mirrors without own "refspecs" (see RepositoryConfig):
+//
+//	"refspecs": ["refs/tags/*", "refs/heads/master", "refs/heads/[0-9]*.x"]
+//
+// Entries that are no strings will be ignored. Invalid ref patterns result in an error.
+func (m *Medusa) GetRefspecs() ([]string, error) {
+	refspecs := toStringSlice(m.config.Get("refspecs"))
+	for _, p := range refspecs {
+		if err := downloader.CheckRefPattern(p); err != nil {
+			return nil, err
+		}
+	}
+	return refspecs, nil
+}
+
+// GetImmutableTags returns the configuration key "immutable-tags".
+// If true, tags of all mirrors without own "immutable-tags" (see RepositoryConfig) are never deleted or moved
+// and the old state of deleted or rewritten branches is archived (see downloader.Options).
+func (m *Medusa) GetImmutableTags() bool {
+	b, _ := m.config.Get("immutable-tags").(bool)
+	return b
+}
+
+// GetDownloadOptions returns the options for clones and updates of all packages.
+// Configured repositories without own "refspecs" and all other packages use the global "refspecs" (see GetRefspecs).
+func (m *Medusa) GetDownloadOptions() (*downloader.PackageOptions, error) {
+	refspecs, err := m.GetRefspecs()
+	if err != nil {
+		return nil, err
+	}
+
+	tamper, err := m.GetTamperPolicy()
+	if err != nil {
+		return nil, err
+	}
+	limits, err := m.GetLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	o := &downloader.PackageOptions{
+		Default: &downloader.Options{
+			Refspecs:      refspecs,
+			ImmutableTags: m.GetImmutableTags(),
+			Tamper:        tamper,
+			Limits:        limits,
+		},
+		Packages: map[string]*downloader.Options{},
+	}
+
+	l, err := m.GetRepositoryConfigs()
+	if err != nil {
+		if IsNoRepositories(err) {
+			return o, nil
+		}
+		return nil, err
+	}
+
+	for _, r := range l {
+		opts := r.DownloadOptions()
+		if len(opts.Refspecs) == 0 {
+			opts.Refspecs = refspecs
+		}
+		opts.Tamper = tamper
+		opts.Limits = limits
+		o.Packages[r.Name] = opts
+	}
+	return o, nil
+}
+
+// getRepositoryConfig returns the RepositoryConfig of the entry e of the configuration key "repositories".
+// If e has no name, nil will be returned.
+func (m *Medusa) getRepositoryConfig(e map[string]interface{}) (*RepositoryConfig, error) {
+	r := &RepositoryConfig{}
+	if r.Name, _ = e["name"].(string); len(r.Name) == 0 {
+		return nil, nil
+	}
+	r.URL, _ = e["url"].(string)
+	r.SkipFsck, _ = e["skip-fsck"].(bool)
+	r.Credentials, _ = e["credentials"].(string)
+	r.ImmutableTags = m.GetImmutableTags()
+	if b, ok := e["immutable-tags"].(bool); ok {
+		r.ImmutableTags = b
+	}
+	r.Disabled, _ = e["disabled"].(bool)
+	r.Notes, _ = e["notes"].(string)
+
+	if d, ok := toInt(e["depth"]); ok {
+		if d < 0 {
+			return nil, fmt.Errorf("Repository %s: Depth must not be negative. Got %d", r.Name, d)
+		}
+		r.Depth = d
+	}
+
+	if refspecs := toStringSlice(e["refspecs"]); len(refspecs) > 0 {
+		for _, refspec := range refspecs {
+			if err := downloader.CheckRefPattern(refspec); err != nil {
+				return nil, fmt.Errorf("Repository %s: %s", r.Name, err)
+			}
+		}
+		r.Refspecs = refspecs
+	}
+
+	if s, ok := e["update-interval"].(string); ok && len(s) > 0 {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("Repository %s: Invalid update interval: %s", r.Name, err)
+		}
+		r.UpdateInterval = d
+	}
+
+	if len(r.Credentials) > 0 {
+		creds, err := m.GetCredentials()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := creds.Get(r.Credentials); !ok {
+			return nil, fmt.Errorf("Repository %s: Unknown credentials %q. They need to be configured in \"credentials.hosts\"", r.Name, r.Credentials)
+		}
+	}
+
+	return r, nil
+}
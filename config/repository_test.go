@@ -0,0 +1,96 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/config"
+)
+
+func TestMedusa_GetRepositoryConfig(t *testing.T) {
+	m, _ := NewMedusa(&MedusaUnitTestProvider{})
+
+	r, err := m.GetRepositoryConfig("symfony/polyfill")
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	expected := &RepositoryConfig{
+		Name:           "symfony/polyfill",
+		URL:            "https://github.com/symfony/polyfill.git",
+		SkipFsck:       true,
+		Depth:          1,
+		Refspecs:       []string{"refs/tags/*", "refs/heads/master"},
+		UpdateInterval: 6 * time.Hour,
+		Credentials:    "git.company.tld",
+		Notes:          "Tags only",
+	}
+	if !reflect.DeepEqual(r, expected) {
+		t.Errorf("Expected %+v. Got %+v", expected, r)
+	}
+
+	if r, _ := m.GetRepositoryConfig("disabled/package"); r == nil || !r.Disabled {
+		t.Errorf("Expected disabled/package to be disabled. Got %+v", r)
+	}
+	if r, err := m.GetRepositoryConfig("twig/twig"); r != nil || err != nil {
+		t.Errorf("Expected no configuration for twig/twig. Got %+v (%v)", r, err)
+	}
+
+	o, err := m.GetDownloadOptions()
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	opts := o.Get("symfony/polyfill")
+	want := expected.DownloadOptions()
+	want.Tamper = opts.Tamper
+	want.Limits = opts.Limits
+	if !reflect.DeepEqual(opts, want) || opts.Tamper == nil {
+		t.Errorf("Expected download options of symfony/polyfill. Got %+v", opts)
+	}
+	// Packages without own refspecs use the global refspecs
+	global := []string{"refs/tags/*", "refs/heads/[0-9]*.x"}
+	for _, name := range []string{"symfony/console", "twig/twig"} {
+		if opts := o.Get(name); opts == nil || !reflect.DeepEqual(opts.Refspecs, global) || !opts.ImmutableTags {
+			t.Errorf("Expected the global refspecs and immutable tags for %s. Got %+v", name, opts)
+		}
+	}
+
+	m, _ = NewMedusa(&EmptyUnitTestProvider{})
+	if o, err := m.GetDownloadOptions(); err != nil || len(o.Get("symfony/polyfill").Refspecs) != 0 {
+		t.Errorf("Expected default download options without configuration. Got %+v (%v)", o, err)
+	}
+	if r, err := m.GetRepositoryConfig("symfony/polyfill"); r != nil || err != nil {
+		t.Errorf("Expected no configuration without repositories. Got %+v (%v)", r, err)
+	}
+}
+
+func TestMedusa_GetRepositoryConfigs_Invalid(t *testing.T) {
+	tests := []map[string]interface{}{
+		{"name": "invalid/depth", "depth": float64(-1)},
+		{"name": "invalid/refspec", "refspecs": []interface{}{"master"}},
+		{"name": "invalid/interval", "update-interval": "daily"},
+		{"name": "invalid/credentials", "credentials": "unknown.tld"},
+	}
+
+	for _, tt := range tests {
+		m, _ := NewMedusa(&MapUnitTestProvider{Values: map[string]interface{}{"repositories": []interface{}{tt}}})
+		if l, err := m.GetRepositoryConfigs(); err == nil {
+			t.Errorf("Expected an error for %v. Got %+v", tt, l)
+		}
+	}
+}
+
+func TestMedusa_GetRefspecs(t *testing.T) {
+	m, _ := NewMedusa(&EmptyUnitTestProvider{})
+	if l, err := m.GetRefspecs(); err != nil || len(l) != 0 {
+		t.Errorf("Expected no refspecs without configuration. Got %v (%v)", l, err)
+	}
+
+	m, _ = NewMedusa(&MapUnitTestProvider{Values: map[string]interface{}{"refspecs": []interface{}{"refs/heads/[0-9"}}})
+	if l, err := m.GetRefspecs(); err == nil {
+		t.Errorf("Expected an error for an invalid ref pattern. Got %v", l)
+	}
+	if o, err := m.GetDownloadOptions(); err == nil {
+		t.Errorf("Expected an error for an invalid ref pattern. Got %+v", o)
+	}
+}
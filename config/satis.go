@@ -79,6 +79,11 @@ func (s *Satis) AddRepository(u string) {
 	s.repositories[r.URL] = r
 }
 
+// RemoveRepository will remove repository u from the current satis configuration
+func (s *Satis) RemoveRepository(u string) {
+	delete(s.repositories, u)
+}
+
 // AddRepositories will add a list of repositories u to the current satis configuration
 func (s *Satis) AddRepositories(u ...string) {
 	for _, r := range u {
@@ -118,6 +123,12 @@ func (s *Satis) WriteFile(filename string, perm os.FileMode) error {
 	return ioutil.WriteFile(filename, b, perm)
 }
 
+// HasRepository returns true if repository u is part of the current satis configuration
+func (s *Satis) HasRepository(u string) bool {
+	_, ok := s.repositories[u]
+	return ok
+}
+
 // GetRepositoriesAsSlice returns all configured repositories
 // from the configuration as a list.
 func (s *Satis) GetRepositoriesAsSlice() []SatisRepository {
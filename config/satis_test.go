@@ -12,3 +12,29 @@ func TestNewSatis_NoProvider(t *testing.T) {
 		t.Error("Expected an error. Got none.")
 	}
 }
+
+func TestSatis_HasRepository(t *testing.T) {
+	p, err := NewJSONProvider([]byte(`{"name": "My mirror", "repositories": [{"type": "git", "url": "http://git.example.com/symfony/console.git"}]}`))
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	s, err := NewSatis(p)
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	s.AddRepository("http://git.example.com/twig/twig.git")
+
+	for _, u := range []string{"http://git.example.com/symfony/console.git", "http://git.example.com/twig/twig.git"} {
+		if !s.HasRepository(u) {
+			t.Errorf("Expected repository %s. Got none", u)
+		}
+	}
+	if s.HasRepository("http://git.example.com/psr/log.git") {
+		t.Error("Expected no repository psr/log. Got one")
+	}
+
+	s.RemoveRepository("http://git.example.com/twig/twig.git")
+	if s.HasRepository("http://git.example.com/twig/twig.git") {
+		t.Error("Expected repository twig/twig to be removed. Got it")
+	}
+}
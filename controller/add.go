@@ -2,17 +2,15 @@ package controller
 
 import (
 	"fmt"
-	"io/ioutil"
-	"net/url"
-	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/andygrunwald/perseus/config"
 	"github.com/andygrunwald/perseus/dependency"
 	"github.com/andygrunwald/perseus/dependency/repository"
-	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/progress"
+	"github.com/andygrunwald/perseus/report"
+	"github.com/andygrunwald/perseus/state"
 )
 
 // AddController reflects the business logic and the Command interface to add a new package.
@@ -29,6 +27,18 @@ type AddController struct {
 	Log logrus.FieldLogger
 	// NumOfWorker is the number of worker used for concurrent actions (like resolving the dependency tree)
 	NumOfWorker int
+	// ResolverOptions are the settings for the dependency resolver.
+	// If nil, the settings of Config will be used.
+	ResolverOptions *dependency.ResolverOptions
+	// Report is the run report. The outcome of every package will be recorded here.
+	// If nil, a new report will be created during Run.
+	Report *report.Report
+	// State is the history of all mirrored repositories.
+	// If nil, the state file of the repository directory will be used.
+	State *state.Store
+	// Progress receives the progress events of the run (see progress.Bus).
+	// If nil, no progress is published.
+	Progress progress.Publisher
 }
 
 // downloadResult represents the result of a download
@@ -44,8 +54,26 @@ func (c *AddController) Run() error {
 		return err
 	}
 
-	var satisRepositories []string
-	downloadablePackages := []*dependency.Package{}
+	if c.Report == nil {
+		c.Report = report.New("add")
+	}
+	defer notifyReport(c.Log, c.Config, c.Report)
+	defer logReport(c.Log, c.Report)
+
+	hooks, err := startHooks(c.Log, c.Config, c.Report)
+	if err != nil {
+		return err
+	}
+	defer hooks.Finish()
+
+	if c.State == nil {
+		s, err := openState(c.Config)
+		if err != nil {
+			return err
+		}
+		c.State = s
+	}
+	defer saveState(c.Log, c.State)
 
 	// We don't respect the error here.
 	// OH: "WTF? Why? You claim 'Serious error handling' in the README!"
@@ -54,110 +82,74 @@ func (c *AddController) Run() error {
 	// When this happen, we will ask Packagist fot the repository url.
 	// If this package is not available on packagist, this will be shift to an error.
 	p.Repository, _ = c.Config.GetRepositoryURLOfPackage(p)
-	if p.Repository == nil {
-
-		// Check if we should load the dependency also
-		if c.WithDependencies {
-			pUrl := "https://packagist.org/"
-			c.Log.WithFields(logrus.Fields{
-				"package": c.Package,
-				"source":  pUrl,
-			}).Info("Loading dependencies")
-
-			packagistClient, err := repository.NewPackagist(pUrl, nil)
-			if err != nil {
-				return err
-			}
-
-			// Lets get a dependency resolver.
-			// If we can't bootstrap one, we are lost anyway.
-			// We set the queue length to the number of workers + 1. Why?
-			// With this every worker has work, when the queue is filled.
-			// During the add command, this is enough in most of the cases.
-			d, err := dependency.NewComposerResolver(c.NumOfWorker, packagistClient)
-			if err != nil {
-				return err
-			}
-			results := d.GetResultStream()
-			go d.Resolve([]*dependency.Package{p})
-
-			dependencyNames := []string{}
-			// Finally we collect all the results of the work.
-			for v := range results {
-				downloadablePackages = append(downloadablePackages, v.Package)
-				dependencyNames = append(dependencyNames, v.Package.Name)
-			}
-
-			if l := len(dependencyNames); l == 0 {
-				c.Log.WithFields(logrus.Fields{
-					"amount":  l,
-					"package": c.Package,
-					"source":  pUrl,
-				}).Info("No dependencies found")
-			} else {
-				c.Log.WithFields(logrus.Fields{
-					"amount":       l,
-					"package":      c.Package,
-					"source":       pUrl,
-					"dependencies": strings.Join(dependencyNames, ", "),
-				}).Info("Dependencies found")
-			}
-
-		} else {
-			// It seems to be that we don't have an URL for the package
-			// Lets ask packagist for it
-			p, err = c.getURLOfPackageFromPackagist(p)
-			if err != nil {
-				return err
-			}
-			downloadablePackages = append(downloadablePackages, p)
-		}
 
-	} else {
+	pUrl := "https://packagist.org/"
+	var resolver dependency.Resolver
+	switch {
+	case p.Repository != nil:
 		c.Log.WithFields(logrus.Fields{
 			"package":    p.Name,
 			"repository": p.Repository,
 		}).Info("Mirroring started")
-		downloadablePackages = append(downloadablePackages, p)
+
+	// Check if we should load the dependency also
+	case c.WithDependencies:
+		c.Log.WithFields(logrus.Fields{
+			"package": c.Package,
+			"source":  pUrl,
+		}).Info("Loading dependencies")
+
+		packagistClient, err := newPackagistClient(c.Config, pUrl)
+		if err != nil {
+			return err
+		}
+
+		// Lets get a dependency resolver.
+		// If we can't bootstrap one, we are lost anyway.
+		// We set the queue length to the number of workers + 1. Why?
+		// With this every worker has work, when the queue is filled.
+		// During the add command, this is enough in most of the cases.
+		resolver, err = dependency.NewComposerResolver(resolverWorkers(c.Config, c.NumOfWorker), packagistClient, getResolverOptions(c.ResolverOptions, c.Config))
+		if err != nil {
+			return err
+		}
+
+	default:
+		// It seems to be that we don't have an URL for the package
+		// Lets ask packagist for it
+		p, err = getURLOfPackageFromPackagist(c.Config, p)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Okay, we have everything done here.
-	// Resolved the dependencies (or not) and collected the packages.
-	// I would say we can start with downloading them ....
+	// I would say we can start with downloading ....
 	// Why we are talking? Lets do it!
+	// Dependencies are downloaded as soon as they are resolved.
 	c.Log.WithFields(logrus.Fields{
-		"amountPackages": len(downloadablePackages),
-		"amountWorker":   c.NumOfWorker,
+		"amountWorker": gitWorkers(c.Config, c.NumOfWorker),
 	}).Info("Start concurrent download process")
-	d, err := downloader.NewGitDownloader(c.NumOfWorker, c.Config.GetString("repodir"))
+	d, err := newGitDownloader(c.Config, c.NumOfWorker, c.Progress)
 	if err != nil {
 		return err
 	}
+	queue := newDownloadQueue(c.Config, c.Log, c.Report, c.Progress, gitWorkers(c.Config, c.NumOfWorker))
+	d.DownloadStream(queue.Packages())
 
-	results := d.GetResultStream()
-	d.Download(downloadablePackages)
-
-	for i := 1; i <= len(downloadablePackages); i++ {
-		v := <-results
-		if v.Error != nil {
-			if os.IsExist(v.Error) {
-				c.Log.WithFields(logrus.Fields{
-					"package": v.Package.Name,
-				}).Info("Package exists on disk. Try updating it instead. Skipping.")
-			} else {
-				c.Log.WithFields(logrus.Fields{
-					"package": v.Package.Name,
-				}).WithError(v.Error).Info("Error while mirroring package")
-				// If we have an error, we don't need to add it to satis repositories
-				continue
-			}
+	total := make(chan int, 1)
+	go func() {
+		if resolver == nil {
+			queue.Add(p)
 		} else {
-			c.Log.WithFields(logrus.Fields{
-				"package": v.Package.Name,
-			}).Info("Mirroring of package successful")
+			c.resolveDependencies(resolver, p, pUrl, queue)
 		}
+		total <- queue.Close()
+	}()
 
-		satisRepositories = append(satisRepositories, c.getLocalUrlForRepository(v.Package.Name))
+	var satisRepositories []string
+	for _, p := range waitForDownloads(c.Log, c.Report, c.State, c.Progress, hooks, d, total) {
+		satisRepositories = append(satisRepositories, getLocalURLForRepository(c.Config, p.Name))
 	}
 	d.Close()
 
@@ -166,6 +158,43 @@ func (c *AddController) Run() error {
 	return err
 }
 
+// resolveDependencies resolves package p incl. its dependencies with resolver
+// and adds every resolved package to queue.
+// source is the URL of the Packagist instance.
+func (c *AddController) resolveDependencies(resolver dependency.Resolver, p *dependency.Package, source string, queue *downloadQueue) {
+	results := resolver.GetResultStream()
+	go resolver.Resolve([]*dependency.Package{p})
+
+	dependencyNames := []string{}
+	// Finally we collect all the results of the work.
+	for v := range results {
+		if v.Error != nil {
+			logResolveError(c.Log, c.Report, v, source)
+			continue
+		}
+
+		logAbandoned(c.Log, c.Report, v)
+		progress.Publish(c.Progress, progress.EventResolved, v.Package.Name)
+		queue.Add(v.Package)
+		dependencyNames = append(dependencyNames, v.Package.Name)
+	}
+
+	if l := len(dependencyNames); l == 0 {
+		c.Log.WithFields(logrus.Fields{
+			"amount":  l,
+			"package": c.Package,
+			"source":  source,
+		}).Info("No dependencies found")
+	} else {
+		c.Log.WithFields(logrus.Fields{
+			"amount":       l,
+			"package":      c.Package,
+			"source":       source,
+			"dependencies": strings.Join(dependencyNames, ", "),
+		}).Info("Dependencies found")
+	}
+}
+
 func (c *AddController) writeSatisConfig(satisRepositories ...string) error {
 	// Write Satis file
 	satisConfig := c.Config.GetString("satisconfig")
@@ -174,19 +203,9 @@ func (c *AddController) writeSatisConfig(satisRepositories ...string) error {
 		return nil
 	}
 
-	satisContent, err := ioutil.ReadFile(satisConfig)
-	if err != nil {
-		return fmt.Errorf("Can't read Satis configuration %s: %s", satisConfig, err)
-	}
-
-	j, err := config.NewJSONProvider(satisContent)
+	s, err := readSatisConfig(satisConfig)
 	if err != nil {
-		return fmt.Errorf("Error while creating JSONProvider: %s", err)
-	}
-
-	s, err := config.NewSatis(j)
-	if err != nil {
-		return fmt.Errorf("Error while creating Satis object: %s", err)
+		return err
 	}
 
 	s.AddRepositories(satisRepositories...)
@@ -201,46 +220,46 @@ func (c *AddController) writeSatisConfig(satisRepositories ...string) error {
 	return nil
 }
 
-func (c *AddController) getLocalUrlForRepository(p string) string {
-	var r string
-
-	satisURL := c.Config.GetString("satisurl")
-	repoDir := c.Config.GetString("repodir")
+// getURLOfPackageFromPackagist requests the repository URL of package p from Packagist.
+// Requests are authenticated with the credentials of cfg.
+func getURLOfPackageFromPackagist(cfg *config.Medusa, p *dependency.Package) (*dependency.Package, error) {
+	packagistPackage, err := getPackageFromPackagist(cfg, p.Name)
+	if err != nil {
+		return p, err
+	}
 
-	if len(satisURL) > 0 {
-		r = fmt.Sprintf("%s/%s.git", satisURL, p)
-	} else {
-		t := fmt.Sprintf("%s/%s.git", repoDir, p)
-		t = strings.TrimLeft(filepath.Clean(t), "/")
-		r = fmt.Sprintf("file:///%s", t)
+	// Overwriting values from Packagist
+	p.Name = packagistPackage.Name
+	u, err := dependency.ParseRepositoryURL(packagistPackage.Repository)
+	if err != nil {
+		return p, fmt.Errorf("URL conversion of %s to a net/url.URL object failed: %s", packagistPackage.Repository, err)
 	}
+	p.Repository = u
 
-	return r
+	return p, nil
 }
 
-func (c *AddController) getURLOfPackageFromPackagist(p *dependency.Package) (*dependency.Package, error) {
-	packagistClient, err := repository.NewPackagist("https://packagist.org/", nil)
+// getPackageFromPackagist requests package name from Packagist.
+// The repository URL of the returned package is not empty, but it is not rewritten (see dependency.RewriteURL).
+// Requests are authenticated with the credentials of cfg.
+func getPackageFromPackagist(cfg *config.Medusa, name string) (*repository.PackagistPackage, error) {
+	packagistClient, err := newPackagistClient(cfg, "https://packagist.org/")
 	if err != nil {
-		return p, fmt.Errorf("Packagist client creation failed: %s", err)
+		return nil, fmt.Errorf("Packagist client creation failed: %s", err)
 	}
 
-	packagistPackage, resp, err := packagistClient.GetPackageByName(p.Name)
+	packagistPackage, resp, err := packagistClient.GetPackageByName(name)
 	if err != nil {
-		return p, fmt.Errorf("Failed to retrieve information about package \"%s\" from Packagist. Called %s. Error: %s", p.Name, resp.Request.URL.String(), err)
+		if resp == nil {
+			return nil, fmt.Errorf("Failed to retrieve information about package \"%s\" from Packagist. Error: %s", name, err)
+		}
+		return nil, fmt.Errorf("Failed to retrieve information about package \"%s\" from Packagist. Called %s. Error: %s", name, resp.Request.URL.String(), err)
 	}
 
 	// Check if URL is empty
 	if len(packagistPackage.Repository) == 0 {
-		return p, fmt.Errorf("Received empty URL for package %s from Packagist", p.Name)
+		return nil, fmt.Errorf("Received empty URL for package %s from Packagist", name)
 	}
 
-	// Overwriting values from Packagist
-	p.Name = packagistPackage.Name
-	u, err := url.Parse(packagistPackage.Repository)
-	if err != nil {
-		return p, fmt.Errorf("URL conversion of %s to a net/url.URL object failed: %s", packagistPackage.Repository, err)
-	}
-	p.Repository = u
-
-	return p, nil
+	return packagistPackage, nil
 }
@@ -6,3 +6,15 @@ type Controller interface {
 	// Run contains the business logic of the defined command.
 	Run() error
 }
+
+const (
+	// OutputFormatText renders the output of a command as a human readable table
+	OutputFormatText = "text"
+	// OutputFormatJSON renders the output of a command as JSON
+	OutputFormatJSON = "json"
+)
+
+// isValidOutputFormat returns true if f is a supported output format (see OutputFormat* constants).
+func isValidOutputFormat(f string) bool {
+	return f == OutputFormatText || f == OutputFormatJSON
+}
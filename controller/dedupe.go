@@ -0,0 +1,390 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/report"
+)
+
+// poolDirName is the directory in the repository directory where the object pools are stored.
+// Vendor names of packages can't start with an underscore, so it never collides with a mirror.
+const poolDirName = "_pool"
+
+// DedupeController reflects the business logic and the Command interface to deduplicate the objects of forks.
+//
+// Mirrors that share at least one root commit (e.g. several forks of the same upstream project)
+// store their objects in a common object pool and borrow them from there via objects/info/alternates.
+// Objects of a pool are never pruned, because git doesn't know which mirrors borrow them.
+//
+// This command is independent from an human interface (CLI, HTTP, etc.)
+// The human interfaces will interact with this command.
+type DedupeController struct {
+	// DryRun prints the mirrors that would share an object pool without changing anything
+	DryRun bool
+	// Config is the main medusa configuration
+	Config *config.Medusa
+	// Log represents a logger to log messages
+	Log logrus.FieldLogger
+	// NumOfWorker is the number of worker used for concurrent actions (like determining root commits)
+	NumOfWorker int
+	// Report is the run report. The outcome of every package will be recorded here.
+	// If nil, a new report will be created during Run.
+	Report *report.Report
+	// Out is the writer where the object pools and their mirrors will be written to (default: os.Stdout)
+	Out io.Writer
+}
+
+// poolGroup is an object pool and the mirrors that share it
+type poolGroup struct {
+	// Pool is the path of the object pool like /tmp/perseus/git-mirror/_pool/<root commit>.git
+	Pool string
+	// Mirrors are the paths of the mirrors that share the pool
+	Mirrors []string
+}
+
+// mirrorRoots are the root commits of a single mirror
+type mirrorRoots struct {
+	// Path reflects the file path of the repository like /tmp/perseus/git-mirror/symfony/console.git
+	Path string
+	// Roots are the root commits of all refs
+	Roots []string
+	// Alternates are the object directories the mirror borrows objects from already
+	Alternates []string
+	// Shallow is true if the mirror is shallow (see downloader.IsShallow)
+	Shallow bool
+	// Err contains an error once there was one during the determination of the root commits
+	Err error
+}
+
+// dedupeResult is the result of the deduplication of a single object pool
+type dedupeResult struct {
+	// Group is the object pool and its mirrors
+	Group *poolGroup
+	// Reclaimed is the number of bytes that were freed per mirror path
+	Reclaimed map[string]int64
+	// Errors are the errors per mirror path
+	Errors map[string]error
+	// PoolGrowth is the number of bytes the object pool grew
+	PoolGrowth int64
+	// Err contains an error once the object pool couldn't be created
+	Err error
+	// GCErr contains an error once the garbage collection of the object pool failed
+	GCErr error
+	// Duration is the time the deduplication of the whole pool took
+	Duration time.Duration
+	// Worker is the id of the worker that processed the object pool
+	Worker int
+}
+
+// Run is the business logic of DedupeCommand.
+func (c *DedupeController) Run() error {
+	if c.Report == nil {
+		c.Report = report.New("dedupe")
+	}
+	defer notifyReport(c.Log, c.Config, c.Report)
+	defer logReport(c.Log, c.Report)
+
+	out := c.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	repoDir := c.Config.GetString("repodir")
+	matches, err := findMirrors(repoDir)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		c.Log.WithFields(logrus.Fields{
+			"path": repoDir,
+		}).Info("No repositories found")
+		return nil
+	}
+
+	groups := c.getPoolGroups(repoDir, c.getRoots(repoDir, matches))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, g := range groups {
+		for _, m := range g.Mirrors {
+			fmt.Fprintf(w, "%s\t%s\n", getPackageNameOfPath(repoDir, g.Pool), getPackageNameOfPath(repoDir, m))
+		}
+	}
+	w.Flush()
+
+	if c.DryRun || len(groups) == 0 {
+		return nil
+	}
+
+	jobs := make(chan *poolGroup, len(groups))
+	results := make(chan *dedupeResult, len(groups))
+	for w := 1; w <= c.NumOfWorker; w++ {
+		go c.worker(repoDir, w, jobs, results)
+	}
+	for _, g := range groups {
+		jobs <- g
+	}
+	close(jobs)
+
+	var saved int64
+	for a := 1; a <= len(groups); a++ {
+		saved += c.logResult(repoDir, <-results)
+	}
+
+	c.Log.WithFields(logrus.Fields{
+		"pools":       len(groups),
+		"saved_bytes": saved,
+		"saved":       formatSize(saved),
+	}).Info("Deduplication finished")
+	return nil
+}
+
+// getRoots determines the root commits and alternates of all mirrors concurrently.
+// Mirrors with an error are logged, recorded as failed and won't be returned.
+func (c *DedupeController) getRoots(repoDir string, matches []string) []*mirrorRoots {
+	jobs := make(chan string, len(matches))
+	results := make(chan *mirrorRoots, len(matches))
+	for w := 1; w <= c.NumOfWorker; w++ {
+		go func() {
+			for j := range jobs {
+				r := &mirrorRoots{Path: j, Shallow: downloader.IsShallow(j)}
+				if r.Shallow {
+					results <- r
+					continue
+				}
+				r.Roots, r.Err = downloader.RootCommits(j)
+				if r.Err == nil {
+					r.Alternates, r.Err = downloader.GetAlternates(j)
+				}
+				results <- r
+			}
+		}()
+	}
+	for _, m := range matches {
+		jobs <- m
+	}
+	close(jobs)
+
+	roots := make([]*mirrorRoots, 0, len(matches))
+	for a := 1; a <= len(matches); a++ {
+		r := <-results
+		if r.Err != nil {
+			name := getPackageNameOfPath(repoDir, r.Path)
+			c.Log.WithFields(logrus.Fields{
+				"package":     name,
+				"path":        r.Path,
+				"error_class": downloader.ClassifyError(r.Err),
+			}).WithError(r.Err).Error("Error while determining root commits")
+			c.Report.Failed(name, r.Err)
+			continue
+		}
+		roots = append(roots, r)
+	}
+	return roots
+}
+
+// getPoolGroups groups all mirrors that share at least one root commit (directly or through other mirrors).
+// Only groups with at least two mirrors are returned.
+// If a mirror of a group is member of an object pool already, this pool will be used.
+// Otherwise the pool is named after the smallest root commit of the group.
+// Mirrors that borrow objects from somewhere else than an object pool and shallow mirrors are skipped.
+func (c *DedupeController) getPoolGroups(repoDir string, roots []*mirrorRoots) []*poolGroup {
+	poolDir := filepath.Join(repoDir, poolDirName)
+
+	// Union-find over the paths of the mirrors
+	parent := map[string]string{}
+	var find func(p string) string
+	find = func(p string) string {
+		if parent[p] != p {
+			parent[p] = find(parent[p])
+		}
+		return parent[p]
+	}
+
+	byRoot := map[string]string{}
+	byPath := map[string]*mirrorRoots{}
+	for _, r := range roots {
+		if r.Shallow {
+			name := getPackageNameOfPath(repoDir, r.Path)
+			c.Log.WithFields(logrus.Fields{
+				"package": name,
+				"path":    r.Path,
+			}).Warn("Mirror is shallow. Skipping")
+			c.Report.Skipped(name, "Mirror is shallow and its history is incomplete")
+			continue
+		}
+		if pool := getPoolOfAlternates(poolDir, r.Alternates); len(r.Alternates) > 0 && len(pool) == 0 {
+			name := getPackageNameOfPath(repoDir, r.Path)
+			c.Log.WithFields(logrus.Fields{
+				"package":    name,
+				"path":       r.Path,
+				"alternates": strings.Join(r.Alternates, ", "),
+			}).Warn("Mirror borrows objects from somewhere else already. Skipping")
+			c.Report.Skipped(name, "Mirror borrows objects from "+strings.Join(r.Alternates, ", "))
+			continue
+		}
+
+		byPath[r.Path] = r
+		parent[r.Path] = r.Path
+		for _, root := range r.Roots {
+			if other, ok := byRoot[root]; ok {
+				parent[find(r.Path)] = find(other)
+				continue
+			}
+			byRoot[root] = r.Path
+		}
+	}
+
+	members := map[string][]string{}
+	for p := range byPath {
+		members[find(p)] = append(members[find(p)], p)
+	}
+
+	groups := []*poolGroup{}
+	for _, paths := range members {
+		if len(paths) < 2 {
+			continue
+		}
+		sort.Strings(paths)
+
+		g := &poolGroup{Mirrors: paths}
+		smallest := ""
+		for _, p := range paths {
+			if pool := getPoolOfAlternates(poolDir, byPath[p].Alternates); len(pool) > 0 && len(g.Pool) == 0 {
+				g.Pool = pool
+			}
+			for _, root := range byPath[p].Roots {
+				if len(smallest) == 0 || root < smallest {
+					smallest = root
+				}
+			}
+		}
+		if len(g.Pool) == 0 {
+			g.Pool = filepath.Join(poolDir, smallest+".git")
+		}
+		groups = append(groups, g)
+	}
+	sort.Sort(poolGroupsByPool(groups))
+
+	return groups
+}
+
+// worker is a single worker of the DedupeCommand.
+// Workers job is to move the objects of the mirrors of an object pool into the pool.
+func (c *DedupeController) worker(repoDir string, id int, jobs <-chan *poolGroup, results chan<- *dedupeResult) {
+	for g := range jobs {
+		start := time.Now()
+		r := &dedupeResult{
+			Group:     g,
+			Reclaimed: map[string]int64{},
+			Errors:    map[string]error{},
+			Worker:    id,
+		}
+
+		poolBefore, _ := dirSize(g.Pool)
+		if r.Err = downloader.InitPool(g.Pool); r.Err != nil {
+			r.Duration = time.Since(start)
+			results <- r
+			continue
+		}
+
+		for _, m := range g.Mirrors {
+			before, _ := dirSize(m)
+			if err := downloader.JoinPool(g.Pool, getPackageNameOfPath(repoDir, m), m); err != nil {
+				r.Errors[m] = err
+				continue
+			}
+			after, _ := dirSize(m)
+			r.Reclaimed[m] = before - after
+		}
+
+		// The pool is configured to never prune objects (see downloader.InitPool)
+		r.GCErr = downloader.GC(g.Pool)
+		poolAfter, _ := dirSize(g.Pool)
+		r.PoolGrowth = poolAfter - poolBefore
+		r.Duration = time.Since(start)
+		results <- r
+	}
+}
+
+// logResult logs the deduplication result r and records the outcome of every mirror in the run report.
+// The saved bytes (reclaimed bytes of all mirrors minus the growth of the pool) are returned.
+func (c *DedupeController) logResult(repoDir string, r *dedupeResult) int64 {
+	pool := getPackageNameOfPath(repoDir, r.Group.Pool)
+	var reclaimed int64
+	for _, m := range r.Group.Mirrors {
+		name := getPackageNameOfPath(repoDir, m)
+		fields := logrus.Fields{
+			"package": name,
+			"path":    m,
+			"pool":    pool,
+			"worker":  r.Worker,
+		}
+
+		if err, ok := r.Errors[m]; ok {
+			fields["error_class"] = downloader.ClassifyError(err)
+			c.Log.WithFields(fields).WithError(err).Error("Error while moving objects into the object pool")
+			c.Report.Failed(name, err)
+			continue
+		}
+		if r.Err != nil {
+			fields["error_class"] = downloader.ClassifyError(r.Err)
+			c.Log.WithFields(fields).WithError(r.Err).Error("Error while creating the object pool")
+			c.Report.Failed(name, r.Err)
+			continue
+		}
+
+		reclaimed += r.Reclaimed[m]
+		fields["reclaimed_bytes"] = r.Reclaimed[m]
+		c.Log.WithFields(fields).Info("Mirror deduplicated")
+		c.Report.Add(name, report.StatusDeduplicated, fmt.Sprintf("pool %s, reclaimed %s", pool, formatSize(r.Reclaimed[m])))
+	}
+
+	fields := logrus.Fields{
+		"pool":              pool,
+		"path":              r.Group.Pool,
+		"mirrors":           len(r.Group.Mirrors),
+		"pool_growth_bytes": r.PoolGrowth,
+		"saved_bytes":       reclaimed - r.PoolGrowth,
+		"duration":          r.Duration.Seconds(),
+		"worker":            r.Worker,
+	}
+	switch {
+	case r.Err != nil:
+	case r.GCErr != nil:
+		// The mirrors are deduplicated, only the garbage collection of the pool failed
+		fields["error_class"] = downloader.ClassifyError(r.GCErr)
+		c.Log.WithFields(fields).WithError(r.GCErr).Error("Error during garbage collection of the object pool")
+	default:
+		c.Log.WithFields(fields).Info("Object pool updated")
+	}
+
+	return reclaimed - r.PoolGrowth
+}
+
+// getPoolOfAlternates returns the path of the object pool in poolDir the alternates point to.
+// If no alternate points into poolDir, an empty string is returned.
+func getPoolOfAlternates(poolDir string, alternates []string) string {
+	for _, a := range alternates {
+		pool := filepath.Dir(a)
+		if filepath.Dir(pool) == filepath.Clean(poolDir) && filepath.Base(a) == "objects" {
+			return pool
+		}
+	}
+	return ""
+}
+
+// poolGroupsByPool sorts object pools by their path
+type poolGroupsByPool []*poolGroup
+
+func (l poolGroupsByPool) Len() int           { return len(l) }
+func (l poolGroupsByPool) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l poolGroupsByPool) Less(i, j int) bool { return l[i].Pool < l[j].Pool }
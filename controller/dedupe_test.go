@@ -0,0 +1,84 @@
+package controller_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/controller"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/report"
+)
+
+func TestDedupeController_Run(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	m, repoDir, cleanup := setupVerify(t)
+	defer cleanup()
+
+	// A mirror without a shared root commit
+	other := filepath.Join(repoDir, "psr", "log.git")
+	os.MkdirAll(other, 0755)
+	git(t, other, "init", "-q", "--bare")
+	tree := git(t, other, "mktree")
+	commit := git(t, other, "commit-tree", "-m", "Unrelated commit", tree)
+	git(t, other, "update-ref", "refs/heads/master", commit)
+
+	// A shallow fork
+	shallow := filepath.Join(repoDir, "fork", "console.git")
+	git(t, repoDir, "clone", "-q", "--bare", "--depth", "1", "file://"+filepath.Join(repoDir, "symfony", "console.git"), shallow)
+
+	// With dry run nothing will be changed
+	out := &bytes.Buffer{}
+	c := &DedupeController{
+		DryRun:      true,
+		Config:      m,
+		Log:         newDiscardLogger(),
+		NumOfWorker: 2,
+		Out:         out,
+	}
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "symfony/console") || !strings.Contains(lines[1], "twig/twig") {
+		t.Fatalf("Expected symfony/console and twig/twig to share a pool. Got %q", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "_pool")); !os.IsNotExist(err) {
+		t.Errorf("Expected no object pool during a dry run. Got %v", err)
+	}
+
+	r := report.New("dedupe")
+	c.DryRun = false
+	c.Report = r
+	c.Out = &bytes.Buffer{}
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if n := r.Counts()[report.StatusDeduplicated]; n != 2 {
+		t.Errorf("Expected 2 deduplicated mirrors. Got %d", n)
+	}
+	if skipped := r.Filter(report.StatusSkipped); len(skipped) != 1 || skipped[0].Package != "fork/console" {
+		t.Errorf("Expected the shallow fork to be skipped. Got %+v", skipped)
+	}
+
+	for _, p := range []string{"symfony/console", "twig/twig"} {
+		mirror := filepath.Join(repoDir, p+".git")
+		alternates, err := downloader.GetAlternates(mirror)
+		if err != nil || len(alternates) != 1 {
+			t.Errorf("Expected %s to borrow objects from an object pool. Got %v (%v)", p, alternates, err)
+		}
+		if failed := downloader.Verify(mirror); len(failed) > 0 {
+			t.Errorf("Expected %s to be valid after deduplication. Got %v", p, failed)
+		}
+	}
+	for _, m := range []string{other, shallow} {
+		if alternates, _ := downloader.GetAlternates(m); len(alternates) != 0 {
+			t.Errorf("Expected %s to keep its objects. Got %v", m, alternates)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/report"
+	"github.com/andygrunwald/perseus/state"
+)
+
+// GCController reflects the business logic and the Command interface to run the garbage collection
+// (incl. repacking) of all mirrors that exceed a threshold.
+// This command is independent from an human interface (CLI, HTTP, etc.)
+// The human interfaces will interact with this command.
+type GCController struct {
+	// Force runs the garbage collection for every mirror, regardless of the thresholds
+	Force bool
+	// Thresholds decide when a mirror needs a garbage collection.
+	// If nil, the thresholds of Config will be used.
+	Thresholds *downloader.GCThresholds
+	// Config is the main medusa configuration
+	Config *config.Medusa
+	// Log represents a logger to log messages
+	Log logrus.FieldLogger
+	// NumOfWorker is the number of worker used for concurrent actions (like garbage collecting git repositories)
+	NumOfWorker int
+	// Report is the run report. The outcome of every package will be recorded here.
+	// If nil, a new report will be created during Run.
+	Report *report.Report
+	// State is the history of all mirrored repositories.
+	// If nil, the state file of the repository directory will be used.
+	State *state.Store
+}
+
+// gcResult is the result of the garbage collection of a single repository
+type gcResult struct {
+	// Path reflects the file path of the repository like /tmp/perseus/git-mirror/symfony/console.git
+	Path string
+	// Reason describes why the garbage collection was needed. Empty if it was not needed.
+	Reason string
+	// Reclaimed is the number of bytes that were freed by the garbage collection
+	Reclaimed int64
+	// Err contains an error once there was one during the garbage collection
+	Err error
+	// Duration is the time the garbage collection took
+	Duration time.Duration
+	// Worker is the id of the worker that processed the garbage collection
+	Worker int
+}
+
+// Run is the business logic of GCCommand.
+func (c *GCController) Run() error {
+	if c.Report == nil {
+		c.Report = report.New("gc")
+	}
+	defer notifyReport(c.Log, c.Config, c.Report)
+	defer logReport(c.Log, c.Report)
+
+	if c.State == nil {
+		s, err := openState(c.Config)
+		if err != nil {
+			return err
+		}
+		c.State = s
+	}
+	defer saveState(c.Log, c.State)
+
+	if c.Thresholds == nil {
+		c.Thresholds = c.Config.GetGCThresholds()
+	}
+
+	repoDir := c.Config.GetString("repodir")
+	matches, err := findMirrors(repoDir)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		c.Log.WithFields(logrus.Fields{
+			"path": repoDir,
+		}).Info("No repositories found")
+		return nil
+	}
+
+	jobs := make(chan string, len(matches))
+	results := make(chan *gcResult, len(matches))
+	for w := 1; w <= c.NumOfWorker; w++ {
+		go c.worker(w, jobs, results)
+	}
+	for _, v := range matches {
+		jobs <- v
+	}
+	close(jobs)
+
+	var reclaimed int64
+	for a := 1; a <= len(matches); a++ {
+		r := <-results
+		logGCResult(c.Log, c.Report, c.State, getPackageNameOfPath(repoDir, r.Path), r)
+		reclaimed += r.Reclaimed
+	}
+
+	c.Log.WithFields(logrus.Fields{
+		"reclaimed_bytes": reclaimed,
+		"reclaimed":       formatSize(reclaimed),
+	}).Info("Garbage collection finished")
+	return nil
+}
+
+// worker is a single worker of the GCCommand.
+// Workers job is to garbage collect a bunch of repositories on disk.
+func (c *GCController) worker(id int, jobs <-chan string, results chan<- *gcResult) {
+	repoDir := c.Config.GetString("repodir")
+	for j := range jobs {
+		s, _ := c.State.Get(getPackageNameOfPath(repoDir, j))
+		r := collectGarbage(j, c.Thresholds, s.LastGCAt, c.Force)
+		r.Worker = id
+		results <- r
+	}
+}
+
+// collectGarbage runs the garbage collection of the repository at path, if a threshold of t is exceeded.
+// lastGC is the point in time of the last garbage collection.
+// With force, the garbage collection runs regardless of the thresholds.
+func collectGarbage(path string, t *downloader.GCThresholds, lastGC time.Time, force bool) *gcResult {
+	start := time.Now()
+	r := &gcResult{Path: path}
+
+	if force {
+		r.Reason = "forced"
+	} else {
+		stats, err := downloader.CountObjects(path)
+		if err != nil {
+			r.Err = err
+			return r
+		}
+		needed, reason := t.Needed(stats, lastGC)
+		if !needed {
+			return r
+		}
+		r.Reason = reason
+	}
+
+	before, err := dirSize(path)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if r.Err = downloader.GC(path); r.Err != nil {
+		r.Duration = time.Since(start)
+		return r
+	}
+	after, err := dirSize(path)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	r.Reclaimed = before - after
+	r.Duration = time.Since(start)
+	return r
+}
+
+// logGCResult logs the garbage collection result r of package name and records it in the run report rep and the state store s.
+func logGCResult(l logrus.FieldLogger, rep *report.Report, s *state.Store, name string, r *gcResult) {
+	fields := logrus.Fields{
+		"package": name,
+		"path":    r.Path,
+		"worker":  r.Worker,
+	}
+
+	switch {
+	case r.Err != nil:
+		fields["error_class"] = downloader.ClassifyError(r.Err)
+		l.WithFields(fields).WithError(r.Err).Error("Error during garbage collection")
+		rep.Failed(name, r.Err)
+	case len(r.Reason) == 0:
+		l.WithFields(fields).Debug("No garbage collection needed")
+	default:
+		fields["reason"] = r.Reason
+		fields["reclaimed_bytes"] = r.Reclaimed
+		fields["duration"] = r.Duration.Seconds()
+		l.WithFields(fields).Info("Garbage collection successful")
+		rep.Add(name, report.StatusCollected, fmt.Sprintf("%s, reclaimed %s", r.Reason, formatSize(r.Reclaimed)))
+		s.Collected(name, time.Now())
+	}
+}
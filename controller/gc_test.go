@@ -0,0 +1,50 @@
+package controller_test
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/controller"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/report"
+)
+
+func TestGCController_Run(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	m, _, cleanup := setupVerify(t)
+	defer cleanup()
+
+	c := &GCController{
+		Thresholds:  &downloader.GCThresholds{MaxAge: time.Hour},
+		Config:      m,
+		Log:         newDiscardLogger(),
+		NumOfWorker: 2,
+	}
+
+	// Mirrors that were never garbage collected exceed the maximum age.
+	// Afterwards they are below all thresholds.
+	for _, expected := range []int{2, 0} {
+		r := report.New("gc")
+		c.Report = r
+		if err := c.Run(); err != nil {
+			t.Fatalf("Expected no error. Got %s", err)
+		}
+		if n := r.Counts()[report.StatusCollected]; n != expected {
+			t.Errorf("Expected %d garbage collected mirrors. Got %d", expected, n)
+		}
+	}
+
+	// Force ignores the thresholds
+	r := report.New("gc")
+	c.Report = r
+	c.Force = true
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if n := r.Counts()[report.StatusCollected]; n != 2 {
+		t.Errorf("Expected 2 garbage collected mirrors. Got %d", n)
+	}
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/dependency"
+	"github.com/andygrunwald/perseus/report"
+)
+
+// GraphController reflects the business logic and the Command interface to render the dependency graph
+// of a single package or of all packages that are configured in the medusa configuration.
+// This command is independent from an human interface (CLI, HTTP, etc.)
+// The human interfaces will interact with this command.
+type GraphController struct {
+	// Package is the package to render the graph for.
+	// If empty, the graph of all configured packages will be rendered.
+	Package string
+	// Format is the output format of the graph (see dependency.GraphFormat* constants)
+	Format string
+	// Config is the main medusa configuration
+	Config *config.Medusa
+	// Log represents a logger to log messages
+	Log logrus.FieldLogger
+	// NumOfWorker is the number of worker used for concurrent actions (like resolving the dependency tree)
+	NumOfWorker int
+	// ResolverOptions are the settings for the dependency resolver.
+	// If nil, the settings of Config will be used.
+	ResolverOptions *dependency.ResolverOptions
+	// Out is the writer where the graph will be written to (default: os.Stdout)
+	Out io.Writer
+}
+
+// Run is the business logic of GraphCommand.
+func (c *GraphController) Run() error {
+	if !dependency.IsValidGraphFormat(c.Format) {
+		return fmt.Errorf("Unknown graph format \"%s\". Supported formats: %s, %s, %s", c.Format, dependency.GraphFormatDOT, dependency.GraphFormatJSON, dependency.GraphFormatMermaid)
+	}
+
+	var packages []string
+	if len(c.Package) > 0 {
+		packages = append(packages, c.Package)
+	} else {
+		packages = getConfiguredPackageNames(c.Config)
+	}
+
+	g, err := resolveDependencyGraph(c.Config, getResolverOptions(c.ResolverOptions, c.Config), c.Log, nil, c.NumOfWorker, packages)
+	if err != nil {
+		return err
+	}
+
+	out := c.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	return g.Write(out, c.Format)
+}
+
+// getConfiguredPackageNames returns the names of all packages that are configured
+// in the medusa configuration (sections "repositories" and "require").
+func getConfiguredPackageNames(cfg *config.Medusa) []string {
+	names := []string{}
+
+	// We don't respect the error here.
+	// No configured repositories is a valid configuration.
+	repoList, _ := cfg.GetNamesOfRepositories()
+	for _, r := range repoList {
+		names = append(names, r.Name)
+	}
+
+	return append(names, cfg.GetRequire()...)
+}
+
+// resolveDependencyGraph resolves the dependencies of all packages and returns the dependency graph.
+// Packages that are configured in the "repositories" section are added as roots without
+// resolving their dependencies. This is the same behaviour as during the "mirror" command.
+// o are the settings for the dependency resolver.
+// Skipped and failed packages are recorded in the run report rep (if not nil).
+func resolveDependencyGraph(cfg *config.Medusa, o *dependency.ResolverOptions, log logrus.FieldLogger, rep *report.Report, numOfWorker int, packages []string) (*dependency.Graph, error) {
+	toResolve := []*dependency.Package{}
+	configured := []string{}
+	for _, name := range packages {
+		p, err := dependency.NewPackage(name, "")
+		if err != nil {
+			return nil, err
+		}
+
+		// See AddController.Run why we don't respect the error here.
+		if u, _ := cfg.GetRepositoryURLOfPackage(p); u != nil {
+			configured = append(configured, p.Name)
+			continue
+		}
+		toResolve = append(toResolve, p)
+	}
+
+	if len(toResolve) == 0 {
+		g := dependency.NewGraph()
+		for _, name := range configured {
+			g.AddRoot(name)
+		}
+		return g, nil
+	}
+
+	pURL := "https://packagist.org/"
+	packagistClient, err := newPackagistClient(cfg, pURL)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := dependency.NewComposerResolver(resolverWorkers(cfg, numOfWorker), packagistClient, o)
+	if err != nil {
+		return nil, err
+	}
+	results := d.GetResultStream()
+	go d.Resolve(toResolve)
+
+	for r := range results {
+		if r.Error != nil {
+			logResolveError(log, rep, r, pURL)
+		}
+	}
+
+	g := d.GetGraph()
+	for _, name := range configured {
+		g.AddRoot(name)
+	}
+
+	return g, nil
+}
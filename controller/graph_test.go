@@ -0,0 +1,19 @@
+package controller_test
+
+import (
+	"testing"
+
+	. "github.com/andygrunwald/perseus/controller"
+)
+
+func TestGraphController_Run_WithUnknownFormat(t *testing.T) {
+	c := &GraphController{
+		Package: "symfony/console",
+		Format:  "svg",
+	}
+
+	err := c.Run()
+	if err == nil {
+		t.Fatal("Expected error while passing an unknown format. Got none")
+	}
+}
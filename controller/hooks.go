@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"path/filepath"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/hook"
+	"github.com/andygrunwald/perseus/report"
+)
+
+// runHooks fires the hook events of a single run (see hook.Dispatcher).
+// A nil runHooks fires nothing.
+type runHooks struct {
+	dispatcher *hook.Dispatcher
+	rep        *report.Report
+	repoDir    string
+}
+
+// startHooks returns the hooks of the configuration cfg for the run of report rep
+// and runs the hooks of the event "run-started" (before any package is processed).
+// Failed hooks are logged and recorded in rep, but don't fail the run.
+// Without configured hooks, nil will be returned.
+func startHooks(l logrus.FieldLogger, cfg *config.Medusa, rep *report.Report) (*runHooks, error) {
+	hooks, err := cfg.GetHooks()
+	if err != nil {
+		return nil, err
+	}
+	if len(hooks) == 0 {
+		return nil, nil
+	}
+
+	d := hook.NewDispatcher(hooks, func(h *hook.Hook, e *hook.Event, err error) {
+		l.WithFields(logrus.Fields{
+			"hook":    h.Name,
+			"event":   e.Type,
+			"package": e.Package,
+		}).WithError(err).Error("Error while running hook")
+		rep.HookFailed(e.Package, h.Name, e.Type, err)
+	})
+	d.Fire(&hook.Event{Type: hook.EventRunStarted, Command: rep.Command})
+	d.Wait()
+
+	return &runHooks{
+		dispatcher: d,
+		rep:        rep,
+		repoDir:    cfg.GetString("repodir"),
+	}, nil
+}
+
+// Package fires the event of type t for package name with the upstream URL repository.
+// If repository is empty, the upstream URL of the mirror is used (if the mirror exists).
+// err is the error of a failed package.
+func (h *runHooks) Package(t, name, repository string, err error) {
+	if h == nil {
+		return
+	}
+	path := filepath.Join(h.repoDir, name+".git")
+	if len(repository) == 0 {
+		repository, _ = downloader.GetRemoteURL(path)
+	}
+	e := &hook.Event{
+		Type:       t,
+		Command:    h.rep.Command,
+		Package:    name,
+		Path:       path,
+		Repository: repository,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	h.dispatcher.Fire(e)
+}
+
+// Finish waits for the hooks of all packages and runs the hooks of the event "run-finished"
+// with the number of packages per status of the run report.
+func (h *runHooks) Finish() {
+	if h == nil {
+		return
+	}
+	h.dispatcher.Wait()
+
+	counts := map[string]int{}
+	for s, n := range h.rep.Counts() {
+		counts[string(s)] = n
+	}
+	h.dispatcher.Fire(&hook.Event{Type: hook.EventRunFinished, Command: h.rep.Command, Counts: counts})
+	h.dispatcher.Wait()
+}
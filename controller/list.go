@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/state"
+)
+
+// Mirror reflects a single mirrored repository on disk.
+type Mirror struct {
+	// Package is the name of the package (e.g. "symfony/console")
+	Package string `json:"package"`
+	// Path is the path of the mirror on disk
+	Path string `json:"path"`
+	// URL is the URL of the upstream repository (remote "origin" of the mirror)
+	URL string `json:"url"`
+	// Size is the size of the mirror on disk in bytes
+	Size int64 `json:"size"`
+	// Branches is the number of branches of the mirror
+	Branches int `json:"branches"`
+	// Tags is the number of tags of the mirror
+	Tags int `json:"tags"`
+	// LastFetch is the point in time of the last successful fetch (clone or update)
+	LastFetch time.Time `json:"last_fetch"`
+	// InSatis is true if the mirror is part of the Satis configuration
+	InSatis bool `json:"in_satis"`
+	// InMedusa is true if the package is configured in the medusa configuration ("repositories" or "require")
+	InMedusa bool `json:"in_medusa"`
+}
+
+// IsOrphaned returns true if the mirror is neither part of the Satis nor of the medusa configuration.
+func (m *Mirror) IsOrphaned() bool {
+	return !m.InSatis && !m.InMedusa
+}
+
+// IsStale returns true if the last successful fetch of the mirror is older than d or unknown.
+func (m *Mirror) IsStale(d time.Duration) bool {
+	return m.LastFetch.IsZero() || time.Since(m.LastFetch) > d
+}
+
+// ListController reflects the business logic and the Command interface to list all mirrored repositories.
+// This command is independent from an human interface (CLI, HTTP, etc.)
+// The human interfaces will interact with this command.
+type ListController struct {
+	// Format is the output format (see OutputFormat* constants)
+	Format string
+	// Orphaned lists only mirrors that are neither part of the Satis nor of the medusa configuration
+	Orphaned bool
+	// NotInSatis lists only mirrors that are not part of the Satis configuration
+	NotInSatis bool
+	// StaleDays lists only mirrors without a successful fetch for more than StaleDays days (0 = no filter)
+	StaleDays int
+	// Config is the main medusa configuration
+	Config *config.Medusa
+	// Log represents a logger to log messages
+	Log logrus.FieldLogger
+	// State is the history of all mirrored repositories.
+	// If nil, the state file of the repository directory will be used.
+	State *state.Store
+	// Out is the writer where the list will be written to (default: os.Stdout)
+	Out io.Writer
+}
+
+// Run is the business logic of ListCommand.
+func (c *ListController) Run() error {
+	if !isValidOutputFormat(c.Format) {
+		return fmt.Errorf("Unknown output format \"%s\". Supported formats: %s, %s", c.Format, OutputFormatText, OutputFormatJSON)
+	}
+	if c.StaleDays < 0 {
+		return fmt.Errorf("Number of days for stale mirrors needs to be zero or greater. Got %d", c.StaleDays)
+	}
+
+	if c.State == nil {
+		s, err := openState(c.Config)
+		if err != nil {
+			return err
+		}
+		c.State = s
+	}
+
+	repoDir := c.Config.GetString("repodir")
+	matches, err := findMirrors(repoDir)
+	if err != nil {
+		return err
+	}
+
+	inSatis, err := c.getSatisFilter()
+	if err != nil {
+		return err
+	}
+	configured := map[string]bool{}
+	for _, name := range getConfiguredPackageNames(c.Config) {
+		configured[name] = true
+	}
+
+	mirrors := []*Mirror{}
+	for _, path := range matches {
+		m := c.getMirror(repoDir, path)
+		m.InSatis = inSatis(m.Package)
+		m.InMedusa = configured[m.Package]
+
+		if c.Orphaned && !m.IsOrphaned() {
+			continue
+		}
+		if c.NotInSatis && m.InSatis {
+			continue
+		}
+		if c.StaleDays > 0 && !m.IsStale(time.Duration(c.StaleDays)*24*time.Hour) {
+			continue
+		}
+		mirrors = append(mirrors, m)
+	}
+
+	out := c.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if c.Format == OutputFormatJSON {
+		b, err := json.MarshalIndent(mirrors, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", b)
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tURL\tSIZE\tBRANCHES\tTAGS\tLAST FETCH\tSATIS\tMEDUSA")
+	for _, m := range mirrors {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n", m.Package, orDash(m.URL), formatSize(m.Size), m.Branches, m.Tags, formatStateTime(m.LastFetch), formatBool(m.InSatis), formatBool(m.InMedusa))
+	}
+	return w.Flush()
+}
+
+// getMirror collects all information about the mirror at path.
+// Information that can't be determined (e.g. because the mirror is broken) is logged and left empty.
+func (c *ListController) getMirror(repoDir, path string) *Mirror {
+	m := &Mirror{
+		Package: getPackageNameOfPath(repoDir, path),
+		Path:    path,
+	}
+	logger := c.Log.WithFields(logrus.Fields{
+		"package": m.Package,
+		"path":    path,
+	})
+
+	var err error
+	if m.URL, err = downloader.GetRemoteURL(path); err != nil {
+		logger.WithError(err).Warn("Error while determining upstream URL of mirror")
+	}
+	if m.Size, err = dirSize(path); err != nil {
+		logger.WithError(err).Warn("Error while determining size of mirror")
+	}
+
+	refs, err := downloader.ListRefs(path)
+	if err != nil {
+		logger.WithError(err).Warn("Error while determining refs of mirror")
+	}
+	for _, ref := range refs {
+		switch {
+		case strings.HasPrefix(ref, "refs/heads/"):
+			m.Branches++
+		case strings.HasPrefix(ref, "refs/tags/"):
+			m.Tags++
+		}
+	}
+
+	// Mirrors created before the state file existed have no history.
+	// In this case the last "git fetch" is the best guess we have.
+	if s, ok := c.State.Get(m.Package); ok && !s.LastFetchSuccess.IsZero() {
+		m.LastFetch = s.LastFetchSuccess
+	} else if fi, err := os.Stat(filepath.Join(path, "FETCH_HEAD")); err == nil {
+		m.LastFetch = fi.ModTime()
+	}
+
+	return m
+}
+
+// getSatisFilter returns a function that reports if the mirror of a package is part of the Satis configuration.
+// If no Satis configuration is specified, no mirror is part of it.
+func (c *ListController) getSatisFilter() (func(p string) bool, error) {
+	satisConfig := c.Config.GetString("satisconfig")
+	if len(satisConfig) == 0 {
+		return func(p string) bool { return false }, nil
+	}
+
+	s, err := readSatisConfig(satisConfig)
+	if err != nil {
+		return nil, err
+	}
+	f := func(p string) bool {
+		return s.HasRepository(getLocalURLForRepository(c.Config, p))
+	}
+	return f, nil
+}
+
+// dirSize returns the size of all files in dir in bytes.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// formatSize returns the size b in bytes in a human readable format like 12.3 MiB.
+func formatSize(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// formatBool returns "yes" or "no".
+func formatBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
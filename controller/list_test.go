@@ -0,0 +1,108 @@
+package controller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/perseus/config"
+	. "github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/viper"
+)
+
+func TestListController_Run_WithUnknownFormat(t *testing.T) {
+	c := &ListController{
+		Format: "xml",
+		Out:    &bytes.Buffer{},
+	}
+
+	err := c.Run()
+	if err == nil {
+		t.Fatal("Expected error while passing an unknown format. Got none")
+	}
+}
+
+func TestListController_Run_Filter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "perseus-list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	repoDir := filepath.Join(dir, "git-mirror")
+	for _, p := range []string{"symfony/console", "twig/twig", "psr/log"} {
+		if err := os.MkdirAll(filepath.Join(repoDir, p+".git"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	satisConfig := filepath.Join(dir, "satis.json")
+	satis := `{"repositories": [{"type": "git", "url": "http://git.example.com/twig/twig.git"}]}`
+	if err := ioutil.WriteFile(satisConfig, []byte(satis), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	v := viper.New()
+	v.SetConfigType("json")
+	medusa := fmt.Sprintf(`{"repodir": %q, "satisconfig": %q, "satisurl": "http://git.example.com", "require": ["symfony/console"]}`, repoDir, satisConfig)
+	if err := v.ReadConfig(bytes.NewBufferString(medusa)); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := config.NewViperProvider(v)
+	m, _ := config.NewMedusa(p)
+	l := newDiscardLogger()
+
+	tests := []struct {
+		Controller *ListController
+		Packages   []string
+	}{
+		{&ListController{}, []string{"psr/log", "symfony/console", "twig/twig"}},
+		{&ListController{Orphaned: true}, []string{"psr/log"}},
+		{&ListController{NotInSatis: true}, []string{"psr/log", "symfony/console"}},
+		// Mirrors without a fetch are always stale
+		{&ListController{StaleDays: 7}, []string{"psr/log", "symfony/console", "twig/twig"}},
+	}
+
+	for _, tt := range tests {
+		out := &bytes.Buffer{}
+		c := tt.Controller
+		c.Format = OutputFormatJSON
+		c.Config = m
+		c.Log = l
+		c.Out = out
+		if err := c.Run(); err != nil {
+			t.Fatalf("Expected no error. Got %s", err)
+		}
+
+		var mirrors []*Mirror
+		if err := json.Unmarshal(out.Bytes(), &mirrors); err != nil {
+			t.Fatalf("Expected JSON output. Got %s", err)
+		}
+		got := []string{}
+		for _, m := range mirrors {
+			got = append(got, m.Package)
+		}
+		if !reflect.DeepEqual(got, tt.Packages) {
+			t.Errorf("Expected packages %v with filter %+v. Got %v", tt.Packages, c, got)
+		}
+	}
+}
+
+func TestMirror_IsStale(t *testing.T) {
+	m := &Mirror{}
+	if !m.IsStale(time.Hour) {
+		t.Error("Expected a mirror without fetch to be stale")
+	}
+	m.LastFetch = time.Now().Add(-2 * time.Hour)
+	if !m.IsStale(time.Hour) {
+		t.Error("Expected a mirror fetched two hours ago to be stale after one hour")
+	}
+	if m.IsStale(24 * time.Hour) {
+		t.Error("Expected a mirror fetched two hours ago not to be stale after one day")
+	}
+}
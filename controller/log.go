@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/dependency"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/report"
+	"github.com/andygrunwald/perseus/state"
+)
+
+// logResolveError logs the error of the resolver result r and records it in the run report rep (if not nil).
+// source is the URL of the Packagist instance.
+// Skipped packages are logged as warning, all other errors as error.
+func logResolveError(l logrus.FieldLogger, rep *report.Report, r *dependency.Result, source string) {
+	if dependency.IsSkipped(r.Error) {
+		reason := r.Error.(*dependency.SkipError).Reason
+		l.WithFields(logrus.Fields{
+			"package": r.Package.Name,
+			"reason":  reason,
+		}).Warn("Package skipped")
+		if rep != nil {
+			rep.Skipped(r.Package.Name, reason)
+		}
+		return
+	}
+
+	fields := logrus.Fields{
+		"package":     r.Package.Name,
+		"source":      source,
+		"error_class": downloader.ClassifyError(r.Error),
+	}
+	if r.Response != nil {
+		fields["responseCode"] = r.Response.StatusCode
+	}
+	l.WithFields(fields).WithError(r.Error).Error("Error while resolving dependencies of package")
+	if rep != nil {
+		rep.Failed(r.Package.Name, r.Error)
+	}
+}
+
+// logAbandoned logs the resolved package of r as warning and records it in the run report rep (may be nil),
+// if it is abandoned upstream.
+func logAbandoned(l logrus.FieldLogger, rep *report.Report, r *dependency.Result) {
+	if !r.Abandoned.IsAbandoned {
+		return
+	}
+
+	fields := logrus.Fields{
+		"package": r.Package.Name,
+	}
+	if len(r.Abandoned.Replacement) > 0 {
+		fields["replacement"] = r.Abandoned.Replacement
+	}
+	l.WithFields(fields).Warn("Package is abandoned upstream")
+	if rep != nil {
+		rep.Abandoned(r.Package.Name, r.Abandoned.Replacement)
+	}
+}
+
+// logDownloadResult logs the download result r and records it in the run report rep, the state store s and the metrics.
+// It returns true if the package is available on disk (mirrored successfully or existed already).
+// Packages that exist already are logged as warning, failures as error.
+func logDownloadResult(l logrus.FieldLogger, rep *report.Report, s *state.Store, r *downloader.Result) bool {
+	url := ""
+	if r.Package.Repository != nil {
+		url = r.Package.Repository.String()
+	}
+	fields := logrus.Fields{
+		"package":    r.Package.Name,
+		"repository": url,
+		"worker":     r.Worker,
+	}
+
+	if r.Error == nil {
+		fields["duration"] = r.Duration.Seconds()
+		l.WithFields(fields).Info("Mirroring of package successful")
+		rep.Add(r.Package.Name, report.StatusMirrored, "")
+		now := time.Now()
+		s.Added(r.Package.Name, url, rep.Command, now)
+		s.Fetched(r.Package.Name, now, r.RefCount, nil)
+		observeFetch(r.Package.Name, r.Duration, nil)
+		return true
+	}
+
+	class := downloader.ClassifyError(r.Error)
+	fields["error_class"] = class
+	if class == downloader.ErrorClassExists {
+		l.WithFields(fields).Warn("Package exists on disk. Try updating it instead. Skipping.")
+		rep.Skipped(r.Package.Name, "Package exists on disk")
+		return true
+	}
+
+	fields["duration"] = r.Duration.Seconds()
+	l.WithFields(fields).WithError(r.Error).Error("Error while mirroring package")
+	rep.Failed(r.Package.Name, r.Error)
+	observeFetch(r.Package.Name, r.Duration, r.Error)
+	return false
+}
+
+// logRefChanges logs the refs of package name that changed upstream during an update.
+// Changes that violate the tamper policy (see downloader.TamperPolicy) and protected refs
+// (see downloader.Options.ImmutableTags) are logged as warning and recorded in the run report rep.
+// All other changes are logged as debug message.
+func logRefChanges(l logrus.FieldLogger, rep *report.Report, name string, changes []*downloader.RefChange) {
+	for _, c := range changes {
+		fields := logrus.Fields{
+			"package": name,
+			"ref":     c.Ref,
+			"change":  c.Kind,
+			"old":     c.Old,
+			"new":     c.New,
+		}
+
+		switch c.Action {
+		case downloader.TamperWarn:
+			fields["action"] = c.Action
+			l.WithFields(fields).Warn("Ref tampered upstream")
+			rep.RefChanged(name, report.StatusTampered, c.Kind, c.String(), reportRef(c))
+		case downloader.TamperBlock:
+			// The failed update is logged already
+			fields["action"] = c.Action
+			l.WithFields(fields).Warn("Ref tampered upstream. Update blocked")
+			rep.RefChanged(name, report.StatusTampered, c.Kind, c.String()+" (blocked)", reportRef(c))
+			continue
+		}
+
+		if len(c.Archive) == 0 {
+			l.WithFields(fields).Debug("Ref changed upstream")
+			continue
+		}
+
+		fields["archive"] = c.Archive
+		if c.Kept {
+			l.WithFields(fields).Warn("Ref changed upstream. Kept the old state, because tags are immutable")
+		} else {
+			l.WithFields(fields).Warn("Ref changed upstream. Archived the old state")
+		}
+		rep.RefChanged(name, report.StatusProtected, c.Kind, c.String(), reportRef(c))
+	}
+}
+
+// reportRef returns the ref of the change c for the run report.
+func reportRef(c *downloader.RefChange) *report.Ref {
+	return &report.Ref{
+		Name:   c.Ref,
+		Old:    c.Old,
+		New:    c.New,
+		Action: c.Action,
+	}
+}
+
+// refChangeStrings returns the human readable representation of all changes.
+func refChangeStrings(changes []*downloader.RefChange) []string {
+	l := make([]string, 0, len(changes))
+	for _, c := range changes {
+		l = append(l, c.String())
+	}
+	return l
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/credentials"
+	"github.com/andygrunwald/perseus/dependency/repository"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/limit"
+	"github.com/andygrunwald/perseus/metrics"
+)
+
+// newPackagistClient returns a client for the Packagist instance.
+// Requests are authenticated with the credentials of cfg, throttled by the limits of cfg and recorded in the Packagist metrics.
+func newPackagistClient(cfg *config.Medusa, instance string) (*repository.PackagistClient, error) {
+	creds, err := cfg.GetCredentials()
+	if err != nil {
+		return nil, err
+	}
+	limits, err := cfg.GetLimits()
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{
+		Transport: credentials.NewTransport(creds, limit.NewTransport(limits.Metadata, metrics.NewPackagistTransport(nil))),
+	}
+	return repository.NewPackagist(instance, httpClient)
+}
+
+// observeFetch records the fetch (clone or update) of repository p that took d in the metrics.
+// If err is not nil, the fetch is recorded as failure.
+func observeFetch(p string, d time.Duration, err error) {
+	if err != nil {
+		metrics.ObserveFetchFailure(p, d, downloader.ClassifyError(err))
+		return
+	}
+	metrics.ObserveFetchSuccess(p, d)
+}
@@ -2,18 +2,14 @@ package controller
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
-	"strings"
 	"sync"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/andygrunwald/perseus/config"
 	"github.com/andygrunwald/perseus/dependency"
-	"github.com/andygrunwald/perseus/dependency/repository"
-	"github.com/andygrunwald/perseus/downloader"
-	"github.com/andygrunwald/perseus/types/set"
+	"github.com/andygrunwald/perseus/progress"
+	"github.com/andygrunwald/perseus/report"
+	"github.com/andygrunwald/perseus/state"
 )
 
 // MirrorController reflects the business logic and the Command interface to mirror all configured packages.
@@ -26,6 +22,18 @@ type MirrorController struct {
 	Log logrus.FieldLogger
 	// NumOfWorker is the number of worker used for concurrent actions (like resolving the dependency tree)
 	NumOfWorker int
+	// ResolverOptions are the settings for the dependency resolver.
+	// If nil, the settings of Config will be used.
+	ResolverOptions *dependency.ResolverOptions
+	// Report is the run report. The outcome of every package will be recorded here.
+	// If nil, a new report will be created during Run.
+	Report *report.Report
+	// State is the history of all mirrored repositories.
+	// If nil, the state file of the repository directory will be used.
+	State *state.Store
+	// Progress receives the progress events of the run (see progress.Bus).
+	// If nil, no progress is published.
+	Progress progress.Publisher
 
 	wg sync.WaitGroup
 }
@@ -33,28 +41,44 @@ type MirrorController struct {
 // Run is the business logic of MirrorCommand.
 func (c *MirrorController) Run() error {
 	c.wg = sync.WaitGroup{}
-	repos := set.New()
+	if c.Report == nil {
+		c.Report = report.New("mirror")
+	}
+	defer notifyReport(c.Log, c.Config, c.Report)
+	defer logReport(c.Log, c.Report)
+
+	hooks, err := startHooks(c.Log, c.Config, c.Report)
+	if err != nil {
+		return err
+	}
+	defer hooks.Finish()
+
+	if c.State == nil {
+		s, err := openState(c.Config)
+		if err != nil {
+			return err
+		}
+		c.State = s
+	}
+	defer saveState(c.Log, c.State)
 
 	// Get list of manual entered repositories
-	// and add them to the set
 	repoList, err := c.Config.GetNamesOfRepositories()
 	if err != nil {
 		if config.IsNoRepositories(err) {
 			c.Log.WithError(err).Info("Configuration")
 		} else {
-			c.Log.WithError(err).Info("")
+			c.Log.WithError(err).Error("Error while reading repositories from configuration")
 		}
 	}
 
-	for _, r := range repoList {
-		repos.Add(r)
-	}
-
 	// Get all required repositories and resolve those dependencies
 	pURL := "https://packagist.org/"
-	packagistClient, err := repository.NewPackagist(pURL, nil)
+	packagistClient, err := newPackagistClient(c.Config, pURL)
 	if err != nil {
-		c.Log.WithError(err).Info("")
+		c.Log.WithFields(logrus.Fields{
+			"source": pURL,
+		}).WithError(err).Error("Error while creating Packagist client")
 	}
 
 	// Lets get a dependency resolver.
@@ -62,7 +86,7 @@ func (c *MirrorController) Run() error {
 	// We set the queue length to the number of workers + 1. Why?
 	// With this every worker has work, when the queue is filled.
 	// During the add command, this is enough in most of the cases.
-	d, err := dependency.NewComposerResolver(c.NumOfWorker, packagistClient)
+	d, err := dependency.NewComposerResolver(resolverWorkers(c.Config, c.NumOfWorker), packagistClient, getResolverOptions(c.ResolverOptions, c.Config))
 	if err != nil {
 		return err
 	}
@@ -76,60 +100,46 @@ func (c *MirrorController) Run() error {
 		l = append(l, p)
 	}
 
-	go d.Resolve(l)
-
-	// Finally we collect all the results of the work.
-	for p := range results {
-		if p.Error != nil {
-			c.Log.WithFields(logrus.Fields{
-				"package": p.Package.Name,
-				"responseCode": p.Response.StatusCode,
-			}).WithError(p.Error).Info("Error while resolving dependencies of package")
-			continue
-		}
-
-		repos.Add(p.Package)
+	loader, err := newGitDownloader(c.Config, c.NumOfWorker, c.Progress)
+	if err != nil {
+		return err
 	}
 
+	// Resolving and downloading run as a pipeline:
+	// Every package is cloned as soon as it is resolved, while the resolver continues with the next packages.
 	c.Log.WithFields(logrus.Fields{
-		"amountPackages": repos.Len(),
-		"amountWorker":   c.NumOfWorker,
+		"amountWorker": gitWorkers(c.Config, c.NumOfWorker),
 	}).Info("Start concurrent download process")
-	loader, err := downloader.NewGitDownloader(c.NumOfWorker, c.Config.GetString("repodir"))
-	if err != nil {
-		return err
-	}
+	queue := newDownloadQueue(c.Config, c.Log, c.Report, c.Progress, gitWorkers(c.Config, c.NumOfWorker))
+	loader.DownloadStream(queue.Packages())
 
-	loaderResults := loader.GetResultStream()
-	flatten := repos.Flatten()
-	loaderList := make([]*dependency.Package, 0, len(flatten))
-	for _, item := range repos.Flatten() {
-		loaderList = append(loaderList, item.(*dependency.Package))
-	}
-	loader.Download(loaderList)
+	total := make(chan int, 1)
+	go func() {
+		for _, r := range repoList {
+			queue.Add(r)
+		}
 
-	var satisRepositories []string
-	for i := 1; i <= int(repos.Len()); i++ {
-		v := <-loaderResults
-		if v.Error != nil {
-			if os.IsExist(v.Error) {
-				c.Log.WithFields(logrus.Fields{
-					"package": v.Package.Name,
-				}).Info("Package exists on disk. Try updating it instead. Skipping.")
-			} else {
-				c.Log.WithFields(logrus.Fields{
-					"package": v.Package.Name,
-				}).WithError(v.Error).Info("Error while mirroring package")
-				// If we have an error, we don't need to add it to satis repositories
+		go d.Resolve(l)
+		for p := range results {
+			if p.Error != nil {
+				logResolveError(c.Log, c.Report, p, pURL)
 				continue
 			}
-		} else {
-			c.Log.WithFields(logrus.Fields{
-				"package": v.Package.Name,
-			}).Info("Mirroring of package successful")
+			logAbandoned(c.Log, c.Report, p)
+			progress.Publish(c.Progress, progress.EventResolved, p.Package.Name)
+			queue.Add(p.Package)
 		}
 
-		satisRepositories = append(satisRepositories, c.getLocalURLForRepository(v.Package.Name))
+		n := queue.Close()
+		c.Log.WithFields(logrus.Fields{
+			"amountPackages": n,
+		}).Info("All packages resolved and queued for download")
+		total <- n
+	}()
+
+	var satisRepositories []string
+	for _, p := range waitForDownloads(c.Log, c.Report, c.State, c.Progress, hooks, loader, total) {
+		satisRepositories = append(satisRepositories, getLocalURLForRepository(c.Config, p.Name))
 	}
 	loader.Close()
 
@@ -138,23 +148,6 @@ func (c *MirrorController) Run() error {
 	return err
 }
 
-func (c *MirrorController) getLocalURLForRepository(p string) string {
-	var r string
-
-	satisURL := c.Config.GetString("satisurl")
-	repoDir := c.Config.GetString("repodir")
-
-	if len(satisURL) > 0 {
-		r = fmt.Sprintf("%s/%s.git", satisURL, p)
-	} else {
-		t := fmt.Sprintf("%s/%s.git", repoDir, p)
-		t = strings.TrimLeft(filepath.Clean(t), "/")
-		r = fmt.Sprintf("file:///%s", t)
-	}
-
-	return r
-}
-
 func (c *MirrorController) writeSatisConfig(satisRepositories ...string) error {
 	// Write Satis file
 	satisConfig := c.Config.GetString("satisconfig")
@@ -163,19 +156,9 @@ func (c *MirrorController) writeSatisConfig(satisRepositories ...string) error {
 		return nil
 	}
 
-	satisContent, err := ioutil.ReadFile(satisConfig)
-	if err != nil {
-		return fmt.Errorf("Can't read Satis configuration %s: %s", satisConfig, err)
-	}
-
-	j, err := config.NewJSONProvider(satisContent)
+	s, err := readSatisConfig(satisConfig)
 	if err != nil {
-		return fmt.Errorf("Error while creating JSONProvider: %s", err)
-	}
-
-	s, err := config.NewSatis(j)
-	if err != nil {
-		return fmt.Errorf("Error while creating Satis object: %s", err)
+		return err
 	}
 
 	s.AddRepositories(satisRepositories...)
@@ -0,0 +1,94 @@
+package controller_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andygrunwald/perseus/config"
+	. "github.com/andygrunwald/perseus/controller"
+	"github.com/andygrunwald/perseus/progress"
+	"github.com/andygrunwald/perseus/report"
+	"github.com/spf13/viper"
+)
+
+func TestMirrorController_Run_Pipeline(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	upstream := filepath.Join(dir, "upstream")
+	os.MkdirAll(upstream, 0755)
+	git(t, upstream, "init", "-q")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+
+	// More packages than the download queue can hold with a single worker.
+	// The first package is configured twice and must be cloned only once.
+	names := []string{"vendor/a", "vendor/b", "vendor/c", "vendor/d", "vendor/e", "vendor/f"}
+	entries := []string{fmt.Sprintf(`{"name": "vendor/a", "url": %q}`, upstream)}
+	for _, name := range names {
+		entries = append(entries, fmt.Sprintf(`{"name": %q, "url": %q}`, name, upstream))
+	}
+
+	repoDir := filepath.Join(dir, "git-mirror")
+	v := viper.New()
+	v.SetConfigType("json")
+	medusa := fmt.Sprintf(`{"repodir": %q, "repositories": [%s]}`, repoDir, strings.Join(entries, ", "))
+	if err := v.ReadConfig(bytes.NewBufferString(medusa)); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := config.NewViperProvider(v)
+	m, _ := config.NewMedusa(p)
+
+	bus := progress.NewBus()
+	tracker := progress.NewTracker()
+	events := bus.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		for e := range events {
+			tracker.Apply(e)
+		}
+		close(done)
+	}()
+
+	r := report.New("mirror")
+	c := &MirrorController{
+		Config:      m,
+		Log:         newDiscardLogger(),
+		NumOfWorker: 1,
+		Report:      r,
+		Progress:    bus,
+	}
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	bus.Close()
+	<-done
+
+	if mirrored := r.Filter(report.StatusMirrored); len(mirrored) != len(names) {
+		t.Errorf("Expected %d mirrored packages. Got %+v", len(names), mirrored)
+	}
+	if entries := r.Entries(); len(entries) != len(names) {
+		t.Errorf("Expected every package only once in the report. Got %+v", entries)
+	}
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(repoDir, name+".git")); err != nil {
+			t.Errorf("Expected a mirror of %s. Got %s", name, err)
+		}
+	}
+
+	if s := tracker.Snapshot(); s.Queued != len(names) || s.Done != len(names) || len(s.Active) != 0 {
+		t.Errorf("Expected %d queued and done packages without active transfers. Got %s", len(names), progress.Summary(s))
+	}
+}
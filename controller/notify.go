@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/notify"
+	"github.com/andygrunwald/perseus/report"
+)
+
+// notifyReport sends the noteworthy events of the run report r to all notifiers of the configuration cfg.
+// Nothing is sent if the run has no events.
+// Errors are logged only, because a failing notification should not fail the run.
+func notifyReport(l logrus.FieldLogger, cfg *config.Medusa, r *report.Report) {
+	n := notify.New(r)
+	if len(n.Events) == 0 {
+		return
+	}
+
+	notifiers, err := cfg.GetNotifiers()
+	if err != nil {
+		l.WithError(err).Error("Error while reading the notifications")
+		return
+	}
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(n); err != nil {
+			l.WithFields(logrus.Fields{
+				"command": r.Command,
+				"events":  len(n.Events),
+			}).WithError(err).Error("Error while sending a notification")
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/dependency"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/limit"
+	"github.com/andygrunwald/perseus/progress"
+	"github.com/andygrunwald/perseus/report"
+)
+
+// newGitDownloader returns a git downloader with numOfWorker workers that respects
+// the credentials and the per package options of the configuration cfg.
+// The progress of all git transfers is published to pub (may be nil).
+func newGitDownloader(cfg *config.Medusa, numOfWorker int, pub progress.Publisher) (downloader.Downloader, error) {
+	creds, err := cfg.GetCredentials()
+	if err != nil {
+		return nil, err
+	}
+	options, err := cfg.GetDownloadOptions()
+	if err != nil {
+		return nil, err
+	}
+	options.Default = withProgress(options.Default, pub)
+	for name, opts := range options.Packages {
+		options.Packages[name] = withProgress(opts, pub)
+	}
+	return downloader.NewGitDownloader(gitWorkers(cfg, numOfWorker), cfg.GetString("repodir"), creds, options)
+}
+
+// gitWorkers returns the number of workers that clone or update mirrors:
+// The configuration key "limits.git-workers" of cfg or numOfWorker, if not configured.
+func gitWorkers(cfg *config.Medusa, numOfWorker int) int {
+	// An invalid configuration is reported by every place that needs the limits
+	l, err := cfg.GetLimits()
+	if err != nil {
+		return numOfWorker
+	}
+	return limit.Workers(l.GitWorkers, numOfWorker)
+}
+
+// gitHosts returns the caps of the concurrent git transfers per host of the configuration key "limits.hosts" of cfg.
+// If not configured, the hosts are unlimited (nil).
+func gitHosts(cfg *config.Medusa) *limit.Hosts {
+	// An invalid configuration is reported by every place that needs the limits
+	l, err := cfg.GetLimits()
+	if err != nil || l == nil {
+		return nil
+	}
+	return l.Hosts
+}
+
+// resolverWorkers returns the number of workers that resolve dependencies:
+// The configuration key "limits.resolver-workers" of cfg or numOfWorker, if not configured.
+func resolverWorkers(cfg *config.Medusa, numOfWorker int) int {
+	l, err := cfg.GetLimits()
+	if err != nil {
+		return numOfWorker
+	}
+	return limit.Workers(l.ResolverWorkers, numOfWorker)
+}
+
+// withProgress returns a copy of opts that publishes the progress of the git transfers to pub.
+// If pub is nil, opts will be returned.
+func withProgress(opts *downloader.Options, pub progress.Publisher) *downloader.Options {
+	if pub == nil {
+		return opts
+	}
+	o := downloader.Options{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Progress = pub
+	return &o
+}
+
+// getDownloadOptions returns the options for clones and updates of package name.
+// If the package is not configured, the default options will be returned.
+func getDownloadOptions(cfg *config.Medusa, name string) (*downloader.Options, error) {
+	o, err := cfg.GetDownloadOptions()
+	if err != nil {
+		return nil, err
+	}
+	return o.Get(name), nil
+}
+
+// skipDisabledPackages returns packages without the packages that are disabled in the configuration cfg.
+// Every disabled package is logged and recorded as skipped in the run report rep.
+func skipDisabledPackages(cfg *config.Medusa, l logrus.FieldLogger, rep *report.Report, packages []*dependency.Package) ([]*dependency.Package, error) {
+	enabled := make([]*dependency.Package, 0, len(packages))
+	for _, p := range packages {
+		r, err := cfg.GetRepositoryConfig(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil && r.Disabled {
+			logDisabledPackage(l, rep, r)
+			continue
+		}
+		enabled = append(enabled, p)
+	}
+	return enabled, nil
+}
+
+// logDisabledPackage logs the disabled package r and records it as skipped in the run report rep.
+func logDisabledPackage(l logrus.FieldLogger, rep *report.Report, r *config.RepositoryConfig) {
+	fields := logrus.Fields{
+		"package": r.Name,
+	}
+	if len(r.Notes) > 0 {
+		fields["notes"] = r.Notes
+	}
+	l.WithFields(fields).Info("Package is disabled. Skipping.")
+	rep.Skipped(r.Name, "Package is disabled in the configuration")
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/dependency"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/hook"
+	"github.com/andygrunwald/perseus/progress"
+	"github.com/andygrunwald/perseus/report"
+	"github.com/andygrunwald/perseus/state"
+)
+
+// downloadQueue streams packages into a downloader as soon as they are known (e.g. resolved).
+// Packages are deduplicated by name and disabled packages are skipped.
+// The queue is bounded: If all workers are busy, Add blocks until a worker is free.
+// This way, the resolver never runs far ahead of the downloads.
+// A downloadQueue is not safe for concurrent use.
+type downloadQueue struct {
+	cfg *config.Medusa
+	log logrus.FieldLogger
+	rep *report.Report
+	pub progress.Publisher
+
+	packages chan *dependency.Package
+	seen     map[string]bool
+	count    int
+}
+
+// newDownloadQueue returns a queue for numOfWorker download workers.
+// Every queued package is published to pub (may be nil).
+func newDownloadQueue(cfg *config.Medusa, l logrus.FieldLogger, rep *report.Report, pub progress.Publisher, numOfWorker int) *downloadQueue {
+	return &downloadQueue{
+		cfg:      cfg,
+		log:      l,
+		rep:      rep,
+		pub:      pub,
+		packages: make(chan *dependency.Package, numOfWorker),
+		seen:     map[string]bool{},
+	}
+}
+
+// Packages returns the channel for downloader.Downloader.DownloadStream.
+func (q *downloadQueue) Packages() <-chan *dependency.Package {
+	return q.packages
+}
+
+// Add queues package p for the download.
+// Packages that were added before and disabled packages are ignored.
+// If the configuration of p is invalid, p is recorded as failed.
+func (q *downloadQueue) Add(p *dependency.Package) {
+	if q.seen[p.Name] {
+		return
+	}
+	q.seen[p.Name] = true
+
+	l, err := skipDisabledPackages(q.cfg, q.log, q.rep, []*dependency.Package{p})
+	if err != nil {
+		q.log.WithField("package", p.Name).WithError(err).Error("Error while reading the configuration of package")
+		q.rep.Failed(p.Name, err)
+		return
+	}
+	for _, p := range l {
+		progress.Publish(q.pub, progress.EventQueued, p.Name)
+		q.packages <- p
+		q.count++
+	}
+}
+
+// Close closes the queue and returns the number of queued packages.
+func (q *downloadQueue) Close() int {
+	close(q.packages)
+	return q.count
+}
+
+// waitForDownloads logs and records the results of loader (see logDownloadResult) until all packages are downloaded.
+// The number of packages is received via total once all packages are queued.
+// The outcome of every package is published to pub (may be nil) and fires the hooks (may be nil).
+// It returns the packages that are available on disk.
+func waitForDownloads(l logrus.FieldLogger, rep *report.Report, s *state.Store, pub progress.Publisher, hooks *runHooks, loader downloader.Downloader, total <-chan int) []*dependency.Package {
+	results := loader.GetResultStream()
+	available := []*dependency.Package{}
+	for n, done := -1, 0; n < 0 || done < n; {
+		select {
+		case n = <-total:
+			// A nil channel blocks forever, total is received only once
+			total = nil
+		case r := <-results:
+			done++
+			if logDownloadResult(l, rep, s, r) {
+				available = append(available, r.Package)
+			}
+			t := downloadEventType(r)
+			progress.Publish(pub, t, r.Package.Name)
+			fireDownloadHooks(hooks, t, r)
+		}
+	}
+	return available
+}
+
+// downloadEventType returns the type of the progress event for the download result r.
+// Packages that exist on disk already are skipped (see logDownloadResult).
+func downloadEventType(r *downloader.Result) string {
+	switch {
+	case r.Error == nil:
+		return progress.EventDone
+	case downloader.ClassifyError(r.Error) == downloader.ErrorClassExists:
+		return progress.EventSkipped
+	}
+	return progress.EventFailed
+}
+
+// fireDownloadHooks fires the hooks of the download result r with the progress event type t (see downloadEventType).
+// Packages that exist on disk already fire no hooks.
+func fireDownloadHooks(hooks *runHooks, t string, r *downloader.Result) {
+	repository := ""
+	if r.Package.Repository != nil {
+		repository = r.Package.Repository.String()
+	}
+	switch t {
+	case progress.EventDone:
+		hooks.Package(hook.EventPackageAdded, r.Package.Name, repository, nil)
+	case progress.EventFailed:
+		hooks.Package(hook.EventPackageFailed, r.Package.Name, repository, r.Error)
+	}
+}
@@ -0,0 +1,310 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/dependency"
+	"github.com/andygrunwald/perseus/report"
+	"github.com/andygrunwald/perseus/state"
+)
+
+const (
+	// ReconcileActionClone clones a configured package that is not mirrored yet
+	ReconcileActionClone = "clone"
+	// ReconcileActionDelete deletes a mirror that is not referenced anymore
+	ReconcileActionDelete = "delete"
+	// ReconcileActionSatisAdd adds a mirror to the Satis configuration
+	ReconcileActionSatisAdd = "satis-add"
+	// ReconcileActionSatisRemove removes a repository of a deleted mirror from the Satis configuration
+	ReconcileActionSatisRemove = "satis-remove"
+)
+
+// ReconcileController reflects the business logic and the Command interface to bring
+// the repository directory, the medusa configuration and the Satis configuration back in sync.
+//
+// Packages that are configured in the medusa configuration, but not mirrored, will be cloned.
+// Mirrors that are missing in the Satis configuration will be added, repositories in the
+// Satis configuration that point to a not existing mirror will be removed.
+// With Prune, mirrors that are not referenced by the medusa configuration (incl. dependencies) will be deleted.
+//
+// This command is independent from an human interface (CLI, HTTP, etc.)
+// The human interfaces will interact with this command.
+type ReconcileController struct {
+	// DryRun prints the differences without changing anything
+	DryRun bool
+	// Prune deletes mirrors that are not referenced by the medusa configuration (incl. dependencies)
+	Prune bool
+	// Config is the main medusa configuration
+	Config *config.Medusa
+	// Log represents a logger to log messages
+	Log logrus.FieldLogger
+	// NumOfWorker is the number of worker used for concurrent actions (like resolving the dependency tree)
+	NumOfWorker int
+	// ResolverOptions are the settings for the dependency resolver.
+	// If nil, the settings of Config will be used.
+	ResolverOptions *dependency.ResolverOptions
+	// Report is the run report. The outcome of every package will be recorded here.
+	// If nil, a new report will be created during Run.
+	Report *report.Report
+	// State is the history of all mirrored repositories.
+	// If nil, the state file of the repository directory will be used.
+	State *state.Store
+	// Out is the writer where the differences will be written to (default: os.Stdout)
+	Out io.Writer
+}
+
+// Run is the business logic of ReconcileCommand.
+func (c *ReconcileController) Run() error {
+	if c.Report == nil {
+		c.Report = report.New("reconcile")
+	}
+	defer notifyReport(c.Log, c.Config, c.Report)
+	defer logReport(c.Log, c.Report)
+
+	if c.State == nil {
+		s, err := openState(c.Config)
+		if err != nil {
+			return err
+		}
+		c.State = s
+	}
+	if !c.DryRun {
+		defer saveState(c.Log, c.State)
+	}
+
+	out := c.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	repoDir := c.Config.GetString("repodir")
+	matches, err := findMirrors(repoDir)
+	if err != nil {
+		return err
+	}
+	mirrored := map[string]string{}
+	for _, path := range matches {
+		mirrored[getPackageNameOfPath(repoDir, path)] = path
+	}
+
+	// Configured packages that were never cloned
+	missing := c.getMissingPackages(mirrored)
+	for _, p := range missing {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", ReconcileActionClone, p.Name, p.Repository.String())
+	}
+	cloned := missing
+	if !c.DryRun && len(missing) > 0 {
+		cloned = c.clone(missing)
+	}
+	for _, p := range cloned {
+		mirrored[p.Name] = filepath.Join(repoDir, p.Name+".git")
+	}
+
+	// Mirrors that are not referenced anymore
+	if c.Prune {
+		unreferenced, err := c.getUnreferencedMirrors(mirrored)
+		if err != nil {
+			return err
+		}
+		for _, name := range unreferenced {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", ReconcileActionDelete, name, mirrored[name])
+			if !c.DryRun && !c.delete(name, mirrored[name]) {
+				continue
+			}
+			delete(mirrored, name)
+		}
+	}
+
+	return c.reconcileSatis(w, mirrored)
+}
+
+// getMissingPackages returns all packages of the medusa configuration that are not mirrored.
+// The repository URL is taken from the configuration or, if not configured, requested from Packagist.
+// Packages without a repository URL are logged and recorded as failed, disabled packages as skipped.
+func (c *ReconcileController) getMissingPackages(mirrored map[string]string) []*dependency.Package {
+	missing := []*dependency.Package{}
+	for _, name := range getConfiguredPackageNames(c.Config) {
+		if _, ok := mirrored[name]; ok {
+			continue
+		}
+
+		r, err := c.Config.GetRepositoryConfig(name)
+		if err != nil {
+			c.Log.WithField("package", name).WithError(err).Error("Invalid package in configuration")
+			c.Report.Failed(name, err)
+			continue
+		}
+		if r != nil && r.Disabled {
+			logDisabledPackage(c.Log, c.Report, r)
+			continue
+		}
+
+		p, err := dependency.NewPackage(name, "")
+		if err != nil {
+			c.Log.WithField("package", name).WithError(err).Error("Invalid package in configuration")
+			c.Report.Failed(name, err)
+			continue
+		}
+
+		// See AddController.Run why we don't respect the error here.
+		p.Repository, _ = c.Config.GetRepositoryURLOfPackage(p)
+		if p.Repository == nil {
+			p, err = getURLOfPackageFromPackagist(c.Config, p)
+			if err != nil {
+				c.Log.WithField("package", name).WithError(err).Error("Error while determining repository URL of package")
+				c.Report.Failed(name, err)
+				continue
+			}
+		}
+		missing = append(missing, p)
+	}
+
+	return missing
+}
+
+// clone mirrors the packages and returns all packages that were mirrored successfully.
+func (c *ReconcileController) clone(packages []*dependency.Package) []*dependency.Package {
+	d, err := newGitDownloader(c.Config, c.NumOfWorker, nil)
+	if err != nil {
+		c.Log.WithError(err).Error("Error while creating downloader")
+		for _, p := range packages {
+			c.Report.Failed(p.Name, err)
+		}
+		return nil
+	}
+
+	results := d.GetResultStream()
+	d.Download(packages)
+
+	cloned := []*dependency.Package{}
+	for i := 1; i <= len(packages); i++ {
+		v := <-results
+		if logDownloadResult(c.Log, c.Report, c.State, v) {
+			cloned = append(cloned, v.Package)
+		}
+	}
+	d.Close()
+
+	return cloned
+}
+
+// getUnreferencedMirrors returns the names of all mirrors that are not part of the dependency graph
+// of the medusa configuration.
+// If the dependencies of at least one package couldn't be resolved, an error is returned.
+// Otherwise all mirrors of the unresolved dependencies would be considered as unreferenced.
+func (c *ReconcileController) getUnreferencedMirrors(mirrored map[string]string) ([]string, error) {
+	failed := c.Report.Counts()[report.StatusFailed]
+	g, err := resolveDependencyGraph(c.Config, getResolverOptions(c.ResolverOptions, c.Config), c.Log, c.Report, c.NumOfWorker, getConfiguredPackageNames(c.Config))
+	if err != nil {
+		return nil, err
+	}
+	if c.Report.Counts()[report.StatusFailed] > failed {
+		return nil, fmt.Errorf("Dependencies of at least one package couldn't be resolved. Refusing to delete unreferenced mirrors")
+	}
+
+	unreferenced := []string{}
+	for name := range mirrored {
+		if !g.Exists(name) {
+			unreferenced = append(unreferenced, name)
+		}
+	}
+	sort.Strings(unreferenced)
+
+	return unreferenced, nil
+}
+
+// delete deletes the mirror of package name at path.
+// It returns true if the mirror was deleted.
+func (c *ReconcileController) delete(name, path string) bool {
+	if err := os.RemoveAll(path); err != nil {
+		c.Log.WithFields(logrus.Fields{
+			"package": name,
+			"path":    path,
+		}).WithError(err).Error("Error while deleting mirror")
+		c.Report.Failed(name, err)
+		return false
+	}
+
+	c.Log.WithFields(logrus.Fields{
+		"package": name,
+		"path":    path,
+	}).Info("Mirror deleted")
+	c.State.Remove(name)
+	return true
+}
+
+// reconcileSatis adds all mirrors to the Satis configuration and removes repositories of not existing mirrors.
+// Repositories that don't point to a mirror of perseus (e.g. external repositories) are kept.
+func (c *ReconcileController) reconcileSatis(w io.Writer, mirrored map[string]string) error {
+	satisConfig := c.Config.GetString("satisconfig")
+	if len(satisConfig) == 0 {
+		c.Log.Info("No Satis configuration specified. Skipping to reconcile the satis configuration.")
+		return nil
+	}
+
+	s, err := readSatisConfig(satisConfig)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	names := make([]string, 0, len(mirrored))
+	for name := range mirrored {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		u := getLocalURLForRepository(c.Config, name)
+		if s.HasRepository(u) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", ReconcileActionSatisAdd, name, u)
+		s.AddRepository(u)
+		changed = true
+	}
+
+	repositories := s.GetRepositoriesAsSlice()
+	sort.Sort(satisRepositoriesByURL(repositories))
+	for _, r := range repositories {
+		name, ok := getPackageNameOfLocalURL(c.Config, r.URL)
+		if !ok {
+			continue
+		}
+		if _, ok := mirrored[name]; ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", ReconcileActionSatisRemove, name, r.URL)
+		s.RemoveRepository(r.URL)
+		changed = true
+	}
+
+	if !changed || c.DryRun {
+		return nil
+	}
+
+	err = s.WriteFile(satisConfig, 0644)
+	if err != nil {
+		return fmt.Errorf("Writing Satis configuration to %s failed: %s", satisConfig, err)
+	}
+
+	c.Log.WithFields(logrus.Fields{
+		"path": satisConfig,
+	}).Info("Satis configuration successful written")
+	return nil
+}
+
+// satisRepositoriesByURL sorts Satis repositories by URL
+type satisRepositoriesByURL []config.SatisRepository
+
+func (l satisRepositoriesByURL) Len() int           { return len(l) }
+func (l satisRepositoriesByURL) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l satisRepositoriesByURL) Less(i, j int) bool { return l[i].URL < l[j].URL }
@@ -0,0 +1,130 @@
+package controller_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	. "github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/viper"
+)
+
+// setupReconcile creates a repository directory with the mirror twig/twig and a Satis configuration
+// with a repository of the deleted mirror old/gone and an external repository.
+// medusa is the content of the medusa configuration. %s will be replaced by the repository directory and the Satis configuration.
+func setupReconcile(t *testing.T, medusa string) (*config.Medusa, string, func()) {
+	dir, err := ioutil.TempDir("", "perseus-reconcile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoDir := filepath.Join(dir, "git-mirror")
+	if err := os.MkdirAll(filepath.Join(repoDir, "twig", "twig.git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	satisConfig := filepath.Join(dir, "satis.json")
+	satis := `{"repositories": [{"type": "git", "url": "http://git.example.com/old/gone.git"}, {"type": "vcs", "url": "https://github.com/my-vendor/private.git"}]}`
+	if err := ioutil.WriteFile(satisConfig, []byte(satis), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	v := viper.New()
+	v.SetConfigType("json")
+	if err := v.ReadConfig(bytes.NewBufferString(fmt.Sprintf(medusa, repoDir, satisConfig))); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := config.NewViperProvider(v)
+	m, _ := config.NewMedusa(p)
+
+	return m, satisConfig, func() { os.RemoveAll(dir) }
+}
+
+func newDiscardLogger() logrus.FieldLogger {
+	l := logrus.New()
+	l.Out = ioutil.Discard
+	return l
+}
+
+func TestReconcileController_Run_DryRun(t *testing.T) {
+	m, satisConfig, cleanup := setupReconcile(t, `{
+		"repodir": %q,
+		"satisconfig": %q,
+		"satisurl": "http://git.example.com",
+		"repositories": [{"name": "symfony/console", "url": "https://github.com/symfony/console.git"}]
+	}`)
+	defer cleanup()
+	before, _ := ioutil.ReadFile(satisConfig)
+
+	out := &bytes.Buffer{}
+	c := &ReconcileController{
+		DryRun:      true,
+		Config:      m,
+		Log:         newDiscardLogger(),
+		NumOfWorker: 1,
+		Out:         out,
+	}
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	expected := []string{
+		"clone symfony/console https://github.com/symfony/console.git",
+		"satis-add symfony/console http://git.example.com/symfony/console.git",
+		"satis-add twig/twig http://git.example.com/twig/twig.git",
+		"satis-remove old/gone http://git.example.com/old/gone.git",
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d actions. Got %d: %s", len(expected), len(lines), out.String())
+	}
+	for i, l := range lines {
+		if got := strings.Join(strings.Fields(l), " "); got != expected[i] {
+			t.Errorf("Expected action \"%s\". Got \"%s\"", expected[i], got)
+		}
+	}
+
+	after, _ := ioutil.ReadFile(satisConfig)
+	if !bytes.Equal(before, after) {
+		t.Errorf("Expected an unchanged Satis configuration during a dry run. Got %s", after)
+	}
+}
+
+func TestReconcileController_Run_Satis(t *testing.T) {
+	m, satisConfig, cleanup := setupReconcile(t, `{
+		"repodir": %q,
+		"satisconfig": %q,
+		"satisurl": "http://git.example.com"
+	}`)
+	defer cleanup()
+
+	c := &ReconcileController{
+		Config:      m,
+		Log:         newDiscardLogger(),
+		NumOfWorker: 1,
+		Out:         ioutil.Discard,
+	}
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	b, _ := ioutil.ReadFile(satisConfig)
+	p, err := config.NewJSONProvider(b)
+	if err != nil {
+		t.Fatalf("Expected a valid Satis configuration. Got %s", err)
+	}
+	s, _ := config.NewSatis(p)
+	for _, u := range []string{"http://git.example.com/twig/twig.git", "https://github.com/my-vendor/private.git"} {
+		if !s.HasRepository(u) {
+			t.Errorf("Expected repository %s. Got none", u)
+		}
+	}
+	if s.HasRepository("http://git.example.com/old/gone.git") {
+		t.Error("Expected repository old/gone to be removed. Got it")
+	}
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/report"
+)
+
+// logReport marks run report r as finished and logs a summary of it.
+func logReport(l logrus.FieldLogger, r *report.Report) {
+	r.Finish()
+
+	c := r.Counts()
+	l.WithFields(logrus.Fields{
+		"command":      r.Command,
+		"mirrored":     c[report.StatusMirrored],
+		"updated":      c[report.StatusUpdated],
+		"skipped":      c[report.StatusSkipped],
+		"failed":       c[report.StatusFailed],
+		"moved":        c[report.StatusMoved],
+		"protected":    c[report.StatusProtected],
+		"tampered":     c[report.StatusTampered],
+		"verified":     c[report.StatusVerified],
+		"repaired":     c[report.StatusRepaired],
+		"collected":    c[report.StatusCollected],
+		"deduplicated": c[report.StatusDeduplicated],
+		"hook_failed":  c[report.StatusHookFailed],
+		"abandoned":    c[report.StatusAbandoned],
+		"duration":     r.Finished.Sub(r.Started).Round(time.Millisecond).String(),
+	}).Info("Run finished")
+}
@@ -0,0 +1,15 @@
+package controller
+
+import (
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/dependency"
+)
+
+// getResolverOptions returns o if it is set.
+// Otherwise the resolver settings of the configuration cfg will be returned.
+func getResolverOptions(o *dependency.ResolverOptions, cfg *config.Medusa) *dependency.ResolverOptions {
+	if o != nil {
+		return o
+	}
+	return cfg.GetResolverOptions()
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/andygrunwald/perseus/config"
+)
+
+// getLocalURLForRepository returns the URL of the mirror of package p as it is written to the Satis configuration.
+// If a "satisurl" is configured, the URL is based on it. Otherwise a file:// URL to the mirror in the "repodir" is returned.
+func getLocalURLForRepository(cfg *config.Medusa, p string) string {
+	return fmt.Sprintf("%s%s.git", getLocalURLPrefix(cfg), p)
+}
+
+// getLocalURLPrefix returns the prefix that all URLs of mirrors in the Satis configuration share.
+func getLocalURLPrefix(cfg *config.Medusa) string {
+	if satisURL := cfg.GetString("satisurl"); len(satisURL) > 0 {
+		return satisURL + "/"
+	}
+
+	t := strings.TrimLeft(filepath.Clean(cfg.GetString("repodir")), "/")
+	return fmt.Sprintf("file:///%s/", t)
+}
+
+// getPackageNameOfLocalURL returns the package name of the mirror URL u from the Satis configuration.
+// If u is no URL of a mirror (e.g. an external repository), the second return value is false.
+func getPackageNameOfLocalURL(cfg *config.Medusa, u string) (string, bool) {
+	prefix := getLocalURLPrefix(cfg)
+	if !strings.HasPrefix(u, prefix) || !strings.HasSuffix(u, ".git") {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(u, prefix), ".git"), true
+}
+
+// readSatisConfig reads the Satis configuration file satisConfig.
+func readSatisConfig(satisConfig string) (*config.Satis, error) {
+	satisContent, err := ioutil.ReadFile(satisConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read Satis configuration %s: %s", satisConfig, err)
+	}
+
+	j, err := config.NewJSONProvider(satisContent)
+	if err != nil {
+		return nil, fmt.Errorf("Error while creating JSONProvider: %s", err)
+	}
+
+	s, err := config.NewSatis(j)
+	if err != nil {
+		return nil, fmt.Errorf("Error while creating Satis object: %s", err)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/dependency"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/metrics"
+)
+
+// ServeController reflects the business logic and the Command interface to run perseus as a daemon.
+// It updates all mirrored packages periodically and exposes metrics about the health of the mirror via HTTP.
+// This command is independent from an human interface (CLI, HTTP, etc.)
+// The human interfaces will interact with this command.
+type ServeController struct {
+	// Listen is the address of the HTTP server (like ":9117")
+	Listen string
+	// Interval is the time between two update runs
+	Interval time.Duration
+	// WithMirror runs the "mirror" command before every update run.
+	// With this, new packages from the configuration will be picked up without a restart.
+	WithMirror bool
+	// FollowURLChanges determines the upstream URL of every mirror before updating it (see UpdateController)
+	FollowURLChanges bool
+	// GC are the thresholds for a garbage collection after every update (see UpdateController)
+	GC *downloader.GCThresholds
+	// Config is the main medusa configuration
+	Config *config.Medusa
+	// Log represents a logger to log messages
+	Log logrus.FieldLogger
+	// NumOfWorker is the number of worker used for concurrent actions (like updating git repositories)
+	NumOfWorker int
+	// ResolverOptions are the settings for the dependency resolver.
+	// If nil, the settings of Config will be used.
+	ResolverOptions *dependency.ResolverOptions
+	// Registry contains the metrics that will be exposed (default: metrics.Default)
+	Registry *metrics.Registry
+}
+
+// Run is the business logic of ServeCommand.
+// Run blocks until the HTTP server stops.
+func (c *ServeController) Run() error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("Interval needs to be greater than zero. Got %s", c.Interval)
+	}
+	registry := c.Registry
+	if registry == nil {
+		registry = metrics.Default
+	}
+	metrics.SetRepoDir(c.Config.GetString("repodir"))
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	server := &http.Server{
+		Addr:    c.Listen,
+		Handler: mux,
+	}
+
+	// We listen before the first run, so that a busy address fails right away
+	addr := c.Listen
+	if len(addr) == 0 {
+		addr = ":http"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("HTTP server couldn't listen on %s: %s", c.Listen, err)
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+	c.Log.WithFields(logrus.Fields{
+		"listen":   c.Listen,
+		"interval": c.Interval.String(),
+	}).Info("Metrics available at /metrics")
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		c.runOnce()
+
+		select {
+		case err := <-serverErr:
+			return fmt.Errorf("HTTP server stopped: %s", err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce runs the mirror (if configured) and update process a single time.
+// Errors will be logged, because the daemon should keep running.
+func (c *ServeController) runOnce() {
+	if c.WithMirror {
+		m := &MirrorController{
+			Config:          c.Config,
+			Log:             c.Log,
+			NumOfWorker:     c.NumOfWorker,
+			ResolverOptions: c.ResolverOptions,
+		}
+		if err := m.Run(); err != nil {
+			c.Log.WithError(err).Error("Error during execution of \"mirror\" command")
+		}
+	}
+
+	u := &UpdateController{
+		Config:           c.Config,
+		Log:              c.Log,
+		NumOfWorker:      c.NumOfWorker,
+		FollowURLChanges: c.FollowURLChanges,
+		GC:               c.GC,
+	}
+	if err := u.Run(); err != nil {
+		c.Log.WithError(err).Error("Error during execution of \"update\" command")
+	}
+}
@@ -0,0 +1,57 @@
+package controller_test
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	. "github.com/andygrunwald/perseus/controller"
+	"github.com/spf13/viper"
+)
+
+func TestServeController_Run_ListenError(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	v := viper.New()
+	v.SetConfigType("json")
+	if err := v.ReadConfig(bytes.NewBufferString(`{"repodir": "/not/existing/perseus"}`)); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := config.NewViperProvider(v)
+	m, _ := config.NewMedusa(p)
+
+	var out bytes.Buffer
+	l := logrus.New()
+	l.Out = &out
+	c := &ServeController{
+		Listen:      busy.Addr().String(),
+		Interval:    time.Hour,
+		Config:      m,
+		Log:         l,
+		NumOfWorker: 1,
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run()
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "listen") {
+			t.Errorf("Expected a listen error. Got %v", err)
+		}
+		if out.Len() > 0 {
+			t.Errorf("Expected no update run. Got log %q", out.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the busy address to fail before the first run")
+	}
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/state"
+)
+
+// openState opens the state store that is located in the repository directory of cfg.
+func openState(cfg *config.Medusa) (*state.Store, error) {
+	p := state.Path(cfg.GetString("repodir"))
+	s, err := state.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading state file %s: %s", p, err)
+	}
+	return s, nil
+}
+
+// saveState writes the state store s to disk.
+// The state is informative only. This is why an error is logged, but doesn't fail the command.
+func saveState(l logrus.FieldLogger, s *state.Store) {
+	if err := s.Save(); err != nil {
+		l.WithError(err).Warn("Error while writing state file")
+	}
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/state"
+)
+
+// StatusController reflects the business logic and the Command interface to print the state of all mirrored repositories.
+// This command is independent from an human interface (CLI, HTTP, etc.)
+// The human interfaces will interact with this command.
+type StatusController struct {
+	// Format is the output format (see OutputFormat* constants)
+	Format string
+	// Config is the main medusa configuration
+	Config *config.Medusa
+	// Log represents a logger to log messages
+	Log logrus.FieldLogger
+	// State is the history of all mirrored repositories.
+	// If nil, the state file of the repository directory will be used.
+	State *state.Store
+	// Out is the writer where the status will be written to (default: os.Stdout)
+	Out io.Writer
+}
+
+// Run is the business logic of StatusCommand.
+func (c *StatusController) Run() error {
+	if !isValidOutputFormat(c.Format) {
+		return fmt.Errorf("Unknown output format \"%s\". Supported formats: %s, %s", c.Format, OutputFormatText, OutputFormatJSON)
+	}
+
+	if c.State == nil {
+		s, err := openState(c.Config)
+		if err != nil {
+			return err
+		}
+		c.State = s
+	}
+
+	repositories, err := c.getRepositories()
+	if err != nil {
+		return err
+	}
+
+	out := c.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if c.Format == OutputFormatJSON {
+		b, err := json.MarshalIndent(repositories, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", b)
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tORIGIN\tADDED\tLAST SUCCESS\tLAST ATTEMPT\tREFS\tLAST ERROR")
+	for _, r := range repositories {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", r.Name, orDash(r.Origin), formatStateTime(r.AddedAt), formatStateTime(r.LastFetchSuccess), formatStateTime(r.LastFetchAttempt), r.RefCount, orDash(r.LastError))
+	}
+	return w.Flush()
+}
+
+// getRepositories returns the state of all repositories sorted by name.
+// Mirrors on disk without a state (e.g. mirrored by an older version of perseus) are part of the list as well.
+func (c *StatusController) getRepositories() ([]state.Repository, error) {
+	repoDir := c.Config.GetString("repodir")
+	matches, err := findMirrors(repoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	repositories := c.State.List()
+	for _, m := range matches {
+		name := getPackageNameOfPath(repoDir, m)
+		if _, ok := c.State.Get(name); !ok {
+			c.Log.WithFields(logrus.Fields{
+				"package": name,
+				"path":    m,
+			}).Debug("Mirror without state found")
+			repositories = append(repositories, state.Repository{Name: name})
+		}
+	}
+	state.Sort(repositories)
+
+	return repositories, nil
+}
+
+// formatStateTime returns t in a human readable format or "-" if t is not set.
+func formatStateTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// orDash returns s or "-" if s is empty.
+func orDash(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,20 @@
+package controller_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/controller"
+)
+
+func TestStatusController_Run_WithUnknownFormat(t *testing.T) {
+	c := &StatusController{
+		Format: "xml",
+		Out:    &bytes.Buffer{},
+	}
+
+	err := c.Run()
+	if err == nil {
+		t.Fatal("Expected error while passing an unknown format. Got none")
+	}
+}
@@ -3,10 +3,20 @@ package controller
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/credentials"
+	"github.com/andygrunwald/perseus/dependency"
 	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/hook"
+	"github.com/andygrunwald/perseus/limit"
+	"github.com/andygrunwald/perseus/progress"
+	"github.com/andygrunwald/perseus/report"
+	"github.com/andygrunwald/perseus/state"
 )
 
 // UpdateController reflects the business logic and the Command interface to update all packages that were added or mirrored in the past.
@@ -19,6 +29,31 @@ type UpdateController struct {
 	Log logrus.FieldLogger
 	// NumOfWorker is the number of worker used for concurrent actions (like updating git repositories)
 	NumOfWorker int
+	// FollowURLChanges determines the upstream URL of every mirror from the configuration or Packagist before updating it.
+	// If the URL changed (e.g. because the package moved), the mirror will be updated from the new URL.
+	FollowURLChanges bool
+	// GC are the thresholds for a garbage collection after a successful update.
+	// If nil, no garbage collection runs.
+	GC *downloader.GCThresholds
+	// Report is the run report. The outcome of every package will be recorded here.
+	// If nil, a new report will be created during Run.
+	Report *report.Report
+	// State is the history of all mirrored repositories.
+	// If nil, the state file of the repository directory will be used.
+	State *state.Store
+	// Progress receives the progress events of the run (see progress.Bus).
+	// If nil, no progress is published.
+	Progress progress.Publisher
+}
+
+// updateJob is a single mirror in the queue of the UpdateCommand
+type updateJob struct {
+	// Path reflects the file path of the repository to update like /tmp/perseus/git-mirror/symfony/console.git
+	Path string
+	// OldURL is the upstream URL of the mirror, if the package moved (see FollowURLChanges). Empty otherwise.
+	OldURL string
+	// NewURL is the new upstream URL of the mirror, if the package moved (see FollowURLChanges). Empty otherwise.
+	NewURL string
 }
 
 // updateResult is the result of an update process of a single repository
@@ -27,71 +62,364 @@ type updateResult struct {
 	Path string
 	// Err contains an error once there was one during the update process
 	Err error
+	// Duration is the time the update took
+	Duration time.Duration
+	// Worker is the id of the worker that processed the update
+	Worker int
+	// RefCount is the number of refs of the repository after a successful update
+	RefCount int
+	// RefChanges are the refs that were deleted or rewritten upstream
+	RefChanges []*downloader.RefChange
+	// OldURL and NewURL are set if the repository was updated successfully from a new upstream URL
+	OldURL string
+	NewURL string
+	// GC is the result of the garbage collection after the update (if configured)
+	GC *gcResult
 }
 
 // Run is the business logic of UpdateCommand.
 func (c *UpdateController) Run() error {
+	if c.Report == nil {
+		c.Report = report.New("update")
+	}
+	defer notifyReport(c.Log, c.Config, c.Report)
+	defer logReport(c.Log, c.Report)
+
+	hooks, err := startHooks(c.Log, c.Config, c.Report)
+	if err != nil {
+		return err
+	}
+	defer hooks.Finish()
+
+	if c.State == nil {
+		s, err := openState(c.Config)
+		if err != nil {
+			return err
+		}
+		c.State = s
+	}
+	defer saveState(c.Log, c.State)
+
 	repoDir := c.Config.GetString("repodir")
 
-	p := fmt.Sprintf("%s/*/*.git", repoDir)
-	matches, err := filepath.Glob(p)
+	matches, err := findMirrors(repoDir)
 	if err != nil {
-		return fmt.Errorf("Error while determining folders for updating: %s", err)
+		return err
 	}
 
 	// If no repositories were found, we will exit here
 	if len(matches) == 0 {
 		c.Log.WithFields(logrus.Fields{
-			"path": p,
+			"path": repoDir,
 		}).Info("No repositories found")
 		return nil
 	}
 
+	matches, err = c.getDueMirrors(repoDir, matches)
+	if err != nil {
+		return err
+	}
+
 	// We run the update process concurrent.
 	// We will boot up a small worker pool and adding all repositories that we want to update.
 	// Let the show begin
-	jobs := make(chan string, len(matches))
+	// The workers take the transfer slot of the upstream host with the job (see limit.Queue).
+	jobs := limit.NewQueue(gitHosts(c.Config))
 	results := make(chan updateResult, len(matches))
-	for w := 1; w <= c.NumOfWorker; w++ {
+	for w := 1; w <= gitWorkers(c.Config, c.NumOfWorker); w++ {
 		go c.worker(w, jobs, results)
 	}
-
-	for _, v := range matches {
-		jobs <- v
-	}
-	close(jobs)
+	go c.queueMirrors(repoDir, matches, jobs)
 
 	// Now lets have a look at all results and log them.
 	for a := 1; a <= len(matches); a++ {
 		r := <-results
-		if r.Err != nil {
+		name := getPackageNameOfPath(repoDir, r.Path)
+		fields := logrus.Fields{
+			"package":  name,
+			"path":     r.Path,
+			"duration": r.Duration.Seconds(),
+			"worker":   r.Worker,
+		}
+		if len(r.NewURL) > 0 {
 			c.Log.WithFields(logrus.Fields{
-				"path": r.Path,
-			}).WithError(r.Err).Info("Error while updating")
+				"package":    name,
+				"path":       r.Path,
+				"old_url":    r.OldURL,
+				"repository": r.NewURL,
+			}).Warn("Upstream URL of package changed. Following the new URL")
+			c.Report.Moved(name, r.OldURL, r.NewURL)
+			c.State.Moved(name, r.NewURL)
+		}
+		if r.Err != nil {
+			fields["error_class"] = downloader.ClassifyError(r.Err)
+			c.Log.WithFields(fields).WithError(r.Err).Error("Error while updating")
+			c.Report.Failed(name, r.Err)
+			progress.Publish(c.Progress, progress.EventFailed, name)
+			c.State.Fetched(name, time.Now(), 0, r.Err)
+			observeFetch(name, r.Duration, r.Err)
+			hooks.Package(hook.EventPackageFailed, name, "", r.Err)
 		} else {
-			c.Log.WithFields(logrus.Fields{
-				"path": r.Path,
-			}).Info("Update successful")
+			c.Log.WithFields(fields).Info("Update successful")
+			c.Report.Updated(name, refChangeStrings(r.RefChanges))
+			progress.Publish(c.Progress, progress.EventDone, name)
+			c.State.Fetched(name, time.Now(), r.RefCount, nil)
+			observeFetch(name, r.Duration, nil)
+			hooks.Package(hook.EventPackageUpdated, name, "", nil)
+		}
+		logRefChanges(c.Log, c.Report, name, r.RefChanges)
+		if r.GC != nil {
+			logGCResult(c.Log, c.Report, c.State, name, r.GC)
 		}
 	}
 
 	return nil
 }
 
-// worker is a single worker of the UpdateCommand.
-// Workers job is to update a bunch of repositories on disk.
-func (c *UpdateController) worker(id int, jobs <-chan string, results chan<- updateResult) {
-	for j := range jobs {
-		updateClient, err := downloader.NewGitUpdater()
+// getDueMirrors returns the mirrors of paths that need an update.
+// Disabled packages and packages whose update interval is not reached yet
+// are logged and recorded as skipped.
+func (c *UpdateController) getDueMirrors(repoDir string, paths []string) ([]string, error) {
+	due := make([]string, 0, len(paths))
+	for _, path := range paths {
+		name := getPackageNameOfPath(repoDir, path)
+		r, err := c.Config.GetRepositoryConfig(name)
 		if err != nil {
-			results <- updateResult{Path: j, Err: fmt.Errorf("Updater client creation failed for package %s: %s", j, err)}
+			return nil, err
+		}
+		if r == nil {
+			due = append(due, path)
 			continue
 		}
-		err = updateClient.Update(j)
-		if err != nil {
-			results <- updateResult{Path: j, Err: err}
+
+		if r.Disabled {
+			logDisabledPackage(c.Log, c.Report, r)
+			continue
+		}
+		if s, ok := c.State.Get(name); ok && r.UpdateInterval > 0 && time.Since(s.LastFetchSuccess) < r.UpdateInterval {
+			c.Log.WithFields(logrus.Fields{
+				"package":            name,
+				"path":               path,
+				"update_interval":    r.UpdateInterval.String(),
+				"last_fetch_success": s.LastFetchSuccess,
+			}).Debug("Update interval not reached. Skipping.")
+			c.Report.Skipped(name, fmt.Sprintf("Update interval of %s not reached", r.UpdateInterval))
+			continue
+		}
+		due = append(due, path)
+	}
+	return due, nil
+}
+
+// queueMirrors pushes the mirrors of paths to jobs and closes jobs afterwards.
+// The upstream URL of every mirror is determined before it is queued (see FollowURLChanges),
+// so that the update waits for a transfer slot of the host it is fetched from.
+// The lookups run concurrently and don't hold a transfer slot.
+func (c *UpdateController) queueMirrors(repoDir string, paths []string, jobs *limit.Queue) {
+	lookups := make(chan string, len(paths))
+	for _, path := range paths {
+		lookups <- path
+	}
+	close(lookups)
+
+	var wg sync.WaitGroup
+	for w := 1; w <= gitWorkers(c.Config, c.NumOfWorker); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range lookups {
+				j := &updateJob{Path: path}
+				if c.FollowURLChanges {
+					j.OldURL, j.NewURL = c.getURLChange(path)
+				}
+				host := credentials.GetHost(j.NewURL)
+				if len(j.NewURL) == 0 {
+					u, _ := downloader.GetRemoteURL(path)
+					host = credentials.GetHost(u)
+				}
+				progress.Publish(c.Progress, progress.EventQueued, getPackageNameOfPath(repoDir, path))
+				jobs.Push(host, j)
+			}
+		}()
+	}
+	wg.Wait()
+	jobs.Close()
+}
+
+// worker is a single worker of the UpdateCommand.
+// Workers job is to update a bunch of repositories on disk.
+// jobs is the queue of mirrors (the worker receives a mirror together with the transfer slot of its host).
+func (c *UpdateController) worker(id int, jobs *limit.Queue, results chan<- updateResult) {
+	for {
+		j, release, ok := jobs.Next()
+		if !ok {
+			return
+		}
+		r := c.update(id, j.(*updateJob), release)
+		if r.Err == nil {
+			c.finishUpdate(id, &r)
+		}
+		results <- r
+	}
+}
+
+// update updates the mirror j.
+// If the package moved, the mirror is updated from the new upstream URL.
+// The new URL is only kept if the update was successful. Otherwise the mirror keeps the old URL.
+// release releases the transfer slot of the upstream host. It is called once the update is finished.
+func (c *UpdateController) update(id int, job *updateJob, release func()) updateResult {
+	defer release()
+
+	j := job.Path
+	r := updateResult{Path: j, Worker: id}
+	creds, err := c.Config.GetCredentials()
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	name := getPackageNameOfPath(c.Config.GetString("repodir"), j)
+	opts, err := getDownloadOptions(c.Config, name)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	opts = withProgress(opts, progress.ForPackage(c.Progress, name))
+	updateClient, err := downloader.NewGitUpdater(creds, opts)
+	if err != nil {
+		r.Err = fmt.Errorf("Updater client creation failed for package %s: %s", j, err)
+		return r
+	}
+
+	logger := c.Log.WithFields(logrus.Fields{
+		"package":    name,
+		"path":       j,
+		"old_url":    job.OldURL,
+		"repository": job.NewURL,
+	})
+	moved := false
+	if len(job.NewURL) > 0 {
+		if err := downloader.SetRemoteURL(j, job.NewURL); err != nil {
+			logger.WithError(err).Warn("Error while changing upstream URL of mirror")
+		} else {
+			moved = true
+		}
+	}
+
+	start := time.Now()
+	r.RefChanges, r.Err = updateClient.Update(j)
+	r.Duration = time.Since(start)
+	if moved && r.Err != nil {
+		if err := downloader.SetRemoteURL(j, job.OldURL); err != nil {
+			logger.WithError(err).Warn("Error while restoring upstream URL of mirror")
 		} else {
-			results <- updateResult{Path: j, Err: nil}
+			logger.Warn("Update from new upstream URL failed. Restored the old URL")
+		}
+		moved = false
+	}
+	if moved {
+		r.OldURL, r.NewURL = job.OldURL, job.NewURL
+	}
+	return r
+}
+
+// finishUpdate counts the refs of the successfully updated mirror of r and collects its garbage (if configured).
+// It doesn't need a transfer slot, because it doesn't talk to the upstream host.
+func (c *UpdateController) finishUpdate(id int, r *updateResult) {
+	// The ref count is only informative.
+	// A failure here doesn't make the update fail.
+	r.RefCount, _ = downloader.CountRefs(r.Path)
+
+	if c.GC != nil {
+		s, _ := c.State.Get(getPackageNameOfPath(c.Config.GetString("repodir"), r.Path))
+		r.GC = collectGarbage(r.Path, c.GC, s.LastGCAt, false)
+		r.GC.Worker = id
+	}
+}
+
+// getURLChange determines the upstream URL of the mirror at path from the configuration or Packagist.
+// The old and the new URL will be returned, if it differs from the URL of the mirror. Otherwise both are empty.
+// The mirror itself is not changed (see update).
+// Errors are logged, because the mirror can still be updated from the old URL.
+func (c *UpdateController) getURLChange(path string) (string, string) {
+	name := getPackageNameOfPath(c.Config.GetString("repodir"), path)
+	logger := c.Log.WithFields(logrus.Fields{
+		"package": name,
+		"path":    path,
+	})
+
+	oldURL, err := downloader.GetRemoteURL(path)
+	if err != nil {
+		logger.WithError(err).Warn("Error while determining upstream URL of mirror")
+		return "", ""
+	}
+	newURL, err := getCanonicalURL(c.Config, name)
+	if err != nil {
+		logger.WithError(err).Warn("Error while determining canonical upstream URL of package")
+		return "", ""
+	}
+	if isSameRepositoryURL(oldURL, newURL) {
+		return "", ""
+	}
+	return oldURL, newURL
+}
+
+// getCanonicalURL returns the upstream URL of package name.
+// A URL from the configuration has precedence over the URL from Packagist.
+func getCanonicalURL(cfg *config.Medusa, name string) (string, error) {
+	p, err := dependency.NewPackage(name, "")
+	if err != nil {
+		return "", err
+	}
+
+	// See AddController.Run why we don't respect the error here.
+	if u, _ := cfg.GetRepositoryURLOfPackage(p); u != nil {
+		return u.String(), nil
+	}
+
+	p, err = getURLOfPackageFromPackagist(cfg, p)
+	if err != nil {
+		return "", err
+	}
+	return p.Repository.String(), nil
+}
+
+// isSameRepositoryURL returns true if the URLs a and b point to the same repository.
+// A trailing slash and the suffix ".git" are ignored, because both are optional for most git hosts.
+func isSameRepositoryURL(a, b string) bool {
+	normalize := func(u string) string {
+		return strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git")
+	}
+	return normalize(a) == normalize(b)
+}
+
+// findMirrors returns the paths of all mirrors in the repository directory repoDir
+// like /tmp/perseus/git-mirror/symfony/console.git.
+// Object pools (see DedupeController) are no mirrors and won't be returned.
+func findMirrors(repoDir string) ([]string, error) {
+	p := fmt.Sprintf("%s/*/*.git", repoDir)
+	matches, err := filepath.Glob(p)
+	if err != nil {
+		return nil, fmt.Errorf("Error while determining folders of mirrors: %s", err)
+	}
+
+	mirrors := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if filepath.Base(filepath.Dir(m)) == poolDirName {
+			continue
 		}
+		mirrors = append(mirrors, m)
+	}
+	return mirrors, nil
+}
+
+// getPackageNameOfPath returns the package name of the mirror at path p.
+// repoDir is the directory where all mirrors are located.
+// E.g. /tmp/perseus/git-mirror/symfony/console.git => symfony/console
+// If p is not located in repoDir, p will be returned.
+func getPackageNameOfPath(repoDir, p string) string {
+	rel, err := filepath.Rel(repoDir, p)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return p
 	}
+	return strings.TrimSuffix(filepath.ToSlash(rel), ".git")
 }
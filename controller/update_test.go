@@ -0,0 +1,337 @@
+package controller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/perseus/config"
+	. "github.com/andygrunwald/perseus/controller"
+	"github.com/andygrunwald/perseus/report"
+	"github.com/andygrunwald/perseus/state"
+	"github.com/spf13/viper"
+)
+
+// git executes a git command in dir and fails the test on error.
+func git(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=perseus", "-c", "user.email=perseus@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Error during cmd \"%+v\": %s. Output: %s", cmd.Args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestUpdateController_Run_FollowURLChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The package was mirrored from oldURL and moved to newURL afterwards
+	oldURL := filepath.Join(dir, "old", "console")
+	newURL := filepath.Join(dir, "new", "console.git")
+	os.MkdirAll(oldURL, 0755)
+	git(t, oldURL, "init", "-q")
+	git(t, oldURL, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+	git(t, dir, "clone", "-q", "--bare", oldURL, newURL)
+
+	repoDir := filepath.Join(dir, "git-mirror")
+	mirror := filepath.Join(repoDir, "symfony", "console.git")
+	git(t, dir, "clone", "-q", "--mirror", oldURL, mirror)
+
+	v := viper.New()
+	v.SetConfigType("json")
+	medusa := fmt.Sprintf(`{"repodir": %q, "repositories": [{"name": "symfony/console", "url": %q}]}`, repoDir, newURL)
+	if err := v.ReadConfig(bytes.NewBufferString(medusa)); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := config.NewViperProvider(v)
+	m, _ := config.NewMedusa(p)
+
+	r := report.New("update")
+	c := &UpdateController{
+		FollowURLChanges: true,
+		Config:           m,
+		Log:              newDiscardLogger(),
+		NumOfWorker:      1,
+		Report:           r,
+	}
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	if u := git(t, mirror, "config", "--get", "remote.origin.url"); u != newURL {
+		t.Errorf("Expected upstream URL %s. Got %s", newURL, u)
+	}
+	moved := r.Filter(report.StatusMoved)
+	if len(moved) != 1 || moved[0].Package != "symfony/console" {
+		t.Fatalf("Expected symfony/console as moved package. Got %+v", moved)
+	}
+	if c := r.Counts(); c[report.StatusUpdated] != 1 {
+		t.Errorf("Expected one updated package. Got %+v", c)
+	}
+
+	// The package moves again, but the update from the new URL fails
+	medusa = fmt.Sprintf(`{"repodir": %q, "repositories": [{"name": "symfony/console", "url": %q}]}`, repoDir, filepath.Join(dir, "missing", "console.git"))
+	if err := v.ReadConfig(bytes.NewBufferString(medusa)); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = config.NewViperProvider(v)
+	c.Config, _ = config.NewMedusa(p)
+	r = report.New("update")
+	c.Report = r
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	if u := git(t, mirror, "config", "--get", "remote.origin.url"); u != newURL {
+		t.Errorf("Expected the upstream URL %s to be restored. Got %s", newURL, u)
+	}
+	if moved := r.Filter(report.StatusMoved); len(moved) != 0 {
+		t.Errorf("Expected no moved package after a failed update. Got %+v", moved)
+	}
+	if c := r.Counts(); c[report.StatusFailed] != 1 {
+		t.Errorf("Expected one failed package. Got %+v", c)
+	}
+}
+
+func TestUpdateController_Run_RepositoryOptions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	upstream := filepath.Join(dir, "upstream")
+	os.MkdirAll(upstream, 0755)
+	git(t, upstream, "init", "-q")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+	git(t, upstream, "branch", "feature")
+	git(t, upstream, "tag", "v1.0.0")
+
+	repoDir := filepath.Join(dir, "git-mirror")
+	for _, name := range []string{"symfony/console", "twig/twig", "monolog/monolog"} {
+		git(t, dir, "clone", "-q", "--mirror", upstream, filepath.Join(repoDir, name+".git"))
+	}
+
+	v := viper.New()
+	v.SetConfigType("json")
+	medusa := fmt.Sprintf(`{"repodir": %q, "repositories": [
+		{"name": "symfony/console", "url": %q, "refspecs": ["refs/tags/*"]},
+		{"name": "twig/twig", "url": %q, "disabled": true, "notes": "Replaced by a fork"},
+		{"name": "monolog/monolog", "url": %q, "update-interval": "24h"}
+	]}`, repoDir, upstream, upstream, upstream)
+	if err := v.ReadConfig(bytes.NewBufferString(medusa)); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := config.NewViperProvider(v)
+	m, _ := config.NewMedusa(p)
+
+	s, err := state.Open(state.Path(repoDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Fetched("monolog/monolog", time.Now().Add(-time.Hour), 3, nil)
+
+	r := report.New("update")
+	c := &UpdateController{
+		Config:      m,
+		Log:         newDiscardLogger(),
+		NumOfWorker: 1,
+		Report:      r,
+		State:       s,
+	}
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	updated := r.Filter(report.StatusUpdated)
+	if len(updated) != 1 || updated[0].Package != "symfony/console" {
+		t.Fatalf("Expected symfony/console as updated package. Got %+v", updated)
+	}
+	if skipped := r.Filter(report.StatusSkipped); len(skipped) != 2 {
+		t.Fatalf("Expected two skipped packages. Got %+v", skipped)
+	}
+
+	// Only the tags are mirrored
+	if refs := git(t, filepath.Join(repoDir, "symfony", "console.git"), "for-each-ref", "--format=%(refname)"); refs != "refs/tags/v1.0.0" {
+		t.Errorf("Expected only refs/tags/v1.0.0. Got %s", refs)
+	}
+}
+
+func TestUpdateController_Run_TamperPolicy(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	upstream := filepath.Join(dir, "upstream")
+	os.MkdirAll(upstream, 0755)
+	git(t, upstream, "init", "-q")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+	git(t, upstream, "tag", "v1.0.0")
+
+	repoDir := filepath.Join(dir, "git-mirror")
+	for _, name := range []string{"symfony/console", "twig/twig"} {
+		git(t, dir, "clone", "-q", "--mirror", upstream, filepath.Join(repoDir, name+".git"))
+	}
+
+	// Upstream moves the tag
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Second commit")
+	git(t, upstream, "tag", "-f", "v1.0.0")
+
+	notifications := []map[string]interface{}{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&n)
+		notifications = append(notifications, n)
+	}))
+	defer ts.Close()
+
+	v := viper.New()
+	v.SetConfigType("json")
+	medusa := fmt.Sprintf(`{"repodir": %q, "tamper-policy": {"tag-moved": "warn", "webhook": %q}, "repositories": [
+		{"name": "symfony/console", "url": %q},
+		{"name": "twig/twig", "url": %q}
+	]}`, repoDir, ts.URL, upstream, upstream)
+	if err := v.ReadConfig(bytes.NewBufferString(medusa)); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := config.NewViperProvider(v)
+	m, _ := config.NewMedusa(p)
+
+	r := report.New("update")
+	c := &UpdateController{
+		Config:      m,
+		Log:         newDiscardLogger(),
+		NumOfWorker: 2,
+		Report:      r,
+	}
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	if tampered := r.Filter(report.StatusTampered); len(tampered) != 2 {
+		t.Errorf("Expected two tampered entries. Got %+v", tampered)
+	}
+	updated := r.Filter(report.StatusUpdated)
+	if len(updated) != 2 || len(updated[0].Refs) != 2 {
+		t.Errorf("Expected two updated packages with the changed refs. Got %+v", updated)
+	}
+
+	if len(notifications) != 1 {
+		t.Fatalf("Expected a single webhook notification. Got %d", len(notifications))
+	}
+	events, _ := notifications[0]["events"].([]interface{})
+	if len(events) != 2 {
+		t.Fatalf("Expected two tamper events. Got %+v", notifications[0])
+	}
+	for _, e := range events {
+		event, _ := e.(map[string]interface{})
+		if event["type"] != "tag-moved" || event["ref"] != "refs/tags/v1.0.0" || event["action"] != "warn" || event["old"] == event["new"] {
+			t.Errorf("Expected the moved tag in the event. Got %+v", event)
+		}
+	}
+}
+
+func TestUpdateController_Run_Hooks(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	upstream := filepath.Join(dir, "upstream")
+	os.MkdirAll(upstream, 0755)
+	git(t, upstream, "init", "-q")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+
+	repoDir := filepath.Join(dir, "git-mirror")
+	for _, name := range []string{"symfony/console", "twig/twig"} {
+		git(t, dir, "clone", "-q", "--mirror", upstream, filepath.Join(repoDir, name+".git"))
+	}
+	// The upstream repository of twig/twig is gone
+	git(t, filepath.Join(repoDir, "twig/twig.git"), "remote", "set-url", "origin", filepath.Join(dir, "gone"))
+
+	var finished map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		finished = map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&finished)
+	}))
+	defer ts.Close()
+
+	out := filepath.Join(dir, "events")
+	v := viper.New()
+	v.SetConfigType("json")
+	medusa := fmt.Sprintf(`{"repodir": %q, "hooks": [
+		{"name": "log", "command": "echo \"$PERSEUS_EVENT $PERSEUS_PACKAGE\" >> %s"},
+		{"name": "broken", "command": "exit 1", "events": ["package-updated"]},
+		{"name": "callback", "url": %q, "events": ["run-finished"], "timeout": "5s"}
+	]}`, repoDir, out, ts.URL)
+	if err := v.ReadConfig(bytes.NewBufferString(medusa)); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := config.NewViperProvider(v)
+	m, _ := config.NewMedusa(p)
+
+	r := report.New("update")
+	c := &UpdateController{
+		Config:      m,
+		Log:         newDiscardLogger(),
+		NumOfWorker: 2,
+		Report:      r,
+	}
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	b, _ := ioutil.ReadFile(out)
+	events := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(events) != 4 || events[0] != "run-started " || events[3] != "run-finished " {
+		t.Fatalf("Expected the run events around the package events. Got %q", events)
+	}
+	packageEvents := map[string]bool{events[1]: true, events[2]: true}
+	if !packageEvents["package-updated symfony/console"] || !packageEvents["package-failed twig/twig"] {
+		t.Errorf("Expected an updated and a failed package. Got %q", events)
+	}
+
+	hookFailed := r.Filter(report.StatusHookFailed)
+	if len(hookFailed) != 1 || hookFailed[0].Package != "symfony/console" || !strings.HasPrefix(hookFailed[0].Reason, "Hook broken (package-updated): ") {
+		t.Errorf("Expected the failed hook in the report. Got %+v", hookFailed)
+	}
+
+	counts, _ := finished["counts"].(map[string]interface{})
+	if finished["event"] != "run-finished" || counts["updated"] != 1.0 || counts["failed"] != 1.0 || counts["hook-failed"] != 1.0 {
+		t.Errorf("Expected the run-finished event with the counts of the report. Got %+v", finished)
+	}
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/dependency"
+	"github.com/andygrunwald/perseus/downloader"
+)
+
+const (
+	// URLSourceConfiguration means the repository URL is configured in the medusa configuration
+	URLSourceConfiguration = "configuration"
+	// URLSourcePackagist means the repository URL was requested from Packagist
+	URLSourcePackagist = "packagist"
+)
+
+// URLController reflects the business logic and the Command interface to show the effective
+// repository URL of a package and how it was determined.
+// This command is independent from an human interface (CLI, HTTP, etc.)
+// The human interfaces will interact with this command.
+type URLController struct {
+	// Package is the package whose URL should be shown
+	Package string
+	// Config is the main medusa configuration
+	Config *config.Medusa
+	// Log represents a logger to log messages
+	Log logrus.FieldLogger
+	// Out is the writer where the URL will be written to (default: os.Stdout)
+	Out io.Writer
+}
+
+// Run is the business logic of URLCommand.
+func (c *URLController) Run() error {
+	if len(c.Package) == 0 {
+		return errors.New("No package applied. Please apply the package whose URL should be shown")
+	}
+
+	out := c.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	p, err := dependency.NewPackage(c.Package, "")
+	if err != nil {
+		return err
+	}
+
+	// See AddController.Run why we don't respect the error here.
+	source := URLSourceConfiguration
+	raw, _ := c.Config.GetRawRepositoryURLOfPackage(p)
+	if len(raw) == 0 {
+		source = URLSourcePackagist
+		packagistPackage, err := getPackageFromPackagist(c.Config, p.Name)
+		if err != nil {
+			return err
+		}
+		raw = packagistPackage.Repository
+	}
+
+	effective, rule := dependency.RewriteURL(raw)
+	ruleDesc := "-"
+	if rule != nil {
+		ruleDesc = rule.String()
+		c.Log.WithFields(logrus.Fields{
+			"package": p.Name,
+			"url":     raw,
+			"rule":    ruleDesc,
+		}).Debug("Repository URL rewritten")
+	}
+
+	mirror := "-"
+	path := filepath.Join(c.Config.GetString("repodir"), p.Name+".git")
+	if _, err := os.Stat(path); err == nil {
+		if u, err := downloader.GetRemoteURL(path); err == nil {
+			mirror = u
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Package:\t%s\n", p.Name)
+	fmt.Fprintf(w, "Source:\t%s\n", source)
+	fmt.Fprintf(w, "URL:\t%s\n", raw)
+	fmt.Fprintf(w, "Rewrite rule:\t%s\n", ruleDesc)
+	fmt.Fprintf(w, "Effective URL:\t%s\n", effective)
+	fmt.Fprintf(w, "Mirror:\t%s\n", mirror)
+	if repo, _ := c.Config.GetRepositoryConfig(p.Name); repo != nil {
+		if repo.Disabled {
+			fmt.Fprintf(w, "Disabled:\t%s\n", "yes")
+		}
+		if len(repo.Notes) > 0 {
+			fmt.Fprintf(w, "Notes:\t%s\n", repo.Notes)
+		}
+	}
+	return w.Flush()
+}
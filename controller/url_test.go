@@ -0,0 +1,60 @@
+package controller_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/andygrunwald/perseus/config"
+	. "github.com/andygrunwald/perseus/controller"
+	"github.com/andygrunwald/perseus/dependency"
+	"github.com/spf13/viper"
+)
+
+func TestURLController_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "perseus-url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	v := viper.New()
+	v.SetConfigType("json")
+	medusa := fmt.Sprintf(`{"repodir": %q, "repositories": [{"name": "myvendor/package", "url": "git@gitlab.company.tld:myvendor/package.git"}]}`, dir)
+	if err := v.ReadConfig(bytes.NewBufferString(medusa)); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := config.NewViperProvider(v)
+	m, _ := config.NewMedusa(p)
+
+	rules, _ := m.GetRewriteRules()
+	rule, _ := dependency.NewPrefixRewriteRule("git@gitlab.company.tld:", "https://gitlab.company.tld/")
+	dependency.SetRewriteRules(append(rules, rule))
+	defer dependency.SetRewriteRules(nil)
+
+	out := &bytes.Buffer{}
+	c := &URLController{
+		Package: "myvendor/package",
+		Config:  m,
+		Log:     newDiscardLogger(),
+		Out:     out,
+	}
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	for _, expected := range []string{
+		"Source:         configuration",
+		"URL:            git@gitlab.company.tld:myvendor/package.git",
+		`Rewrite rule:   prefix "git@gitlab.company.tld:" => "https://gitlab.company.tld/"`,
+		"Effective URL:  https://gitlab.company.tld/myvendor/package.git",
+		"Mirror:         -",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %q. Got:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/report"
+	"github.com/andygrunwald/perseus/state"
+)
+
+// VerifyController reflects the business logic and the Command interface to check the integrity of all mirrors.
+// Broken mirrors can be repaired by cloning them again from upstream.
+// This command is independent from an human interface (CLI, HTTP, etc.)
+// The human interfaces will interact with this command.
+type VerifyController struct {
+	// Repair repairs broken mirrors. A stale info/refs will be regenerated,
+	// all other failures will be repaired by cloning the mirror again from upstream.
+	Repair bool
+	// Rotate verifies only 1/Rotate of all mirrors per run (0 = all mirrors).
+	// Mirrors with the oldest verification are verified first.
+	// Running the command every night with Rotate 7 verifies every mirror once a week.
+	Rotate int
+	// Config is the main medusa configuration
+	Config *config.Medusa
+	// Log represents a logger to log messages
+	Log logrus.FieldLogger
+	// NumOfWorker is the number of worker used for concurrent actions (like verifying git repositories).
+	// The configuration key "limits.git-workers" takes precedence, because a repair clones mirrors again.
+	NumOfWorker int
+	// Report is the run report. The outcome of every package will be recorded here.
+	// If nil, a new report will be created during Run.
+	Report *report.Report
+	// State is the history of all mirrored repositories.
+	// If nil, the state file of the repository directory will be used.
+	State *state.Store
+}
+
+// verifyResult is the result of the verification of a single repository
+type verifyResult struct {
+	// Path reflects the file path of the repository like /tmp/perseus/git-mirror/symfony/console.git
+	Path string
+	// Failed are the integrity checks that failed
+	Failed []*downloader.CheckError
+	// Repaired is true if the repository was repaired successfully
+	Repaired bool
+	// RepairErr contains an error once the repair failed
+	RepairErr error
+	// Duration is the time the verification (incl. repair) took
+	Duration time.Duration
+	// Worker is the id of the worker that processed the verification
+	Worker int
+}
+
+// Run is the business logic of VerifyCommand.
+func (c *VerifyController) Run() error {
+	if c.Rotate < 0 {
+		return fmt.Errorf("Rotate needs to be zero or greater. Got %d", c.Rotate)
+	}
+	if c.Report == nil {
+		c.Report = report.New("verify")
+	}
+	defer notifyReport(c.Log, c.Config, c.Report)
+	defer logReport(c.Log, c.Report)
+
+	if c.State == nil {
+		s, err := openState(c.Config)
+		if err != nil {
+			return err
+		}
+		c.State = s
+	}
+	defer saveState(c.Log, c.State)
+
+	repoDir := c.Config.GetString("repodir")
+	matches, err := findMirrors(repoDir)
+	if err != nil {
+		return err
+	}
+	matches = c.getRotation(repoDir, matches)
+	if len(matches) == 0 {
+		c.Log.WithFields(logrus.Fields{
+			"path": repoDir,
+		}).Info("No repositories found")
+		return nil
+	}
+
+	jobs := make(chan string, len(matches))
+	results := make(chan verifyResult, len(matches))
+	for w := 1; w <= gitWorkers(c.Config, c.NumOfWorker); w++ {
+		go c.worker(w, jobs, results)
+	}
+	for _, v := range matches {
+		jobs <- v
+	}
+	close(jobs)
+
+	broken := 0
+	for a := 1; a <= len(matches); a++ {
+		r := <-results
+		name := getPackageNameOfPath(repoDir, r.Path)
+		fields := logrus.Fields{
+			"package":  name,
+			"path":     r.Path,
+			"duration": r.Duration.Seconds(),
+			"worker":   r.Worker,
+		}
+
+		if len(r.Failed) == 0 {
+			c.Log.WithFields(fields).Info("Mirror verified")
+			c.Report.Add(name, report.StatusVerified, "")
+			c.State.Verified(name, time.Now(), nil)
+			continue
+		}
+
+		checkErr := joinCheckErrors(r.Failed)
+		fields["checks"] = checkNames(r.Failed)
+		switch {
+		case r.Repaired:
+			c.Log.WithFields(fields).WithError(checkErr).Warn("Mirror was broken and is repaired")
+			c.Report.Add(name, report.StatusRepaired, checkErr.Error())
+			c.State.Verified(name, time.Now(), nil)
+		case r.RepairErr != nil:
+			broken++
+			fields["error_class"] = downloader.ClassifyError(r.RepairErr)
+			c.Log.WithFields(fields).WithError(r.RepairErr).Error("Mirror is broken and couldn't be repaired")
+			c.Report.Failed(name, fmt.Errorf("%s. Repair failed: %s", checkErr, r.RepairErr))
+			c.State.Verified(name, time.Now(), checkErr)
+		default:
+			broken++
+			c.Log.WithFields(fields).WithError(checkErr).Error("Mirror is broken")
+			c.Report.Failed(name, checkErr)
+			c.State.Verified(name, time.Now(), checkErr)
+		}
+	}
+
+	if broken > 0 {
+		return fmt.Errorf("%d of %d mirrors are broken", broken, len(matches))
+	}
+	return nil
+}
+
+// worker is a single worker of the VerifyCommand.
+// Workers job is to verify (and repair) a bunch of repositories on disk.
+func (c *VerifyController) worker(id int, jobs <-chan string, results chan<- verifyResult) {
+	for j := range jobs {
+		start := time.Now()
+		r := verifyResult{
+			Path:   j,
+			Failed: downloader.Verify(j),
+			Worker: id,
+		}
+		if len(r.Failed) > 0 && c.Repair {
+			r.RepairErr = c.repair(j, r.Failed)
+			r.Repaired = r.RepairErr == nil
+		}
+		r.Duration = time.Since(start)
+		results <- r
+	}
+}
+
+// repair repairs the mirror at path based on the failed checks.
+func (c *VerifyController) repair(path string, failed []*downloader.CheckError) error {
+	if !downloader.NeedsReclone(failed) {
+		return downloader.RepairInfoRefs(path)
+	}
+
+	// The configuration of a broken mirror is mostly still readable.
+	// If not, we fall back to the configuration and Packagist.
+	u, err := downloader.GetRemoteURL(path)
+	if err != nil || len(u) == 0 {
+		u, err = getCanonicalURL(c.Config, getPackageNameOfPath(c.Config.GetString("repodir"), path))
+		if err != nil {
+			return fmt.Errorf("Upstream URL couldn't be determined: %s", err)
+		}
+	}
+
+	creds, err := c.Config.GetCredentials()
+	if err != nil {
+		return err
+	}
+	opts, err := getDownloadOptions(c.Config, getPackageNameOfPath(c.Config.GetString("repodir"), path))
+	if err != nil {
+		return err
+	}
+	return downloader.Reclone(u, path, creds, opts)
+}
+
+// getRotation returns the mirrors that should be verified in this run.
+// Without rotation all mirrors are returned.
+// Otherwise 1/Rotate of all mirrors with the oldest verification (never verified first) are returned.
+func (c *VerifyController) getRotation(repoDir string, matches []string) []string {
+	if c.Rotate <= 1 || len(matches) == 0 {
+		return matches
+	}
+
+	l := &mirrorsByLastVerification{
+		paths:        make([]string, len(matches)),
+		lastVerified: make(map[string]time.Time, len(matches)),
+	}
+	copy(l.paths, matches)
+	for _, m := range matches {
+		if s, ok := c.State.Get(getPackageNameOfPath(repoDir, m)); ok {
+			l.lastVerified[m] = s.LastVerifiedAt
+		}
+	}
+	sort.Sort(l)
+
+	// Round up to verify every mirror within Rotate runs
+	n := (len(l.paths) + c.Rotate - 1) / c.Rotate
+	return l.paths[:n]
+}
+
+// joinCheckErrors combines the failed checks to a single error.
+func joinCheckErrors(failed []*downloader.CheckError) error {
+	msgs := make([]string, 0, len(failed))
+	for _, e := range failed {
+		msgs = append(msgs, e.Error())
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// checkNames returns the names of the failed checks like "fsck, objects".
+func checkNames(failed []*downloader.CheckError) string {
+	names := make([]string, 0, len(failed))
+	for _, e := range failed {
+		names = append(names, e.Check)
+	}
+	return strings.Join(names, ", ")
+}
+
+// mirrorsByLastVerification sorts paths of mirrors by the time of their last verification (oldest first)
+type mirrorsByLastVerification struct {
+	paths        []string
+	lastVerified map[string]time.Time
+}
+
+func (l *mirrorsByLastVerification) Len() int      { return len(l.paths) }
+func (l *mirrorsByLastVerification) Swap(i, j int) { l.paths[i], l.paths[j] = l.paths[j], l.paths[i] }
+func (l *mirrorsByLastVerification) Less(i, j int) bool {
+	a, b := l.lastVerified[l.paths[i]], l.lastVerified[l.paths[j]]
+	if a.Equal(b) {
+		return l.paths[i] < l.paths[j]
+	}
+	return a.Before(b)
+}
@@ -0,0 +1,191 @@
+package controller_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/andygrunwald/perseus/config"
+	. "github.com/andygrunwald/perseus/controller"
+	"github.com/andygrunwald/perseus/report"
+	"github.com/spf13/viper"
+)
+
+// setupVerify creates an upstream repository and the mirrors symfony/console and twig/twig of it.
+func setupVerify(t *testing.T) (*config.Medusa, string, func()) {
+	dir, err := ioutil.TempDir("", "perseus-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upstream := filepath.Join(dir, "upstream")
+	os.MkdirAll(upstream, 0755)
+	git(t, upstream, "init", "-q")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+
+	repoDir := filepath.Join(dir, "git-mirror")
+	for _, p := range []string{"symfony/console", "twig/twig"} {
+		mirror := filepath.Join(repoDir, p+".git")
+		git(t, dir, "clone", "-q", "--mirror", upstream, mirror)
+		git(t, mirror, "update-server-info")
+	}
+
+	v := viper.New()
+	v.SetConfigType("json")
+	if err := v.ReadConfig(bytes.NewBufferString(fmt.Sprintf(`{"repodir": %q}`, repoDir))); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := config.NewViperProvider(v)
+	m, _ := config.NewMedusa(p)
+
+	return m, repoDir, func() { os.RemoveAll(dir) }
+}
+
+// corruptMirror deletes all objects of the mirror.
+func corruptMirror(t *testing.T, mirror string) {
+	objects, _ := filepath.Glob(filepath.Join(mirror, "objects", "??"))
+	packs, _ := filepath.Glob(filepath.Join(mirror, "objects", "pack", "*"))
+	for _, o := range append(objects, packs...) {
+		if err := os.RemoveAll(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyController_Run_Repair(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	m, repoDir, cleanup := setupVerify(t)
+	defer cleanup()
+	corruptMirror(t, filepath.Join(repoDir, "twig", "twig.git"))
+
+	// Without repair the broken mirror is reported
+	r := report.New("verify")
+	c := &VerifyController{
+		Config:      m,
+		Log:         newDiscardLogger(),
+		NumOfWorker: 2,
+		Report:      r,
+	}
+	if err := c.Run(); err == nil {
+		t.Fatal("Expected an error because of a broken mirror. Got none")
+	}
+	if failed := r.Filter(report.StatusFailed); len(failed) != 1 || failed[0].Package != "twig/twig" {
+		t.Fatalf("Expected twig/twig as broken mirror. Got %+v", failed)
+	}
+	if n := r.Counts()[report.StatusVerified]; n != 1 {
+		t.Errorf("Expected one verified mirror. Got %d", n)
+	}
+
+	// With repair the mirror is cloned again
+	r = report.New("verify")
+	c.Repair = true
+	c.Report = r
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if repaired := r.Filter(report.StatusRepaired); len(repaired) != 1 || repaired[0].Package != "twig/twig" {
+		t.Fatalf("Expected twig/twig as repaired mirror. Got %+v", repaired)
+	}
+
+	// Afterwards everything is fine
+	r = report.New("verify")
+	c.Repair = false
+	c.Report = r
+	if err := c.Run(); err != nil {
+		t.Fatalf("Expected no error after the repair. Got %s", err)
+	}
+	if n := r.Counts()[report.StatusVerified]; n != 2 {
+		t.Errorf("Expected two verified mirrors. Got %d", n)
+	}
+}
+
+func TestVerifyController_Run_Rotate(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	m, _, cleanup := setupVerify(t)
+	defer cleanup()
+
+	c := &VerifyController{
+		Rotate:      2,
+		Config:      m,
+		Log:         newDiscardLogger(),
+		NumOfWorker: 1,
+	}
+
+	// Every run verifies the mirror with the oldest verification
+	for _, expected := range []string{"symfony/console", "twig/twig", "symfony/console"} {
+		r := report.New("verify")
+		c.Report = r
+		if err := c.Run(); err != nil {
+			t.Fatalf("Expected no error. Got %s", err)
+		}
+		verified := r.Filter(report.StatusVerified)
+		if len(verified) != 1 || verified[0].Package != expected {
+			t.Errorf("Expected %s to be verified. Got %+v", expected, verified)
+		}
+	}
+}
+
+func TestVerifyController_Run_Repair_ImmutableTags(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	_, repoDir, cleanup := setupVerify(t)
+	defer cleanup()
+
+	v := viper.New()
+	v.SetConfigType("json")
+	if err := v.ReadConfig(bytes.NewBufferString(fmt.Sprintf(`{"repodir": %q, "immutable-tags": true}`, repoDir))); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := config.NewViperProvider(v)
+	m, _ := config.NewMedusa(p)
+
+	// symfony/console has a kept tag and an archive, but a ref to a missing object
+	console := filepath.Join(repoDir, "symfony", "console.git")
+	kept := git(t, console, "commit-tree", "-m", "Kept release", "4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+	git(t, console, "update-ref", "refs/tags/v1.0.0", kept)
+	git(t, console, "update-ref", "refs/perseus/archived/tags/v1.0.1/deleted", kept)
+	if err := ioutil.WriteFile(filepath.Join(console, "refs", "heads", "broken"), []byte("0123456789012345678901234567890123456789\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// twig/twig lost all objects, including the ones of its tag
+	twig := filepath.Join(repoDir, "twig", "twig.git")
+	git(t, twig, "tag", "v1.0.0")
+	corruptMirror(t, twig)
+
+	r := report.New("verify")
+	c := &VerifyController{
+		Config:      m,
+		Log:         newDiscardLogger(),
+		NumOfWorker: 2,
+		Repair:      true,
+		Report:      r,
+	}
+	if err := c.Run(); err == nil {
+		t.Fatal("Expected an error because twig/twig can't be repaired. Got none")
+	}
+	if repaired := r.Filter(report.StatusRepaired); len(repaired) != 1 || repaired[0].Package != "symfony/console" {
+		t.Fatalf("Expected symfony/console as repaired mirror. Got %+v", repaired)
+	}
+	if failed := r.Filter(report.StatusFailed); len(failed) != 1 || failed[0].Package != "twig/twig" {
+		t.Fatalf("Expected twig/twig as failed mirror. Got %+v", failed)
+	}
+
+	// The new clone kept the tag and the archive
+	for _, ref := range []string{"refs/tags/v1.0.0", "refs/perseus/archived/tags/v1.0.1/deleted"} {
+		if o := git(t, console, "rev-parse", ref); o != kept {
+			t.Errorf("Expected %s to point to %s. Got %s", ref, kept, o)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(twig, "refs", "tags", "v1.0.0")); err != nil {
+		t.Errorf("Expected twig/twig to be left in place. Got %s", err)
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/perseus/config"
+	"github.com/andygrunwald/perseus/dependency"
+)
+
+// WhyController reflects the business logic and the Command interface to explain
+// why a package is mirrored. It prints all require chains from the configured packages to the package.
+// This command is independent from an human interface (CLI, HTTP, etc.)
+// The human interfaces will interact with this command.
+type WhyController struct {
+	// Package is the package that should be explained
+	Package string
+	// Limit is the maximum number of require chains that will be printed (0 = unlimited)
+	Limit int
+	// Config is the main medusa configuration
+	Config *config.Medusa
+	// Log represents a logger to log messages
+	Log logrus.FieldLogger
+	// NumOfWorker is the number of worker used for concurrent actions (like resolving the dependency tree)
+	NumOfWorker int
+	// ResolverOptions are the settings for the dependency resolver.
+	// If nil, the settings of Config will be used.
+	ResolverOptions *dependency.ResolverOptions
+	// Out is the writer where the explanation will be written to (default: os.Stdout)
+	Out io.Writer
+}
+
+// Run is the business logic of WhyCommand.
+func (c *WhyController) Run() error {
+	if len(c.Package) == 0 {
+		return errors.New("No package applied. Please apply the package that should be explained")
+	}
+
+	out := c.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	g, err := resolveDependencyGraph(c.Config, getResolverOptions(c.ResolverOptions, c.Config), c.Log, nil, c.NumOfWorker, getConfiguredPackageNames(c.Config))
+	if err != nil {
+		return err
+	}
+
+	if g.IsRoot(c.Package) {
+		fmt.Fprintf(out, "%s is configured in the medusa configuration\n", c.Package)
+	}
+
+	chains := g.Why(c.Package, c.Limit)
+	if len(chains) == 0 {
+		if !g.IsRoot(c.Package) {
+			fmt.Fprintf(out, "%s is not required by any configured package\n", c.Package)
+		}
+		return nil
+	}
+
+	fmt.Fprintf(out, "%s is required by the following require chains:\n", c.Package)
+	for _, chain := range chains {
+		fmt.Fprintf(out, "  %s\n", formatRequireChain(chain))
+	}
+
+	return nil
+}
+
+// formatRequireChain returns a human readable representation of a require chain like
+// symfony/console -> symfony/debug (~2.8|~3.0) -> psr/log (~1.0)
+func formatRequireChain(chain []*dependency.Edge) string {
+	if len(chain) == 0 {
+		return ""
+	}
+
+	parts := []string{chain[0].From}
+	for _, e := range chain {
+		parts = append(parts, fmt.Sprintf("%s (%s)", e.To, e.Label()))
+	}
+	return strings.Join(parts, " -> ")
+}
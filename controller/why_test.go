@@ -0,0 +1,18 @@
+package controller_test
+
+import (
+	"testing"
+
+	. "github.com/andygrunwald/perseus/controller"
+)
+
+func TestWhyController_Run_WithEmptyPackage(t *testing.T) {
+	c := &WhyController{
+		Package: "",
+	}
+
+	err := c.Run()
+	if err == nil {
+		t.Fatal("Expected error while passing an empty package. Got none")
+	}
+}
@@ -0,0 +1,335 @@
+// Package credentials provides the credentials for private git hosts and Packagist instances.
+//
+// Credentials are configured per host. They are applied to git operations via the environment
+// (a credential helper for HTTPS, GIT_SSH_COMMAND for SSH) and to HTTP requests via a http.RoundTripper.
+// Secrets never become part of a URL or a command line.
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Host reflects the credentials of a single host.
+type Host struct {
+	// Username is the username for HTTP basic authentication.
+	// If empty and Token is set, "x-access-token" will be used.
+	Username string
+	// Password is the password for HTTP basic authentication
+	Password string
+	// Token is an access token (like a GitHub or GitLab token).
+	// It is used as password for HTTP basic authentication.
+	Token string
+	// SSHKey is the path of the private key for SSH connections
+	SSHKey string
+	// KnownHosts is the path of the known_hosts file for SSH connections.
+	// If set, the host key will be checked strictly.
+	KnownHosts string
+}
+
+// BasicAuth returns the username and password for HTTP basic authentication.
+// If the host has no credentials for HTTP, ok is false.
+func (h *Host) BasicAuth() (username, password string, ok bool) {
+	switch {
+	case len(h.Password) > 0:
+		return h.Username, h.Password, true
+	case len(h.Token) > 0:
+		username = h.Username
+		if len(username) == 0 {
+			username = "x-access-token"
+		}
+		return username, h.Token, true
+	}
+	return "", "", false
+}
+
+// Store contains the credentials of all hosts.
+// A nil Store is valid and has no credentials.
+type Store struct {
+	hosts map[string]*Host
+}
+
+// New returns an empty Store.
+func New() *Store {
+	return &Store{
+		hosts: map[string]*Host{},
+	}
+}
+
+// Set sets the credentials of host.
+// Fields of h that are not empty overwrite the current credentials of host.
+func (s *Store) Set(host string, h *Host) {
+	host = strings.ToLower(host)
+	c, ok := s.hosts[host]
+	if !ok {
+		s.hosts[host] = h
+		return
+	}
+
+	if len(h.Username) > 0 {
+		c.Username = h.Username
+	}
+	if len(h.Password) > 0 {
+		c.Password = h.Password
+	}
+	if len(h.Token) > 0 {
+		c.Token = h.Token
+	}
+	if len(h.SSHKey) > 0 {
+		c.SSHKey = h.SSHKey
+	}
+	if len(h.KnownHosts) > 0 {
+		c.KnownHosts = h.KnownHosts
+	}
+}
+
+// Get returns the credentials of host.
+func (s *Store) Get(host string) (*Host, bool) {
+	if s == nil {
+		return nil, false
+	}
+	h, ok := s.hosts[strings.ToLower(host)]
+	return h, ok
+}
+
+// Hosts returns the names of all hosts with credentials (sorted).
+func (s *Store) Hosts() []string {
+	if s == nil {
+		return []string{}
+	}
+	hosts := make([]string, 0, len(s.hosts))
+	for h := range s.hosts {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Secrets returns all passwords and tokens of the store.
+// They need to be masked before they are written somewhere (see Masker).
+func (s *Store) Secrets() []string {
+	secrets := []string{}
+	if s == nil {
+		return secrets
+	}
+	for _, h := range s.hosts {
+		for _, secret := range []string{h.Password, h.Token} {
+			if len(secret) > 0 {
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+	return secrets
+}
+
+// authJSON reflects the parts of the Composer auth.json that are supported.
+// Checkout https://getcomposer.org/doc/articles/authentication-for-private-packages.md
+type authJSON struct {
+	HTTPBasic   map[string]authJSONBasic `json:"http-basic"`
+	GitHubOAuth map[string]string        `json:"github-oauth"`
+	GitLabToken map[string]authJSONToken `json:"gitlab-token"`
+	GitLabOAuth map[string]string        `json:"gitlab-oauth"`
+}
+
+type authJSONBasic struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// authJSONToken is a GitLab token. Composer allows a plain token or an object with username and token.
+type authJSONToken struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler to support both kinds of values: string and object.
+func (t *authJSONToken) UnmarshalJSON(b []byte) error {
+	var token string
+	if err := json.Unmarshal(b, &token); err == nil {
+		t.Token = token
+		return nil
+	}
+
+	type plain authJSONToken
+	var p plain
+	if err := json.Unmarshal(b, &p); err != nil {
+		return fmt.Errorf("GitLab token must be a string or an object with username and token. Got %s", b)
+	}
+	*t = authJSONToken(p)
+	return nil
+}
+
+// AddAuthJSON adds the credentials of a Composer auth.json (with the keys
+// "http-basic", "github-oauth", "gitlab-token" and "gitlab-oauth") to the store.
+func (s *Store) AddAuthJSON(b []byte) error {
+	var a authJSON
+	if err := json.Unmarshal(b, &a); err != nil {
+		return err
+	}
+
+	for host, c := range a.HTTPBasic {
+		s.Set(host, &Host{Username: c.Username, Password: c.Password})
+	}
+	for host, token := range a.GitHubOAuth {
+		s.Set(host, &Host{Token: token})
+	}
+	for host, c := range a.GitLabToken {
+		username := c.Username
+		if len(username) == 0 {
+			username = "oauth2"
+		}
+		s.Set(host, &Host{Username: username, Token: c.Token})
+	}
+	for host, token := range a.GitLabOAuth {
+		s.Set(host, &Host{Username: "oauth2", Token: token})
+	}
+	return nil
+}
+
+// LoadAuthJSON adds the credentials of the Composer auth.json file at path to the store (see AddAuthJSON).
+func (s *Store) LoadAuthJSON(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := s.AddAuthJSON(b); err != nil {
+		return fmt.Errorf("Couldn't parse auth.json %s: %s", path, err)
+	}
+	return nil
+}
+
+// scpLikeURL matches the scp like syntax of git URLs like git@github.com:symfony/console.git
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):`)
+
+// getHost returns the host of the git repository URL repository and if it is accessed via SSH.
+func getHost(repository string) (string, bool) {
+	u, err := url.Parse(repository)
+	if err == nil && len(u.Scheme) > 0 && len(u.Host) > 0 {
+		return u.Hostname(), u.Scheme == "ssh" || strings.HasPrefix(u.Scheme, "git+ssh")
+	}
+	if m := scpLikeURL.FindStringSubmatch(repository); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// GetHost returns the host name of the git repository URL repository
+// (like "github.com" for "git@github.com:symfony/console.git").
+// If the URL has no host (like a local path), an empty string will be returned.
+func GetHost(repository string) string {
+	host, _ := getHost(repository)
+	return host
+}
+
+// GitEnv returns the environment for a git command that talks to the git repository URL repository.
+// If the store has no credentials for the host of repository, nil is returned
+// and the git command inherits the environment of the current process.
+//
+// HTTPS credentials are provided by a credential helper that reads them from the environment.
+// This requires git 2.31 or newer.
+func (s *Store) GitEnv(repository string) []string {
+	return s.GitEnvFor(repository, "")
+}
+
+// GitEnvFor is like GitEnv, but uses the credentials of the entry name instead of
+// the credentials of the host of repository. If name is empty, it is equal to GitEnv.
+// This allows different credentials for repositories of the same host (like deploy keys).
+func (s *Store) GitEnvFor(repository, name string) []string {
+	host, ssh := getHost(repository)
+	if len(name) > 0 {
+		host = name
+	}
+	h, ok := s.Get(host)
+	if !ok {
+		return nil
+	}
+
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if ssh {
+		if len(h.SSHKey) == 0 && len(h.KnownHosts) == 0 {
+			return nil
+		}
+		return append(env, "GIT_SSH_COMMAND="+sshCommand(h))
+	}
+
+	username, password, ok := h.BasicAuth()
+	if !ok {
+		return nil
+	}
+	return append(env,
+		// An empty helper resets all configured helpers
+		"GIT_CONFIG_COUNT=2",
+		"GIT_CONFIG_KEY_0=credential.helper",
+		"GIT_CONFIG_VALUE_0=",
+		"GIT_CONFIG_KEY_1=credential.helper",
+		`GIT_CONFIG_VALUE_1=!f() { test "$1" = get && echo "username=$PERSEUS_GIT_USERNAME" && echo "password=$PERSEUS_GIT_PASSWORD"; }; f`,
+		"PERSEUS_GIT_USERNAME="+username,
+		"PERSEUS_GIT_PASSWORD="+password,
+	)
+}
+
+// sshCommand returns the ssh command with the key and known_hosts of h.
+func sshCommand(h *Host) string {
+	cmd := []string{"ssh"}
+	if len(h.SSHKey) > 0 {
+		cmd = append(cmd, "-i", shellQuote(h.SSHKey), "-o", "IdentitiesOnly=yes")
+	}
+	if len(h.KnownHosts) > 0 {
+		cmd = append(cmd, "-o", "UserKnownHostsFile="+shellQuote(h.KnownHosts), "-o", "StrictHostKeyChecking=yes")
+	}
+	return strings.Join(cmd, " ")
+}
+
+// shellQuote quotes s for the usage in a shell command.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// transport is a http.RoundTripper that adds the credentials of the requested host
+type transport struct {
+	store *Store
+	next  http.RoundTripper
+}
+
+// NewTransport returns a http.RoundTripper that adds HTTP basic authentication
+// to every request of a host with credentials in store.
+// Requests that are authenticated already are not touched.
+// Requests are executed by next. If next is nil, http.DefaultTransport will be used.
+func NewTransport(store *Store, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{
+		store: store,
+		next:  next,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h, ok := t.store.Get(req.URL.Hostname())
+	if !ok || len(req.Header.Get("Authorization")) > 0 {
+		return t.next.RoundTrip(req)
+	}
+	username, password, ok := h.BasicAuth()
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.SetBasicAuth(username, password)
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,157 @@
+package credentials_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/credentials"
+)
+
+func TestHost_BasicAuth(t *testing.T) {
+	tests := []struct {
+		Host     *Host
+		Username string
+		Password string
+		OK       bool
+	}{
+		{&Host{Username: "perseus", Password: "secret"}, "perseus", "secret", true},
+		{&Host{Token: "token"}, "x-access-token", "token", true},
+		{&Host{Username: "oauth2", Token: "token"}, "oauth2", "token", true},
+		{&Host{SSHKey: "/home/perseus/.ssh/id_rsa"}, "", "", false},
+	}
+
+	for _, tt := range tests {
+		u, p, ok := tt.Host.BasicAuth()
+		if u != tt.Username || p != tt.Password || ok != tt.OK {
+			t.Errorf("Expected BasicAuth() of %+v = %s, %s, %v. Got %s, %s, %v", tt.Host, tt.Username, tt.Password, tt.OK, u, p, ok)
+		}
+	}
+}
+
+func TestStore_AddAuthJSON(t *testing.T) {
+	authJSON := `{
+		"http-basic": {"repo.company.tld": {"username": "perseus", "password": "secret"}},
+		"github-oauth": {"github.com": "gh-token"},
+		"gitlab-token": {"gitlab.com": "gl-token", "gitlab.company.tld": {"username": "perseus", "token": "gl-token2"}}
+	}`
+
+	s := New()
+	if err := s.AddAuthJSON([]byte(authJSON)); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	tests := []struct {
+		Host     string
+		Username string
+		Password string
+	}{
+		{"repo.company.tld", "perseus", "secret"},
+		{"github.com", "x-access-token", "gh-token"},
+		{"GitHub.com", "x-access-token", "gh-token"},
+		{"gitlab.com", "oauth2", "gl-token"},
+		{"gitlab.company.tld", "perseus", "gl-token2"},
+	}
+	for _, tt := range tests {
+		h, ok := s.Get(tt.Host)
+		if !ok {
+			t.Errorf("Expected credentials for host %s. Got none", tt.Host)
+			continue
+		}
+		if u, p, _ := h.BasicAuth(); u != tt.Username || p != tt.Password {
+			t.Errorf("Expected %s:%s for host %s. Got %s:%s", tt.Username, tt.Password, tt.Host, u, p)
+		}
+	}
+
+	if err := s.AddAuthJSON([]byte(`{"gitlab-token": {"gitlab.com": 42}}`)); err == nil {
+		t.Error("Expected an error for an invalid GitLab token. Got none")
+	}
+}
+
+func TestStore_GitEnv(t *testing.T) {
+	s := New()
+	s.Set("github.com", &Host{Token: "gh-token"})
+	s.Set("git.company.tld", &Host{SSHKey: "/home/perseus/.ssh/id_rsa", KnownHosts: "/home/perseus/.ssh/known_hosts"})
+
+	tests := []struct {
+		Repository string
+		Contains   string
+	}{
+		{"https://github.com/symfony/console.git", "PERSEUS_GIT_PASSWORD=gh-token"},
+		{"git@git.company.tld:myvendor/package.git", "GIT_SSH_COMMAND=ssh -i '/home/perseus/.ssh/id_rsa'"},
+		{"ssh://git@git.company.tld/myvendor/package.git", "UserKnownHostsFile='/home/perseus/.ssh/known_hosts'"},
+		// No credentials
+		{"https://gitlab.com/myvendor/package.git", ""},
+		{"git@github.com:symfony/console.git", ""},
+	}
+
+	for _, tt := range tests {
+		env := s.GitEnv(tt.Repository)
+		if len(tt.Contains) == 0 {
+			if env != nil {
+				t.Errorf("Expected no environment for %s. Got %v", tt.Repository, env)
+			}
+			continue
+		}
+		if !strings.Contains(strings.Join(env, "\n"), tt.Contains) {
+			t.Errorf("Expected environment for %s to contain %q. Got %v", tt.Repository, tt.Contains, env)
+		}
+	}
+
+	var nilStore *Store
+	if env := nilStore.GitEnv("https://github.com/symfony/console.git"); env != nil {
+		t.Errorf("Expected no environment without a store. Got %v", env)
+	}
+}
+
+func TestStore_GitEnvFor(t *testing.T) {
+	s := New()
+	s.Set("github.com", &Host{Token: "gh-token"})
+	s.Set("deploy-key-console", &Host{SSHKey: "/home/perseus/.ssh/console"})
+
+	env := strings.Join(s.GitEnvFor("ssh://git@github.com/symfony/console.git", "deploy-key-console"), "\n")
+	if !strings.Contains(env, "GIT_SSH_COMMAND=ssh -i '/home/perseus/.ssh/console'") {
+		t.Errorf("Expected environment to contain the deploy key. Got %v", env)
+	}
+
+	env = strings.Join(s.GitEnvFor("https://github.com/symfony/console.git", ""), "\n")
+	if !strings.Contains(env, "PERSEUS_GIT_PASSWORD=gh-token") {
+		t.Errorf("Expected environment to contain the credentials of the host. Got %v", env)
+	}
+
+	if env := s.GitEnvFor("https://github.com/symfony/console.git", "unknown"); env != nil {
+		t.Errorf("Expected no environment for unknown credentials. Got %v", env)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	s := New()
+	c := &http.Client{Transport: NewTransport(s, nil)}
+
+	// Without credentials the request is not authenticated
+	if _, err := c.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	if len(auth) > 0 {
+		t.Errorf("Expected no Authorization header. Got %s", auth)
+	}
+
+	s.Set(req.URL.Hostname(), &Host{Username: "perseus", Password: "secret"})
+	if _, err := c.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Basic cGVyc2V1czpzZWNyZXQ="; auth != expected {
+		t.Errorf("Expected Authorization header %s. Got %s", expected, auth)
+	}
+	if len(req.Header.Get("Authorization")) > 0 {
+		t.Error("Expected the original request to be untouched")
+	}
+}
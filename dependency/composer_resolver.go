@@ -2,10 +2,11 @@ package dependency
 
 import (
 	"fmt"
-	"strings"
+	"sort"
 	"sync"
 
 	"github.com/andygrunwald/perseus/dependency/repository"
+	"github.com/andygrunwald/perseus/metrics"
 	"github.com/andygrunwald/perseus/types/set"
 )
 
@@ -18,15 +19,61 @@ type ComposerResolver struct {
 	workerCount int
 	waitGroup   sync.WaitGroup
 	// queue is the channel where all jobs are stored that needs to be processed by the worker
-	queue chan *Package
+	queue chan *job
 	// results is the channel where all resolved dependencies will be streamed
 	results chan *Result
 	// resolved is a storage to track which packages are already resolved
 	resolved *set.Set
 	// queued is a storage to track which packages were already queued
 	queued *set.Set
-	// replacee is a hashmap to replace old/renamed/obsolete packages that would throw an error otherwise
-	replacee map[string]string
+	// ignore is a list of glob patterns of packages that should not be resolved
+	ignore []string
+	// requireDev is the follow mode for the "require-dev" section (see Follow* constants)
+	requireDev string
+	// suggest is the follow mode for the "suggest" section (see Follow* constants)
+	suggest string
+	// maxDepth limits how deep dependencies will be resolved (0 = no limit)
+	maxDepth int
+	// versions is the global version policy (nil = all versions are allowed)
+	versions *VersionPolicy
+	// packageVersions are version policies per package that replace the global policy
+	packageVersions map[string]*VersionPolicy
+	// aliases is a hashmap to replace old/renamed/obsolete packages that would throw an error otherwise
+	aliases map[string]string
+	// replaced is a hashmap of package names that are replaced or provided by other (already resolved) packages.
+	// Key is the replaced/provided package, value the set of packages that replace/provide it.
+	replaced     map[string]map[string]bool
+	replacedLock sync.RWMutex
+	// deferred are the jobs of all discovered, but not yet resolved packages (except root packages).
+	// They are decided once the queue is drained (see queueDeferred).
+	deferred map[string]*job
+	// unavailable are the failed results of packages that might be provided by another package (like virtual packages).
+	// They are reported once all packages are resolved, if no other package provides them.
+	unavailable  map[string]*Result
+	deferredLock sync.Mutex
+	// jobs are the latest jobs per package name. They track the minimum depth of every package (see lowerDepth).
+	jobs     map[string]*job
+	jobsLock sync.Mutex
+	// graph is the dependency graph with all parent -> child edges discovered during the resolve process
+	graph *Graph
+}
+
+// job is a single package in the queue of the resolver.
+type job struct {
+	// pkg is the package to resolve
+	pkg *Package
+	// depth is the shortest known distance to the root package (root packages have a depth of 0).
+	// It can be lowered until the dependencies of the package are followed (see lowerDepth).
+	depth int
+	// kind is the kind of the edge the package was discovered with (empty for root packages)
+	kind string
+	// decided is true if the package should be resolved, because it is not only required by its replacers (see queueDeferred)
+	decided bool
+	// followed is true once the dependencies of the package are followed with depth
+	followed bool
+	// refollow is true if the package was resolved already, but its dependencies need to be followed
+	// again with a lower depth (see lowerDepth). No result is reported for it.
+	refollow bool
 }
 
 // GetResultStream will return the channel for results.
@@ -36,26 +83,43 @@ func (d *ComposerResolver) GetResultStream() <-chan *Result {
 	return d.results
 }
 
+// GetGraph returns the dependency graph.
+// The graph is complete once the result stream is closed.
+func (d *ComposerResolver) GetGraph() *Graph {
+	return d.graph
+}
+
 // Resolve will start of the dependency resolver process.
 func (d *ComposerResolver) Resolve(packageList []*Package) {
 	d.startWorker()
 
 	// Queue packages
 	for _, p := range packageList {
-		d.queuePackage(p)
+		d.graph.AddRoot(p.Name)
+		d.queuePackage(&job{pkg: p})
 	}
 
-	// Wait until all packages are resolved and close everything
+	// The packages are resolved level by level: Dependencies that are discovered while the queue
+	// is processed are deferred and decided once the queue is drained (see queueDeferred).
+	// With this, the decision about replaced or provided packages never depends on
+	// the order in which the workers resolve the packages of a level.
 	d.waitGroup.Wait()
+	for d.queueDeferred() {
+		d.waitGroup.Wait()
+	}
+
+	// Close everything
+	d.reportUnavailable()
 	close(d.queue)
 	close(d.results)
 }
 
-// QueuePackage adds package p to the queue
-func (d *ComposerResolver) queuePackage(p *Package) {
+// QueuePackage adds job j to the queue
+func (d *ComposerResolver) queuePackage(j *job) {
 	d.waitGroup.Add(1)
-	d.markAsQueued(p.Name)
-	d.queue <- p
+	d.trackJob(j)
+	metrics.ResolverQueueDepth.Add(1)
+	d.queue <- j
 }
 
 // startWorker will boot up the worker routines
@@ -70,35 +134,69 @@ func (d *ComposerResolver) startWorker() {
 // id is a unique number assigned per worker (only for logging/debugging purpose).
 // jobs is the jobs channel. The worker needs to be able to add more jobs to the queue as well.
 // results is the channel where all results will be stored once they are resolved.
-func (d *ComposerResolver) worker(id int, queue chan<- *Package, results chan<- *Result) {
+func (d *ComposerResolver) worker(id int, queue chan<- *job, results chan<- *Result) {
 	// Worker has started. Lets do the hard work. Gimme the jobs.
-	for j := range d.queue {
+	for qj := range d.queue {
+		metrics.ResolverQueueDepth.Add(-1)
+		j := qj.pkg
 		packageName := j.Name
 
-		// We don't need to process system packages.
-		// System packages (like php or ext-curl) needs to be fulfilled by the system.
+		// We don't need to process platform packages.
+		// Platform packages (like php or ext-curl) needs to be fulfilled by the system.
 		// Not by the ApiClient
-		if d.isSystemPackage(packageName) {
+		if IsPlatformPackage(packageName) {
 			d.waitGroup.Done()
 			continue
 		}
 
+		// Packages that are not following the "vendor/package" format
+		// are not available at the ApiClient.
+		if !IsValidPackageName(packageName) {
+			d.skip(qj, "Not a valid package name (vendor/package)")
+			continue
+		}
+
 		// We overwrite specific packages, because they are added as dependencies to some older tags.
 		// And those was renamed (for some reasons). But we are scanning all tags / branches.
-		if r, ok := d.replacee[packageName]; ok {
+		if r, ok := d.aliases[packageName]; ok {
 			packageName = r
 		}
 
+		// The user doesn't want to resolve this package.
+		if pattern := MatchesPattern(packageName, d.ignore); len(pattern) > 0 {
+			d.skip(qj, fmt.Sprintf("Matches ignore pattern \"%s\"", pattern))
+			continue
+		}
+
+		// If the package is replaced or provided by another package, we might not need to fetch it.
+		// This is decided once the queue is drained (see queueDeferred).
+		// Root packages are an exception. They were requested on purpose.
+		if !qj.decided && !qj.refollow && !d.graph.IsRoot(j.Name) {
+			d.deferJob(packageName, qj)
+			d.waitGroup.Done()
+			continue
+		}
+
 		// Get information about the package from ApiClient
 		p, resp, err := d.repository.GetPackageByName(packageName)
+		if qj.refollow && (err != nil || p == nil) {
+			// The result of the package was reported already
+			d.waitGroup.Done()
+			continue
+		}
 		if err != nil {
 			// API Call error here. Request to Packagist failed
-			r := &Result{
-				Package: j,
-				Response: resp,
-				Error:   fmt.Errorf("API returned status code %d: %s", resp.StatusCode, err),
+			// Virtual packages (like psr/log-implementation) are not available at Packagist.
+			// If it is provided by another package, this is not an error (see reportUnavailable).
+			if resp != nil {
+				err = fmt.Errorf("API returned status code %d: %s", resp.StatusCode, err)
 			}
-			results <- r
+			d.deferUnavailable(packageName, &Result{
+				Package:  j,
+				Response: resp,
+				Error:    err,
+				Kind:     qj.kind,
+			})
 			d.waitGroup.Done()
 			continue
 		}
@@ -108,9 +206,10 @@ func (d *ComposerResolver) worker(id int, queue chan<- *Package, results chan<-
 		if p == nil {
 			// API Call error here. No package received from Packagist
 			r := &Result{
-				Package: j,
+				Package:  j,
 				Response: resp,
-				Error:   fmt.Errorf("API Call to Packagist successful (Status code %d), but no package received", resp.StatusCode),
+				Error:    fmt.Errorf("API Call to Packagist successful (Status code %d), but no package received", resp.StatusCode),
+				Kind:     qj.kind,
 			}
 			results <- r
 			d.waitGroup.Done()
@@ -118,46 +217,47 @@ func (d *ComposerResolver) worker(id int, queue chan<- *Package, results chan<-
 		}
 
 		// Now we got the package.
+		// First we remember which packages are replaced or provided by this package.
+		// Those don't need to be fetched anymore.
+		policy := d.getVersionPolicy(p.Name)
+		d.markAsReplaced(p, policy)
+
 		// Let us determine all requirements / dependencies from all versions,
-		// because those packages needs to be resolved as well
-		for _, version := range p.Versions {
-			// If we don` have required packaged, we can handle the next one
-			if len(version.Require) == 0 {
-				continue
-			}
+		// because those packages needs to be resolved as well.
+		// Dependencies beyond the max depth won't be followed at all.
+		depth := d.followDepth(qj)
+		if d.maxDepth == 0 || depth < d.maxDepth {
+			followRequireDev := d.shouldSectionBeFollowed(d.requireDev, depth)
+			followSuggest := d.shouldSectionBeFollowed(d.suggest, depth)
 
-			// Handle dependency per dependency
-			for dependency := range version.Require {
-				// We check if this dependency was already queued.
-				// It is typical that many different versions of one package don't
-				// change dependencies so often. So we would queue one package
-				// multiple times. With this small check we save a lot of work here.
-				if d.shouldPackageBeQueued(dependency) {
-					d.markAsQueued(dependency)
-
-					packageToResolve, _ := NewPackage(dependency, "")
-					// We add two additional waitgroup entries here.
-					// You might ask why? Regularly we add a new entry when we have a new package.
-					// Here we add two, because of a) the new package and b) the new queue
-					// entry of the package. We queue the package in a new go routine to
-					// avoid a blocking state here. But we need to know when this go routine
-					// is finished. So we observice this "Add package to queue" go routine
-					// with the same waitgroup.
-					d.waitGroup.Add(2)
-					go func() {
-						queue <- packageToResolve
-						d.waitGroup.Done()
-					}()
+			for name, version := range p.Versions {
+				// Versions that are not allowed by the policy (like feature branches)
+				// should not pull in other packages.
+				if !policy.Allows(name) {
+					continue
+				}
+				d.queueDependencies(p.Name, depth+1, EdgeKindRequire, version.Require, queue)
+				if followRequireDev {
+					d.queueDependencies(p.Name, depth+1, EdgeKindRequireDev, version.RequireDev, queue)
+				}
+				if followSuggest {
+					d.queueDependencies(p.Name, depth+1, EdgeKindSuggest, version.Suggest, queue)
 				}
 			}
 		}
+		if qj.refollow {
+			d.waitGroup.Done()
+			continue
+		}
 
 		// Package was resolved. Lets do everything which is necessary to change this package to a result.
 		resolvedPackage, err := NewPackage(p.Name, p.Repository)
 		r := &Result{
-			Package: resolvedPackage,
-			Response: resp,
-			Error:   err,
+			Package:   resolvedPackage,
+			Response:  resp,
+			Error:     err,
+			Kind:      qj.kind,
+			Abandoned: getAbandoned(p, policy),
 		}
 		results <- r
 		d.waitGroup.Done()
@@ -165,11 +265,288 @@ func (d *ComposerResolver) worker(id int, queue chan<- *Package, results chan<-
 	}
 }
 
+// queueDependencies adds all dependencies of package p to the graph and queues them if necessary.
+// dependencies is a section (like "require") of a composer.json of kind (see EdgeKind* constants).
+// depth is the depth of the dependencies (depth of p + 1).
+func (d *ComposerResolver) queueDependencies(p string, depth int, kind string, dependencies map[string]string, queue chan<- *job) {
+	// Handle dependency per dependency
+	for dependency, constraint := range dependencies {
+		if IsPlatformPackage(dependency) {
+			continue
+		}
+		if r, ok := d.aliases[dependency]; ok {
+			dependency = r
+		}
+		// Suggestions come with a human readable description instead of a constraint.
+		if kind == EdgeKindSuggest {
+			constraint = ""
+		}
+
+		// Every requirement is an edge in the dependency graph.
+		// Even if the dependency was already queued by someone else.
+		d.graph.AddEdge(p, dependency, kind, constraint)
+
+		// We check if this dependency was already queued.
+		// It is typical that many different versions of one package don't
+		// change dependencies so often. So we would queue one package
+		// multiple times. With this small check we save a lot of work here.
+		if j := d.getJob(dependency, depth, kind); j != nil {
+			// We add two additional waitgroup entries here.
+			// You might ask why? Regularly we add a new entry when we have a new package.
+			// Here we add two, because of a) the new package and b) the new queue
+			// entry of the package. We queue the package in a new go routine to
+			// avoid a blocking state here. But we need to know when this go routine
+			// is finished. So we observice this "Add package to queue" go routine
+			// with the same waitgroup.
+			d.waitGroup.Add(2)
+			metrics.ResolverQueueDepth.Add(1)
+			go func() {
+				queue <- j
+				d.waitGroup.Done()
+			}()
+		}
+	}
+}
+
+// trackJob marks the package of job j as queued and remembers j as the latest job of the package (see lowerDepth).
+func (d *ComposerResolver) trackJob(j *job) {
+	d.jobsLock.Lock()
+	defer d.jobsLock.Unlock()
+
+	d.markAsQueued(j.pkg.Name)
+	d.jobs[j.pkg.Name] = j
+}
+
+// getJob returns the job to queue for the dependency p of kind at depth:
+// A new job if p should be queued (see shouldPackageBeQueued), a job that follows the dependencies
+// of p again if depth is a shorter path to p (see lowerDepth) or nil.
+func (d *ComposerResolver) getJob(p string, depth int, kind string) *job {
+	d.jobsLock.Lock()
+	defer d.jobsLock.Unlock()
+
+	if !d.shouldPackageBeQueued(p) {
+		return d.lowerDepth(p, depth)
+	}
+
+	d.markAsQueued(p)
+	packageToResolve, _ := NewPackage(p, "")
+	j := &job{
+		pkg:   packageToResolve,
+		depth: depth,
+		kind:  kind,
+	}
+	d.jobs[p] = j
+	return j
+}
+
+// followDepth marks the dependencies of the package of job j as followed and returns the depth to follow them with.
+func (d *ComposerResolver) followDepth(j *job) int {
+	d.jobsLock.Lock()
+	defer d.jobsLock.Unlock()
+
+	j.followed = true
+	return j.depth
+}
+
+// lowerDepth lowers the depth of package p to depth, if depth is lower than the known depth of p.
+// If the dependencies of p were not followed yet, the pending job is updated.
+// Otherwise a job is returned that follows the dependencies of p again with the lower depth.
+// Without a max depth, the depth of a package doesn't change what is resolved. So nil is returned.
+// The caller is responsible to hold d.jobsLock.
+func (d *ComposerResolver) lowerDepth(p string, depth int) *job {
+	if d.maxDepth == 0 {
+		return nil
+	}
+
+	j, ok := d.jobs[p]
+	if !ok || depth >= j.depth {
+		return nil
+	}
+	if !j.followed {
+		j.depth = depth
+		return nil
+	}
+
+	n := &job{
+		pkg:      j.pkg,
+		depth:    depth,
+		kind:     j.kind,
+		refollow: true,
+	}
+	d.jobs[p] = n
+	return n
+}
+
+// shouldSectionBeFollowed returns true if the dependencies of a section (like "require-dev")
+// with follow mode should be resolved for a package at depth.
+func (d *ComposerResolver) shouldSectionBeFollowed(mode string, depth int) bool {
+	switch mode {
+	case FollowAll:
+		return true
+	case FollowRoot:
+		return depth == 0
+	}
+	return false
+}
+
+// skip reports the package of job j as skipped with reason to the result stream.
+func (d *ComposerResolver) skip(j *job, reason string) {
+	d.results <- &Result{
+		Package: j.pkg,
+		Error: &SkipError{
+			Package: j.pkg.Name,
+			Reason:  reason,
+		},
+		Kind: j.kind,
+	}
+	d.waitGroup.Done()
+}
+
 // markAsResolved will mark package p as resolved.
 func (d *ComposerResolver) markAsResolved(p string) {
 	d.resolved.Add(p)
 }
 
+// markAsReplaced will remember all packages that are replaced or provided by package p.
+// Only versions that are allowed by policy are respected.
+// The package itself is skipped, because packages might replace themselves (e.g. "self.version").
+func (d *ComposerResolver) markAsReplaced(p *repository.PackagistPackage, policy *VersionPolicy) {
+	d.replacedLock.Lock()
+	defer d.replacedLock.Unlock()
+
+	for name, version := range p.Versions {
+		if !policy.Allows(name) {
+			continue
+		}
+		for _, l := range []map[string]string{version.Replace, version.Provide} {
+			for name := range l {
+				if name == p.Name {
+					continue
+				}
+				if _, ok := d.replaced[name]; !ok {
+					d.replaced[name] = make(map[string]bool)
+				}
+				d.replaced[name][p.Name] = true
+			}
+		}
+	}
+}
+
+// getAbandoned returns the abandoned setting of package p.
+// The package is abandoned if at least one version that is allowed by policy is abandoned.
+// The replacement of the first abandoned version (sorted by name) with a replacement wins.
+func getAbandoned(p *repository.PackagistPackage, policy *VersionPolicy) repository.Abandoned {
+	versions := make([]string, 0, len(p.Versions))
+	for name := range p.Versions {
+		versions = append(versions, name)
+	}
+	sort.Strings(versions)
+
+	a := repository.Abandoned{}
+	for _, name := range versions {
+		v := p.Versions[name].Abandoned
+		if !v.IsAbandoned || !policy.Allows(name) {
+			continue
+		}
+		a.IsAbandoned = true
+		if len(a.Replacement) == 0 {
+			a.Replacement = v.Replacement
+		}
+	}
+	return a
+}
+
+// getVersionPolicy returns the version policy for package p.
+// A policy for the package itself has precedence over the global policy.
+// The returned policy might be nil (all versions are allowed).
+func (d *ComposerResolver) getVersionPolicy(p string) *VersionPolicy {
+	if policy, ok := d.packageVersions[p]; ok {
+		return policy
+	}
+	return d.versions
+}
+
+// isPackageReplaced returns true if package p is replaced or provided by another package.
+// False otherwise.
+func (d *ComposerResolver) isPackageReplaced(p string) bool {
+	d.replacedLock.RLock()
+	defer d.replacedLock.RUnlock()
+
+	_, ok := d.replaced[p]
+	return ok
+}
+
+// deferJob remembers the job j of package p until the queue is drained (see queueDeferred).
+func (d *ComposerResolver) deferJob(p string, j *job) {
+	d.deferredLock.Lock()
+	defer d.deferredLock.Unlock()
+
+	d.deferred[p] = j
+}
+
+// deferUnavailable remembers the failed result r of package p (see reportUnavailable).
+func (d *ComposerResolver) deferUnavailable(p string, r *Result) {
+	d.deferredLock.Lock()
+	defer d.deferredLock.Unlock()
+
+	d.unavailable[p] = r
+}
+
+// queueDeferred queues all deferred packages that are not replaced or provided by another package
+// or that are required by another package than their replacers.
+// A replaced package is only skipped, if every chain of requirements from a root package to it passes one of its replacers.
+// Skipped packages stay deferred, because a later level might require them elsewhere.
+// All deferred packages are decided with the packages that were resolved before.
+// With this, the decision doesn't depend on the order the packages were resolved in.
+// It returns true if at least one package was queued.
+// It needs to be called once all queued packages are resolved.
+func (d *ComposerResolver) queueDeferred() bool {
+	d.deferredLock.Lock()
+	names := make([]string, 0, len(d.deferred))
+	for p := range d.deferred {
+		names = append(names, p)
+	}
+	d.deferredLock.Unlock()
+	sort.Strings(names)
+
+	queued := false
+	for _, p := range names {
+		d.replacedLock.RLock()
+		replacers := d.replaced[p]
+		d.replacedLock.RUnlock()
+		if len(replacers) > 0 && !d.graph.IsReachableWithout(p, replacers) {
+			continue
+		}
+
+		d.deferredLock.Lock()
+		j := d.deferred[p]
+		delete(d.deferred, p)
+		d.deferredLock.Unlock()
+
+		j.decided = true
+		d.queuePackage(j)
+		queued = true
+	}
+	return queued
+}
+
+// reportUnavailable reports the failed results of all packages that are not provided by another package.
+// It needs to be called once all packages are resolved.
+func (d *ComposerResolver) reportUnavailable() {
+	names := make([]string, 0, len(d.unavailable))
+	for p := range d.unavailable {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+
+	for _, p := range names {
+		if d.isPackageReplaced(p) {
+			continue
+		}
+		d.results <- d.unavailable[p]
+	}
+}
+
 // markAsQueued will mark package p as queued.
 func (d *ComposerResolver) markAsQueued(p string) {
 	d.queued.Add(p)
@@ -178,11 +555,12 @@ func (d *ComposerResolver) markAsQueued(p string) {
 // shouldPackageBeQueued will return true if package p should be queued.
 // False otherwise.
 // A package should be queued if
-// - it is not a system package
+// - it is not a platform package
 // - was not already queued
 // - was not already resolved
+// Replaced or provided packages are queued as well. They are decided once the queue is drained (see queueDeferred).
 func (d *ComposerResolver) shouldPackageBeQueued(p string) bool {
-	if d.isSystemPackage(p) {
+	if IsPlatformPackage(p) {
 		return false
 	}
 
@@ -208,28 +586,3 @@ func (d *ComposerResolver) isPackageAlreadyResolved(p string) bool {
 func (d *ComposerResolver) isPackageAlreadyQueued(p string) bool {
 	return d.queued.Exists(p)
 }
-
-// isSystemPackage returns true if p is a system package. False otherwise.
-//
-// A system package is a package that is not part of your package repository
-// and and it needs to be fulfilled by the system.
-// Examples: php, ext-curl
-func (d *ComposerResolver) isSystemPackage(p string) bool {
-	// If the package name don't contain a "/" we will skip it here.
-	// In a composer.json in the require / require-dev part you normally add packages
-	// you depend on. A package name follows the format "vendor/package".
-	// E.g. symfony/console
-	// You can put other dependencies in here as well like `php` or `ext-zip`.
-	// Those dependencies will be skipped (because they don`t have a vendor ;)).
-	// The reason is simple: If you try to request the package "php" at packagist
-	// you won`t get a JSON response with information we expect.
-	// You will get valid HTML of the packagist search.
-	// To avoid those errors and to save API calls we skip dependencies without a vendor.
-	//
-	// This follows the documentation as well:
-	//
-	// 	The package name consists of a vendor name and the project's name.
-	// 	Often these will be identical - the vendor name just exists to prevent naming clashes.
-	//	Source: https://getcomposer.org/doc/01-basic-usage.md
-	return !strings.Contains(p, "/")
-}
@@ -1,18 +1,20 @@
 package dependency_test
 
 import (
+	"fmt"
+	"reflect"
+	"sort"
 	"testing"
 
-	"fmt"
 	. "github.com/andygrunwald/perseus/dependency"
 	"github.com/andygrunwald/perseus/dependency/repository"
 )
 
 // resolvePackages is a small helper function to avoid code duplication
-// It will start the dependency resolver for packageName
-func resolvePackages(t testError, packageName string) []*Result {
+// It will start the dependency resolver for packageName with resolver options o
+func resolvePackages(t testError, packageName string, o *ResolverOptions) []*Result {
 	apiClient := &testApiClient{}
-	d, err := NewComposerResolver(3, apiClient)
+	d, err := NewComposerResolver(3, apiClient, o)
 	if err != nil {
 		t.Errorf("Didn't expected an error. Got %s", err)
 	}
@@ -43,7 +45,7 @@ func isStringInResult(needle string, haystack []*Result) bool {
 }
 
 func TestComposerResolver_SystemPackage(t *testing.T) {
-	got := resolvePackages(t, "php")
+	got := resolvePackages(t, "php", nil)
 
 	if len(got) > 0 {
 		t.Errorf("Didn't expected results. Got %+v", got)
@@ -52,7 +54,7 @@ func TestComposerResolver_SystemPackage(t *testing.T) {
 
 func TestComposerResolver_ApiClientError(t *testing.T) {
 	p := "api/error"
-	got := resolvePackages(t, p)
+	got := resolvePackages(t, p, nil)
 
 	if got[0].Error == nil {
 		t.Errorf("Expected an error for package %s to emulate an API error. Got nothing", p)
@@ -61,7 +63,7 @@ func TestComposerResolver_ApiClientError(t *testing.T) {
 
 func TestComposerResolver_EmptyPackageFromApiClient(t *testing.T) {
 	p := "api/empty"
-	got := resolvePackages(t, p)
+	got := resolvePackages(t, p, nil)
 
 	if got[0].Error == nil {
 		t.Errorf("Expected an error for package %s to emulate an empty package from API. Got nothing", p)
@@ -70,7 +72,7 @@ func TestComposerResolver_EmptyPackageFromApiClient(t *testing.T) {
 
 func TestComposerResolver_SuccessSymfonyConsole(t *testing.T) {
 	p := "symfony/console"
-	got := resolvePackages(t, p)
+	got := resolvePackages(t, p, nil)
 
 	if n := len(got); n != 4 {
 		t.Errorf("Expected four resolved dependencies. Got %d: %+v", n, got)
@@ -96,10 +98,40 @@ func TestComposerResolver_SuccessSymfonyConsole(t *testing.T) {
 	}
 }
 
+func TestComposerResolver_Graph(t *testing.T) {
+	d, err := NewComposerResolver(3, &testApiClient{}, nil)
+	if err != nil {
+		t.Fatalf("Didn't expected an error. Got %s", err)
+	}
+	results := d.GetResultStream()
+	p, _ := NewPackage("symfony/console", "")
+	go d.Resolve([]*Package{p})
+	for range results {
+	}
+
+	g := d.GetGraph()
+	if !g.IsRoot("symfony/console") {
+		t.Errorf("Expected symfony/console to be a root package. Got roots %+v", g.Roots())
+	}
+
+	// 3 requirements + the circular one of psr/log. System packages like php are no edges.
+	if n := len(g.Edges()); n != 4 {
+		t.Errorf("Expected four edges. Got %d: %+v", n, g.Edges())
+	}
+
+	chains := g.Why("psr/log", 0)
+	if n := len(chains); n != 1 {
+		t.Fatalf("Expected one require chain for psr/log. Got %d", n)
+	}
+	if c := chains[0][0].Constraints; len(c) != 2 {
+		t.Errorf("Expected two constraints for symfony/console -> symfony/debug. Got %+v", c)
+	}
+}
+
 func BenchmarkComposerResolver_SuccessSymfonyConsole(b *testing.B) {
 	p := "symfony/console"
 	for n := 0; n < b.N; n++ {
-		resolvePackages(b, p)
+		resolvePackages(b, p, nil)
 	}
 }
 
@@ -114,13 +146,277 @@ func TestComposerResolver_ReplacedPackageNames(t *testing.T) {
 		{"zendframework/zend-registry", "zf1/zend-registry"},
 	}
 
+	o := &ResolverOptions{
+		Aliases: map[string]string{
+			"symfony/translator":          "symfony/translation",
+			"symfony/doctrine-bundle":     "doctrine/doctrine-bundle",
+			"metadata/metadata":           "jms/metadata",
+			"zendframework/zend-registry": "zf1/zend-registry",
+		},
+	}
+
 	for _, tt := range tests {
-		if got := resolvePackages(t, tt.packageName); got[0].Package.Name != tt.replacedPackageName {
+		if got := resolvePackages(t, tt.packageName, o); got[0].Package.Name != tt.replacedPackageName {
 			t.Errorf("Package %s was not replaced as expected. Expected: %s, got: %s", tt.packageName, tt.replacedPackageName, got[0].Package.Name)
 		}
 	}
 }
 
+func TestComposerResolver_AliasesAreNotBuiltIn(t *testing.T) {
+	p := "symfony/translator"
+	got := resolvePackages(t, p, nil)
+
+	if n := len(got); n != 1 {
+		t.Fatalf("Expected one result. Got %d: %+v", n, got)
+	}
+	if got[0].Error == nil {
+		t.Errorf("Expected an error for package %s without a configured alias. Got nothing", p)
+	}
+}
+
+func TestComposerResolver_ReplacedAndProvidedPackages(t *testing.T) {
+	p := "app/replace-and-provide"
+	got := resolvePackages(t, p, nil)
+
+	for _, r := range got {
+		if r.Error != nil {
+			t.Errorf("Didn't expected an error for package %s. Got %s", r.Package.Name, r.Error)
+		}
+	}
+
+	// symfony/polyfill-mbstring is replaced by symfony/polyfill
+	// psr/log-implementation is a virtual package provided by monolog/monolog
+	for _, name := range []string{"symfony/polyfill-mbstring", "psr/log-implementation"} {
+		if isStringInResult(name, got) {
+			t.Errorf("Package %s is replaced or provided by another package. Expected it not to be in resultset.", name)
+		}
+	}
+	for _, name := range []string{p, "symfony/polyfill", "monolog/monolog"} {
+		if !isStringInResult(name, got) {
+			t.Errorf("Expected package %s in resultset. Not found.", name)
+		}
+	}
+}
+
+func TestComposerResolver_ReplacedPackageRequiredElsewhere(t *testing.T) {
+	// symfony/polyfill-mbstring is replaced by symfony/polyfill, but required by symfony/console as well.
+	// The result must not depend on the order the packages are resolved in.
+	for i := 0; i < 20; i++ {
+		got := resolvePackages(t, "app/replaced-elsewhere", nil)
+		for _, name := range []string{"symfony/polyfill", "symfony/console", "symfony/polyfill-mbstring"} {
+			if !isStringInResult(name, got) {
+				t.Fatalf("Run %d: Expected package %s in resultset. Not found.", i, name)
+			}
+		}
+	}
+}
+
+func TestComposerResolver_ReplaceeBeforeReplacer(t *testing.T) {
+	// order/replacee is resolvable before its slow replacer order/replacer, but required by order/fast as well.
+	// order/replaced-only is only required by its replacer.
+	for i := 0; i < 5; i++ {
+		got := resolvePackages(t, "order/root", nil)
+		for _, name := range []string{"order/root", "order/fast", "order/replacer", "order/replacee"} {
+			if !isStringInResult(name, got) {
+				t.Fatalf("Run %d: Expected package %s in resultset. Not found.", i, name)
+			}
+		}
+		if isStringInResult("order/replaced-only", got) {
+			t.Fatalf("Run %d: Package order/replaced-only is only required by its replacer. Expected it not to be in resultset.", i)
+		}
+	}
+}
+
+func TestComposerResolver_AbandonedPackage(t *testing.T) {
+	got := resolvePackages(t, "app/abandoned", nil)
+	if len(got) != 1 || got[0].Error != nil {
+		t.Fatalf("Expected one result without error. Got %+v", got)
+	}
+	if a := got[0].Abandoned; !a.IsAbandoned || a.Replacement != "app/successor" {
+		t.Errorf("Expected app/abandoned to be abandoned in favor of app/successor. Got %+v", a)
+	}
+
+	// Abandoned versions that are not allowed by the version policy don't count
+	o := &ResolverOptions{Versions: &VersionPolicy{Exclude: []string{"1.1.0", "dev-*"}}}
+	got = resolvePackages(t, "app/abandoned", o)
+	if len(got) != 1 || got[0].Abandoned.IsAbandoned {
+		t.Errorf("Expected app/abandoned not to be abandoned for the allowed versions. Got %+v", got)
+	}
+}
+
+func TestComposerResolver_MaxDepth_ShortestPath(t *testing.T) {
+	// depth/target is discovered at depth 3 via the long path first and at depth 2 via the short path afterwards.
+	// With the shortest path, depth/leaf is at depth 3 and within the max depth.
+	got := resolvePackages(t, "depth/root", &ResolverOptions{MaxDepth: 3})
+
+	names := []string{}
+	for _, r := range got {
+		if r.Error != nil {
+			t.Errorf("Didn't expected an error for package %s. Got %s", r.Package.Name, r.Error)
+		}
+		names = append(names, r.Package.Name)
+	}
+	sort.Strings(names)
+
+	expected := []string{"depth/leaf", "depth/long", "depth/longer", "depth/root", "depth/short", "depth/target"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected every package once: %v. Got %v", expected, names)
+	}
+}
+
+func TestComposerResolver_IgnoredPackages(t *testing.T) {
+	o := &ResolverOptions{
+		Ignore: []string{"symfony/debug", "psr/*"},
+	}
+	got := resolvePackages(t, "symfony/console", o)
+
+	skipped := 0
+	for _, r := range got {
+		if r.Package.Name == "psr/log" {
+			t.Errorf("Package psr/log is required by an ignored package. Expected it not to be resolved.")
+		}
+		if IsSkipped(r.Error) {
+			skipped++
+			if r.Package.Name != "symfony/debug" {
+				t.Errorf("Expected only symfony/debug to be skipped. Got %s", r.Package.Name)
+			}
+			continue
+		}
+		if r.Error != nil {
+			t.Errorf("Didn't expected an error for package %s. Got %s", r.Package.Name, r.Error)
+		}
+	}
+
+	if skipped != 1 {
+		t.Errorf("Expected one skipped package. Got %d", skipped)
+	}
+	if n := len(got); n != 3 {
+		t.Errorf("Expected three results (2 resolved, 1 skipped). Got %d: %+v", n, got)
+	}
+}
+
+func TestComposerResolver_InvalidPackageName(t *testing.T) {
+	got := resolvePackages(t, "phpunit", nil)
+
+	if n := len(got); n != 1 {
+		t.Fatalf("Expected one result. Got %d", n)
+	}
+	if !IsSkipped(got[0].Error) {
+		t.Errorf("Expected package phpunit to be skipped. Got %+v", got[0].Error)
+	}
+}
+
+func TestComposerResolver_RequireDevAndSuggest(t *testing.T) {
+	tests := []struct {
+		options  *ResolverOptions
+		expected []string
+	}{
+		// Default: Only the require section
+		{nil, []string{"app/dev", "psr/log", "symfony/console", "symfony/debug", "symfony/polyfill-mbstring"}},
+		{&ResolverOptions{RequireDev: FollowRoot}, []string{"app/dev", "phpunit/phpunit", "psr/log", "symfony/console", "symfony/debug", "symfony/polyfill-mbstring"}},
+		{&ResolverOptions{RequireDev: FollowAll}, []string{"app/dev", "dev/only", "phpunit/phpunit", "psr/log", "symfony/console", "symfony/debug", "symfony/polyfill-mbstring"}},
+		{&ResolverOptions{Suggest: FollowRoot}, []string{"app/dev", "monolog/monolog", "psr/log", "symfony/console", "symfony/debug", "symfony/polyfill-mbstring"}},
+		{&ResolverOptions{RequireDev: FollowAll, MaxDepth: 1}, []string{"app/dev", "phpunit/phpunit", "symfony/debug"}},
+	}
+
+	for _, tt := range tests {
+		got := resolvePackages(t, "app/dev", tt.options)
+		names := []string{}
+		for _, r := range got {
+			if r.Error != nil {
+				t.Errorf("Options %+v: Didn't expected an error for package %s. Got %s", tt.options, r.Package.Name, r.Error)
+			}
+			names = append(names, r.Package.Name)
+		}
+		sort.Strings(names)
+
+		if !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("Options %+v: Got different packages than expected. Expected %+v, got %+v", tt.options, tt.expected, names)
+		}
+	}
+}
+
+func TestComposerResolver_VersionPolicy(t *testing.T) {
+	all := []string{"app/branches", "dev/only", "phpunit/phpunit", "psr/log", "symfony/console", "symfony/debug", "symfony/polyfill-mbstring"}
+	tests := []struct {
+		options  *ResolverOptions
+		expected []string
+	}{
+		{nil, all},
+		{&ResolverOptions{Versions: &VersionPolicy{MinimumStability: StabilityStable}}, []string{"app/branches", "dev/only"}},
+		{&ResolverOptions{Versions: &VersionPolicy{MinimumStability: StabilityBeta}}, []string{"app/branches", "dev/only", "phpunit/phpunit"}},
+		{&ResolverOptions{Versions: &VersionPolicy{Exclude: []string{"dev-feature-*"}}}, []string{"app/branches", "dev/only", "phpunit/phpunit"}},
+		{&ResolverOptions{Versions: &VersionPolicy{Include: []string{"v1.0.*"}}}, []string{"app/branches", "dev/only"}},
+		// The policy of a package has precedence over the global policy
+		{&ResolverOptions{
+			Versions: &VersionPolicy{MinimumStability: StabilityStable},
+			PackageVersions: map[string]*VersionPolicy{
+				"app/branches": {MinimumStability: StabilityDev},
+			},
+		}, all},
+	}
+
+	for _, tt := range tests {
+		got := resolvePackages(t, "app/branches", tt.options)
+		names := []string{}
+		for _, r := range got {
+			if r.Error != nil {
+				t.Errorf("Options %+v: Didn't expected an error for package %s. Got %s", tt.options, r.Package.Name, r.Error)
+			}
+			names = append(names, r.Package.Name)
+		}
+		sort.Strings(names)
+
+		if !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("Options %+v: Got different packages than expected. Expected %+v, got %+v", tt.options, tt.expected, names)
+		}
+	}
+}
+
+func TestComposerResolver_EdgeKinds(t *testing.T) {
+	apiClient := &testApiClient{}
+	o := &ResolverOptions{
+		RequireDev: FollowRoot,
+		Suggest:    FollowRoot,
+	}
+	d, err := NewComposerResolver(3, apiClient, o)
+	if err != nil {
+		t.Fatalf("Didn't expected an error. Got %s", err)
+	}
+	results := d.GetResultStream()
+	p, _ := NewPackage("app/dev", "")
+	go d.Resolve([]*Package{p})
+
+	kinds := map[string]string{}
+	for r := range results {
+		kinds[r.Package.Name] = r.Kind
+	}
+
+	expected := map[string]string{
+		"app/dev":         "",
+		"symfony/debug":   EdgeKindRequire,
+		"phpunit/phpunit": EdgeKindRequireDev,
+		"monolog/monolog": EdgeKindSuggest,
+	}
+	for name, kind := range expected {
+		if kinds[name] != kind {
+			t.Errorf("Expected kind %q for package %s. Got %q", kind, name, kinds[name])
+		}
+	}
+
+	for _, e := range d.GetGraph().Edges() {
+		if e.From != "app/dev" {
+			continue
+		}
+		if e.Kind != expected[e.To] {
+			t.Errorf("Expected edge %s -> %s of kind %q. Got %q", e.From, e.To, expected[e.To], e.Kind)
+		}
+		if e.Kind == EdgeKindSuggest && len(e.Constraints) > 0 {
+			t.Errorf("Expected no constraints for a suggestion. Got %+v", e.Constraints)
+		}
+	}
+}
+
 func ExampleComposerResolver() {
 	u := "https://packagist.org/"
 	packageName := "symfony/console"
@@ -139,7 +435,7 @@ func ExampleComposerResolver() {
 	}
 
 	// Create a composer resolver and inject the packagist client
-	resolver, err := NewComposerResolver(numOfWorker, packagistClient)
+	resolver, err := NewComposerResolver(numOfWorker, packagistClient, nil)
 	if err != nil {
 		panic(err)
 	}
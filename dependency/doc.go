@@ -27,6 +27,11 @@ of packages. And this algorithm is not stable.
 Means: The order of the package can be different each time.
 The caller code must be able to handle it.
 
+Next to the result stream, every resolver records the dependency graph it discovered.
+A Graph contains all parent -> child edges incl. the version constraints that introduced them.
+It can be used to explain why a package is required (see Graph.Why) or to render the graph
+in formats like DOT, JSON or Mermaid (see Graph.Write).
+
 Checkout the examples on how to use a resolver in combination with a repository.Client.
 */
 package dependency
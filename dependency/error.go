@@ -0,0 +1,27 @@
+package dependency
+
+import (
+	"fmt"
+)
+
+// SkipError reflects an own error dedicated to the situation
+// that a package was skipped on purpose during the resolve process
+// (e.g. because it matches an ignore pattern).
+type SkipError struct {
+	// Package is the name of the skipped package
+	Package string
+	// Reason describes why the package was skipped
+	Reason string
+}
+
+// Error returns the error message
+func (e *SkipError) Error() string {
+	return fmt.Sprintf("Package %s skipped: %s", e.Package, e.Reason)
+}
+
+// IsSkipped returns a boolean indicating whether the error is known to report
+// that a package was skipped on purpose.
+func IsSkipped(err error) bool {
+	_, ok := err.(*SkipError)
+	return ok
+}
@@ -0,0 +1,25 @@
+package dependency_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/dependency"
+)
+
+func TestIsSkipped(t *testing.T) {
+	tests := []struct {
+		err    error
+		result bool
+	}{
+		{&SkipError{Package: "my-vendor/package", Reason: "Ignored"}, true},
+		{errors.New("Dummy error"), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if res := IsSkipped(tt.err); res != tt.result {
+			t.Errorf("Expected IsSkipped(%+v) to be %+v. Got %+v.", tt.err, tt.result, res)
+		}
+	}
+}
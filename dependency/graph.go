@@ -0,0 +1,328 @@
+package dependency
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	// EdgeKindRequire is an edge from the "require" section of a composer.json
+	EdgeKindRequire = "require"
+	// EdgeKindRequireDev is an edge from the "require-dev" section of a composer.json
+	EdgeKindRequireDev = "require-dev"
+	// EdgeKindSuggest is an edge from the "suggest" section of a composer.json
+	EdgeKindSuggest = "suggest"
+)
+
+// edgeKindWeight ranks the edge kinds. The lower the weight, the stronger the relation.
+var edgeKindWeight = map[string]int{
+	EdgeKindRequire:    0,
+	EdgeKindRequireDev: 1,
+	EdgeKindSuggest:    2,
+}
+
+// Edge reflects a single parent -> child relation in the dependency graph.
+// A package (From) requires another package (To) with one or more version constraints.
+type Edge struct {
+	// From is the name of the package that declares the requirement (e.g. "symfony/console")
+	From string `json:"from"`
+	// To is the name of the package that is required (e.g. "symfony/debug")
+	To string `json:"to"`
+	// Constraints are all version constraints that introduced this edge.
+	// Different versions of From might require To with different constraints (e.g. "~2.8|~3.0").
+	Constraints []string `json:"constraints"`
+	// Kind is the section of the composer.json that introduced this edge (see EdgeKind* constants).
+	// If different versions of From declare To in different sections, the strongest kind wins
+	// (require before require-dev before suggest).
+	Kind string `json:"kind"`
+}
+
+// Graph reflects the dependency graph that was discovered during a resolver run.
+// Root packages are the packages the resolver was started with.
+// Graph is threadsafe, because resolver worker add edges concurrently.
+type Graph struct {
+	lock  sync.RWMutex
+	roots map[string]struct{}
+	nodes map[string]struct{}
+	// edges is a map of parent name -> child name -> edge
+	edges map[string]map[string]*Edge
+}
+
+// NewGraph will create a new and empty dependency graph.
+func NewGraph() *Graph {
+	g := &Graph{
+		roots: make(map[string]struct{}),
+		nodes: make(map[string]struct{}),
+		edges: make(map[string]map[string]*Edge),
+	}
+	return g
+}
+
+// AddRoot adds package p as a root package to the graph.
+func (g *Graph) AddRoot(p string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.roots[p] = struct{}{}
+	g.nodes[p] = struct{}{}
+}
+
+// AddEdge adds the requirement "from requires to with constraint" of kind (see EdgeKind* constants) to the graph.
+// If the edge exists already, the constraint will be added to the existing edge.
+// An empty constraint (e.g. for suggestions) adds the edge without a constraint.
+func (g *Graph) AddEdge(from, to, kind, constraint string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.addEdgeUnlocked(from, to, kind, constraint)
+}
+
+// IsRoot returns true if package p is a root package of the graph.
+func (g *Graph) IsRoot(p string) bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	_, ok := g.roots[p]
+	return ok
+}
+
+// Exists returns true if package p is part of the graph.
+func (g *Graph) Exists(p string) bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	_, ok := g.nodes[p]
+	return ok
+}
+
+// Roots returns the names of all root packages sorted by name.
+func (g *Graph) Roots() []string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return sortedKeys(g.roots)
+}
+
+// Nodes returns the names of all packages in the graph sorted by name.
+func (g *Graph) Nodes() []string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return sortedKeys(g.nodes)
+}
+
+// Edges returns all edges of the graph sorted by parent and child name.
+func (g *Graph) Edges() []*Edge {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	l := []*Edge{}
+	for _, from := range sortedEdgeKeys(g.edges) {
+		children := g.edges[from]
+		for _, to := range sortedChildKeys(children) {
+			l = append(l, children[to])
+		}
+	}
+	return l
+}
+
+// Subgraph returns a new graph with root as the only root package
+// and all packages that are reachable from root.
+func (g *Graph) Subgraph(root string) *Graph {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	s := NewGraph()
+	if _, ok := g.nodes[root]; !ok {
+		return s
+	}
+	s.roots[root] = struct{}{}
+	s.nodes[root] = struct{}{}
+
+	visited := map[string]struct{}{root: {}}
+	queue := []string{root}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+
+		for to, e := range g.edges[from] {
+			s.addEdgeUnlocked(from, to, e.Kind, "")
+			for _, c := range e.Constraints {
+				s.addEdgeUnlocked(from, to, e.Kind, c)
+			}
+			if _, ok := visited[to]; !ok {
+				visited[to] = struct{}{}
+				queue = append(queue, to)
+			}
+		}
+	}
+
+	return s
+}
+
+// IsReachableWithout returns true if package p is a root package or reachable from a root package
+// without passing one of the packages of without.
+func (g *Graph) IsReachableWithout(p string, without map[string]bool) bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	if _, ok := g.roots[p]; ok {
+		return true
+	}
+
+	visited := map[string]bool{}
+	queue := []string{}
+	for root := range g.roots {
+		if !without[root] {
+			visited[root] = true
+			queue = append(queue, root)
+		}
+	}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+
+		for to := range g.edges[from] {
+			if to == p {
+				return true
+			}
+			if visited[to] || without[to] {
+				continue
+			}
+			visited[to] = true
+			queue = append(queue, to)
+		}
+	}
+	return false
+}
+
+// Why returns the require chains that lead from a root package to package p.
+// Every chain starts at a root package and ends with p.
+// Circular dependencies are not followed. To keep the output readable on big graphs,
+// not more than limit chains will be returned. A limit of zero or less means no limit.
+func (g *Graph) Why(p string, limit int) [][]*Edge {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	// We walk the graph backwards: From p to the roots.
+	parents := make(map[string][]*Edge)
+	for _, children := range g.edges {
+		for to, e := range children {
+			parents[to] = append(parents[to], e)
+		}
+	}
+	for _, l := range parents {
+		sort.Sort(edgesByName(l))
+	}
+
+	chains := [][]*Edge{}
+	visited := map[string]bool{p: true}
+	path := []*Edge{}
+
+	var walk func(name string) bool
+	walk = func(name string) bool {
+		if _, ok := g.roots[name]; ok && len(path) > 0 {
+			chain := make([]*Edge, 0, len(path))
+			for i := len(path) - 1; i >= 0; i-- {
+				chain = append(chain, path[i])
+			}
+			chains = append(chains, chain)
+			if limit > 0 && len(chains) >= limit {
+				return false
+			}
+			// A root package was requested on purpose.
+			// There is no need to explain why it is required by others.
+			return true
+		}
+
+		for _, e := range parents[name] {
+			if visited[e.From] {
+				continue
+			}
+			visited[e.From] = true
+			path = append(path, e)
+			goOn := walk(e.From)
+			path = path[:len(path)-1]
+			visited[e.From] = false
+			if !goOn {
+				return false
+			}
+		}
+		return true
+	}
+	walk(p)
+
+	return chains
+}
+
+// addEdgeUnlocked is AddEdge without locking.
+// The caller is responsible to hold the lock.
+func (g *Graph) addEdgeUnlocked(from, to, kind, constraint string) {
+	g.nodes[from] = struct{}{}
+	g.nodes[to] = struct{}{}
+
+	children, ok := g.edges[from]
+	if !ok {
+		children = make(map[string]*Edge)
+		g.edges[from] = children
+	}
+
+	e, ok := children[to]
+	if !ok {
+		e = &Edge{From: from, To: to, Constraints: []string{}, Kind: kind}
+		children[to] = e
+	}
+	if w, ok := edgeKindWeight[kind]; ok && w < edgeKindWeight[e.Kind] {
+		e.Kind = kind
+	}
+
+	if len(constraint) == 0 {
+		return
+	}
+	for _, c := range e.Constraints {
+		if c == constraint {
+			return
+		}
+	}
+	e.Constraints = append(e.Constraints, constraint)
+	sort.Strings(e.Constraints)
+}
+
+// edgesByName sorts edges by parent and child name
+type edgesByName []*Edge
+
+func (l edgesByName) Len() int      { return len(l) }
+func (l edgesByName) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l edgesByName) Less(i, j int) bool {
+	if l[i].From == l[j].From {
+		return l[i].To < l[j].To
+	}
+	return l[i].From < l[j].From
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	l := make([]string, 0, len(m))
+	for k := range m {
+		l = append(l, k)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func sortedEdgeKeys(m map[string]map[string]*Edge) []string {
+	l := make([]string, 0, len(m))
+	for k := range m {
+		l = append(l, k)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func sortedChildKeys(m map[string]*Edge) []string {
+	l := make([]string, 0, len(m))
+	for k := range m {
+		l = append(l, k)
+	}
+	sort.Strings(l)
+	return l
+}
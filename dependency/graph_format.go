@@ -0,0 +1,161 @@
+package dependency
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// GraphFormatDOT is the Graphviz DOT language (https://graphviz.org/doc/info/lang.html)
+	GraphFormatDOT = "dot"
+	// GraphFormatJSON is a JSON document with a list of packages and edges
+	GraphFormatJSON = "json"
+	// GraphFormatMermaid is a Mermaid flowchart (https://mermaid-js.github.io/)
+	GraphFormatMermaid = "mermaid"
+)
+
+// graphDocument is the JSON representation of a Graph
+type graphDocument struct {
+	Roots    []string `json:"roots"`
+	Packages []string `json:"packages"`
+	Edges    []*Edge  `json:"edges"`
+}
+
+// IsValidGraphFormat returns true if format is a supported output format for a Graph.
+func IsValidGraphFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case GraphFormatDOT, GraphFormatJSON, GraphFormatMermaid:
+		return true
+	}
+	return false
+}
+
+// Write writes graph g in format to w.
+// Supported formats are GraphFormatDOT, GraphFormatJSON and GraphFormatMermaid.
+func (g *Graph) Write(w io.Writer, format string) error {
+	switch strings.ToLower(format) {
+	case GraphFormatDOT:
+		return g.WriteDOT(w)
+	case GraphFormatJSON:
+		return g.WriteJSON(w)
+	case GraphFormatMermaid:
+		return g.WriteMermaid(w)
+	}
+
+	return fmt.Errorf("Unknown graph format \"%s\". Supported formats: %s, %s, %s", format, GraphFormatDOT, GraphFormatJSON, GraphFormatMermaid)
+}
+
+// WriteDOT writes graph g in the Graphviz DOT language to w.
+// Root packages are drawn as boxes, edges are labeled with their constraints.
+// require-dev edges are drawn dashed, suggest edges dotted.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	b := &errWriter{w: w}
+
+	b.printf("digraph dependencies {\n")
+	b.printf("\trankdir=LR;\n")
+	b.printf("\tnode [shape=ellipse];\n")
+	for _, n := range g.Nodes() {
+		if g.IsRoot(n) {
+			b.printf("\t%s [shape=box];\n", dotQuote(n))
+		} else {
+			b.printf("\t%s;\n", dotQuote(n))
+		}
+	}
+	for _, e := range g.Edges() {
+		style := ""
+		switch e.Kind {
+		case EdgeKindRequireDev:
+			style = ", style=dashed"
+		case EdgeKindSuggest:
+			style = ", style=dotted"
+		}
+		b.printf("\t%s -> %s [label=%s%s];\n", dotQuote(e.From), dotQuote(e.To), dotQuote(e.Label()), style)
+	}
+	b.printf("}\n")
+
+	return b.err
+}
+
+// WriteJSON writes graph g as JSON document to w.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	d := graphDocument{
+		Roots:    g.Roots(),
+		Packages: g.Nodes(),
+		Edges:    g.Edges(),
+	}
+
+	b, err := json.MarshalIndent(&d, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// WriteMermaid writes graph g as Mermaid flowchart to w.
+// Mermaid is picky about node identifiers. This is why every package
+// gets a generated identifier and the package name as label.
+// require-dev and suggest edges are drawn as dotted links.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	b := &errWriter{w: w}
+
+	ids := make(map[string]string)
+	b.printf("graph LR\n")
+	for i, n := range g.Nodes() {
+		id := fmt.Sprintf("p%d", i)
+		ids[n] = id
+		if g.IsRoot(n) {
+			b.printf("    %s[\"%s\"]\n", id, mermaidEscape(n))
+		} else {
+			b.printf("    %s(\"%s\")\n", id, mermaidEscape(n))
+		}
+	}
+	for _, e := range g.Edges() {
+		link := "-->"
+		if e.Kind == EdgeKindRequireDev || e.Kind == EdgeKindSuggest {
+			link = "-.->"
+		}
+		b.printf("    %s %s|\"%s\"| %s\n", ids[e.From], link, mermaidEscape(e.Label()), ids[e.To])
+	}
+
+	return b.err
+}
+
+// errWriter is a small helper to write formatted output and to remember
+// the first error that occurred. Following writes will be skipped.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (b *errWriter) printf(format string, a ...interface{}) {
+	if b.err != nil {
+		return
+	}
+	_, b.err = fmt.Fprintf(b.w, format, a...)
+}
+
+// Label returns a human readable label of edge e.
+// The label consists of the constraints. Other kinds than require are prefixed with the kind
+// (e.g. "require-dev: ^6.0").
+func (e *Edge) Label() string {
+	l := strings.Join(e.Constraints, ", ")
+	if len(e.Kind) == 0 || e.Kind == EdgeKindRequire {
+		return l
+	}
+	if len(l) == 0 {
+		return e.Kind
+	}
+	return e.Kind + ": " + l
+}
+
+func dotQuote(s string) string {
+	return "\"" + strings.Replace(s, "\"", "\\\"", -1) + "\""
+}
+
+func mermaidEscape(s string) string {
+	r := strings.NewReplacer("\"", "#quot;", "|", "#124;")
+	return r.Replace(s)
+}
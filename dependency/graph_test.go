@@ -0,0 +1,214 @@
+package dependency_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/dependency"
+)
+
+// unitTestGraph returns a small graph for unit testing
+//
+//	symfony/console
+//	|- symfony/polyfill-mbstring
+//	|- symfony/debug
+//		|- psr/log
+//			|- symfony/console (circular)
+func unitTestGraph() *Graph {
+	g := NewGraph()
+	g.AddRoot("symfony/console")
+	g.AddEdge("symfony/console", "symfony/polyfill-mbstring", EdgeKindRequire, "~1.0")
+	g.AddEdge("symfony/console", "symfony/debug", EdgeKindRequire, "~2.8|~3.0")
+	g.AddEdge("symfony/console", "symfony/debug", EdgeKindRequire, "~2.7,>=2.7.2|~3.0.0")
+	g.AddEdge("symfony/debug", "psr/log", EdgeKindRequire, "~1.0")
+	g.AddEdge("psr/log", "symfony/console", EdgeKindRequire, "~3.0")
+	return g
+}
+
+func TestGraph_AddEdge_MergesConstraints(t *testing.T) {
+	g := unitTestGraph()
+	g.AddEdge("symfony/console", "symfony/debug", EdgeKindRequire, "~2.8|~3.0")
+
+	for _, e := range g.Edges() {
+		if e.From == "symfony/console" && e.To == "symfony/debug" {
+			expected := []string{"~2.7,>=2.7.2|~3.0.0", "~2.8|~3.0"}
+			if !reflect.DeepEqual(e.Constraints, expected) {
+				t.Errorf("Got different constraints than expected. Expected %+v, got %+v", expected, e.Constraints)
+			}
+			return
+		}
+	}
+	t.Error("Edge symfony/console -> symfony/debug not found")
+}
+
+func TestGraph_AddEdge_StrongestKindWins(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge("a/a", "b/b", EdgeKindSuggest, "")
+	g.AddEdge("a/a", "b/b", EdgeKindRequireDev, "^1.0")
+	g.AddEdge("a/a", "c/c", EdgeKindRequire, "^1.0")
+	g.AddEdge("a/a", "c/c", EdgeKindRequireDev, "^2.0")
+
+	expected := []struct {
+		to          string
+		kind        string
+		constraints []string
+	}{
+		{"b/b", EdgeKindRequireDev, []string{"^1.0"}},
+		{"c/c", EdgeKindRequire, []string{"^1.0", "^2.0"}},
+	}
+
+	edges := g.Edges()
+	if n := len(edges); n != len(expected) {
+		t.Fatalf("Expected %d edges. Got %d", len(expected), n)
+	}
+	for i, e := range edges {
+		if e.To != expected[i].to || e.Kind != expected[i].kind || !reflect.DeepEqual(e.Constraints, expected[i].constraints) {
+			t.Errorf("Got different edge than expected. Expected %+v, got %+v", expected[i], e)
+		}
+	}
+}
+
+func TestGraph_Nodes(t *testing.T) {
+	g := unitTestGraph()
+	expected := []string{"psr/log", "symfony/console", "symfony/debug", "symfony/polyfill-mbstring"}
+
+	if got := g.Nodes(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got different nodes than expected. Expected %+v, got %+v", expected, got)
+	}
+	if got := g.Roots(); !reflect.DeepEqual(got, []string{"symfony/console"}) {
+		t.Errorf("Got different roots than expected. Got %+v", got)
+	}
+}
+
+func TestGraph_Subgraph(t *testing.T) {
+	g := unitTestGraph()
+	s := g.Subgraph("symfony/debug")
+
+	expected := []string{"psr/log", "symfony/console", "symfony/debug", "symfony/polyfill-mbstring"}
+	if got := s.Nodes(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got different nodes than expected. Expected %+v, got %+v", expected, got)
+	}
+	if !s.IsRoot("symfony/debug") || s.IsRoot("symfony/console") {
+		t.Errorf("Expected symfony/debug as the only root. Got %+v", s.Roots())
+	}
+
+	if n := len(g.Subgraph("not/existing").Nodes()); n != 0 {
+		t.Errorf("Expected an empty graph for an unknown root. Got %d nodes", n)
+	}
+}
+
+func TestGraph_IsReachableWithout(t *testing.T) {
+	g := unitTestGraph()
+
+	tests := []struct {
+		p        string
+		without  map[string]bool
+		expected bool
+	}{
+		{"psr/log", nil, true},
+		{"psr/log", map[string]bool{"symfony/debug": true}, false},
+		{"symfony/polyfill-mbstring", map[string]bool{"symfony/debug": true}, true},
+		{"symfony/console", map[string]bool{"symfony/console": true}, true},
+		{"unknown/package", nil, false},
+	}
+	for _, tt := range tests {
+		if got := g.IsReachableWithout(tt.p, tt.without); got != tt.expected {
+			t.Errorf("Expected IsReachableWithout(%s, %v) = %v. Got %v", tt.p, tt.without, tt.expected, got)
+		}
+	}
+}
+
+func TestGraph_Why(t *testing.T) {
+	g := unitTestGraph()
+	chains := g.Why("psr/log", 0)
+
+	if n := len(chains); n != 1 {
+		t.Fatalf("Expected one chain. Got %d: %+v", n, chains)
+	}
+
+	got := []string{}
+	for _, e := range chains[0] {
+		got = append(got, e.From+">"+e.To)
+	}
+	expected := []string{"symfony/console>symfony/debug", "symfony/debug>psr/log"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got different chain than expected. Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestGraph_Why_Limit(t *testing.T) {
+	g := NewGraph()
+	g.AddRoot("a/a")
+	g.AddRoot("b/b")
+	g.AddEdge("a/a", "c/c", EdgeKindRequire, "*")
+	g.AddEdge("b/b", "c/c", EdgeKindRequire, "*")
+
+	if n := len(g.Why("c/c", 0)); n != 2 {
+		t.Errorf("Expected two chains without a limit. Got %d", n)
+	}
+	if n := len(g.Why("c/c", 1)); n != 1 {
+		t.Errorf("Expected one chain with limit 1. Got %d", n)
+	}
+	if n := len(g.Why("not/existing", 0)); n != 0 {
+		t.Errorf("Expected no chain for an unknown package. Got %d", n)
+	}
+}
+
+func TestGraph_Write(t *testing.T) {
+	tests := []struct {
+		format   string
+		contains []string
+	}{
+		{GraphFormatDOT, []string{"digraph dependencies {", "\"symfony/console\" [shape=box];", "\"symfony/debug\" -> \"psr/log\" [label=\"~1.0\"];"}},
+		{GraphFormatDOT, []string{"\"symfony/console\" -> \"phpunit/phpunit\" [label=\"require-dev: ^5.7\", style=dashed];", "\"symfony/console\" -> \"psr/log\" [label=\"suggest\", style=dotted];"}},
+		{GraphFormatMermaid, []string{"graph LR", "p2[\"symfony/console\"]", "p3 -->|\"~1.0\"| p1", "p2 -.->|\"require-dev: ^5.7\"| p0"}},
+	}
+
+	g := unitTestGraph()
+	g.AddEdge("symfony/console", "phpunit/phpunit", EdgeKindRequireDev, "^5.7")
+	g.AddEdge("symfony/console", "psr/log", EdgeKindSuggest, "")
+	for _, tt := range tests {
+		b := new(bytes.Buffer)
+		if err := g.Write(b, tt.format); err != nil {
+			t.Errorf("Format %s: Got error %s", tt.format, err)
+		}
+		for _, c := range tt.contains {
+			if !strings.Contains(b.String(), c) {
+				t.Errorf("Format %s: Expected output to contain %q. Got:\n%s", tt.format, c, b.String())
+			}
+		}
+	}
+}
+
+func TestGraph_WriteJSON(t *testing.T) {
+	g := unitTestGraph()
+	b := new(bytes.Buffer)
+	if err := g.Write(b, GraphFormatJSON); err != nil {
+		t.Fatalf("Got error %s", err)
+	}
+
+	var d struct {
+		Roots    []string `json:"roots"`
+		Packages []string `json:"packages"`
+		Edges    []*Edge  `json:"edges"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &d); err != nil {
+		t.Fatalf("Output is no valid JSON: %s", err)
+	}
+	if n := len(d.Edges); n != 4 {
+		t.Errorf("Expected four edges. Got %d", n)
+	}
+	if n := len(d.Packages); n != 4 {
+		t.Errorf("Expected four packages. Got %d", n)
+	}
+}
+
+func TestGraph_Write_UnknownFormat(t *testing.T) {
+	g := unitTestGraph()
+	if err := g.Write(new(bytes.Buffer), "svg"); err == nil {
+		t.Error("Expected an error for an unknown format. Got none")
+	}
+}
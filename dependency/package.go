@@ -3,7 +3,6 @@ package dependency
 import (
 	"errors"
 	"net/url"
-	"regexp"
 )
 
 // Package represents a single package.
@@ -28,13 +27,7 @@ func NewPackage(name, repository string) (*Package, error) {
 		return p, nil
 	}
 
-	reg, err := regexp.Compile("^git@github.com:")
-	if err != nil {
-		return p, err
-	}
-
-	safeURL := reg.ReplaceAllString(repository, "git://github.com/")
-	u, err := url.Parse(safeURL)
+	u, err := ParseRepositoryURL(repository)
 	if err != nil {
 		return p, err
 	}
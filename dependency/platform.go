@@ -0,0 +1,55 @@
+package dependency
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// platformPackageRegexp matches all platform packages that are known by Composer.
+// It is the same expression that Composer itself uses.
+// Checkout https://github.com/composer/composer/blob/master/src/Composer/Repository/PlatformRepository.php
+var platformPackageRegexp = regexp.MustCompile(`(?i)^(?:php(?:-64bit|-ipv6|-zts|-debug)?|hhvm|(?:ext|lib)-[a-z0-9](?:[_.-]?[a-z0-9]+)*|composer(?:-(?:plugin|runtime)-api)?)$`)
+
+// IsPlatformPackage returns true if p is a platform package. False otherwise.
+//
+// A platform package is a package that is not part of your package repository
+// and it needs to be fulfilled by the system.
+// If you request a platform package like "php" at Packagist, you won`t get a JSON
+// response with information we expect. You will get valid HTML of the Packagist search.
+//
+// Platform packages are:
+//
+//	php, php-64bit, php-ipv6, php-zts, php-debug
+//	hhvm
+//	ext-<name> (e.g. ext-curl)
+//	lib-<name> (e.g. lib-icu)
+//	composer, composer-plugin-api, composer-runtime-api
+//
+// Checkout https://getcomposer.org/doc/01-basic-usage.md#platform-packages
+func IsPlatformPackage(p string) bool {
+	return platformPackageRegexp.MatchString(p)
+}
+
+// IsValidPackageName returns true if p follows the package name format "vendor/package".
+//
+//	The package name consists of a vendor name and the project's name.
+//	Often these will be identical - the vendor name just exists to prevent naming clashes.
+//	Source: https://getcomposer.org/doc/01-basic-usage.md
+func IsValidPackageName(p string) bool {
+	i := strings.Index(p, "/")
+	return i > 0 && i < len(p)-1
+}
+
+// MatchesPattern returns the first pattern of patterns that matches package p.
+// Patterns are globs like "my-vendor/*" or "symfony/polyfill-*".
+// See path.Match for the syntax. A "*" doesn't match the "/" between vendor and package name.
+// If no pattern matches, an empty string will be returned.
+func MatchesPattern(p string, patterns []string) string {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, p); err == nil && ok {
+			return pattern
+		}
+	}
+	return ""
+}
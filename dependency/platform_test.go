@@ -0,0 +1,79 @@
+package dependency_test
+
+import (
+	"testing"
+
+	. "github.com/andygrunwald/perseus/dependency"
+)
+
+func TestIsPlatformPackage(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform bool
+	}{
+		{"php", true},
+		{"php-64bit", true},
+		{"php-zts", true},
+		{"PHP", true},
+		{"hhvm", true},
+		{"ext-curl", true},
+		{"ext-pdo_mysql", true},
+		{"lib-icu", true},
+		{"lib-libxml", true},
+		{"composer", true},
+		{"composer-plugin-api", true},
+		{"composer-runtime-api", true},
+		{"symfony/console", false},
+		{"php/php-src", false},
+		{"composer/composer", false},
+		{"ext-", false},
+		{"phpunit", false},
+		{"composer-installers", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPlatformPackage(tt.name); got != tt.platform {
+			t.Errorf("IsPlatformPackage(%s) = %v; want %v", tt.name, got, tt.platform)
+		}
+	}
+}
+
+func TestIsValidPackageName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"symfony/console", true},
+		{"a/b", true},
+		{"phpunit", false},
+		{"/console", false},
+		{"symfony/", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidPackageName(tt.name); got != tt.valid {
+			t.Errorf("IsValidPackageName(%s) = %v; want %v", tt.name, got, tt.valid)
+		}
+	}
+}
+
+func TestMatchesPattern(t *testing.T) {
+	patterns := []string{"my-vendor/*", "symfony/polyfill-*", "[invalid"}
+	tests := []struct {
+		name    string
+		pattern string
+	}{
+		{"my-vendor/package", "my-vendor/*"},
+		{"symfony/polyfill-mbstring", "symfony/polyfill-*"},
+		{"symfony/polyfill", ""},
+		{"my-vendor/sub/package", ""},
+		{"other/package", ""},
+	}
+
+	for _, tt := range tests {
+		if got := MatchesPattern(tt.name, patterns); got != tt.pattern {
+			t.Errorf("MatchesPattern(%s) = %q; want %q", tt.name, got, tt.pattern)
+		}
+	}
+}
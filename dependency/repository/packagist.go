@@ -48,6 +48,51 @@ type PackagistPackage struct {
 type Composer struct {
 	// Require are a map of other packages incl. the version constraint that package depends on
 	Require map[string]string `json:"require"`
+	// RequireDev are a map of other packages incl. the version constraint that are only needed
+	// for developing this package (e.g. running tests)
+	RequireDev map[string]string `json:"require-dev"`
+	// Suggest are a map of other packages incl. a description that can enhance or work well with this package.
+	// The values are human readable descriptions, no version constraints.
+	Suggest map[string]string `json:"suggest"`
+	// Replace are a map of other packages incl. the version constraint that are replaced by this package.
+	// E.g. symfony/polyfill replaces symfony/polyfill-mbstring.
+	Replace map[string]string `json:"replace"`
+	// Provide are a map of other (mostly virtual) packages incl. the version constraint that are provided by this package.
+	// E.g. monolog/monolog provides psr/log-implementation.
+	Provide map[string]string `json:"provide"`
+	// Conflict are a map of other packages incl. the version constraint that conflict with this package.
+	// It is informational only: A mirror contains all versions of a package, so conflicts don't matter for the resolver.
+	Conflict map[string]string `json:"conflict"`
+	// Abandoned indicates if this package is abandoned and which package should be used instead
+	Abandoned Abandoned `json:"abandoned"`
+}
+
+// Abandoned reflects the "abandoned" setting of a composer.json.
+// Composer allows two kinds of values: A boolean or the name of the package that should be used instead.
+// Checkout https://getcomposer.org/doc/04-schema.md#abandoned
+type Abandoned struct {
+	// IsAbandoned is true if the package is abandoned
+	IsAbandoned bool
+	// Replacement is the name of the package that should be used instead (optional)
+	Replacement string
+}
+
+// UnmarshalJSON implements json.Unmarshaler to support both kinds of values: boolean and string.
+func (a *Abandoned) UnmarshalJSON(b []byte) error {
+	var flag bool
+	if err := json.Unmarshal(b, &flag); err == nil {
+		a.IsAbandoned = flag
+		a.Replacement = ""
+		return nil
+	}
+
+	var replacement string
+	if err := json.Unmarshal(b, &replacement); err != nil {
+		return fmt.Errorf("Abandoned must be a boolean or a package name. Got %s", b)
+	}
+	a.IsAbandoned = true
+	a.Replacement = replacement
+	return nil
 }
 
 // NewPackagist will create a new PackagistClient.
@@ -154,3 +154,78 @@ func TestGetPackageByName_InvalidJSON(t *testing.T) {
 		t.Error("Expected an error. Got nothing")
 	}
 }
+
+func TestGetPackageByName_RequireDevAndSuggest(t *testing.T) {
+	setup()
+	defer teardown()
+	testMux.HandleFunc("/packages/my/package.json", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testRequestURL(t, r, "/packages/my/package.json")
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"package":{"name":"my\/package","repository":"https:\/\/github.com\/my\/package","versions":{
+			"v1.0.0":{"require":{"php":">=7.0"},"require-dev":{"phpunit\/phpunit":"^6.0"},"suggest":{"ext-curl":"For faster downloads","monolog\/monolog":"For logging"}}
+		}}}`)
+	})
+
+	p, _, err := testClient.GetPackageByName("my/package")
+	if err != nil {
+		t.Fatalf("Didn't expected an error. Got: %s", err)
+	}
+
+	v := p.Versions["v1.0.0"]
+	if got := v.RequireDev["phpunit/phpunit"]; got != "^6.0" {
+		t.Errorf("Expected require-dev of phpunit/phpunit. Got %+v", v.RequireDev)
+	}
+	if got := v.Suggest["monolog/monolog"]; got != "For logging" {
+		t.Errorf("Expected suggest of monolog/monolog. Got %+v", v.Suggest)
+	}
+}
+
+func TestGetPackageByName_ReplaceProvideConflictAbandoned(t *testing.T) {
+	setup()
+	defer teardown()
+	testMux.HandleFunc("/packages/my/package.json", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testRequestURL(t, r, "/packages/my/package.json")
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"package":{"name":"my\/package","repository":"https:\/\/github.com\/my\/package","versions":{
+			"v2.0.0":{"require":{"php":">=7.0"},"replace":{"my\/package-util":"self.version"},"provide":{"psr\/log-implementation":"1.0.0"},"conflict":{"my\/old":"<1.0"},"abandoned":"my\/new-package"},
+			"v1.1.0":{"abandoned":true},
+			"v1.0.0":{"abandoned":false}
+		}}}`)
+	})
+
+	p, _, err := testClient.GetPackageByName("my/package")
+	if err != nil {
+		t.Fatalf("Didn't expected an error. Got: %s", err)
+	}
+
+	v := p.Versions["v2.0.0"]
+	if got := v.Replace["my/package-util"]; got != "self.version" {
+		t.Errorf("Expected replace of my/package-util. Got %+v", v.Replace)
+	}
+	if got := v.Provide["psr/log-implementation"]; got != "1.0.0" {
+		t.Errorf("Expected provide of psr/log-implementation. Got %+v", v.Provide)
+	}
+	if got := v.Conflict["my/old"]; got != "<1.0" {
+		t.Errorf("Expected conflict with my/old. Got %+v", v.Conflict)
+	}
+
+	tests := []struct {
+		version     string
+		abandoned   bool
+		replacement string
+	}{
+		{"v2.0.0", true, "my/new-package"},
+		{"v1.1.0", true, ""},
+		{"v1.0.0", false, ""},
+	}
+	for _, tt := range tests {
+		a := p.Versions[tt.version].Abandoned
+		if a.IsAbandoned != tt.abandoned || a.Replacement != tt.replacement {
+			t.Errorf("Version %s: Expected abandoned %v with replacement %q. Got %+v", tt.version, tt.abandoned, tt.replacement, a)
+		}
+	}
+}
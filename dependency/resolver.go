@@ -2,8 +2,8 @@ package dependency
 
 import (
 	"fmt"
-	"sync"
 	"net/http"
+	"sync"
 
 	"github.com/andygrunwald/perseus/dependency/repository"
 	"github.com/andygrunwald/perseus/types/set"
@@ -13,66 +13,130 @@ import (
 type Resolver interface {
 	Resolve(packageList []*Package)
 	GetResultStream() <-chan *Result
+	// GetGraph returns the dependency graph that was discovered during Resolve.
+	// The graph is complete once the result stream is closed.
+	GetGraph() *Graph
 }
 
 // Result reflects a result of a dependency resolver process.
 type Result struct {
-	Package *Package
+	Package  *Package
 	Response *http.Response
-	Error   error
+	Error    error
+	// Kind is the kind of the edge (see EdgeKind* constants) the package was discovered with first.
+	// It is empty for root packages.
+	Kind string
+	// Abandoned is set if a version of the package that is allowed by the version policy is abandoned
+	Abandoned repository.Abandoned
+}
+
+const (
+	// FollowNone won't follow the dependencies of a section at all (default)
+	FollowNone = "none"
+	// FollowRoot follows the dependencies of a section only for root packages
+	FollowRoot = "root"
+	// FollowAll follows the dependencies of a section for all packages (transitively)
+	FollowAll = "all"
+)
+
+// IsValidFollowMode returns true if m is a valid follow mode (see Follow* constants).
+// An empty mode is valid and equal to FollowNone.
+func IsValidFollowMode(m string) bool {
+	switch m {
+	case "", FollowNone, FollowRoot, FollowAll:
+		return true
+	}
+	return false
+}
+
+// ResolverOptions are optional settings to influence the resolve process.
+type ResolverOptions struct {
+	// Aliases is a map of package names that will be replaced by other package names before they are resolved.
+	// This is useful for old/renamed/obsolete packages that are still required by older tags and branches
+	// and would throw an error otherwise (e.g. "symfony/translator" => "symfony/translation").
+	Aliases map[string]string
+	// Ignore is a list of glob patterns (like "my-vendor/*").
+	// Packages that match one of those patterns won't be resolved.
+	// They will be reported as skipped via a SkipError.
+	Ignore []string
+	// RequireDev controls if packages of the "require-dev" section will be resolved (see Follow* constants).
+	// Default is FollowNone.
+	RequireDev string
+	// Suggest controls if packages of the "suggest" section will be resolved (see Follow* constants).
+	// Default is FollowNone.
+	Suggest string
+	// MaxDepth limits how deep the dependencies of the root packages will be resolved.
+	// Root packages have a depth of 0, their direct dependencies a depth of 1 and so on.
+	// Zero means no limit.
+	MaxDepth int
+	// Versions decides which versions of a package are respected when following its dependencies.
+	// Versions that are not allowed (like "dev-feature-foo") won't pull in their dependencies.
+	// If nil, all versions are respected.
+	Versions *VersionPolicy
+	// PackageVersions are version policies per package (e.g. for root packages).
+	// Key is the package name. A policy of a package replaces the global policy Versions for this package.
+	PackageVersions map[string]*VersionPolicy
 }
 
 // NewComposerResolver will create a new instance of a Resolver.
 // Standard implementation is the ComposerResolver.
-func NewComposerResolver(numOfWorker int, p repository.Client) (Resolver, error) {
+// o are optional settings for the resolve process. If o is nil, defaults will be used.
+func NewComposerResolver(numOfWorker int, p repository.Client, o *ResolverOptions) (Resolver, error) {
 	if numOfWorker == 0 {
 		return nil, fmt.Errorf("Starting a dependency resolver with zero worker is not possible")
 	}
 	if p == nil {
 		return nil, fmt.Errorf("Starting a dependency resolver with an empty repository.Client is not possible")
 	}
+	if o == nil {
+		o = &ResolverOptions{}
+	}
+	if !IsValidFollowMode(o.RequireDev) {
+		return nil, fmt.Errorf("Unknown require-dev mode \"%s\". Supported modes: %s, %s, %s", o.RequireDev, FollowNone, FollowRoot, FollowAll)
+	}
+	if !IsValidFollowMode(o.Suggest) {
+		return nil, fmt.Errorf("Unknown suggest mode \"%s\". Supported modes: %s, %s, %s", o.Suggest, FollowNone, FollowRoot, FollowAll)
+	}
+	if o.MaxDepth < 0 {
+		return nil, fmt.Errorf("A negative max depth (%d) is not possible", o.MaxDepth)
+	}
+	if o.Versions != nil {
+		if err := o.Versions.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for name, policy := range o.PackageVersions {
+		if err := policy.Validate(); err != nil {
+			return nil, fmt.Errorf("Version policy of package %s: %s", name, err)
+		}
+	}
+
+	aliases := make(map[string]string, len(o.Aliases))
+	for k, v := range o.Aliases {
+		aliases[k] = v
+	}
 
 	d := &ComposerResolver{
-		workerCount: numOfWorker,
-		waitGroup:   sync.WaitGroup{},
-		queue:       make(chan *Package, (numOfWorker + 1)),
-		results:     make(chan *Result),
-		resolved:    set.New(),
-		queued:      set.New(),
-		repository:  p,
-		replacee:    getReplaceeMap(),
+		workerCount:     numOfWorker,
+		waitGroup:       sync.WaitGroup{},
+		queue:           make(chan *job, (numOfWorker + 1)),
+		results:         make(chan *Result),
+		resolved:        set.New(),
+		queued:          set.New(),
+		repository:      p,
+		aliases:         aliases,
+		ignore:          o.Ignore,
+		requireDev:      o.RequireDev,
+		suggest:         o.Suggest,
+		maxDepth:        o.MaxDepth,
+		versions:        o.Versions,
+		packageVersions: o.PackageVersions,
+		replaced:        make(map[string]map[string]bool),
+		deferred:        make(map[string]*job),
+		unavailable:     make(map[string]*Result),
+		jobs:            make(map[string]*job),
+		graph:           NewGraph(),
 	}
 
 	return d, nil
 }
-
-// getReplaceeMap will return a map of package names that needs to be replaced with some other package names.
-// We need to do this, because those packages was renamed a long time ago.
-// But the original packages are still part of older tags and branches.
-// I guess, those packages were renamed before even packagist exists.
-// That might explain the reason why there is no "obsolete"/"deprecated"-reference on packagist.
-// Anyway. We need to deal with it.
-// I tried so find some information why or when this packages were renamed.
-// If you have more information about this, please ping me or make a PR and add this information
-// as a simple comment. Might be useful for someone who is using this at a later stage.
-//
-// TODO: This is packagist related. Move to packagist
-func getReplaceeMap() map[string]string {
-	m := map[string]string{
-		"symfony/translator": "symfony/translation",
-
-		// On January 2, 2012 they moved packages around.
-		// One of them was the symfony/doctrine-bundle => doctrine/doctrine-bundle move.
-		// Checkout the blogpost at https://symfony.com/blog/symfony-2-1-the-doctrine-bundle-has-moved-to-the-doctrine-organization
-		//
-		// Here are a few changes in packages / apps:
-		//	- Symfony standard: https://github.com/symfony/symfony-standard/commit/5dee24eb280452fe46e76f99706c21ab417462ac
-		//	- GeneratorBundle: https://github.com/sensiolabs/SensioGeneratorBundle/commit/11c9b68bd7f67a9cf3429d48af2d1a817dbd58cb#diff-b5d0ee8c97c7abd7e3fa29b9a27d1780
-		//
-		// Older tags still reference "symfony/doctrine-bundle".
-		"symfony/doctrine-bundle":     "doctrine/doctrine-bundle",
-		"metadata/metadata":           "jms/metadata",
-		"zendframework/zend-registry": "zf1/zend-registry",
-	}
-	return m
-}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	. "github.com/andygrunwald/perseus/dependency"
 	"github.com/andygrunwald/perseus/dependency/repository"
@@ -38,14 +39,14 @@ func (c *testApiClient) GetPackageByName(name string) (*repository.PackagistPack
 			Versions: map[string]repository.Composer{
 				"3.2.2": {
 					Require: map[string]string{
-						"php": ">=5.5.9",
+						"php":                       ">=5.5.9",
 						"symfony/polyfill-mbstring": " ~1.0",
 						"symfony/debug":             "~2.8|~3.0",
 					},
 				},
 				"2.8.12": {
 					Require: map[string]string{
-						"php": ">=5.3.9",
+						"php":                       ">=5.3.9",
 						"symfony/polyfill-mbstring": " ~1.0",
 						"symfony/debug":             "~2.7,>=2.7.2|~3.0.0",
 					},
@@ -108,6 +109,190 @@ func (c *testApiClient) GetPackageByName(name string) (*repository.PackagistPack
 			},
 		}
 		return p, &http.Response{StatusCode: http.StatusOK}, nil
+	// Simulate: A package that requires packages that replace and provide other packages.
+	// The second version requires those packages directly. Workers might pick them up in any order.
+	case "app/replace-and-provide":
+		p := &repository.PackagistPackage{
+			Name: name,
+			Versions: map[string]repository.Composer{
+				"1.0.0": {
+					Require: map[string]string{
+						"symfony/polyfill": "~1.0",
+						"monolog/monolog":  "~1.0",
+					},
+				},
+			},
+		}
+		return p, &http.Response{StatusCode: http.StatusOK}, nil
+	// Simulate: A package that reaches depth/target via a long and a short (but slow) path
+	//	depth/root
+	//	|- depth/long -> depth/longer -> depth/target -> depth/leaf
+	//	|- depth/short -> depth/target -> depth/leaf
+	case "depth/root":
+		return requirePackage(name, "depth/long", "depth/short"), &http.Response{StatusCode: http.StatusOK}, nil
+	case "depth/long":
+		return requirePackage(name, "depth/longer"), &http.Response{StatusCode: http.StatusOK}, nil
+	case "depth/longer":
+		return requirePackage(name, "depth/target"), &http.Response{StatusCode: http.StatusOK}, nil
+	case "depth/short":
+		// The short path is discovered after the long one
+		time.Sleep(100 * time.Millisecond)
+		return requirePackage(name, "depth/target"), &http.Response{StatusCode: http.StatusOK}, nil
+	case "depth/target":
+		return requirePackage(name, "depth/leaf"), &http.Response{StatusCode: http.StatusOK}, nil
+	case "depth/leaf":
+		return requirePackage(name), &http.Response{StatusCode: http.StatusOK}, nil
+	// Simulate: A replaced package that is discovered before its slow replacer is resolved
+	//	order/root
+	//	|- order/fast -> order/replacee
+	//	|- order/replacer (replaces order/replacee and order/replaced-only) -> order/replaced-only
+	case "order/root":
+		return requirePackage(name, "order/fast", "order/replacer"), &http.Response{StatusCode: http.StatusOK}, nil
+	case "order/fast":
+		return requirePackage(name, "order/replacee"), &http.Response{StatusCode: http.StatusOK}, nil
+	case "order/replacer":
+		time.Sleep(50 * time.Millisecond)
+		p := &repository.PackagistPackage{
+			Name: name,
+			Versions: map[string]repository.Composer{
+				"1.0.0": {
+					Require: map[string]string{
+						"order/replaced-only": "self.version",
+					},
+					Replace: map[string]string{
+						"order/replacee":      "self.version",
+						"order/replaced-only": "self.version",
+					},
+				},
+			},
+		}
+		return p, &http.Response{StatusCode: http.StatusOK}, nil
+	case "order/replacee", "order/replaced-only":
+		return requirePackage(name), &http.Response{StatusCode: http.StatusOK}, nil
+	// Simulate: A package that was abandoned in favor of another package
+	case "app/abandoned":
+		p := &repository.PackagistPackage{
+			Name: name,
+			Versions: map[string]repository.Composer{
+				"1.0.0":      {},
+				"1.1.0":      {Abandoned: repository.Abandoned{IsAbandoned: true, Replacement: "app/successor"}},
+				"dev-master": {Abandoned: repository.Abandoned{IsAbandoned: true}},
+			},
+		}
+		return p, &http.Response{StatusCode: http.StatusOK}, nil
+	// Simulate: A package that requires a replaced package via another package than its replacer
+	case "app/replaced-elsewhere":
+		p := &repository.PackagistPackage{
+			Name: name,
+			Versions: map[string]repository.Composer{
+				"1.0.0": {
+					Require: map[string]string{
+						"symfony/polyfill": "~1.0",
+						"symfony/console":  "~3.2",
+					},
+				},
+			},
+		}
+		return p, &http.Response{StatusCode: http.StatusOK}, nil
+	// Simulate: API returns valid content for symfony/polyfill, that replaces symfony/polyfill-mbstring
+	case "symfony/polyfill":
+		p := &repository.PackagistPackage{
+			Name: name,
+			Versions: map[string]repository.Composer{
+				"1.3.0": {
+					Replace: map[string]string{
+						"symfony/polyfill":          "self.version",
+						"symfony/polyfill-mbstring": "self.version",
+					},
+				},
+				"1.2.0": {
+					Require: map[string]string{
+						"symfony/polyfill-mbstring": "self.version",
+					},
+				},
+			},
+		}
+		return p, &http.Response{StatusCode: http.StatusOK}, nil
+	// Simulate: API returns valid content for monolog/monolog, that provides psr/log-implementation
+	case "monolog/monolog":
+		p := &repository.PackagistPackage{
+			Name: name,
+			Versions: map[string]repository.Composer{
+				"1.22.0": {
+					Require: map[string]string{
+						"psr/log-implementation": "1.0.0",
+					},
+					Provide: map[string]string{
+						"psr/log-implementation": "1.0.0",
+					},
+				},
+			},
+		}
+		return p, &http.Response{StatusCode: http.StatusOK}, nil
+	// Simulate: A package with require, require-dev and suggest sections
+	case "app/dev":
+		p := &repository.PackagistPackage{
+			Name: name,
+			Versions: map[string]repository.Composer{
+				"1.0.0": {
+					Require: map[string]string{
+						"symfony/debug": "~3.0",
+					},
+					RequireDev: map[string]string{
+						"ext-xdebug":      "*",
+						"phpunit/phpunit": "^5.7",
+					},
+					Suggest: map[string]string{
+						"monolog/monolog": "For logging",
+					},
+				},
+			},
+		}
+		return p, &http.Response{StatusCode: http.StatusOK}, nil
+	// Simulate: A require-dev dependency with require-dev dependencies on its own
+	case "phpunit/phpunit":
+		p := &repository.PackagistPackage{
+			Name: name,
+			Versions: map[string]repository.Composer{
+				"5.7.0": {
+					RequireDev: map[string]string{
+						"dev/only": "^1.0",
+					},
+				},
+			},
+		}
+		return p, &http.Response{StatusCode: http.StatusOK}, nil
+	// Simulate: A package with releases, pre-releases and feature branches
+	case "app/branches":
+		p := &repository.PackagistPackage{
+			Name: name,
+			Versions: map[string]repository.Composer{
+				"v1.0.0": {
+					Require: map[string]string{
+						"dev/only": "^1.0",
+					},
+				},
+				"v1.1.0-beta1": {
+					Require: map[string]string{
+						"phpunit/phpunit": "^5.7",
+					},
+				},
+				"dev-feature-foo": {
+					Require: map[string]string{
+						"symfony/debug": "dev-master",
+					},
+				},
+			},
+		}
+		return p, &http.Response{StatusCode: http.StatusOK}, nil
+	case "dev/only":
+		p := &repository.PackagistPackage{
+			Name: name,
+		}
+		return p, &http.Response{StatusCode: http.StatusOK}, nil
+	// Simulate: Virtual packages are not available
+	case "psr/log-implementation":
+		return nil, &http.Response{StatusCode: http.StatusNotFound}, fmt.Errorf("Package not found")
 	// Simulate: API returns valid content for symfony/translation
 	case "symfony/translation":
 		fallthrough
@@ -122,11 +307,11 @@ func (c *testApiClient) GetPackageByName(name string) (*repository.PackagistPack
 		return p, &http.Response{StatusCode: http.StatusOK}, nil
 	}
 
-	return nil, nil, nil
+	return nil, &http.Response{StatusCode: http.StatusNotFound}, fmt.Errorf("Package not found")
 }
 
 func TestNewComposerResolver(t *testing.T) {
-	d, err := NewComposerResolver(10, &testApiClient{})
+	d, err := NewComposerResolver(10, &testApiClient{}, nil)
 	if err != nil {
 		t.Errorf("Error while creating a new dependency resolver: %s", err)
 	}
@@ -135,6 +320,26 @@ func TestNewComposerResolver(t *testing.T) {
 	}
 }
 
+func TestNewComposerResolver_InvalidOptions(t *testing.T) {
+	tests := []*ResolverOptions{
+		{RequireDev: "everything"},
+		{Suggest: "some"},
+		{MaxDepth: -1},
+		{Versions: &VersionPolicy{MinimumStability: "unstable"}},
+		{PackageVersions: map[string]*VersionPolicy{"my/package": {MinimumStability: "unstable"}}},
+	}
+
+	for _, o := range tests {
+		d, err := NewComposerResolver(1, &testApiClient{}, o)
+		if err == nil {
+			t.Errorf("Expected an error for options %+v. Got none", o)
+		}
+		if d != nil {
+			t.Errorf("Expected no resolver for options %+v. Got %+v", o, d)
+		}
+	}
+}
+
 func TestNewComposerResolver_Error(t *testing.T) {
 	tests := []struct {
 		numOfWorker     int
@@ -146,8 +351,22 @@ func TestNewComposerResolver_Error(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		if got, err := NewComposerResolver(tt.numOfWorker, tt.packagistClient); err == nil {
+		if got, err := NewComposerResolver(tt.numOfWorker, tt.packagistClient, nil); err == nil {
 			t.Errorf("No error while creating a new dependency resolver. Got: %+v", got)
 		}
 	}
 }
+
+// requirePackage returns package name with a single version that requires the packages requires.
+func requirePackage(name string, requires ...string) *repository.PackagistPackage {
+	r := map[string]string{}
+	for _, p := range requires {
+		r[p] = "^1.0"
+	}
+	return &repository.PackagistPackage{
+		Name: name,
+		Versions: map[string]repository.Composer{
+			"1.0.0": {Require: r},
+		},
+	}
+}
@@ -0,0 +1,114 @@
+package dependency
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RewriteRule rewrites repository URLs, like "url.<base>.insteadOf" of git.
+// A rule matches either by Prefix or by Regexp.
+type RewriteRule struct {
+	// Prefix matches all URLs that start with Prefix. The prefix is replaced by Replacement.
+	Prefix string
+	// Regexp matches all URLs that match Regexp. The match is replaced by Replacement,
+	// which may contain references to submatches like $1.
+	Regexp *regexp.Regexp
+	// Replacement is the replacement of the matching part of an URL
+	Replacement string
+}
+
+// NewPrefixRewriteRule returns a RewriteRule that replaces prefix by replacement.
+func NewPrefixRewriteRule(prefix, replacement string) (*RewriteRule, error) {
+	if len(prefix) == 0 {
+		return nil, errors.New("Prefix of rewrite rule is empty")
+	}
+	return &RewriteRule{Prefix: prefix, Replacement: replacement}, nil
+}
+
+// NewRegexpRewriteRule returns a RewriteRule that replaces all matches of the regular expression expr by replacement.
+func NewRegexpRewriteRule(expr, replacement string) (*RewriteRule, error) {
+	if len(expr) == 0 {
+		return nil, errors.New("Regular expression of rewrite rule is empty")
+	}
+	r, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid regular expression of rewrite rule: %s", err)
+	}
+	return &RewriteRule{Regexp: r, Replacement: replacement}, nil
+}
+
+// Rewrite returns the rewritten repository URL u.
+// If the rule doesn't match, u is returned unchanged and the second return value is false.
+func (r *RewriteRule) Rewrite(u string) (string, bool) {
+	if r.Regexp != nil {
+		if !r.Regexp.MatchString(u) {
+			return u, false
+		}
+		return r.Regexp.ReplaceAllString(u, r.Replacement), true
+	}
+
+	if !strings.HasPrefix(u, r.Prefix) {
+		return u, false
+	}
+	return r.Replacement + strings.TrimPrefix(u, r.Prefix), true
+}
+
+// String returns a human readable representation of the rule like
+// prefix "git@github.com:" => "https://github.com/".
+func (r *RewriteRule) String() string {
+	if r.Regexp != nil {
+		return fmt.Sprintf("regexp %q => %q", r.Regexp.String(), r.Replacement)
+	}
+	return fmt.Sprintf("prefix %q => %q", r.Prefix, r.Replacement)
+}
+
+// DefaultRewriteRules are applied after the configured rewrite rules (see SetRewriteRules).
+//
+// SSH URLs of GitHub (like git@github.com:symfony/console.git) are rewritten to HTTPS URLs,
+// because public repositories can be cloned via HTTPS without any credentials.
+// All other SSH URLs in the scp like syntax (like git@git.company.tld:myvendor/package.git)
+// are rewritten to the URL syntax (like ssh://git@git.company.tld/myvendor/package.git), because they are no valid URLs.
+var DefaultRewriteRules = []*RewriteRule{
+	{Prefix: "git@github.com:", Replacement: "https://github.com/"},
+	{Regexp: regexp.MustCompile(`^([^@/:]+@[^/:]+):/?(.+)$`), Replacement: "ssh://$1/$2"},
+}
+
+var (
+	rewriteMu    sync.RWMutex
+	rewriteRules []*RewriteRule
+)
+
+// SetRewriteRules sets the rewrite rules that are applied to every repository URL (see RewriteURL).
+// The rules are applied in order, before the DefaultRewriteRules.
+func SetRewriteRules(rules []*RewriteRule) {
+	rewriteMu.Lock()
+	defer rewriteMu.Unlock()
+	rewriteRules = rules
+}
+
+// RewriteURL rewrites the repository URL u with the first matching rewrite rule.
+// The configured rules (see SetRewriteRules) are checked first, the DefaultRewriteRules afterwards.
+// Besides the effective URL, the matching rule is returned. If no rule matches, u and nil are returned.
+func RewriteURL(u string) (string, *RewriteRule) {
+	rewriteMu.RLock()
+	rules := append(append([]*RewriteRule{}, rewriteRules...), DefaultRewriteRules...)
+	rewriteMu.RUnlock()
+
+	for _, r := range rules {
+		if rewritten, ok := r.Rewrite(u); ok {
+			return rewritten, r
+		}
+	}
+	return u, nil
+}
+
+// ParseRepositoryURL rewrites the repository URL u (see RewriteURL) and parses it.
+// This is the place where every repository URL of a package is created.
+func ParseRepositoryURL(u string) (*url.URL, error) {
+	rewritten, _ := RewriteURL(u)
+	return url.Parse(rewritten)
+}
@@ -0,0 +1,76 @@
+package dependency_test
+
+import (
+	"testing"
+
+	. "github.com/andygrunwald/perseus/dependency"
+)
+
+func TestRewriteURL_DefaultRules(t *testing.T) {
+	tests := []struct {
+		repository string
+		want       string
+		rewritten  bool
+	}{
+		{"git@github.com:symfony/console.git", "https://github.com/symfony/console.git", true},
+		{"https://github.com/symfony/console.git", "https://github.com/symfony/console.git", false},
+		{"ssh://git@github.com/symfony/console.git", "ssh://git@github.com/symfony/console.git", false},
+		{"git@othervcs:myvendor/package.git", "ssh://git@othervcs/myvendor/package.git", true},
+		{"git@othervcs:/srv/git/package.git", "ssh://git@othervcs/srv/git/package.git", true},
+		{"https://user@git.company.tld:8443/myvendor/package.git", "https://user@git.company.tld:8443/myvendor/package.git", false},
+	}
+
+	for _, tt := range tests {
+		got, rule := RewriteURL(tt.repository)
+		if got != tt.want {
+			t.Errorf("RewriteURL(%s) = %s; want %s", tt.repository, got, tt.want)
+		}
+		if (rule != nil) != tt.rewritten {
+			t.Errorf("RewriteURL(%s): Expected a matching rule: %v. Got %v", tt.repository, tt.rewritten, rule)
+		}
+	}
+}
+
+func TestRewriteURL_ConfiguredRules(t *testing.T) {
+	prefix, err := NewPrefixRewriteRule("git@gitlab.company.tld:", "https://gitlab.company.tld/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expr, err := NewRegexpRewriteRule(`^https://bitbucket\.org/(.+?)(\.git)?$`, "ssh://git@bitbucket.org/$1.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Configured rules have precedence over the default rules
+	github, _ := NewPrefixRewriteRule("git@github.com:", "ssh://git@github.com/")
+
+	SetRewriteRules([]*RewriteRule{prefix, expr, github})
+	defer SetRewriteRules(nil)
+
+	tests := []struct {
+		repository string
+		want       string
+		rule       *RewriteRule
+	}{
+		{"git@gitlab.company.tld:myvendor/package.git", "https://gitlab.company.tld/myvendor/package.git", prefix},
+		{"https://bitbucket.org/myvendor/package", "ssh://git@bitbucket.org/myvendor/package.git", expr},
+		{"https://bitbucket.org/myvendor/package.git", "ssh://git@bitbucket.org/myvendor/package.git", expr},
+		{"git@github.com:symfony/console.git", "ssh://git@github.com/symfony/console.git", github},
+		{"https://github.com/symfony/console.git", "https://github.com/symfony/console.git", nil},
+	}
+
+	for _, tt := range tests {
+		got, rule := RewriteURL(tt.repository)
+		if got != tt.want || rule != tt.rule {
+			t.Errorf("RewriteURL(%s) = %s, %v; want %s, %v", tt.repository, got, rule, tt.want, tt.rule)
+		}
+	}
+}
+
+func TestNewRewriteRule_Invalid(t *testing.T) {
+	if _, err := NewPrefixRewriteRule("", "https://github.com/"); err == nil {
+		t.Error("Expected an error for an empty prefix. Got none")
+	}
+	if _, err := NewRegexpRewriteRule("^git@(", "https://github.com/"); err == nil {
+		t.Error("Expected an error for an invalid regular expression. Got none")
+	}
+}
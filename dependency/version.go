@@ -0,0 +1,140 @@
+package dependency
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// StabilityStable is the stability of a regular release (e.g. "v1.0.0")
+	StabilityStable = "stable"
+	// StabilityRC is the stability of a release candidate (e.g. "v1.0.0-RC1")
+	StabilityRC = "RC"
+	// StabilityBeta is the stability of a beta release (e.g. "v1.0.0-beta2")
+	StabilityBeta = "beta"
+	// StabilityAlpha is the stability of an alpha release (e.g. "v1.0.0-alpha1")
+	StabilityAlpha = "alpha"
+	// StabilityDev is the stability of a branch (e.g. "dev-master" or "2.1.x-dev")
+	StabilityDev = "dev"
+)
+
+// stabilityWeight ranks the stabilities. The lower the weight, the more stable a version is.
+var stabilityWeight = map[string]int{
+	StabilityStable: 0,
+	StabilityRC:     1,
+	StabilityBeta:   2,
+	StabilityAlpha:  3,
+	StabilityDev:    4,
+}
+
+// stabilityModifierRegexp matches the stability modifier at the end of a version name.
+// It is the same expression that Composer itself uses.
+// Checkout https://github.com/composer/semver/blob/master/src/VersionParser.php
+var stabilityModifierRegexp = regexp.MustCompile(`(?i)[._-]?(?:(stable|beta|b|rc|alpha|a|patch|pl|p)((?:[.-]?\d+)*)?)?([.-]?dev)?(?:\+.*)?$`)
+
+// ParseStability returns the stability (see Stability* constants) of the version name.
+//
+//	dev-master, dev-feature/foo, 2.1.x-dev => dev
+//	v1.0.0-alpha1 => alpha
+//	v1.0.0-beta2, v1.0.0b2 => beta
+//	v1.0.0-RC1 => RC
+//	v1.0.0, v1.0.0-patch1 => stable
+func ParseStability(version string) string {
+	v := strings.ToLower(version)
+	if strings.HasPrefix(v, "dev-") || strings.HasSuffix(v, "-dev") {
+		return StabilityDev
+	}
+
+	m := stabilityModifierRegexp.FindStringSubmatch(v)
+	if m == nil {
+		return StabilityStable
+	}
+	if len(m[3]) > 0 {
+		return StabilityDev
+	}
+
+	switch m[1] {
+	case "beta", "b":
+		return StabilityBeta
+	case "alpha", "a":
+		return StabilityAlpha
+	case "rc":
+		return StabilityRC
+	}
+	return StabilityStable
+}
+
+// IsValidStability returns true if s is a known stability (see Stability* constants).
+// The check is case insensitive.
+func IsValidStability(s string) bool {
+	return len(normalizeStability(s)) > 0
+}
+
+// normalizeStability returns the Stability* constant of s.
+// If s is no known stability, an empty string will be returned.
+func normalizeStability(s string) string {
+	for k := range stabilityWeight {
+		if strings.EqualFold(k, s) {
+			return k
+		}
+	}
+	return ""
+}
+
+// VersionPolicy decides which versions of a package will be respected
+// when following the dependencies of this package.
+// An empty VersionPolicy allows all versions.
+type VersionPolicy struct {
+	// MinimumStability is the minimum stability (see Stability* constants) a version needs to have.
+	// Empty means all stabilities are allowed.
+	MinimumStability string
+	// Include is a list of glob patterns of version names (like "v2.*" or "dev-master").
+	// If set, only versions matching one of those patterns are allowed.
+	Include []string
+	// Exclude is a list of glob patterns of version names (like "dev-feature-*").
+	// Versions matching one of those patterns are not allowed.
+	Exclude []string
+}
+
+// Validate returns an error if the policy contains an unknown stability.
+func (p *VersionPolicy) Validate() error {
+	if len(p.MinimumStability) > 0 && !IsValidStability(p.MinimumStability) {
+		return fmt.Errorf("Unknown stability \"%s\". Supported stabilities: %s, %s, %s, %s, %s", p.MinimumStability, StabilityStable, StabilityRC, StabilityBeta, StabilityAlpha, StabilityDev)
+	}
+	return nil
+}
+
+// Allows returns true if the version name is allowed by policy p.
+// A nil policy allows all versions.
+// The "*" in patterns matches every character, including "/" (e.g. "dev-feature/*").
+func (p *VersionPolicy) Allows(version string) bool {
+	if p == nil {
+		return true
+	}
+
+	if m := normalizeStability(p.MinimumStability); len(m) > 0 {
+		if stabilityWeight[ParseStability(version)] > stabilityWeight[m] {
+			return false
+		}
+	}
+
+	if len(p.Include) > 0 && !matchesVersionPattern(version, p.Include) {
+		return false
+	}
+
+	return !matchesVersionPattern(version, p.Exclude)
+}
+
+// matchesVersionPattern returns true if version matches one of the glob patterns.
+func matchesVersionPattern(version string, patterns []string) bool {
+	for _, pattern := range patterns {
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.Replace(expr, `\*`, ".*", -1)
+		expr = strings.Replace(expr, `\?`, ".", -1)
+		if ok, err := regexp.MatchString("^"+expr+"$", version); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
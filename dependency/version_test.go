@@ -0,0 +1,96 @@
+package dependency_test
+
+import (
+	"testing"
+
+	. "github.com/andygrunwald/perseus/dependency"
+)
+
+func TestParseStability(t *testing.T) {
+	tests := []struct {
+		version   string
+		stability string
+	}{
+		{"dev-master", StabilityDev},
+		{"dev-feature/foo", StabilityDev},
+		{"2.1.x-dev", StabilityDev},
+		{"v1.0.0-alpha1", StabilityAlpha},
+		{"1.0.0a2", StabilityAlpha},
+		{"v1.0.0-beta2", StabilityBeta},
+		{"v1.0.0b2", StabilityBeta},
+		{"v1.0.0-RC1", StabilityRC},
+		{"1.0.0-rc.2", StabilityRC},
+		{"v1.0.0", StabilityStable},
+		{"1.0.0-patch1", StabilityStable},
+		{"1.0.0-p1", StabilityStable},
+		{"3.2.2", StabilityStable},
+	}
+
+	for _, tt := range tests {
+		if got := ParseStability(tt.version); got != tt.stability {
+			t.Errorf("ParseStability(%s) = %s; want %s", tt.version, got, tt.stability)
+		}
+	}
+}
+
+func TestIsValidStability(t *testing.T) {
+	tests := []struct {
+		stability string
+		valid     bool
+	}{
+		{"stable", true},
+		{"RC", true},
+		{"rc", true},
+		{"beta", true},
+		{"alpha", true},
+		{"dev", true},
+		{"", false},
+		{"unstable", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidStability(tt.stability); got != tt.valid {
+			t.Errorf("IsValidStability(%s) = %v; want %v", tt.stability, got, tt.valid)
+		}
+	}
+}
+
+func TestVersionPolicy_Allows(t *testing.T) {
+	tests := []struct {
+		policy  *VersionPolicy
+		version string
+		allowed bool
+	}{
+		{nil, "dev-master", true},
+		{&VersionPolicy{}, "dev-master", true},
+		{&VersionPolicy{MinimumStability: "stable"}, "v1.0.0", true},
+		{&VersionPolicy{MinimumStability: "stable"}, "v1.0.0-RC1", false},
+		{&VersionPolicy{MinimumStability: "rc"}, "v1.0.0-RC1", true},
+		{&VersionPolicy{MinimumStability: "beta"}, "v1.0.0-alpha1", false},
+		{&VersionPolicy{MinimumStability: "beta"}, "dev-master", false},
+		{&VersionPolicy{MinimumStability: "dev"}, "dev-master", true},
+		{&VersionPolicy{Include: []string{"v2.*", "dev-master"}}, "dev-master", true},
+		{&VersionPolicy{Include: []string{"v2.*", "dev-master"}}, "v2.1.0", true},
+		{&VersionPolicy{Include: []string{"v2.*", "dev-master"}}, "v1.0.0", false},
+		{&VersionPolicy{Exclude: []string{"dev-feature*"}}, "dev-feature/foo", false},
+		{&VersionPolicy{Exclude: []string{"dev-feature*"}}, "dev-master", true},
+		{&VersionPolicy{Include: []string{"dev-*"}, Exclude: []string{"dev-feature*"}}, "dev-feature-bar", false},
+		{&VersionPolicy{Exclude: []string{"v1.?.0"}}, "v1.2.0", false},
+		{&VersionPolicy{Exclude: []string{"v1.?.0"}}, "v1.10.0", true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.Allows(tt.version); got != tt.allowed {
+			t.Errorf("Policy %+v: Allows(%s) = %v; want %v", tt.policy, tt.version, got, tt.allowed)
+		}
+	}
+}
+
+func TestVersionPolicy_Validate(t *testing.T) {
+	if err := (&VersionPolicy{MinimumStability: "RC"}).Validate(); err != nil {
+		t.Errorf("Didn't expected an error. Got %s", err)
+	}
+	if err := (&VersionPolicy{MinimumStability: "unstable"}).Validate(); err == nil {
+		t.Error("Expected an error for an unknown stability. Got none")
+	}
+}
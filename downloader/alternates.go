@@ -0,0 +1,139 @@
+package downloader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RootCommits returns the root commits (commits without a parent) of all refs of the git repository target.
+// Forks of the same project share at least one root commit.
+// The root commits of a shallow repository (see IsShallow) are the boundary of its truncated history
+// and not the real root commits. This is why an error is returned for them.
+func RootCommits(target string) ([]string, error) {
+	if IsShallow(target) {
+		return nil, fmt.Errorf("Repository %s is shallow. Its root commits are unknown", target)
+	}
+	stdOut, err := runGit(target, "rev-list", "--max-parents=0", "--all")
+	if err != nil {
+		return nil, err
+	}
+
+	roots := strings.Fields(string(stdOut))
+	sort.Strings(roots)
+	return roots, nil
+}
+
+// IsShallow returns true if the git repository target is shallow (see Options.Depth).
+func IsShallow(target string) bool {
+	_, err := os.Stat(filepath.Join(target, "shallow"))
+	return err == nil
+}
+
+// GetAlternates returns the object directories the git repository target borrows objects from
+// (see objects/info/alternates). Relative paths are resolved against the object directory of target.
+func GetAlternates(target string) ([]string, error) {
+	objects := filepath.Join(target, "objects")
+	b, err := ioutil.ReadFile(filepath.Join(objects, "info", "alternates"))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	alternates := []string{}
+	for _, l := range strings.Split(string(b), "\n") {
+		l = strings.TrimSpace(l)
+		if len(l) == 0 || strings.HasPrefix(l, "#") {
+			continue
+		}
+		if !filepath.IsAbs(l) {
+			l = filepath.Join(objects, l)
+		}
+		alternates = append(alternates, filepath.Clean(l))
+	}
+	return alternates, nil
+}
+
+// InitPool creates the object pool pool as bare git repository, if it doesn't exist yet.
+//
+// Objects of a pool may be borrowed by other repositories. Git doesn't know about them,
+// so unreachable objects of a pool must never be pruned. Because of this the automatic
+// garbage collection is disabled and a manual garbage collection (see GC) will never prune objects.
+func InitPool(pool string) error {
+	if _, err := os.Stat(pool); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(pool), 0755); err != nil {
+		return err
+	}
+	if _, err := runGit(filepath.Dir(pool), "init", "--bare", "--quiet", pool); err != nil {
+		return err
+	}
+
+	settings := [][]string{
+		{"gc.auto", "0"},
+		{"gc.pruneExpire", "never"},
+		{"gc.reflogExpire", "never"},
+		{"gc.reflogExpireUnreachable", "never"},
+	}
+	for _, s := range settings {
+		if _, err := runGit(pool, "config", s[0], s[1]); err != nil {
+			os.RemoveAll(pool)
+			return err
+		}
+	}
+	return nil
+}
+
+// JoinPool stores the objects of the git repository target in the object pool pool
+// and lets target borrow them from there.
+//
+// All refs of target are fetched into the pool below refs/pool/<name>/.
+// With this all objects target needs are reachable inside the pool.
+// Afterwards target is repacked without the objects that are available in the pool.
+//
+// The pool is referenced with a relative path, so that the dumb HTTP protocol
+// is able to follow it as long as the pool is served next to target.
+//
+// Shallow repositories (see IsShallow) can't join a pool, because their history is incomplete.
+func JoinPool(pool, name, target string) error {
+	if IsShallow(target) {
+		return fmt.Errorf("Repository %s is shallow and can't join an object pool", target)
+	}
+	poolObjects := filepath.Join(pool, "objects")
+	alternates, err := GetAlternates(target)
+	if err != nil {
+		return err
+	}
+	for _, a := range alternates {
+		if a != filepath.Clean(poolObjects) {
+			return fmt.Errorf("Repository %s borrows objects from %s already", target, a)
+		}
+	}
+
+	refspec := fmt.Sprintf("+refs/*:refs/pool/%s/*", name)
+	if _, err := runGit(pool, "fetch", "--quiet", "--no-tags", target, refspec); err != nil {
+		return err
+	}
+
+	objects := filepath.Join(target, "objects")
+	rel, err := filepath.Rel(objects, poolObjects)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(objects, "info", "alternates"), []byte(filepath.ToSlash(rel)+"\n"), 0644); err != nil {
+		return err
+	}
+
+	// -l omits all objects that are borrowed from the pool
+	if _, err := runGit(target, "repack", "-a", "-d", "-l", "-q"); err != nil {
+		return err
+	}
+
+	return (&Git{}).updateServerInfo(target)
+}
@@ -0,0 +1,43 @@
+package downloader_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/downloader"
+)
+
+func TestGetAlternates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "perseus-alternates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	repo := filepath.Join(dir, "symfony", "console.git")
+	info := filepath.Join(repo, "objects", "info")
+	if err := os.MkdirAll(info, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	alternates, err := GetAlternates(repo)
+	if err != nil || len(alternates) != 0 {
+		t.Fatalf("Expected no alternates without alternates file. Got %v (%v)", alternates, err)
+	}
+
+	content := "# Object pool\n../../../_pool/abc.git/objects\n/srv/other/objects\n"
+	if err := ioutil.WriteFile(filepath.Join(info, "alternates"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	alternates, err = GetAlternates(repo)
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	expected := []string{filepath.Join(dir, "_pool", "abc.git", "objects"), "/srv/other/objects"}
+	if !reflect.DeepEqual(alternates, expected) {
+		t.Errorf("Expected %v. Got %v", expected, alternates)
+	}
+}
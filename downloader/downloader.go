@@ -10,6 +10,11 @@ import (
 type Downloader interface {
 	io.Closer
 
+	// Download starts the concurrent download of packages.
+	// It returns immediately. Every package results in a single Result.
 	Download(packages []*dependency.Package)
+	// DownloadStream starts the concurrent download of all packages of the channel packages
+	// as soon as they arrive. It returns immediately. The workers stop once packages is closed.
+	DownloadStream(packages <-chan *dependency.Package)
 	GetResultStream() <-chan *Result
 }
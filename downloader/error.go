@@ -0,0 +1,72 @@
+package downloader
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+const (
+	// ErrorClassExists means the repository exists already on disk
+	ErrorClassExists = "exists"
+	// ErrorClassNotFound means the remote repository doesn't exist (anymore)
+	ErrorClassNotFound = "not_found"
+	// ErrorClassAuth means the remote repository requires (other) credentials
+	ErrorClassAuth = "auth"
+	// ErrorClassTimeout means the remote repository didn't answer in time
+	ErrorClassTimeout = "timeout"
+	// ErrorClassNetwork means the remote repository wasn't reachable (like DNS or connection errors)
+	ErrorClassNetwork = "network"
+	// ErrorClassTampered means the update was blocked, because a tag was moved or a protected branch was force pushed upstream
+	ErrorClassTampered = "tampered"
+	// ErrorClassGit means a git command failed for another reason (like a corrupt repository)
+	ErrorClassGit = "git"
+	// ErrorClassUnknown is every error that can't be classified
+	ErrorClassUnknown = "unknown"
+)
+
+// errorClassPatterns maps parts of git error messages to an error class.
+// The order matters: The first match wins.
+var errorClassPatterns = []struct {
+	class    string
+	patterns []string
+}{
+	{ErrorClassAuth, []string{"authentication failed", "permission denied", "could not read username", "could not read password", "access denied", "returned error: 401", "returned error: 403"}},
+	{ErrorClassNotFound, []string{"repository not found", "does not appear to be a git repository", "returned error: 404", "not found"}},
+	{ErrorClassTimeout, []string{"timed out", "timeout"}},
+	{ErrorClassNetwork, []string{"could not resolve host", "connection refused", "connection reset", "network is unreachable", "unable to access", "early eof", "the remote end hung up"}},
+}
+
+// ClassifyError returns the class (see ErrorClass* constants) of err.
+// Error classes are used for metrics and logs, because error messages
+// are too different to aggregate them.
+// If err is nil, an empty string will be returned.
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+	if os.IsExist(err) {
+		return ErrorClassExists
+	}
+	if IsTamper(err) {
+		return ErrorClassTampered
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, c := range errorClassPatterns {
+		for _, p := range c.patterns {
+			if strings.Contains(msg, p) {
+				return c.class
+			}
+		}
+	}
+
+	// All git commands are wrapped in the same error message.
+	if strings.Contains(msg, "error during cmd") {
+		return ErrorClassGit
+	}
+	return ErrorClassUnknown
+}
@@ -0,0 +1,32 @@
+package downloader_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/downloader"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err   error
+		class string
+	}{
+		{nil, ""},
+		{os.ErrExist, ErrorClassExists},
+		{errors.New("Error during cmd \"[git clone --mirror https://github.com/my/package.git]\". stdErr: remote: Repository not found."), ErrorClassNotFound},
+		{errors.New("Error during cmd \"[git fetch --prune]\". stdErr: fatal: Authentication failed for 'https://github.com/my/package.git/'"), ErrorClassAuth},
+		{errors.New("Error during cmd \"[git fetch --prune]\". stdErr: git@github.com: Permission denied (publickey)."), ErrorClassAuth},
+		{errors.New("Error during cmd \"[git fetch --prune]\". stdErr: fatal: unable to access 'https://github.com/my/package.git/': Could not resolve host: github.com"), ErrorClassNetwork},
+		{errors.New("Error during cmd \"[git fetch --prune]\". stdErr: ssh: connect to host github.com port 22: Connection timed out"), ErrorClassTimeout},
+		{errors.New("Error during cmd \"[git fsck]\". stdErr: error: object file is empty"), ErrorClassGit},
+		{errors.New("Something strange happened"), ErrorClassUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.class {
+			t.Errorf("ClassifyError(%v) = %q; want %q", tt.err, got, tt.class)
+		}
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
+	"time"
 
+	"github.com/andygrunwald/perseus/credentials"
 	"github.com/andygrunwald/perseus/dependency"
+	"github.com/andygrunwald/perseus/limit"
+	"github.com/andygrunwald/perseus/progress"
 )
 
 // Git represents an Updater and Downloader for the git protocol
@@ -20,18 +25,34 @@ type Git struct {
 	queue chan *dependency.Package
 	// results is the channel where all resolved dependencies will be streamed
 	results chan *Result
+
+	// credentials are used to authenticate against the upstream git hosts
+	credentials *credentials.Store
+
+	// options are the settings of the packages to download
+	options *PackageOptions
+	// updateOptions are the settings of the repository to update
+	updateOptions *Options
 }
 
 // Result reflects a result of a concurrent download process.
 type Result struct {
 	Package *dependency.Package
 	Error   error
+	// Duration is the time the download took
+	Duration time.Duration
+	// Worker is the id of the worker that processed the download
+	Worker int
+	// RefCount is the number of refs of the mirror after a successful download
+	RefCount int
 }
 
 // NewGitDownloader creates a new downloader based on the git protocol.
 // numOfWorker initiates the number of workers we should spawn to work concurrent.
 // dir is the base directory where the downloads will be mirrored, too.
-func NewGitDownloader(numOfWorker int, dir string) (Downloader, error) {
+// creds are the credentials for the upstream git hosts (may be nil).
+// options are the settings of the packages (may be nil).
+func NewGitDownloader(numOfWorker int, dir string, creds *credentials.Store, options *PackageOptions) (Downloader, error) {
 	if numOfWorker == 0 {
 		return nil, fmt.Errorf("Starting a concurrent git downloader with zero worker is not possible")
 	}
@@ -41,6 +62,8 @@ func NewGitDownloader(numOfWorker int, dir string) (Downloader, error) {
 		dir:         dir,
 		queue:       make(chan *dependency.Package, (numOfWorker + 1)),
 		results:     make(chan *Result),
+		credentials: creds,
+		options:     options,
 	}
 	return c, nil
 }
@@ -58,92 +81,243 @@ func (d *Git) Close() error {
 	return nil
 }
 
-// Download will start the concurrent download process
+// Download will start the concurrent download process.
+// The packages are queued in the background, because the queue is bounded
+// and the workers block until their results are received.
 func (d *Git) Download(packages []*dependency.Package) {
-	// Start the worker
-	for w := 1; w <= d.workerCount; w++ {
-		go d.worker(w, d.queue, d.results)
-	}
+	go func() {
+		for _, p := range packages {
+			d.queue <- p
+		}
+		close(d.queue)
+	}()
+	d.DownloadStream(d.queue)
+}
+
+// DownloadStream will start the concurrent download process for the packages of the channel packages.
+// Every package is cloned as soon as a worker and a transfer slot of its host are free (see limit.Queue).
+// Packages of capped hosts don't block the workers for packages of other hosts.
+func (d *Git) DownloadStream(packages <-chan *dependency.Package) {
+	q := limit.NewQueue(d.options.hosts())
+	go func() {
+		for p := range packages {
+			q.Push(credentials.GetHost(p.Repository.String()), p)
+		}
+		q.Close()
+	}()
 
-	// Queue the downloads
-	for _, p := range packages {
-		d.queue <- p
+	for w := 1; w <= d.workerCount; w++ {
+		go d.worker(w, q, d.results)
 	}
-	close(d.queue)
 }
 
 // worker is a single worker routine. This worker will be launched multiple times to work on
 // the queue as efficient as possible.
 // id the a id per worker (only for logging/debugging purpose).
-// jobs is the jobs channel (the worker needs to be able to read the jobs).
+// jobs is the queue of packages (the worker receives a package together with the transfer slot of its host).
 // results is the channel where all results will be stored once they are resolved.
-func (d *Git) worker(id int, jobs <-chan *dependency.Package, results chan<- *Result) {
-	for j := range jobs {
-		targetDir := fmt.Sprintf("%s/%s.git", d.dir, j.Name)
-
-		// Check if directory already exists
-		_, err := os.Stat(targetDir)
-		if err == nil {
-			// Directory exists
-			r := &Result{
-				Package: j,
-				Error:   os.ErrExist,
-			}
-			results <- r
-			continue
+func (d *Git) worker(id int, jobs *limit.Queue, results chan<- *Result) {
+	for {
+		j, release, ok := jobs.Next()
+		if !ok {
+			return
 		}
+		results <- d.download(id, j.(*dependency.Package), release)
+	}
+}
 
-		// Initial clone
-		err = d.clone(j.Repository.String(), targetDir)
-		if err != nil {
-			r := &Result{
-				Package: j,
-				Error:   err,
-			}
-			results <- r
-			continue
+// download clones the package j.
+// release releases the transfer slot of the host of j (see initialClone).
+func (d *Git) download(id int, j *dependency.Package, release func()) *Result {
+	start := time.Now()
+	targetDir := fmt.Sprintf("%s/%s.git", d.dir, j.Name)
+	opts := d.options.Get(j.Name).forPackage(j.Name)
+
+	err := d.initialClone(j, targetDir, opts, release)
+	if err == os.ErrExist {
+		return &Result{
+			Package: j,
+			Error:   err,
+			Worker:  id,
 		}
+	}
+	if err != nil {
+		return &Result{
+			Package:  j,
+			Error:    err,
+			Duration: time.Since(start),
+			Worker:   id,
+		}
+	}
 
-		err = d.updateServerInfo(targetDir)
-		if err != nil {
-			r := &Result{
-				Package: j,
-				Error:   err,
-			}
-			results <- r
-			continue
+	err = d.updateServerInfo(targetDir)
+	if err != nil {
+		return &Result{
+			Package:  j,
+			Error:    err,
+			Duration: time.Since(start),
+			Worker:   id,
 		}
+	}
 
+	if opts == nil || !opts.SkipFsck {
 		err = d.fsck(targetDir)
 		if err != nil {
-			r := &Result{
-				Package: j,
-				Error:   err,
+			return &Result{
+				Package:  j,
+				Error:    err,
+				Duration: time.Since(start),
+				Worker:   id,
 			}
-			results <- r
-			continue
 		}
+	}
 
-		// Everything successful downloaded
-		r := &Result{
-			Package: j,
-			Error:   nil,
-		}
-		results <- r
+	// The ref count is only informative.
+	// A failure here doesn't make the download fail.
+	refCount, _ := CountRefs(targetDir)
+
+	// Everything successful downloaded
+	return &Result{
+		Package:  j,
+		Error:    nil,
+		Duration: time.Since(start),
+		Worker:   id,
+		RefCount: refCount,
+	}
+}
+
+// initialClone clones the package j to targetDir with the options opts.
+// If targetDir exists already, os.ErrExist is returned.
+// release releases the transfer slot of the host of j. It is called once the clone is finished.
+func (d *Git) initialClone(j *dependency.Package, targetDir string, opts *Options, release func()) error {
+	defer release()
+
+	// Check if directory already exists
+	if _, err := os.Stat(targetDir); err == nil {
+		return os.ErrExist
+	}
+	return d.clone(j.Repository.String(), targetDir, opts)
+}
+
+// ListRefs returns the names of all refs (like refs/heads/master or refs/tags/v1.0.0) of the git repository target.
+func ListRefs(target string) ([]string, error) {
+	stdOut, err := runGit(target, "for-each-ref", "--format=%(refname)")
+	if err != nil {
+		return nil, err
 	}
+
+	return strings.Fields(string(stdOut)), nil
+}
+
+// CountRefs returns the number of refs (branches, tags, ...) of the git repository target.
+func CountRefs(target string) (int, error) {
+	refs, err := ListRefs(target)
+	return len(refs), err
+}
+
+// GetRemoteURL returns the URL of the upstream repository (remote "origin") of the git repository target.
+func GetRemoteURL(target string) (string, error) {
+	stdOut, err := runGit(target, "config", "--get", "remote.origin.url")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(stdOut)), nil
+}
+
+// SetRemoteURL sets the URL of the upstream repository (remote "origin") of the git repository target to u.
+func SetRemoteURL(target, u string) error {
+	_, err := runGit(target, "remote", "set-url", "origin", u)
+	return err
+}
+
+// runGit executes the git command with args in directory dir and returns stdout.
+func runGit(dir string, args ...string) ([]byte, error) {
+	return runGitWithEnv(dir, nil, args...)
+}
+
+// runGitWithEnv is like runGit, but with the environment env.
+// If env is nil, the git command inherits the environment of the current process.
+func runGitWithEnv(dir string, env []string, args ...string) ([]byte, error) {
+	return runGitWithInput(dir, env, "", args...)
 }
 
-func (d *Git) clone(repository, target string) error {
-	cmd := exec.Command("git", "clone", "--mirror", repository, target)
+// runGitWithInput is like runGitWithEnv, but passes input to stdin of the git command.
+func runGitWithInput(dir string, env []string, input string, args ...string) ([]byte, error) {
+	return runGitWithProgress(dir, env, input, nil, args...)
+}
+
+// runGitWithProgress is like runGitWithInput, but publishes the progress of the git transfer
+// to the progress publisher of opts (see progressWriter).
+// If opts publish no progress, it is equal to runGitWithInput.
+func runGitWithProgress(dir string, env []string, input string, opts *Options, args ...string) ([]byte, error) {
+	var pw *progressWriter
+	if opts.hasProgress() && len(args) > 0 {
+		pw = &progressWriter{opts: opts}
+		args = append([]string{args[0], "--progress"}, args[1:]...)
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	if len(input) > 0 {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	if pw != nil {
+		cmd.Stderr = pw
+	}
 	stdOut, err := cmd.Output()
 	if err != nil {
 		if ee, ok := err.(*exec.ExitError); ok {
-			return fmt.Errorf("Error during cmd \"%+v\". Process state: %s. stdOut: %s. stdErr: %s", cmd.Args, ee.String(), stdOut, ee.Stderr)
+			stdErr := ee.Stderr
+			if pw != nil {
+				stdErr = pw.Stderr()
+			}
+			return nil, fmt.Errorf("Error during cmd \"%+v\". Process state: %s. stdOut: %s. stdErr: %s", cmd.Args, ee.String(), stdOut, stdErr)
 		}
-		return fmt.Errorf("Error during cmd \"%+v\". stdOut: %s", cmd.Args, stdOut)
+		return nil, fmt.Errorf("Error during cmd \"%+v\". stdOut: %s", cmd.Args, stdOut)
 	}
 
-	return nil
+	return stdOut, nil
+}
+
+// clone clones repository into target.
+// The caller needs to hold a transfer slot of the host of repository (see limit.Hosts).
+func (d *Git) clone(repository, target string, opts *Options) error {
+	env, err := opts.throttleTransfer(d.credentials.GitEnvFor(repository, opts.credentialsName()))
+	if err != nil {
+		return err
+	}
+	opts.publish(&progress.Event{Type: progress.EventStarted})
+
+	if !opts.hasRefspecs() {
+		args := append([]string{"clone", "--mirror"}, opts.depthArgs()...)
+		_, err := runGitWithProgress("", env, "", opts, append(args, repository, target)...)
+		return err
+	}
+
+	// `git clone --mirror` mirrors all refs.
+	// To mirror only a part of the refs, we set up the mirror by hand and fetch the refs afterwards.
+	err = d.cloneRefs(repository, target, opts, env)
+	if err != nil {
+		os.RemoveAll(target)
+	}
+	return err
+}
+
+// cloneRefs creates the mirror target of repository with the refs that match the ref patterns of opts only.
+// env is the environment of the git commands that talk to repository.
+func (d *Git) cloneRefs(repository, target string, opts *Options, env []string) error {
+	if _, err := runGit("", "init", "--bare", "--quiet", target); err != nil {
+		return err
+	}
+	if _, err := runGit(target, "remote", "add", "origin", repository); err != nil {
+		return err
+	}
+	if err := configureRemote(target, opts); err != nil {
+		return err
+	}
+	return fetchRefs(target, env, opts)
 }
 
 func (d *Git) fsck(target string) error {
@@ -182,38 +356,148 @@ func (d *Git) updateServerInfo(target string) error {
 }
 
 // NewGitUpdater created a new updater based on the git protocol.
+// creds are the credentials for the upstream git hosts (may be nil).
+// opts are the settings of the repository to update (may be nil).
 //
 // TODO Make me concurrent
-func NewGitUpdater() (Updater, error) {
-	client := &Git{}
+func NewGitUpdater(creds *credentials.Store, opts *Options) (Updater, error) {
+	client := &Git{
+		credentials:   creds,
+		updateOptions: opts,
+	}
 	return client, nil
 }
 
 // Update updates target with a simple `git fetch`.
-func (d *Git) Update(target string) error {
-	err := d.fetch(target)
+// If the options limit the refs, only the matching refs are fetched (see fetchRefs).
+// The changed refs are returned.
+// If the tamper policy blocks a change, the update is rolled back and a TamperError is returned.
+// With immutable tags, tags are never deleted or moved (see updateImmutable).
+// The caller needs to hold a transfer slot of the upstream host (see limit.Queue).
+func (d *Git) Update(target string) ([]*RefChange, error) {
+	before, err := snapshotRefs(target)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	err = configureRemote(target, d.updateOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*RefChange
+	if d.updateOptions.hasImmutableTags() {
+		changes, err = d.updateImmutable(target, before)
+	} else {
+		changes, err = d.updateAll(target, before)
+	}
+	if IsTamper(err) {
+		// The refs of a blocked update are rolled back, but the fetch rewrote FETCH_HEAD already
+		if err := d.updateServerInfo(target); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return changes, err
 	}
 
 	err = d.updateServerInfo(target)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return changes, nil
 }
 
-func (d *Git) fetch(target string) error {
-	cmd := exec.Command("git", "fetch", "--prune")
-	cmd.Dir = target
-	stdOut, err := cmd.Output()
+// updateAll fetches all (mirrored) refs of target and returns the changed refs since the snapshot before.
+func (d *Git) updateAll(target string, before map[string]string) ([]*RefChange, error) {
+	if err := d.fetch(target); err != nil {
+		return nil, err
+	}
+	// The archive of a mirror that had immutable tags before is kept
+	if err := restorePerseusRefs(target, before); err != nil {
+		return nil, err
+	}
+	return d.getRefChanges(target, before)
+}
+
+// updateImmutable updates target without deleting or moving a tag (see updateImmutable).
+func (d *Git) updateImmutable(target string, before map[string]string) ([]*RefChange, error) {
+	env, err := d.upstreamEnv(target)
 	if err != nil {
-		if ee, ok := err.(*exec.ExitError); ok {
-			return fmt.Errorf("Error during cmd \"%+v\". Process state: %s. stdOut: %s. stdErr: %s", cmd.Args, ee.String(), stdOut, ee.Stderr)
+		return nil, err
+	}
+	return updateImmutable(target, env, d.updateOptions, before)
+}
+
+// getRefChanges returns the changed refs of target since the snapshot before.
+// Refs that are not mirrored (anymore) because of the ref patterns are ignored.
+// Blocked changes roll back the update.
+func (d *Git) getRefChanges(target string, before map[string]string) ([]*RefChange, error) {
+	opts := d.updateOptions
+	after, err := snapshotRefs(target)
+	if err != nil {
+		return nil, err
+	}
+	changes := diffRefs(target, before, after, opts != nil && opts.Depth > 0)
+
+	if opts == nil {
+		return changes, nil
+	}
+
+	if opts.hasRefspecs() {
+		m, err := newRefMatcher(opts.Refspecs)
+		if err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("Error during cmd \"%+v\". stdOut: %s", cmd.Args, stdOut)
+		mirrored := changes[:0]
+		for _, c := range changes {
+			if m.Match(c.Ref) {
+				mirrored = append(mirrored, c)
+			}
+		}
+		changes = mirrored
 	}
 
-	return nil
+	if err := opts.Tamper.apply(changes); err != nil {
+		return nil, err
+	}
+	if blocked := blockedChanges(changes); len(blocked) > 0 {
+		if err := rollbackRefs(target, before, after); err != nil {
+			return nil, err
+		}
+		return changes, &TamperError{Changes: blocked}
+	}
+	return changes, nil
+}
+
+// upstreamEnv returns the environment of the git commands that talk to the upstream repository of target
+// (see throttleTransfer).
+func (d *Git) upstreamEnv(target string) ([]string, error) {
+	var env []string
+	u, err := GetRemoteURL(target)
+	if err == nil {
+		env = d.credentials.GitEnvFor(u, d.updateOptions.credentialsName())
+	}
+	env, err = d.updateOptions.throttleTransfer(env)
+	if err != nil {
+		return nil, err
+	}
+	d.updateOptions.publish(&progress.Event{Type: progress.EventStarted})
+	return env, nil
+}
+
+func (d *Git) fetch(target string) error {
+	env, err := d.upstreamEnv(target)
+	if err != nil {
+		return err
+	}
+
+	if d.updateOptions.hasRefspecs() {
+		return fetchRefs(target, env, d.updateOptions)
+	}
+
+	args := append([]string{"fetch", "--prune"}, d.updateOptions.depthArgs()...)
+	_, err = runGitWithProgress(target, env, "", d.updateOptions, args...)
+	return err
 }
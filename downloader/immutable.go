@@ -0,0 +1,216 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// hasImmutableTags returns true if the options protect the mirror against deleted or rewritten refs.
+func (o *Options) hasImmutableTags() bool {
+	return o != nil && o.ImmutableTags
+}
+
+// archiveRef returns the ref of the archive for ref with the suffix s (like an object name)
+// e.g. refs/heads/master => refs/perseus/archived/heads/master/<s>
+func archiveRef(ref, s string) string {
+	return ArchivePrefix + strings.TrimPrefix(ref, "refs/") + "/" + s
+}
+
+// archived returns the object the archive of the change points to (see RefChange.Archive).
+func (c *RefChange) archived() string {
+	if c.Kind == RefTagMoved {
+		return c.New
+	}
+	return c.Old
+}
+
+// updateImmutable updates the mirror target without deleting or moving a tag (see Options.ImmutableTags).
+// before is the snapshot of the refs of target before the update.
+// env is the environment of the git commands that talk to the upstream repository.
+//
+// No fetch touches a ref: The objects of the changed upstream refs are fetched into FETCH_HEAD only.
+// Afterwards all changes are applied within a single `git update-ref` transaction:
+//
+//   - New refs are created and branches follow upstream.
+//   - The old state of a deleted or force pushed branch is archived (see ArchivePrefix).
+//   - Tags are never deleted or moved. The new upstream state of a moved tag is archived instead.
+//   - Refs of the archive are never touched.
+//
+// If the tamper policy blocks a change, nothing is applied and a TamperError is returned.
+// Moved or deleted tags that are archived already are not returned again.
+func updateImmutable(target string, env []string, opts *Options, before map[string]string) ([]*RefChange, error) {
+	upstream, head, err := lsRemote(target, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// mirrored returns true if ref is part of the mirror (see Options.Refspecs)
+	m, err := newRefMatcher(opts.Refspecs)
+	if err != nil {
+		return nil, err
+	}
+	mirrored := func(ref string) bool {
+		return !isPerseusRef(ref) && (!opts.hasRefspecs() || m.Match(ref))
+	}
+
+	wanted := map[string]string{}
+	changed := []string{}
+	for _, ref := range sortedRefs(upstream) {
+		if !mirrored(ref) {
+			continue
+		}
+		wanted[ref] = upstream[ref]
+		if before[ref] != upstream[ref] {
+			changed = append(changed, ref)
+		}
+	}
+	if opts.hasRefspecs() && len(wanted) == 0 {
+		return nil, fmt.Errorf("No ref of the upstream repository matches the ref patterns %s", strings.Join(opts.Refspecs, ", "))
+	}
+
+	if len(changed) > 0 {
+		// Refspecs without a destination are fetched into FETCH_HEAD only.
+		// The empty --refmap prevents that the configured fetch refspecs of the remote update any ref.
+		args := append([]string{"fetch", "--no-tags", "--refmap=", "--stdin"}, opts.depthArgs()...)
+		if _, err := runGitWithProgress(target, env, strings.Join(changed, "\n")+"\n", opts, append(args, "origin")...); err != nil {
+			return nil, err
+		}
+		if err := checkFetched(target, changed, wanted); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, stale := classifyImmutable(target, before, wanted, mirrored, opts.Depth > 0)
+	if err := opts.Tamper.apply(changes); err != nil {
+		return nil, err
+	}
+	if blocked := blockedChanges(changes); len(blocked) > 0 {
+		return changes, &TamperError{Changes: blocked}
+	}
+
+	var tx bytes.Buffer
+	for _, c := range changes {
+		if len(c.Archive) > 0 {
+			fmt.Fprintf(&tx, "update %s %s\n", c.Archive, c.archived())
+		}
+		switch {
+		case c.Kept:
+		case c.Kind == RefDeleted:
+			fmt.Fprintf(&tx, "delete %s %s\n", c.Ref, c.Old)
+		case len(c.Old) == 0:
+			fmt.Fprintf(&tx, "create %s %s\n", c.Ref, c.New)
+		default:
+			fmt.Fprintf(&tx, "update %s %s %s\n", c.Ref, c.New, c.Old)
+		}
+	}
+	// Refs that are not mirrored anymore because of the ref patterns (tags are kept)
+	for _, ref := range stale {
+		fmt.Fprintf(&tx, "delete %s %s\n", ref, before[ref])
+	}
+	if tx.Len() > 0 {
+		if _, err := runGitWithInput(target, nil, tx.String(), "update-ref", "--stdin"); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := wanted[head]; ok && opts.hasRefspecs() {
+		if _, err := runGit(target, "symbolic-ref", "HEAD", head); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// classifyImmutable returns the changes between the refs of the mirror target before the update
+// and the wanted refs of the upstream repository (see updateImmutable).
+// mirrored decides if a ref of the mirror is part of the mirror (see Options.Refspecs).
+// Refs of the mirror that are not mirrored anymore are returned as stale (without tags).
+// If shallow is true, updated branches are always considered as fast-forward, because the history of a shallow mirror is incomplete.
+func classifyImmutable(target string, before, wanted map[string]string, mirrored func(ref string) bool, shallow bool) ([]*RefChange, []string) {
+	refs := map[string]string{}
+	for ref, o := range before {
+		refs[ref] = o
+	}
+	for ref, o := range wanted {
+		refs[ref] = o
+	}
+
+	changes := []*RefChange{}
+	stale := []string{}
+	for _, ref := range sortedRefs(refs) {
+		if isPerseusRef(ref) {
+			continue
+		}
+		old, existed := before[ref]
+		n, ok := wanted[ref]
+
+		var c *RefChange
+		switch {
+		case !existed:
+			kind := RefNewRef
+			switch {
+			case isTag(ref):
+				kind = RefNewTag
+			case isBranch(ref):
+				kind = RefNewBranch
+			}
+			c = &RefChange{Ref: ref, Kind: kind, New: n}
+		case !ok && !mirrored(ref):
+			if !isTag(ref) {
+				stale = append(stale, ref)
+			}
+			continue
+		case !ok:
+			c = &RefChange{Ref: ref, Kind: RefDeleted, Old: old}
+			switch {
+			case isTag(ref):
+				c.Kept = true
+				c.Archive = archiveRef(ref, "deleted")
+			case isBranch(ref):
+				c.Archive = archiveRef(ref, old)
+			}
+		case n == old:
+			continue
+		case isTag(ref):
+			c = &RefChange{Ref: ref, Kind: RefTagMoved, Old: old, New: n, Kept: true, Archive: archiveRef(ref, n)}
+		default:
+			c = &RefChange{Ref: ref, Kind: RefFastForward, Old: old, New: n}
+			if !shallow {
+				// If the ancestry can't be determined, we assume a regular update.
+				if ff, err := isAncestor(target, old, n); err == nil && !ff {
+					c.Kind = RefForcePush
+				}
+			}
+			if c.Kind == RefForcePush && isBranch(ref) {
+				c.Archive = archiveRef(ref, old)
+			}
+		}
+
+		// A kept tag whose upstream state is archived already is known from a former update
+		if c.Kept && before[c.Archive] == c.archived() {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	return changes, stale
+}
+
+// checkFetched returns an error if an object of the refs of wanted is missing in the git repository target
+// (e.g. because a ref changed upstream between listing and fetching it).
+func checkFetched(target string, refs []string, wanted map[string]string) error {
+	objects := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		objects = append(objects, wanted[ref])
+	}
+	stdOut, err := runGitWithInput(target, nil, strings.Join(objects, "\n")+"\n", "cat-file", "--batch-check")
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(strings.TrimSpace(string(stdOut)), "\n") {
+		if strings.HasSuffix(line, " missing") && i < len(refs) {
+			return fmt.Errorf("Object %s of %s is missing after the fetch. The ref probably changed upstream during the update", objects[i], refs[i])
+		}
+	}
+	return nil
+}
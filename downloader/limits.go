@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"os"
+	"strings"
+
+	"github.com/andygrunwald/perseus/credentials"
+	"github.com/andygrunwald/perseus/limit"
+)
+
+// gitConfigParameters is the environment variable of the configuration keys of `git -c`
+const gitConfigParameters = "GIT_CONFIG_PARAMETERS"
+
+// throttleTransfer routes HTTP(S) transfers through the throttling proxy (see limit.Limits.ProxyURL).
+// env is the environment of the git transfer. It returns the environment with the proxy.
+// Transfers via SSH are not throttled.
+func (o *Options) throttleTransfer(env []string) ([]string, error) {
+	if o == nil || o.Limits == nil {
+		return env, nil
+	}
+
+	proxy, err := o.Limits.ProxyURL()
+	if err != nil {
+		return nil, err
+	}
+	if len(proxy) > 0 {
+		env = withGitConfig(env, "http.proxy", proxy)
+	}
+	return env, nil
+}
+
+// acquireHost blocks until a transfer slot of the host of repository is free (see limit.Hosts).
+// The returned function releases the slot once the transfer is finished.
+func (o *Options) acquireHost(repository string) func() {
+	if o == nil || o.Limits == nil {
+		return func() {}
+	}
+	return o.Limits.Hosts.Acquire(credentials.GetHost(repository))
+}
+
+// hosts returns the transfer caps per host (see limit.Limits.Hosts).
+// The limits are shared by all packages of the process, so the ones of the default options are used.
+func (o *PackageOptions) hosts() *limit.Hosts {
+	if o == nil || o.Default == nil || o.Default.Limits == nil {
+		return nil
+	}
+	return o.Default.Limits.Hosts
+}
+
+// withGitConfig returns env with the git configuration key k set to v (like `git -c k=v`).
+// Configuration keys of `git -c` overwrite all configuration files.
+// If env is nil, the environment of the current process is used.
+func withGitConfig(env []string, k, v string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+
+	params := "'" + k + "=" + v + "'"
+	l := make([]string, 0, len(env)+1)
+	for _, e := range env {
+		if strings.HasPrefix(e, gitConfigParameters+"=") {
+			params = strings.TrimPrefix(e, gitConfigParameters+"=") + " " + params
+			continue
+		}
+		l = append(l, e)
+	}
+	return append(l, gitConfigParameters+"="+params)
+}
@@ -0,0 +1,89 @@
+package downloader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ObjectStats reflects the object storage of a git repository (see `git count-objects -v`).
+type ObjectStats struct {
+	// LooseObjects is the number of loose objects
+	LooseObjects int
+	// Packs is the number of pack files
+	Packs int
+}
+
+// GCThresholds decide when a mirror needs a garbage collection.
+// A garbage collection is needed once at least one threshold is exceeded.
+// A threshold of zero is disabled.
+type GCThresholds struct {
+	// LooseObjects is the maximum number of loose objects
+	LooseObjects int
+	// Packs is the maximum number of pack files
+	Packs int
+	// MaxAge is the maximum time since the last garbage collection
+	MaxAge time.Duration
+}
+
+// Needed returns true and the reason if a mirror with the object storage stats
+// and the last garbage collection at lastGC needs a garbage collection.
+// A zero lastGC means the mirror was never garbage collected (by perseus).
+func (t *GCThresholds) Needed(stats *ObjectStats, lastGC time.Time) (bool, string) {
+	if t.LooseObjects > 0 && stats.LooseObjects > t.LooseObjects {
+		return true, fmt.Sprintf("%d loose objects (threshold %d)", stats.LooseObjects, t.LooseObjects)
+	}
+	if t.Packs > 0 && stats.Packs > t.Packs {
+		return true, fmt.Sprintf("%d packs (threshold %d)", stats.Packs, t.Packs)
+	}
+	if t.MaxAge > 0 && (lastGC.IsZero() || time.Since(lastGC) > t.MaxAge) {
+		return true, fmt.Sprintf("last garbage collection older than %s", t.MaxAge)
+	}
+	return false, ""
+}
+
+// CountObjects returns the object storage stats of the git repository target.
+func CountObjects(target string) (*ObjectStats, error) {
+	stdOut, err := runGit(target, "count-objects", "-v")
+	if err != nil {
+		return nil, err
+	}
+
+	// Output looks like
+	//	count: 12
+	//	size: 48
+	//	in-pack: 4711
+	//	packs: 2
+	//	...
+	s := &ObjectStats{}
+	for _, l := range strings.Split(string(stdOut), "\n") {
+		parts := strings.SplitN(l, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			continue
+		}
+		switch parts[0] {
+		case "count":
+			s.LooseObjects = v
+		case "packs":
+			s.Packs = v
+		}
+	}
+
+	return s, nil
+}
+
+// GC runs the garbage collection (incl. repacking) of the git repository target.
+// Afterwards info/refs will be regenerated for the dumb HTTP protocol.
+// Objects that are borrowed from an object pool (see JoinPool) stay in the pool.
+func GC(target string) error {
+	if _, err := runGit(target, "gc", "--quiet"); err != nil {
+		return err
+	}
+
+	return (&Git{}).updateServerInfo(target)
+}
@@ -0,0 +1,43 @@
+package downloader_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/downloader"
+)
+
+func TestGCThresholds_Needed(t *testing.T) {
+	thresholds := &GCThresholds{
+		LooseObjects: 100,
+		Packs:        10,
+		MaxAge:       30 * 24 * time.Hour,
+	}
+	recently := time.Now().Add(-24 * time.Hour)
+
+	tests := []struct {
+		Thresholds *GCThresholds
+		Stats      *ObjectStats
+		LastGC     time.Time
+		Expected   bool
+	}{
+		{thresholds, &ObjectStats{LooseObjects: 10, Packs: 2}, recently, false},
+		{thresholds, &ObjectStats{LooseObjects: 101, Packs: 2}, recently, true},
+		{thresholds, &ObjectStats{LooseObjects: 10, Packs: 11}, recently, true},
+		{thresholds, &ObjectStats{LooseObjects: 10, Packs: 2}, time.Now().Add(-31 * 24 * time.Hour), true},
+		// Never garbage collected
+		{thresholds, &ObjectStats{LooseObjects: 10, Packs: 2}, time.Time{}, true},
+		// Disabled thresholds
+		{&GCThresholds{}, &ObjectStats{LooseObjects: 100000, Packs: 1000}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, reason := tt.Thresholds.Needed(tt.Stats, tt.LastGC)
+		if got != tt.Expected {
+			t.Errorf("Expected Needed(%+v, %s) = %v. Got %v (%s)", tt.Stats, tt.LastGC, tt.Expected, got, reason)
+		}
+		if got && len(reason) == 0 {
+			t.Errorf("Expected a reason for Needed(%+v, %s). Got none", tt.Stats, tt.LastGC)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package downloader
+
+import (
+	"fmt"
+
+	"github.com/andygrunwald/perseus/limit"
+	"github.com/andygrunwald/perseus/progress"
+)
+
+// Options are the settings of a single package for clones and updates.
+// The zero value (and nil) mirrors the complete repository.
+type Options struct {
+	// SkipFsck disables the file system check after the initial clone
+	SkipFsck bool
+	// Depth creates a shallow mirror with the history truncated to Depth commits.
+	// 0 mirrors the complete history.
+	Depth int
+	// Refspecs limits the refs that are mirrored to the refs that match one of the ref patterns
+	// like "refs/tags/*", "refs/heads/master" or "refs/heads/[0-9]*.x" (see CheckRefPattern).
+	// If empty, all refs are mirrored.
+	Refspecs []string
+	// Credentials is the name of an entry of the credential store.
+	// If set, it is used instead of the credentials of the host of the repository.
+	Credentials string
+	// ImmutableTags protects the mirror against refs that are deleted or rewritten upstream during an update.
+	// Tags are never deleted or moved. Deleted or rewritten branches follow upstream,
+	// but their old state is archived (see ArchivePrefix).
+	ImmutableTags bool
+	// Tamper decides what happens if a tag is moved or a protected branch is force pushed upstream.
+	// If nil, all changes are accepted.
+	Tamper *TamperPolicy
+	// Limits cap the concurrent git transfers per host and the bandwidth of all git transfers.
+	// If nil, git transfers are unlimited.
+	Limits *limit.Limits
+	// Progress receives the progress events of the git transfers (see progress.EventStarted and progress.EventTransfer).
+	// If nil, no progress is published.
+	Progress progress.Publisher
+}
+
+// PackageOptions are the options of all packages.
+type PackageOptions struct {
+	// Default are the options of all packages without own options (may be nil)
+	Default *Options
+	// Packages are the options of single packages by package name
+	Packages map[string]*Options
+}
+
+// Get returns the options of package name.
+// If the package has no own options, the default options will be returned.
+func (o *PackageOptions) Get(name string) *Options {
+	if o == nil {
+		return nil
+	}
+	if opts, ok := o.Packages[name]; ok {
+		return opts
+	}
+	return o.Default
+}
+
+// hasRefspecs returns true if the options limit the refs that are mirrored.
+func (o *Options) hasRefspecs() bool {
+	return o != nil && len(o.Refspecs) > 0
+}
+
+// credentialsName returns the name of the credentials of the options o.
+func (o *Options) credentialsName() string {
+	if o == nil {
+		return ""
+	}
+	return o.Credentials
+}
+
+// depthArgs returns the arguments of git clone and git fetch for a shallow mirror.
+func (o *Options) depthArgs() []string {
+	if o == nil || o.Depth <= 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("--depth=%d", o.Depth)}
+}
@@ -0,0 +1,159 @@
+package downloader_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andygrunwald/perseus/dependency"
+	. "github.com/andygrunwald/perseus/downloader"
+	"github.com/andygrunwald/perseus/progress"
+)
+
+// git executes a git command in dir and fails the test on error.
+func git(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=perseus", "-c", "user.email=perseus@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Error during cmd \"%+v\": %s. Output: %s", cmd.Args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestGit_Download_Options(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-options")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	upstream := filepath.Join(dir, "upstream")
+	os.MkdirAll(upstream, 0755)
+	git(t, upstream, "init", "-q")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Second commit")
+	git(t, upstream, "branch", "feature")
+	git(t, upstream, "branch", "1.x")
+	git(t, upstream, "branch", "2.x")
+	git(t, upstream, "tag", "-a", "-m", "Release", "v1.0.0")
+	head := git(t, upstream, "symbolic-ref", "HEAD")
+
+	repoDir := filepath.Join(dir, "git-mirror")
+	options := &PackageOptions{
+		Packages: map[string]*Options{
+			"symfony/console": {
+				Depth:    1,
+				Refspecs: []string{head, "refs/heads/[0-9]*.x", "refs/tags/*"},
+			},
+		},
+	}
+	d, err := NewGitDownloader(1, repoDir, nil, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// file:// is required for shallow clones of local repositories
+	p, err := dependency.NewPackage("symfony/console", "file://"+upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go d.Download([]*dependency.Package{p})
+	r := <-d.GetResultStream()
+	d.Close()
+	if r.Error != nil {
+		t.Fatalf("Expected no error. Got %s", r.Error)
+	}
+
+	mirror := filepath.Join(repoDir, "symfony", "console.git")
+	expected := []string{"refs/heads/1.x", "refs/heads/2.x", head, "refs/tags/v1.0.0"}
+	if refs := strings.Fields(git(t, mirror, "for-each-ref", "--format=%(refname)")); strings.Join(refs, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected refs %v. Got %v", expected, refs)
+	}
+	if h := git(t, mirror, "symbolic-ref", "HEAD"); h != head {
+		t.Errorf("Expected HEAD to point to %s. Got %s", head, h)
+	}
+	if n := git(t, mirror, "rev-list", "--count", "--all"); n != "1" {
+		t.Errorf("Expected a shallow mirror with one commit. Got %s commits", n)
+	}
+
+	// Refs that don't match anymore are deleted during the update
+	u, _ := NewGitUpdater(nil, &Options{Refspecs: []string{"refs/tags/*"}})
+	if _, err := u.Update(mirror); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if refs := git(t, mirror, "for-each-ref", "--format=%(refname)"); refs != "refs/tags/v1.0.0" {
+		t.Errorf("Expected only refs/tags/v1.0.0 after the update. Got %s", refs)
+	}
+
+	// Without refspecs an update mirrors all refs again
+	u, _ = NewGitUpdater(nil, nil)
+	if _, err := u.Update(mirror); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if refs := strings.Fields(git(t, mirror, "for-each-ref", "--format=%(refname)")); len(refs) != 5 {
+		t.Errorf("Expected five refs after the update. Got %v", refs)
+	}
+}
+
+// recorder is a progress.Publisher that records all events.
+type recorder struct {
+	mu     sync.Mutex
+	events []*progress.Event
+}
+
+func (r *recorder) Publish(e *progress.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func TestGit_Download_Progress(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	upstream := filepath.Join(dir, "upstream")
+	os.MkdirAll(upstream, 0755)
+	git(t, upstream, "init", "-q")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+
+	rec := &recorder{}
+	d, err := NewGitDownloader(1, filepath.Join(dir, "git-mirror"), nil, &PackageOptions{Default: &Options{Progress: rec}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// file:// transfers a pack, so git reports its progress
+	p, _ := dependency.NewPackage("symfony/console", "file://"+upstream)
+	go d.Download([]*dependency.Package{p})
+	r := <-d.GetResultStream()
+	d.Close()
+	if r.Error != nil {
+		t.Fatalf("Expected no error. Got %s", r.Error)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.events) == 0 || rec.events[0].Type != progress.EventStarted {
+		t.Fatalf("Expected a started event first. Got %+v", rec.events)
+	}
+	for _, e := range rec.events {
+		if e.Package != "symfony/console" {
+			t.Errorf("Expected events of symfony/console. Got %+v", e)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package downloader
+
+import (
+	"bytes"
+
+	"github.com/andygrunwald/perseus/progress"
+)
+
+// hasProgress returns true if the options publish progress events.
+func (o *Options) hasProgress() bool {
+	return o != nil && o.Progress != nil
+}
+
+// publish publishes the progress event e (if the options publish progress events).
+func (o *Options) publish(e *progress.Event) {
+	if o.hasProgress() {
+		o.Progress.Publish(e)
+	}
+}
+
+// forPackage returns a copy of the options that publishes the progress events of package name.
+func (o *Options) forPackage(name string) *Options {
+	if !o.hasProgress() {
+		return o
+	}
+	c := *o
+	c.Progress = progress.ForPackage(o.Progress, name)
+	return &c
+}
+
+// progressWriter parses the progress output of git (stderr with --progress)
+// and publishes the transferred bytes (see progress.EventTransfer).
+// All other output is kept for error messages.
+type progressWriter struct {
+	opts   *Options
+	line   []byte
+	bytes  int64
+	stderr bytes.Buffer
+}
+
+// Write implements io.Writer.
+// git overwrites progress lines with a carriage return.
+func (w *progressWriter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		if c == '\r' || c == '\n' {
+			w.flush()
+			continue
+		}
+		w.line = append(w.line, c)
+	}
+	return len(p), nil
+}
+
+// flush handles the current line.
+func (w *progressWriter) flush() {
+	if len(w.line) == 0 {
+		return
+	}
+	line := string(w.line)
+	w.line = w.line[:0]
+
+	_, percent, b, ok := progress.ParseGitProgress(line)
+	if !ok {
+		w.stderr.WriteString(line + "\n")
+		return
+	}
+	if b >= 0 {
+		w.bytes = b
+	}
+	w.opts.publish(&progress.Event{Type: progress.EventTransfer, Bytes: w.bytes, Percent: percent})
+}
+
+// Stderr returns the output of git without the progress lines.
+func (w *progressWriter) Stderr() []byte {
+	w.flush()
+	return w.stderr.Bytes()
+}
@@ -0,0 +1,241 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+const (
+	// RefNewTag means the tag was created upstream
+	RefNewTag = "new-tag"
+	// RefNewBranch means the branch was created upstream
+	RefNewBranch = "new-branch"
+	// RefNewRef means a ref that is neither a tag nor a branch (like refs/pull/1/head) was created upstream
+	RefNewRef = "new-ref"
+	// RefFastForward means the ref points upstream to a descendant of the old commit
+	RefFastForward = "fast-forward"
+	// RefForcePush means the ref points upstream to a commit that is no descendant of the old commit
+	RefForcePush = "force-push"
+	// RefTagMoved means the tag points upstream to another object
+	RefTagMoved = "tag-moved"
+	// RefDeleted means the ref was deleted upstream
+	RefDeleted = "deleted"
+)
+
+const (
+	// perseusPrefix is the namespace of the refs that perseus manages itself.
+	// These refs are never fetched, pruned or deleted by an update.
+	perseusPrefix = "refs/perseus/"
+	// ArchivePrefix is the namespace of the refs that were deleted or rewritten upstream (see Options.ImmutableTags).
+	ArchivePrefix = perseusPrefix + "archived/"
+)
+
+// RefChange reflects a change of a ref of a mirror during an update.
+type RefChange struct {
+	// Ref is the name of the ref like refs/tags/v1.0.0
+	Ref string
+	// Kind is the kind of the change (see Ref* constants)
+	Kind string
+	// Old is the object the ref pointed to before the update
+	Old string
+	// New is the object the ref points to upstream. Empty if the ref was deleted.
+	New string
+	// Action is the action of the tamper policy for this change (see Tamper* constants).
+	// Empty if the policy doesn't care about this change.
+	Action string
+	// Kept is true if the ref still points to Old, because tags are immutable (see Options.ImmutableTags)
+	Kept bool
+	// Archive is the ref of the archive that saves the state which is not part of the mirror anymore (see Options.ImmutableTags):
+	// Old of a deleted or force pushed branch, New of a moved tag and Old of a tag that was deleted upstream.
+	// Empty if nothing was archived.
+	Archive string
+}
+
+// String returns a human readable representation of the change like
+// "refs/tags/v1.0.0 tag-moved (kept 0a1b2c3)".
+func (c *RefChange) String() string {
+	s := c.Ref + " " + c.Kind
+	switch {
+	case c.Kept && len(c.Archive) > 0:
+		s += fmt.Sprintf(" (kept %s, archived as %s)", shortObject(c.Old), c.Archive)
+	case c.Kept:
+		s += fmt.Sprintf(" (kept %s)", shortObject(c.Old))
+	case len(c.Archive) > 0:
+		s += fmt.Sprintf(" (archived as %s)", c.Archive)
+	}
+	return s
+}
+
+// refChangesByRef sorts ref changes by the name of the ref
+type refChangesByRef []*RefChange
+
+func (l refChangesByRef) Len() int           { return len(l) }
+func (l refChangesByRef) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l refChangesByRef) Less(i, j int) bool { return l[i].Ref < l[j].Ref }
+
+// shortObject returns the abbreviated object name o
+func shortObject(o string) string {
+	if len(o) > 7 {
+		return o[:7]
+	}
+	return o
+}
+
+// snapshotRefs returns all refs of the git repository target with the objects they point to.
+func snapshotRefs(target string) (map[string]string, error) {
+	stdOut, err := runGit(target, "for-each-ref", "--format=%(refname) %(objectname)")
+	if err != nil {
+		return nil, err
+	}
+
+	refs := map[string]string{}
+	for _, line := range strings.Split(string(stdOut), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			refs[fields[0]] = fields[1]
+		}
+	}
+	return refs, nil
+}
+
+// isAncestor returns true if the commit ancestor is an ancestor of the commit descendant in the git repository target.
+func isAncestor(target, ancestor, descendant string) (bool, error) {
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", ancestor, descendant)
+	cmd.Dir = target
+	err := cmd.Run()
+	if err == nil {
+		return true, nil
+	}
+	// Exit status 1 means "no ancestor", every other status an error
+	if ee, ok := err.(*exec.ExitError); ok {
+		if status, ok := ee.Sys().(syscall.WaitStatus); ok && status.ExitStatus() == 1 {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("Error during cmd \"%+v\": %s", cmd.Args, err)
+}
+
+// diffRefs returns and classifies the changed refs of the mirror target between the snapshots before and after.
+// Refs of the archive (see ArchivePrefix) are ignored.
+// If shallow is true, updated branches are always considered as fast-forward, because the history of a shallow mirror is incomplete.
+func diffRefs(target string, before, after map[string]string, shallow bool) []*RefChange {
+	changes := []*RefChange{}
+	for ref, old := range before {
+		if strings.HasPrefix(ref, ArchivePrefix) {
+			continue
+		}
+
+		n, ok := after[ref]
+		switch {
+		case !ok:
+			changes = append(changes, &RefChange{Ref: ref, Kind: RefDeleted, Old: old})
+		case n == old:
+		case isTag(ref):
+			changes = append(changes, &RefChange{Ref: ref, Kind: RefTagMoved, Old: old, New: n})
+		default:
+			kind := RefFastForward
+			if !shallow {
+				// If the ancestry can't be determined, we assume a regular update.
+				if ff, err := isAncestor(target, old, n); err == nil && !ff {
+					kind = RefForcePush
+				}
+			}
+			changes = append(changes, &RefChange{Ref: ref, Kind: kind, Old: old, New: n})
+		}
+	}
+
+	for ref, n := range after {
+		if _, ok := before[ref]; ok || strings.HasPrefix(ref, ArchivePrefix) {
+			continue
+		}
+		kind := RefNewRef
+		switch {
+		case isTag(ref):
+			kind = RefNewTag
+		case isBranch(ref):
+			kind = RefNewBranch
+		}
+		changes = append(changes, &RefChange{Ref: ref, Kind: kind, New: n})
+	}
+
+	sort.Sort(refChangesByRef(changes))
+	return changes
+}
+
+// restorePerseusRefs resets the refs of the mirror target that are managed by perseus (see perseusPrefix)
+// to the snapshot before. A fetch with --prune deletes them, because they don't exist upstream,
+// and fetches refs that upstream publishes in this namespace.
+func restorePerseusRefs(target string, before map[string]string) error {
+	after, err := snapshotRefs(target)
+	if err != nil {
+		return err
+	}
+
+	var tx bytes.Buffer
+	for _, ref := range sortedRefs(before) {
+		if isPerseusRef(ref) && after[ref] != before[ref] {
+			fmt.Fprintf(&tx, "update %s %s\n", ref, before[ref])
+		}
+	}
+	for _, ref := range sortedRefs(after) {
+		if _, ok := before[ref]; !ok && isPerseusRef(ref) {
+			fmt.Fprintf(&tx, "delete %s\n", ref)
+		}
+	}
+	if tx.Len() == 0 {
+		return nil
+	}
+	_, err = runGitWithInput(target, nil, tx.String(), "update-ref", "--stdin")
+	return err
+}
+
+// isPerseusRef returns true if ref is managed by perseus (like a ref of the archive)
+func isPerseusRef(ref string) bool {
+	return strings.HasPrefix(ref, perseusPrefix)
+}
+
+// sortedRefs returns the names of refs in sorted order
+func sortedRefs(refs map[string]string) []string {
+	l := make([]string, 0, len(refs))
+	for ref := range refs {
+		l = append(l, ref)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// isTag returns true if ref is a tag like refs/tags/v1.0.0
+func isTag(ref string) bool {
+	return strings.HasPrefix(ref, "refs/tags/")
+}
+
+// isBranch returns true if ref is a branch like refs/heads/master
+func isBranch(ref string) bool {
+	return strings.HasPrefix(ref, "refs/heads/")
+}
+
+// rollbackRefs resets all refs of the mirror target to the snapshot before.
+// after is the snapshot of the current state.
+func rollbackRefs(target string, before, after map[string]string) error {
+	for ref := range after {
+		if _, ok := before[ref]; ok {
+			continue
+		}
+		if _, err := runGit(target, "update-ref", "-d", ref); err != nil {
+			return err
+		}
+	}
+	for ref, old := range before {
+		if after[ref] == old {
+			continue
+		}
+		if _, err := runGit(target, "update-ref", ref, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package downloader_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/downloader"
+)
+
+func TestGit_Update_ImmutableTags(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-refs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	upstream := filepath.Join(dir, "upstream")
+	os.MkdirAll(upstream, 0755)
+	git(t, upstream, "init", "-q")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+	first := git(t, upstream, "rev-parse", "HEAD")
+	git(t, upstream, "tag", "v1.0.0")
+	git(t, upstream, "tag", "v1.0.1")
+	git(t, upstream, "branch", "feature")
+	git(t, upstream, "branch", "rewritten")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Second commit")
+	second := git(t, upstream, "rev-parse", "HEAD")
+
+	mirror := filepath.Join(dir, "git-mirror", "symfony", "console.git")
+	git(t, dir, "clone", "-q", "--mirror", upstream, mirror)
+
+	// Upstream moves and deletes tags, deletes a branch and force pushes another one
+	git(t, upstream, "tag", "-f", "v1.0.0")
+	git(t, upstream, "tag", "-d", "v1.0.1")
+	git(t, upstream, "branch", "-D", "feature")
+	git(t, upstream, "checkout", "-q", "--orphan", "orphan")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Rewritten history")
+	git(t, upstream, "branch", "-f", "rewritten", "orphan")
+	rewritten := git(t, upstream, "rev-parse", "HEAD")
+
+	u, _ := NewGitUpdater(nil, &Options{ImmutableTags: true})
+	changes, err := u.Update(mirror)
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	expected := []string{
+		"refs/heads/feature deleted (archived as refs/perseus/archived/heads/feature/" + first + ")",
+		"refs/heads/orphan new-branch",
+		"refs/heads/rewritten force-push (archived as refs/perseus/archived/heads/rewritten/" + first + ")",
+		"refs/tags/v1.0.0 tag-moved (kept " + first[:7] + ", archived as refs/perseus/archived/tags/v1.0.0/" + second + ")",
+		"refs/tags/v1.0.1 deleted (kept " + first[:7] + ", archived as refs/perseus/archived/tags/v1.0.1/deleted)",
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes. Got %v", len(expected), changes)
+	}
+	for i, c := range changes {
+		if s := c.String(); s != expected[i] {
+			t.Errorf("Expected change %q. Got %q", expected[i], s)
+		}
+	}
+
+	refs := map[string]string{
+		"refs/tags/v1.0.0":                               first,
+		"refs/tags/v1.0.1":                               first,
+		"refs/heads/rewritten":                           rewritten,
+		"refs/perseus/archived/tags/v1.0.0/" + second:    second,
+		"refs/perseus/archived/tags/v1.0.1/deleted":      first,
+		"refs/perseus/archived/heads/feature/" + first:   first,
+		"refs/perseus/archived/heads/rewritten/" + first: first,
+	}
+	for ref, object := range refs {
+		if o := git(t, mirror, "rev-parse", ref); o != object {
+			t.Errorf("Expected %s to point to %s. Got %s", ref, object, o)
+		}
+	}
+
+	// The protected refs are known already. A second update reports no changes and keeps them.
+	changes, err = u.Update(mirror)
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("Expected no changes during the second update. Got %v (%v)", changes, err)
+	}
+	for ref, object := range refs {
+		if o := git(t, mirror, "rev-parse", ref); o != object {
+			t.Errorf("Expected %s to point to %s after the second update. Got %s", ref, object, o)
+		}
+	}
+}
+
+func TestGit_Update_ImmutableTags_KeepsArchive(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-refs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	upstream := filepath.Join(dir, "upstream")
+	os.MkdirAll(upstream, 0755)
+	git(t, upstream, "init", "-q")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+	first := git(t, upstream, "rev-parse", "HEAD")
+	git(t, upstream, "tag", "v1.0.0")
+
+	mirror := filepath.Join(dir, "git-mirror", "symfony", "console.git")
+	git(t, dir, "clone", "-q", "--mirror", upstream, mirror)
+	git(t, mirror, "update-ref", "refs/perseus/archived/tags/v0.9.0/deleted", first)
+
+	// Upstream publishes refs in the namespace of the archive and moves a tag
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Second commit")
+	second := git(t, upstream, "rev-parse", "HEAD")
+	git(t, upstream, "update-ref", "refs/perseus/archived/tags/v0.9.0/deleted", second)
+	git(t, upstream, "update-ref", "refs/perseus/archived/injected", second)
+	git(t, upstream, "tag", "-f", "v1.0.0")
+
+	u, _ := NewGitUpdater(nil, &Options{ImmutableTags: true})
+	if _, err := u.Update(mirror); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	if o := git(t, mirror, "rev-parse", "refs/perseus/archived/tags/v0.9.0/deleted"); o != first {
+		t.Errorf("Expected the archive to point to %s. Got %s", first, o)
+	}
+	if o := git(t, mirror, "for-each-ref", "refs/perseus/archived/injected"); len(o) > 0 {
+		t.Errorf("Expected no upstream ref in the archive. Got %s", o)
+	}
+	if o := git(t, mirror, "rev-parse", "refs/tags/v1.0.0"); o != first {
+		t.Errorf("Expected refs/tags/v1.0.0 to point to %s. Got %s", first, o)
+	}
+}
+
+func TestGit_Update_KeepsArchiveWithoutImmutableTags(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-refs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	upstream := filepath.Join(dir, "upstream")
+	os.MkdirAll(upstream, 0755)
+	git(t, upstream, "init", "-q")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+	first := git(t, upstream, "rev-parse", "HEAD")
+	git(t, upstream, "tag", "v1.0.0")
+
+	// The mirror had immutable tags before
+	mirror := filepath.Join(dir, "git-mirror", "symfony", "console.git")
+	git(t, dir, "clone", "-q", "--mirror", upstream, mirror)
+	git(t, mirror, "update-ref", "refs/perseus/archived/tags/v0.9.0/deleted", first)
+
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Second commit")
+	second := git(t, upstream, "rev-parse", "HEAD")
+	git(t, upstream, "update-ref", "refs/perseus/archived/injected", second)
+	git(t, upstream, "tag", "-f", "v1.0.0")
+
+	u, _ := NewGitUpdater(nil, &Options{})
+	if _, err := u.Update(mirror); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	if o := git(t, mirror, "rev-parse", "refs/perseus/archived/tags/v0.9.0/deleted"); o != first {
+		t.Errorf("Expected the archive to point to %s. Got %s", first, o)
+	}
+	if o := git(t, mirror, "for-each-ref", "refs/perseus/archived/injected"); len(o) > 0 {
+		t.Errorf("Expected no upstream ref in the archive. Got %s", o)
+	}
+	if o := git(t, mirror, "rev-parse", "refs/tags/v1.0.0"); o != second {
+		t.Errorf("Expected refs/tags/v1.0.0 to point to %s. Got %s", second, o)
+	}
+}
@@ -0,0 +1,199 @@
+package downloader
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// allRefs is the refspec of a mirror without limitation (see `git clone --mirror`)
+	allRefs = "+refs/*:refs/*"
+	// refPatternsKey is the git configuration key of a mirror that contains the ref patterns of the mirror.
+	// It is informative only, the refs are fetched with explicit refspecs (see fetchRefs).
+	refPatternsKey = "perseus.refspec"
+)
+
+// CheckRefPattern returns an error if p is no valid ref pattern for Options.Refspecs.
+// A ref pattern is a full ref name like "refs/heads/master" that may contain wildcards:
+// "*" matches any sequence of characters (including "/"), "?" matches a single character
+// and "[...]" matches a character class like "[0-9]" (or "[!0-9]" for the negation).
+func CheckRefPattern(p string) error {
+	if !strings.HasPrefix(p, "refs/") {
+		return fmt.Errorf("Ref pattern %q needs to start with \"refs/\"", p)
+	}
+	if strings.ContainsAny(p, ": \t") {
+		return fmt.Errorf("Ref pattern %q must not contain a colon or whitespace", p)
+	}
+	_, err := compileRefPattern(p)
+	return err
+}
+
+// compileRefPattern returns the regular expression of the ref pattern p (see CheckRefPattern).
+func compileRefPattern(p string) (*regexp.Regexp, error) {
+	expr := "^"
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			expr += ".*"
+		case '?':
+			expr += "."
+		case '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("Ref pattern %q contains an unclosed \"[\"", p)
+			}
+			class := p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr += "[" + strings.Replace(class, `\`, `\\`, -1) + "]"
+			i += end + 1
+		default:
+			expr += regexp.QuoteMeta(string(c))
+		}
+	}
+
+	r, err := regexp.Compile(expr + "$")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid ref pattern %q: %s", p, err)
+	}
+	return r, nil
+}
+
+// refMatcher matches ref names against a list of ref patterns.
+type refMatcher []*regexp.Regexp
+
+// newRefMatcher returns a refMatcher for the ref patterns.
+func newRefMatcher(patterns []string) (refMatcher, error) {
+	m := make(refMatcher, 0, len(patterns))
+	for _, p := range patterns {
+		r, err := compileRefPattern(p)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, r)
+	}
+	return m, nil
+}
+
+// Match returns true if ref matches at least one of the ref patterns.
+func (m refMatcher) Match(ref string) bool {
+	for _, r := range m {
+		if r.MatchString(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// configureRemote configures the remote "origin" of the mirror target for the refs of opts.
+// Without ref patterns, the remote mirrors all refs (like after `git clone --mirror`).
+// With ref patterns, the remote has no fetch refspecs at all, because the refs are fetched
+// with explicit refspecs (see fetchRefs). The ref patterns are stored in the configuration of the mirror.
+func configureRemote(target string, opts *Options) error {
+	// --unset-all fails if the key doesn't exist. This is fine, because the key should not exist afterwards anyway.
+	runGit(target, "config", "--unset-all", "remote.origin.fetch")
+	runGit(target, "config", "--unset-all", refPatternsKey)
+
+	if !opts.hasRefspecs() {
+		if _, err := runGit(target, "config", "remote.origin.fetch", allRefs); err != nil {
+			return err
+		}
+		_, err := runGit(target, "config", "remote.origin.mirror", "true")
+		return err
+	}
+
+	for _, p := range opts.Refspecs {
+		if _, err := runGit(target, "config", "--add", refPatternsKey, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lsRemote returns the refs of the remote "origin" of the git repository target with the objects they point to
+// and the ref HEAD of the remote points to (empty, if HEAD is detached).
+// Peeled tags (like refs/tags/v1.0.0^{}) are not returned.
+// env is the environment of the git command.
+func lsRemote(target string, env []string) (map[string]string, string, error) {
+	stdOut, err := runGitWithEnv(target, env, "ls-remote", "--symref", "origin")
+	if err != nil {
+		return nil, "", err
+	}
+
+	refs := map[string]string{}
+	head := ""
+	for _, line := range strings.Split(string(stdOut), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 2 {
+			continue
+		}
+		if fields[1] == "HEAD" {
+			if strings.HasPrefix(fields[0], "ref: ") {
+				head = strings.TrimPrefix(fields[0], "ref: ")
+			}
+			continue
+		}
+		if strings.HasSuffix(fields[1], "^{}") {
+			continue
+		}
+		refs[fields[1]] = fields[0]
+	}
+	return refs, head, nil
+}
+
+// fetchRefs updates the mirror target with the refs of the upstream repository that match the ref patterns of opts.
+// The refs are fetched with explicit refspecs. Refs of the mirror that don't match (anymore) will be deleted.
+// Refs of the archive (see ArchivePrefix) are never touched.
+// HEAD of the mirror points to the default branch of the upstream repository, if this branch is mirrored.
+// env is the environment of the git commands that talk to the upstream repository.
+func fetchRefs(target string, env []string, opts *Options) error {
+	m, err := newRefMatcher(opts.Refspecs)
+	if err != nil {
+		return err
+	}
+	refs, head, err := lsRemote(target, env)
+	if err != nil {
+		return err
+	}
+
+	wanted := map[string]bool{}
+	refspecs := []string{}
+	for _, ref := range sortedRefs(refs) {
+		if m.Match(ref) && !isPerseusRef(ref) {
+			wanted[ref] = true
+			refspecs = append(refspecs, "+"+ref+":"+ref)
+		}
+	}
+	if len(refspecs) == 0 {
+		return fmt.Errorf("No ref of the upstream repository matches the ref patterns %s", strings.Join(opts.Refspecs, ", "))
+	}
+
+	// The refspecs are passed via stdin, because there might be thousands of them (like tags).
+	// --no-tags prevents that tags are fetched, which are not part of the ref patterns.
+	args := append([]string{"fetch", "--no-tags", "--stdin"}, opts.depthArgs()...)
+	if _, err := runGitWithProgress(target, env, strings.Join(refspecs, "\n")+"\n", opts, append(args, "origin")...); err != nil {
+		return err
+	}
+
+	local, err := ListRefs(target)
+	if err != nil {
+		return err
+	}
+	for _, ref := range local {
+		if wanted[ref] || isPerseusRef(ref) {
+			continue
+		}
+		if _, err := runGit(target, "update-ref", "-d", ref); err != nil {
+			return err
+		}
+	}
+
+	if wanted[head] {
+		if _, err := runGit(target, "symbolic-ref", "HEAD", head); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package downloader_test
+
+import (
+	"testing"
+
+	. "github.com/andygrunwald/perseus/downloader"
+)
+
+func TestCheckRefPattern(t *testing.T) {
+	tests := []struct {
+		Pattern string
+		Valid   bool
+	}{
+		{"refs/tags/*", true},
+		{"refs/heads/master", true},
+		{"refs/heads/release-*", true},
+		{"refs/heads/[0-9]*.x", true},
+		{"refs/heads/[!0-9]*", true},
+		{"refs/*/v?", true},
+		{"master", false},
+		{"+refs/heads/*:refs/heads/*", false},
+		{"refs/heads/[0-9", false},
+	}
+
+	for _, tt := range tests {
+		if err := CheckRefPattern(tt.Pattern); (err == nil) != tt.Valid {
+			t.Errorf("Expected CheckRefPattern(%q) to be valid = %v. Got %v", tt.Pattern, tt.Valid, err)
+		}
+	}
+}
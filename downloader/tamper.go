@@ -0,0 +1,91 @@
+package downloader
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// TamperAllow accepts the change silently
+	TamperAllow = "allow"
+	// TamperWarn accepts the change, but raises a warning
+	TamperWarn = "warn"
+	// TamperBlock rejects the complete update of the mirror
+	TamperBlock = "block"
+)
+
+// TamperPolicy decides what happens if a tag is moved or a protected branch is force pushed upstream.
+// Both are supply chain red flags: Somebody changed a state that others might rely on.
+type TamperPolicy struct {
+	// TagMoved is the action for moved tags (see Tamper* constants)
+	TagMoved string
+	// ForcePush is the action for force pushes on protected branches (see Tamper* constants)
+	ForcePush string
+	// ProtectedBranches are ref patterns of the protected branches like "refs/heads/master" (see CheckRefPattern)
+	ProtectedBranches []string
+}
+
+// CheckTamperAction returns an error if a is no valid action of a TamperPolicy.
+func CheckTamperAction(a string) error {
+	switch a {
+	case TamperAllow, TamperWarn, TamperBlock:
+		return nil
+	}
+	return fmt.Errorf("Unknown tamper action %q. Valid actions are %q, %q and %q", a, TamperAllow, TamperWarn, TamperBlock)
+}
+
+// apply sets the action of the policy p for every change.
+// Changes the policy doesn't care about keep an empty action.
+func (p *TamperPolicy) apply(changes []*RefChange) error {
+	if p == nil {
+		return nil
+	}
+	protected, err := newRefMatcher(p.ProtectedBranches)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		switch {
+		case c.Kind == RefTagMoved:
+			c.Action = p.TagMoved
+		case c.Kind == RefForcePush && protected.Match(c.Ref):
+			c.Action = p.ForcePush
+		}
+	}
+	return nil
+}
+
+// TamperError means the update of a mirror was blocked by the TamperPolicy.
+// The mirror stays at the state before the update.
+type TamperError struct {
+	// Changes are the blocked changes
+	Changes []*RefChange
+}
+
+// Error returns the error message of the blocked update.
+func (e *TamperError) Error() string {
+	l := make([]string, 0, len(e.Changes))
+	for _, c := range e.Changes {
+		l = append(l, fmt.Sprintf("%s %s (%s -> %s)", c.Ref, c.Kind, shortObject(c.Old), shortObject(c.New)))
+	}
+	return "Update blocked by tamper policy: " + strings.Join(l, ", ")
+}
+
+// IsTamper returns a boolean indicating whether the error is known to report
+// that the update of a mirror was blocked by the TamperPolicy.
+func IsTamper(err error) bool {
+	_, ok := err.(*TamperError)
+	return ok
+}
+
+// blockedChanges returns the changes that are blocked by the tamper policy.
+func blockedChanges(changes []*RefChange) []*RefChange {
+	blocked := []*RefChange{}
+	for _, c := range changes {
+		if c.Action == TamperBlock {
+			blocked = append(blocked, c)
+		}
+	}
+	return blocked
+}
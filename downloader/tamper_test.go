@@ -0,0 +1,103 @@
+package downloader_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/downloader"
+)
+
+func TestCheckTamperAction(t *testing.T) {
+	for _, a := range []string{TamperAllow, TamperWarn, TamperBlock} {
+		if err := CheckTamperAction(a); err != nil {
+			t.Errorf("Expected action %q to be valid. Got %s", a, err)
+		}
+	}
+	if err := CheckTamperAction("ignore"); err == nil {
+		t.Error("Expected action \"ignore\" to be invalid. Got no error")
+	}
+}
+
+func TestGit_Update_TamperPolicy(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-tamper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	upstream := filepath.Join(dir, "upstream")
+	os.MkdirAll(upstream, 0755)
+	git(t, upstream, "init", "-q")
+	git(t, upstream, "symbolic-ref", "HEAD", "refs/heads/master")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+	first := git(t, upstream, "rev-parse", "HEAD")
+	git(t, upstream, "tag", "v1.0.0")
+
+	mirror := filepath.Join(dir, "git-mirror", "symfony", "console.git")
+	git(t, dir, "clone", "-q", "--mirror", upstream, mirror)
+	git(t, mirror, "update-server-info")
+
+	// Upstream adds a tag and moves another one
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "Second commit")
+	second := git(t, upstream, "rev-parse", "HEAD")
+	git(t, upstream, "tag", "v1.1.0")
+	git(t, upstream, "tag", "-f", "v1.0.0")
+
+	policy := &TamperPolicy{TagMoved: TamperBlock, ForcePush: TamperWarn, ProtectedBranches: []string{"refs/heads/master"}}
+	u, _ := NewGitUpdater(nil, &Options{Tamper: policy})
+	changes, err := u.Update(mirror)
+	if !IsTamper(err) {
+		t.Fatalf("Expected a tamper error. Got %v", err)
+	}
+	if blocked := err.(*TamperError).Changes; len(blocked) != 1 || blocked[0].Ref != "refs/tags/v1.0.0" {
+		t.Errorf("Expected refs/tags/v1.0.0 to be blocked. Got %v", blocked)
+	}
+	if len(changes) != 3 {
+		t.Errorf("Expected 3 changes. Got %v", changes)
+	}
+
+	// The update is rolled back completely
+	if o := git(t, mirror, "rev-parse", "refs/tags/v1.0.0"); o != first {
+		t.Errorf("Expected refs/tags/v1.0.0 to point to %s. Got %s", first, o)
+	}
+	if o := git(t, mirror, "rev-parse", "refs/heads/master"); o != first {
+		t.Errorf("Expected refs/heads/master to point to %s. Got %s", first, o)
+	}
+	if refs, _ := ListRefs(mirror); len(refs) != 2 {
+		t.Errorf("Expected refs/tags/v1.1.0 to be rolled back. Got %v", refs)
+	}
+	if failed := Verify(mirror); len(failed) > 0 {
+		t.Errorf("Expected the mirror to be valid after a blocked update. Got %v", failed)
+	}
+
+	// With a warning, the update is accepted
+	policy.TagMoved = TamperWarn
+	changes, err = u.Update(mirror)
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	actions := map[string]string{}
+	for _, c := range changes {
+		actions[c.Ref] = c.Kind + " " + c.Action
+	}
+	expected := map[string]string{
+		"refs/heads/master": RefFastForward + " ",
+		"refs/tags/v1.0.0":  RefTagMoved + " " + TamperWarn,
+		"refs/tags/v1.1.0":  RefNewTag + " ",
+	}
+	for ref, a := range expected {
+		if actions[ref] != a {
+			t.Errorf("Expected %q for %s. Got %q", a, ref, actions[ref])
+		}
+	}
+	if o := git(t, mirror, "rev-parse", "refs/tags/v1.0.0"); o != second {
+		t.Errorf("Expected refs/tags/v1.0.0 to point to %s. Got %s", second, o)
+	}
+}
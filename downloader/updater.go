@@ -8,5 +8,7 @@ import (
 type Updater interface {
 	io.Closer
 
-	Update(target string) error
+	// Update updates the mirror target and returns the refs that were deleted or rewritten upstream.
+	// The caller needs to hold a transfer slot of the upstream host (see limit.Queue).
+	Update(target string) ([]*RefChange, error)
 }
@@ -0,0 +1,225 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/perseus/credentials"
+)
+
+const (
+	// CheckFsck verifies the integrity of all objects with "git fsck"
+	CheckFsck = "fsck"
+	// CheckRefs verifies that the mirror has refs and all of them point to existing commits
+	CheckRefs = "refs"
+	// CheckInfoRefs verifies that info/refs (used by the dumb HTTP protocol) exists and is up to date
+	CheckInfoRefs = "info-refs"
+	// CheckObjects verifies that all objects reachable from refs are available
+	CheckObjects = "objects"
+)
+
+// CheckError reflects a failed integrity check of a mirror.
+type CheckError struct {
+	// Check is the failed check (see Check* constants)
+	Check string
+	// Err is the reason why the check failed
+	Err error
+}
+
+// Error returns the error message of the check.
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("Check \"%s\" failed: %s", e.Check, e.Err)
+}
+
+// Verify runs all integrity checks on the mirror target and returns the failed checks.
+// If all checks pass, an empty list is returned.
+func Verify(target string) []*CheckError {
+	failed := []*CheckError{}
+
+	checks := []struct {
+		name  string
+		check func(string) error
+	}{
+		{CheckFsck, checkFsck},
+		{CheckRefs, checkRefs},
+		{CheckInfoRefs, checkInfoRefs},
+		{CheckObjects, checkObjects},
+	}
+	for _, c := range checks {
+		if err := c.check(target); err != nil {
+			failed = append(failed, &CheckError{Check: c.name, Err: err})
+		}
+	}
+
+	return failed
+}
+
+// NeedsReclone returns true if at least one of the failed checks can only be repaired by cloning the mirror again.
+// A stale info/refs can be repaired in place (see Git.updateServerInfo).
+func NeedsReclone(failed []*CheckError) bool {
+	for _, e := range failed {
+		if e.Check != CheckInfoRefs {
+			return true
+		}
+	}
+	return false
+}
+
+// RepairInfoRefs regenerates info/refs of the mirror target.
+func RepairInfoRefs(target string) error {
+	return (&Git{}).updateServerInfo(target)
+}
+
+// Reclone replaces the mirror target with a fresh clone of repository.
+// The new clone is created next to target and verified first.
+// Only if this was successful, target will be replaced. With this target is never left half cloned.
+// creds are the credentials for the upstream git host (may be nil).
+// opts are the settings of the repository (may be nil).
+//
+// With immutable tags, the tags and the archive of target are taken over into the new clone (see Options.ImmutableTags).
+// If they are not readable anymore, target is not cloned again, because this would lose them.
+func Reclone(repository, target string, creds *credentials.Store, opts *Options) error {
+	d := &Git{credentials: creds}
+	suffix := fmt.Sprintf(".%d", time.Now().UnixNano())
+	tmp := target + ".repair" + suffix
+	broken := target + ".broken" + suffix
+
+	immutable := opts.hasImmutableTags()
+	if immutable {
+		if _, err := runGit(target, "rev-list", "--objects", "--quiet", "--tags", "--glob="+perseusPrefix+"*"); err != nil {
+			return fmt.Errorf("Mirror has immutable tags, but its tags and archive are not readable anymore. A new clone would lose them. Repair the mirror manually: %s", err)
+		}
+	}
+
+	release := opts.acquireHost(repository)
+	err := d.clone(repository, tmp, opts)
+	release()
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if immutable {
+		if err := keepImmutableRefs(tmp, target); err != nil {
+			os.RemoveAll(tmp)
+			return fmt.Errorf("Mirror has immutable tags, but its tags and archive couldn't be taken over into the new clone. Repair the mirror manually: %s", err)
+		}
+	}
+	if err := d.updateServerInfo(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if opts == nil || !opts.SkipFsck {
+		if err := d.fsck(tmp); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+	}
+
+	if err := os.Rename(target, broken); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		// Bring back the broken mirror. A broken mirror is better than no mirror.
+		os.Rename(broken, target)
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	return os.RemoveAll(broken)
+}
+
+// keepImmutableRefs takes over the tags and the archive of the mirror broken into the git repository target.
+// Tags of broken overwrite the tags of the upstream repository, the archive of broken replaces the one of target.
+func keepImmutableRefs(target, broken string) error {
+	if _, err := runGit(target, "fetch", "-q", "--no-tags", "--prune", broken, "+"+perseusPrefix+"*:"+perseusPrefix+"*"); err != nil {
+		return err
+	}
+	_, err := runGit(target, "fetch", "-q", "--no-tags", broken, "+refs/tags/*:refs/tags/*")
+	return err
+}
+
+func checkFsck(target string) error {
+	return (&Git{}).fsck(target)
+}
+
+func checkRefs(target string) error {
+	stdOut, err := runGit(target, "for-each-ref", "--format=%(objectname)")
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(stdOut))) == 0 {
+		return errors.New("Mirror has no refs")
+	}
+
+	// Every ref needs to point to an existing object.
+	// cat-file prints "<object> missing" for every object that doesn't exist.
+	cmd := exec.Command("git", "cat-file", "--batch-check")
+	cmd.Dir = target
+	cmd.Stdin = bytes.NewReader(stdOut)
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("Error during cmd \"%+v\": %s", cmd.Args, err)
+	}
+	missing := 0
+	for _, l := range strings.Split(string(out), "\n") {
+		if strings.HasSuffix(l, " missing") {
+			missing++
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d refs point to missing objects", missing)
+	}
+	return nil
+}
+
+func checkInfoRefs(target string) error {
+	b, err := ioutil.ReadFile(filepath.Join(target, "info", "refs"))
+	if err != nil {
+		return err
+	}
+
+	// info/refs is written after every clone and fetch.
+	// If a ref changed afterwards, info/refs is outdated.
+	// Peeled tags (<ref>^{}) are skipped, because the tag itself is listed as well.
+	info := map[string]string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && !strings.HasSuffix(fields[1], "^{}") {
+			info[fields[1]] = fields[0]
+		}
+	}
+	refs, err := snapshotRefs(target)
+	if err != nil {
+		return err
+	}
+
+	outdated := 0
+	for ref, o := range refs {
+		if info[ref] != o {
+			outdated++
+		}
+	}
+	for ref := range info {
+		if _, ok := refs[ref]; !ok {
+			outdated++
+		}
+	}
+	if outdated > 0 {
+		return fmt.Errorf("info/refs is outdated for %d refs", outdated)
+	}
+	return nil
+}
+
+func checkObjects(target string) error {
+	// With --quiet nothing is printed, but rev-list fails if an object is missing
+	_, err := runGit(target, "rev-list", "--objects", "--all", "--quiet")
+	return err
+}
@@ -0,0 +1,71 @@
+package downloader_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/downloader"
+)
+
+func TestNeedsReclone(t *testing.T) {
+	tests := []struct {
+		Failed   []*CheckError
+		Expected bool
+	}{
+		{[]*CheckError{}, false},
+		{[]*CheckError{{Check: CheckInfoRefs, Err: errors.New("info/refs is outdated for 1 refs")}}, false},
+		{[]*CheckError{{Check: CheckFsck, Err: errors.New("missing blob")}}, true},
+		{[]*CheckError{{Check: CheckInfoRefs, Err: errors.New("stale")}, {Check: CheckObjects, Err: errors.New("missing")}}, true},
+	}
+
+	for _, tt := range tests {
+		if got := NeedsReclone(tt.Failed); got != tt.Expected {
+			t.Errorf("Expected NeedsReclone(%+v) = %v. Got %v", tt.Failed, tt.Expected, got)
+		}
+	}
+}
+
+func TestVerify_InfoRefs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mirror := filepath.Join(dir, "console.git")
+	git(t, dir, "init", "-q", "--bare", mirror)
+	tree := git(t, mirror, "mktree")
+	first := git(t, mirror, "commit-tree", "-m", "Initial commit", tree)
+	git(t, mirror, "update-ref", "refs/heads/master", first)
+	git(t, mirror, "tag", "-a", "-m", "Release", "v1.0.0", first)
+	git(t, mirror, "update-server-info")
+
+	// A failed fetch rewrites FETCH_HEAD, but no ref
+	if err := ioutil.WriteFile(filepath.Join(mirror, "FETCH_HEAD"), []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if failed := Verify(mirror); len(failed) > 0 {
+		t.Errorf("Expected no failed checks. Got %v", failed)
+	}
+
+	second := git(t, mirror, "commit-tree", "-p", first, "-m", "Second commit", tree)
+	git(t, mirror, "update-ref", "refs/heads/master", second)
+	failed := Verify(mirror)
+	if len(failed) != 1 || failed[0].Check != CheckInfoRefs {
+		t.Fatalf("Expected a stale info/refs. Got %v", failed)
+	}
+	if err := RepairInfoRefs(mirror); err != nil {
+		t.Fatal(err)
+	}
+	if failed := Verify(mirror); len(failed) > 0 {
+		t.Errorf("Expected no failed checks after the repair. Got %v", failed)
+	}
+}
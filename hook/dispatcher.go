@@ -0,0 +1,67 @@
+package hook
+
+import (
+	"sync"
+	"time"
+)
+
+// MaxConcurrent is the maximum number of hooks that run at the same time
+const MaxConcurrent = 4
+
+// ErrorFunc is called for every hook that failed for event e.
+type ErrorFunc func(h *Hook, e *Event, err error)
+
+// Dispatcher runs the matching hooks of every fired event in the background.
+// It is safe for concurrent use. A nil Dispatcher runs no hooks.
+type Dispatcher struct {
+	hooks   []*Hook
+	onError ErrorFunc
+
+	wg    sync.WaitGroup
+	slots chan struct{}
+}
+
+// NewDispatcher returns a Dispatcher for hooks.
+// onError is called for every failed hook (may be nil).
+func NewDispatcher(hooks []*Hook, onError ErrorFunc) *Dispatcher {
+	return &Dispatcher{
+		hooks:   hooks,
+		onError: onError,
+		slots:   make(chan struct{}, MaxConcurrent),
+	}
+}
+
+// Fire runs all hooks of event e in the background (see Wait).
+// If e has no time, the current time is used.
+func (d *Dispatcher) Fire(e *Event) {
+	if d == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	for _, h := range d.hooks {
+		if !h.Handles(e.Type) {
+			continue
+		}
+		d.wg.Add(1)
+		go func(h *Hook) {
+			defer d.wg.Done()
+			d.slots <- struct{}{}
+			defer func() { <-d.slots }()
+
+			if err := h.Run(e); err != nil && d.onError != nil {
+				d.onError(h, e, err)
+			}
+		}(h)
+	}
+}
+
+// Wait blocks until all hooks of the fired events finished.
+func (d *Dispatcher) Wait() {
+	if d == nil {
+		return
+	}
+	d.wg.Wait()
+}
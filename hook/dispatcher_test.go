@@ -0,0 +1,54 @@
+package hook_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/hook"
+)
+
+func TestDispatcher_Fire(t *testing.T) {
+	var mu sync.Mutex
+	failed := map[string]string{}
+	d := NewDispatcher([]*Hook{
+		New("ok", "true", "", 0, EventPackageAdded),
+		New("broken", "exit 1", "", 0, EventPackageAdded, EventPackageFailed),
+		New("finished", "exit 1", "", 0, EventRunFinished),
+	}, func(h *Hook, e *Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed[h.Name+" "+e.Package] = e.Type
+	})
+
+	for _, name := range []string{"vendor/a", "vendor/b", "vendor/c"} {
+		d.Fire(&Event{Type: EventPackageAdded, Package: name})
+	}
+	d.Wait()
+
+	if len(failed) != 3 {
+		t.Errorf("Expected the broken hook to fail for every package. Got %+v", failed)
+	}
+	for _, name := range []string{"vendor/a", "vendor/b", "vendor/c"} {
+		if failed["broken "+name] != EventPackageAdded {
+			t.Errorf("Expected a failure of the broken hook for %s. Got %+v", name, failed)
+		}
+	}
+}
+
+func TestDispatcher_Fire_Time(t *testing.T) {
+	var got *Event
+	d := NewDispatcher([]*Hook{New("broken", "exit 1", "", 0)}, func(h *Hook, e *Event, err error) {
+		got = e
+	})
+	d.Fire(&Event{Type: EventRunStarted, Command: "update"})
+	d.Wait()
+	if got == nil || time.Since(got.Time) > time.Minute {
+		t.Errorf("Expected the event with the current time. Got %+v", got)
+	}
+
+	// Must not panic
+	var none *Dispatcher
+	none.Fire(&Event{Type: EventRunStarted})
+	none.Wait()
+}
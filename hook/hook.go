@@ -0,0 +1,198 @@
+// Package hook runs custom actions (like a Satis build or a cache warmup) on events of a run.
+//
+// A Hook is a shell command and/or an HTTP callback that is limited to the event types it cares about.
+// Controllers fire events (like a package that was added) to a Dispatcher that runs all matching hooks.
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// EventRunStarted means a run (like "mirror" or "update") started. Hooks of this event run before any package is processed
+	EventRunStarted = "run-started"
+	// EventPackageAdded means a new package was mirrored (initial clone)
+	EventPackageAdded = "package-added"
+	// EventPackageUpdated means a mirror was updated
+	EventPackageUpdated = "package-updated"
+	// EventPackageFailed means a package couldn't be mirrored or updated
+	EventPackageFailed = "package-failed"
+	// EventRunFinished means a run finished. Hooks of this event run after the hooks of all packages
+	EventRunFinished = "run-finished"
+)
+
+// DefaultTimeout is the maximum duration of a hook without own timeout
+const DefaultTimeout = time.Minute
+
+// killTimeout is the maximum duration to wait for the output of a killed command
+const killTimeout = 5 * time.Second
+
+// CheckEventType returns an error if t is no valid event type.
+func CheckEventType(t string) error {
+	switch t {
+	case EventRunStarted, EventPackageAdded, EventPackageUpdated, EventPackageFailed, EventRunFinished:
+		return nil
+	}
+	return fmt.Errorf("Unknown hook event %q. Valid events are %q, %q, %q, %q and %q", t, EventRunStarted, EventPackageAdded, EventPackageUpdated, EventPackageFailed, EventRunFinished)
+}
+
+// Event reflects a single event of a run.
+type Event struct {
+	// Type is the type of the event (see Event* constants)
+	Type string `json:"event"`
+	// Command is the name of the command of the run (e.g. "update")
+	Command string `json:"command"`
+	// Package is the name of the package (e.g. "symfony/console"). Empty for events of the run.
+	Package string `json:"package,omitempty"`
+	// Path is the path of the mirror (e.g. /var/perseus/git-mirror/symfony/console.git)
+	Path string `json:"path,omitempty"`
+	// Repository is the upstream URL of the package (if known)
+	Repository string `json:"repository,omitempty"`
+	// Error is the error of a failed package
+	Error string `json:"error,omitempty"`
+	// Counts are the number of packages per status of the run report (EventRunFinished only)
+	Counts map[string]int `json:"counts,omitempty"`
+	// Time is the point in time when the event happened
+	Time time.Time `json:"time"`
+}
+
+// Env returns the environment variables of the event for shell commands like "PERSEUS_PACKAGE=symfony/console".
+func (e *Event) Env() []string {
+	return []string{
+		"PERSEUS_EVENT=" + e.Type,
+		"PERSEUS_COMMAND=" + e.Command,
+		"PERSEUS_PACKAGE=" + e.Package,
+		"PERSEUS_PATH=" + e.Path,
+		"PERSEUS_REPOSITORY=" + e.Repository,
+		"PERSEUS_ERROR=" + e.Error,
+	}
+}
+
+// Hook is a custom action for some event types.
+// The shell command runs with the environment of the event (see Event.Env) and the event as JSON on stdin.
+// The HTTP callback receives the event as JSON POST request.
+// If both are set, the command runs first.
+type Hook struct {
+	// Name identifies the hook in logs and the run report
+	Name string
+	// Command is a shell command (executed via "sh -c")
+	Command string
+	// URL is the URL of the HTTP callback
+	URL string
+	// Timeout is the maximum duration of the command and of the HTTP callback (each).
+	// If 0, DefaultTimeout applies.
+	Timeout time.Duration
+	// Events are the event types of the hook. If empty, the hook runs for all events.
+	Events []string
+}
+
+// New returns a hook with the shell command and/or the HTTP callback url for the event types events (all, if empty).
+func New(name, command, url string, timeout time.Duration, events ...string) *Hook {
+	return &Hook{
+		Name:    name,
+		Command: command,
+		URL:     url,
+		Timeout: timeout,
+		Events:  events,
+	}
+}
+
+// Handles returns true if the hook runs for events of type t.
+func (h *Hook) Handles(t string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Run runs the hook for event e.
+func (h *Hook) Run(e *Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if len(h.Command) > 0 {
+		if err := h.runCommand(e, payload); err != nil {
+			return err
+		}
+	}
+	if len(h.URL) > 0 {
+		return h.post(payload)
+	}
+	return nil
+}
+
+// timeout returns the timeout of the hook.
+func (h *Hook) timeout() time.Duration {
+	if h.Timeout > 0 {
+		return h.Timeout
+	}
+	return DefaultTimeout
+}
+
+// runCommand runs the shell command of the hook with the environment of event e and payload on stdin.
+// A command that exceeds the timeout is killed.
+func (h *Hook) runCommand(e *Event, payload []byte) error {
+	cmd := exec.Command("sh", "-c", h.Command)
+	cmd.Env = append(os.Environ(), e.Env()...)
+	cmd.Stdin = bytes.NewReader(payload)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Error while starting command %q: %s", h.Command, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	timer := time.NewTimer(h.timeout())
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("Command %q failed: %s. Output: %s", h.Command, err, strings.TrimSpace(out.String()))
+		}
+		return nil
+	case <-timer.C:
+		killProcessGroup(cmd)
+		// Children that survived the kill might still hold the output open
+		select {
+		case <-done:
+			return fmt.Errorf("Command %q timed out after %s. Output: %s", h.Command, h.timeout(), strings.TrimSpace(out.String()))
+		case <-time.After(killTimeout):
+			return fmt.Errorf("Command %q timed out after %s", h.Command, h.timeout())
+		}
+	}
+}
+
+// post sends payload as JSON POST request to the URL of the hook.
+// Every response status other than 2xx results in an error.
+func (h *Hook) post(payload []byte) error {
+	c := &http.Client{Timeout: h.timeout()}
+	resp, err := c.Post(h.URL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Callback %s returned status %s", h.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package hook_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/hook"
+)
+
+func newTestEvent() *Event {
+	return &Event{
+		Type:       EventPackageAdded,
+		Command:    "mirror",
+		Package:    "symfony/console",
+		Path:       "/var/perseus/git-mirror/symfony/console.git",
+		Repository: "https://github.com/symfony/console.git",
+		Time:       time.Now(),
+	}
+}
+
+func TestCheckEventType(t *testing.T) {
+	for _, e := range []string{EventRunStarted, EventPackageAdded, EventPackageUpdated, EventPackageFailed, EventRunFinished} {
+		if err := CheckEventType(e); err != nil {
+			t.Errorf("Expected %q to be valid. Got %s", e, err)
+		}
+	}
+	if err := CheckEventType("package-deleted"); err == nil {
+		t.Error("Expected an error for an unknown event. Got none")
+	}
+}
+
+func TestHook_Handles(t *testing.T) {
+	h := New("build", "true", "", 0, EventPackageAdded, EventPackageUpdated)
+	if !h.Handles(EventPackageUpdated) || h.Handles(EventRunFinished) {
+		t.Errorf("Expected the hook to handle only its events. Got %+v", h.Events)
+	}
+	if h := New("all", "true", "", 0); !h.Handles(EventRunFinished) {
+		t.Error("Expected a hook without events to handle all events")
+	}
+}
+
+func TestHook_Run_Command(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "perseus-hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	h := New("env", `echo "$PERSEUS_EVENT $PERSEUS_PACKAGE $PERSEUS_PATH" > `+out+` && cat >> `+out, "", 0)
+	if err := h.Run(newTestEvent()); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+
+	b, _ := ioutil.ReadFile(out)
+	lines := strings.SplitN(string(b), "\n", 2)
+	if lines[0] != "package-added symfony/console /var/perseus/git-mirror/symfony/console.git" {
+		t.Errorf("Expected the event in the environment. Got %q", lines[0])
+	}
+	got := &Event{}
+	if len(lines) < 2 || json.Unmarshal([]byte(lines[1]), got) != nil || got.Repository != "https://github.com/symfony/console.git" {
+		t.Errorf("Expected the event as JSON on stdin. Got %q", b)
+	}
+}
+
+func TestHook_Run_CommandError(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	err := New("fail", "echo broken >&2; exit 3", "", 0).Run(newTestEvent())
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Expected an error with the output of the command. Got %v", err)
+	}
+
+	start := time.Now()
+	err = New("slow", "sleep 5", "", 100*time.Millisecond).Run(newTestEvent())
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout. Got %v", err)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("Expected the command to be killed after the timeout. Took %s", d)
+	}
+}
+
+func TestHook_Run_CommandTimeoutKillsChildren(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	dir, err := ioutil.TempDir("", "perseus-hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "marker")
+
+	err = New("slow", "(sleep 1; touch "+marker+") & wait", "", 200*time.Millisecond).Run(newTestEvent())
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout. Got %v", err)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the children of the command to be killed after the timeout")
+	}
+}
+
+func TestHook_Run_URL(t *testing.T) {
+	var got *Event
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected JSON. Got %s", ct)
+		}
+		got = &Event{}
+		json.NewDecoder(r.Body).Decode(got)
+	}))
+	defer ts.Close()
+
+	if err := New("callback", "", ts.URL, 0).Run(newTestEvent()); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if got == nil || got.Type != EventPackageAdded || got.Package != "symfony/console" {
+		t.Errorf("Expected the event as JSON. Got %+v", got)
+	}
+}
+
+func TestHook_Run_URLError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	if err := New("callback", "", ts.URL, 0).Run(newTestEvent()); err == nil {
+		t.Error("Expected an error for status 502. Got none")
+	}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer slow.Close()
+	if err := New("callback", "", slow.URL, 100*time.Millisecond).Run(newTestEvent()); err == nil {
+		t.Error("Expected a timeout. Got none")
+	}
+}
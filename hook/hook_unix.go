@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package hook
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup lets cmd run in its own process group,
+// so that a timeout kills the shell together with its children.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the process group of the started command cmd.
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,14 @@
+package hook
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing, because there are no process groups on Windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the started command cmd.
+// Children of the shell are not killed on Windows.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
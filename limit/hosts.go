@@ -0,0 +1,87 @@
+package limit
+
+import (
+	"strings"
+	"sync"
+)
+
+// AnyHost is the key of the cap for all hosts without own cap
+const AnyHost = "*"
+
+// Hosts caps the number of concurrent transfers per host.
+// Hosts without cap (and without AnyHost cap) are unlimited.
+// It is safe for concurrent use. A nil Hosts is unlimited.
+type Hosts struct {
+	caps map[string]int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	used map[string]int
+}
+
+// NewHosts returns Hosts with the caps per host name like {"github.com": 2, "*": 8}.
+// Caps < 1 are unlimited.
+func NewHosts(caps map[string]int) *Hosts {
+	h := &Hosts{
+		caps: map[string]int{},
+		used: map[string]int{},
+	}
+	h.cond = sync.NewCond(&h.mu)
+	for host, n := range caps {
+		h.caps[strings.ToLower(host)] = n
+	}
+	return h
+}
+
+// Cap returns the maximum number of concurrent transfers of host (0 = unlimited).
+func (h *Hosts) Cap(host string) int {
+	if h == nil {
+		return 0
+	}
+	if n, ok := h.caps[strings.ToLower(host)]; ok {
+		return n
+	}
+	return h.caps[AnyHost]
+}
+
+// Acquire blocks until a transfer slot of host is free.
+// The returned function releases the slot and needs to be called once the transfer is finished.
+func (h *Hosts) Acquire(host string) func() {
+	if h.Cap(host) < 1 {
+		return func() {}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for !h.free(host) {
+		h.cond.Wait()
+	}
+	return h.take(host)
+}
+
+// free returns true if a transfer slot of host is free. h.mu needs to be locked.
+func (h *Hosts) free(host string) bool {
+	n := h.Cap(host)
+	return n < 1 || h.used[strings.ToLower(host)] < n
+}
+
+// take takes a transfer slot of host and returns the function that releases it.
+// The function can be called more than once, but releases the slot only once.
+// h.mu needs to be locked.
+func (h *Hosts) take(host string) func() {
+	if h.Cap(host) < 1 {
+		return func() {}
+	}
+
+	host = strings.ToLower(host)
+	h.used[host]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			h.mu.Lock()
+			h.used[host]--
+			h.cond.Broadcast()
+			h.mu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package limit_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/limit"
+)
+
+func TestHosts_Cap(t *testing.T) {
+	h := NewHosts(map[string]int{"GitHub.com": 2, AnyHost: 4})
+	tests := map[string]int{
+		"github.com":         2,
+		"gitlab.company.tld": 4,
+	}
+	for host, expected := range tests {
+		if n := h.Cap(host); n != expected {
+			t.Errorf("Expected cap %d for %s. Got %d", expected, host, n)
+		}
+	}
+
+	var unlimited *Hosts
+	if n := unlimited.Cap("github.com"); n != 0 {
+		t.Errorf("Expected nil hosts to be unlimited. Got %d", n)
+	}
+}
+
+func TestHosts_Acquire(t *testing.T) {
+	h := NewHosts(map[string]int{"github.com": 2})
+
+	var mu sync.Mutex
+	running, max := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := h.Acquire("github.com")
+			defer release()
+
+			mu.Lock()
+			running++
+			if running > max {
+				max = running
+			}
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if max != 2 {
+		t.Errorf("Expected at most 2 concurrent transfers. Got %d", max)
+	}
+
+	// Hosts without cap are not blocked
+	done := make(chan struct{})
+	go func() {
+		release := h.Acquire("gitlab.company.tld")
+		release()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected a host without cap to be unlimited")
+	}
+}
@@ -0,0 +1,54 @@
+// Package limit throttles the load perseus puts on upstream hosts:
+// the number of concurrent transfers per host (see Hosts), the bandwidth
+// of git transfers (see Proxy) and the request rate of metadata APIs like Packagist (see Rate).
+package limit
+
+import (
+	"sync"
+)
+
+// Limits are the limits of a single perseus process.
+// All downloads, updates and requests of the process share them.
+// The zero value (and nil) is unlimited.
+type Limits struct {
+	// ResolverWorkers is the number of workers that resolve dependencies via Packagist.
+	// If 0, the default number of workers is used.
+	ResolverWorkers int
+	// GitWorkers is the number of workers that clone or update mirrors.
+	// If 0, the default number of workers is used.
+	GitWorkers int
+	// Hosts caps the concurrent git transfers per host (may be nil)
+	Hosts *Hosts
+	// Bandwidth limits the bytes per second of all git transfers via HTTP(S) (may be nil)
+	Bandwidth *Rate
+	// Metadata limits the requests per second to metadata APIs like Packagist (may be nil)
+	Metadata *Rate
+
+	proxy     *Proxy
+	proxyErr  error
+	proxyOnce sync.Once
+}
+
+// Workers returns n or the configured number of workers, if configured > 0.
+func Workers(configured, n int) int {
+	if configured > 0 {
+		return configured
+	}
+	return n
+}
+
+// ProxyURL returns the URL of the proxy that throttles the git transfers to Bandwidth.
+// The proxy is started on first use and runs until the process ends.
+// Without bandwidth limit, an empty string will be returned.
+func (l *Limits) ProxyURL() (string, error) {
+	if l == nil || l.Bandwidth == nil {
+		return "", nil
+	}
+	l.proxyOnce.Do(func() {
+		l.proxy, l.proxyErr = NewProxy(l.Bandwidth)
+	})
+	if l.proxyErr != nil {
+		return "", l.proxyErr
+	}
+	return l.proxy.URL(), nil
+}
@@ -0,0 +1,209 @@
+package limit
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// dialTimeout is the timeout to connect to the upstream host of a CONNECT tunnel
+const dialTimeout = 30 * time.Second
+
+// hopHeaders are the headers that are not forwarded by the Proxy
+var hopHeaders = []string{"Connection", "Proxy-Connection", "Proxy-Authorization", "Proxy-Authenticate", "Keep-Alive", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// Proxy is a local HTTP proxy that throttles all traffic through it with a Rate.
+// It supports CONNECT tunnels (HTTPS) and plain HTTP requests.
+// Git uses it via the configuration http.proxy.
+// Requests and tunnels to the upstream host go through the proxy of the environment (HTTP_PROXY, HTTPS_PROXY and NO_PROXY).
+type Proxy struct {
+	// Upstream returns the proxy for a request to the upstream host (like http.ProxyFromEnvironment).
+	// If it returns nil, the upstream host is connected directly.
+	Upstream func(*http.Request) (*url.URL, error)
+
+	listener  net.Listener
+	rate      *Rate
+	transport http.RoundTripper
+}
+
+// NewProxy starts a proxy on a random port of the loopback interface
+// that transfers at most rate bytes per second (both directions together).
+func NewProxy(rate *Rate) (*Proxy, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{
+		Upstream: http.ProxyFromEnvironment,
+		listener: l,
+		rate:     rate,
+	}
+	p.transport = &http.Transport{Proxy: func(r *http.Request) (*url.URL, error) {
+		return p.Upstream(r)
+	}}
+	go http.Serve(l, p)
+	return p, nil
+}
+
+// URL returns the URL of the proxy like "http://127.0.0.1:4711".
+func (p *Proxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+// Close stops the proxy.
+func (p *Proxy) Close() error {
+	return p.listener.Close()
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	p.forward(w, r)
+}
+
+// tunnel connects the client with the upstream host of the CONNECT request r.
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := p.dial(r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Tunnel not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hj.Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer client.Close()
+
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		return
+	}
+
+	// The tunnel is closed once one side closes the connection
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, NewReader(buf, p.rate))
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, NewReader(upstream, p.rate))
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// dial connects to the upstream host (host:port) of a CONNECT tunnel.
+// If there is a proxy for the host (see Upstream), the connection is tunneled through it.
+func (p *Proxy) dial(host string) (net.Conn, error) {
+	proxy, err := p.Upstream(&http.Request{URL: &url.URL{Scheme: "https", Host: host}})
+	if err != nil {
+		return nil, err
+	}
+	if proxy == nil {
+		return net.DialTimeout("tcp", host, dialTimeout)
+	}
+
+	addr := proxy.Host
+	if len(proxy.Port()) == 0 {
+		addr = net.JoinHostPort(proxy.Hostname(), "80")
+	}
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: host},
+		Host:   host,
+		Header: http.Header{},
+	}
+	if proxy.User != nil {
+		pw, _ := proxy.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + pw))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("Proxy %s refused the tunnel to %s: %s", proxy.Host, host, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+
+	// The proxy may have sent data of the tunnel together with its response
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose first bytes were read into a buffer already.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read reads from the buffer first and from the connection afterwards.
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// forward sends the plain HTTP request r to the upstream host.
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	if !r.URL.IsAbs() {
+		http.Error(w, "Request URI needs to be absolute", http.StatusBadRequest)
+		return
+	}
+
+	r.RequestURI = ""
+	for _, h := range hopHeaders {
+		r.Header.Del(h)
+	}
+	if r.Body != nil {
+		r.Body = ioutil.NopCloser(NewReader(r.Body, p.rate))
+	}
+
+	resp, err := p.transport.RoundTrip(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, NewReader(resp.Body, p.rate))
+}
@@ -0,0 +1,139 @@
+package limit_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/limit"
+)
+
+func newProxyClient(t *testing.T, p *Proxy) *http.Client {
+	u, err := url.Parse(p.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(u),
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+}
+
+func TestProxy(t *testing.T) {
+	body := strings.Repeat("x", 3000)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+	servers := map[string]*httptest.Server{
+		"http":  httptest.NewServer(handler),
+		"https": httptest.NewTLSServer(handler),
+	}
+
+	for scheme, ts := range servers {
+		defer ts.Close()
+
+		p, err := NewProxy(NewRate(10000, 1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+
+		start := time.Now()
+		resp, err := newProxyClient(t, p).Get(ts.URL)
+		if err != nil {
+			t.Fatalf("%s: Expected no error. Got %s", scheme, err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(b) != body {
+			t.Errorf("%s: Expected the body of the upstream server. Got %d bytes", scheme, len(b))
+		}
+		// 1000 bytes pass at once, the other 2000 bytes (plus headers) need at least 200ms
+		if d := time.Since(start); d < 150*time.Millisecond {
+			t.Errorf("%s: Expected the transfer to be throttled. Took %s", scheme, d)
+		}
+	}
+}
+
+func TestProxy_Upstream(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("upstream"))
+	}))
+	defer ts.Close()
+
+	// The proxy of the environment counts the tunnels and passes them on
+	parent, err := NewProxy(NewRate(1<<20, 1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Close()
+	var mu sync.Mutex
+	tunnels := 0
+	env := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			mu.Lock()
+			tunnels++
+			mu.Unlock()
+		}
+		parent.ServeHTTP(w, r)
+	}))
+	defer env.Close()
+	envURL, _ := url.Parse(env.URL)
+
+	p, err := NewProxy(NewRate(1<<20, 1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.Upstream = http.ProxyURL(envURL)
+
+	resp, err := newProxyClient(t, p).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(b) != "upstream" {
+		t.Errorf("Expected the body of the upstream server. Got %q", b)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if tunnels != 1 {
+		t.Errorf("Expected the tunnel to go through the proxy of the environment. Got %d tunnels", tunnels)
+	}
+}
+
+func TestLimits_ProxyURL(t *testing.T) {
+	var l *Limits
+	if u, err := l.ProxyURL(); err != nil || len(u) != 0 {
+		t.Errorf("Expected no proxy without limits. Got %q (%v)", u, err)
+	}
+
+	l = &Limits{Bandwidth: NewRate(1<<20, 0)}
+	u, err := l.ProxyURL()
+	if err != nil || !strings.HasPrefix(u, "http://127.0.0.1:") {
+		t.Errorf("Expected a local proxy. Got %q (%v)", u, err)
+	}
+	if u2, _ := l.ProxyURL(); u2 != u {
+		t.Errorf("Expected the proxy to be started only once. Got %q and %q", u, u2)
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	if n := Workers(0, 4); n != 4 {
+		t.Errorf("Expected the default of 4 workers. Got %d", n)
+	}
+	if n := Workers(2, 4); n != 2 {
+		t.Errorf("Expected 2 configured workers. Got %d", n)
+	}
+}
@@ -0,0 +1,67 @@
+package limit
+
+// Queue passes jobs to the workers in the order they were pushed,
+// but skips the jobs of hosts whose transfer slots are all taken (see Hosts).
+// A worker receives a job together with the transfer slot of its host.
+// With this, workers never wait for a capped host while jobs of other hosts are waiting.
+// It is safe for concurrent use.
+type Queue struct {
+	hosts   *Hosts
+	pending []queued
+	closed  bool
+}
+
+// queued is a job of the Queue
+type queued struct {
+	host string
+	job  interface{}
+}
+
+// NewQueue returns an empty Queue with the transfer caps hosts (may be nil).
+func NewQueue(hosts *Hosts) *Queue {
+	if hosts == nil {
+		hosts = NewHosts(nil)
+	}
+	return &Queue{hosts: hosts}
+}
+
+// Push adds job with the transfer to host to the queue.
+func (q *Queue) Push(host string, job interface{}) {
+	q.hosts.mu.Lock()
+	q.pending = append(q.pending, queued{host: host, job: job})
+	q.hosts.cond.Broadcast()
+	q.hosts.mu.Unlock()
+}
+
+// Close marks that no further jobs will be pushed.
+// Workers receive the pending jobs anyway.
+func (q *Queue) Close() {
+	q.hosts.mu.Lock()
+	q.closed = true
+	q.hosts.cond.Broadcast()
+	q.hosts.mu.Unlock()
+}
+
+// Next blocks until a pending job of a host with a free transfer slot is available and takes the slot.
+// The returned function releases the slot and needs to be called once the transfer is finished
+// (it can be called more than once).
+// If the queue is closed and empty, false is returned.
+func (q *Queue) Next() (interface{}, func(), bool) {
+	h := q.hosts
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for {
+		for i, j := range q.pending {
+			if !h.free(j.host) {
+				continue
+			}
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return j.job, h.take(j.host), true
+		}
+		if q.closed && len(q.pending) == 0 {
+			return nil, nil, false
+		}
+		h.cond.Wait()
+	}
+}
@@ -0,0 +1,65 @@
+package limit_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/limit"
+)
+
+func TestQueue_Next(t *testing.T) {
+	q := NewQueue(NewHosts(map[string]int{"github.com": 1}))
+	q.Push("github.com", "symfony/console")
+	q.Push("github.com", "symfony/yaml")
+	q.Push("gitlab.company.tld", "company/app")
+
+	job, release, ok := q.Next()
+	if !ok || job != "symfony/console" {
+		t.Fatalf("Expected symfony/console. Got %v (%v)", job, ok)
+	}
+
+	// github.com is capped. The job of the next host is passed instead of waiting.
+	if job, _, ok := q.Next(); !ok || job != "company/app" {
+		t.Fatalf("Expected company/app. Got %v (%v)", job, ok)
+	}
+
+	next := make(chan interface{})
+	go func() {
+		job, _, _ := q.Next()
+		next <- job
+	}()
+	select {
+	case job := <-next:
+		t.Fatalf("Expected to wait for the slot of github.com. Got %v", job)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+	release()
+	select {
+	case job := <-next:
+		if job != "symfony/yaml" {
+			t.Errorf("Expected symfony/yaml. Got %v", job)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected symfony/yaml after the slot of github.com was released")
+	}
+
+	q.Close()
+	if job, _, ok := q.Next(); ok {
+		t.Errorf("Expected a closed and empty queue. Got %v", job)
+	}
+}
+
+func TestQueue_Next_Unlimited(t *testing.T) {
+	q := NewQueue(nil)
+	q.Push("github.com", 1)
+	q.Push("github.com", 2)
+	q.Close()
+
+	for _, expected := range []int{1, 2} {
+		if job, _, ok := q.Next(); !ok || job != expected {
+			t.Errorf("Expected job %d. Got %v (%v)", expected, job, ok)
+		}
+	}
+}
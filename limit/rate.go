@@ -0,0 +1,103 @@
+package limit
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Rate is a token bucket that limits events (like requests or bytes) per second.
+// Waiting callers are served in the order they arrive.
+// It is safe for concurrent use. A nil Rate is unlimited.
+type Rate struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+}
+
+// NewRate returns a Rate of perSecond events per second.
+// burst is the number of events that may happen at once. If burst < 1, one second of events is allowed at once.
+func NewRate(perSecond float64, burst int) *Rate {
+	b := float64(burst)
+	if b < 1 {
+		b = math.Max(1, perSecond)
+	}
+	return &Rate{
+		perSecond: perSecond,
+		burst:     b,
+		tokens:    b,
+		last:      time.Now(),
+	}
+}
+
+// Wait blocks until n events are allowed.
+func (r *Rate) Wait(n int) {
+	if r == nil || n <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	r.tokens = math.Min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.perSecond)
+	r.last = now
+	// The tokens are reserved right away. Later callers wait for this reservation.
+	r.tokens -= float64(n)
+	var d time.Duration
+	if r.tokens < 0 {
+		d = time.Duration(-r.tokens / r.perSecond * float64(time.Second))
+	}
+	r.mu.Unlock()
+
+	time.Sleep(d)
+}
+
+// reader is an io.Reader that is throttled by a Rate
+type reader struct {
+	r    io.Reader
+	rate *Rate
+}
+
+// NewReader returns an io.Reader that reads from r with at most rate bytes per second.
+// If rate is nil, r will be returned.
+func NewReader(r io.Reader, rate *Rate) io.Reader {
+	if rate == nil {
+		return r
+	}
+	return &reader{r: r, rate: rate}
+}
+
+// Read implements io.Reader.
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.rate.Wait(n)
+	return n, err
+}
+
+// transport is a http.RoundTripper that is throttled by a Rate
+type transport struct {
+	next http.RoundTripper
+	rate *Rate
+}
+
+// NewTransport returns a http.RoundTripper that sends at most rate requests per second.
+// Requests are executed by next. If next is nil, http.DefaultTransport will be used.
+// If rate is nil, next will be returned.
+func NewTransport(rate *Rate, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if rate == nil {
+		return next
+	}
+	return &transport{next: next, rate: rate}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.rate.Wait(1)
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,73 @@
+package limit_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/limit"
+)
+
+func TestRate_Wait(t *testing.T) {
+	r := NewRate(100, 1)
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		r.Wait(1)
+	}
+	// The first event passes at once, the other 10 events need 10ms each
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Errorf("Expected 11 events at 100 events per second to take at least 90ms. Took %s", d)
+	}
+}
+
+func TestRate_Wait_Nil(t *testing.T) {
+	var r *Rate
+	start := time.Now()
+	r.Wait(1 << 30)
+	if d := time.Since(start); d > 10*time.Millisecond {
+		t.Errorf("Expected a nil rate to be unlimited. Took %s", d)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 3000)
+	start := time.Now()
+	b, err := ioutil.ReadAll(NewReader(bytes.NewReader(data), NewRate(10000, 1000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Errorf("Expected all data. Got %d bytes", len(b))
+	}
+	// 1000 bytes pass at once, the other 2000 bytes need 200ms
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("Expected 3000 bytes at 10000 bytes per second to take at least 150ms. Took %s", d)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+
+	c := &http.Client{Transport: NewTransport(NewRate(50, 1), nil)}
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if requests != 6 {
+		t.Errorf("Expected 6 requests. Got %d", requests)
+	}
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Errorf("Expected 6 requests at 50 requests per second to take at least 90ms. Took %s", d)
+	}
+}
@@ -0,0 +1,365 @@
+// Package metrics exposes metrics about the health of the mirror
+// in the Prometheus text format (https://prometheus.io/docs/instrumenting/exposition_formats/).
+//
+// Metrics can be scraped via HTTP (see the "serve" command) or written
+// to a file for the textfile collector of the node exporter (one-shot runs).
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets in seconds.
+// They are the same as the default buckets of the official Prometheus client.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector is a single metric family (incl. all label combinations)
+// that can be written in the Prometheus text format.
+type Collector interface {
+	// Write writes the metric family in the Prometheus text format to w.
+	Write(w io.Writer) error
+}
+
+// Registry is a collection of metric families.
+// Registry is threadsafe.
+type Registry struct {
+	lock       sync.RWMutex
+	collectors []Collector
+}
+
+// NewRegistry will create a new and empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// MustRegister adds collectors to registry r.
+func (r *Registry) MustRegister(collectors ...Collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Write writes all registered metric families in the Prometheus text format to w.
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, c := range r.collectors {
+		if err := c.Write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler to expose all metrics for a Prometheus scrape.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// vec is the storage of a metric family with all its label combinations.
+type vec struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+
+	lock sync.Mutex
+	// values is a map of joined label values -> label values
+	values map[string][]string
+}
+
+func newVec(name, help, metricType string, labels []string) vec {
+	return vec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labels:     labels,
+		values:     make(map[string][]string),
+	}
+}
+
+// key returns the storage key of labelValues.
+// The caller is responsible to hold the lock.
+func (v *vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	k := strings.Join(labelValues, "\xff")
+	if _, ok := v.values[k]; !ok {
+		l := make([]string, len(labelValues))
+		copy(l, labelValues)
+		v.values[k] = l
+	}
+	return k
+}
+
+// sortedKeys returns all storage keys sorted.
+// The caller is responsible to hold the lock.
+func (v *vec) sortedKeys() []string {
+	l := make([]string, 0, len(v.values))
+	for k := range v.values {
+		l = append(l, k)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// header writes the HELP and TYPE lines of the metric family.
+func (v *vec) header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.metricType)
+	return err
+}
+
+// CounterVec is a counter with labels (like "repository").
+// A counter only goes up.
+type CounterVec struct {
+	vec
+	counts map[string]float64
+}
+
+// NewCounterVec will create a new counter name with help text help and label names labels.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		vec:    newVec(name, help, "counter", labels),
+		counts: make(map[string]float64),
+	}
+}
+
+// Inc increments the counter with labelValues by 1.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds value to the counter with labelValues. Negative values are ignored.
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.counts[c.key(labelValues)] += value
+}
+
+// Write writes the counter in the Prometheus text format to w.
+func (c *CounterVec) Write(w io.Writer) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.header(w); err != nil {
+		return err
+	}
+	for _, k := range c.sortedKeys() {
+		if err := writeSample(w, c.name, c.labels, c.values[k], c.counts[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GaugeVec is a gauge with labels (like "repository").
+// A gauge can go up and down.
+type GaugeVec struct {
+	vec
+	gauges map[string]float64
+}
+
+// NewGaugeVec will create a new gauge name with help text help and label names labels.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{
+		vec:    newVec(name, help, "gauge", labels),
+		gauges: make(map[string]float64),
+	}
+}
+
+// Set sets the gauge with labelValues to value.
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.gauges[g.key(labelValues)] = value
+}
+
+// Add adds value to the gauge with labelValues. value can be negative.
+func (g *GaugeVec) Add(value float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.gauges[g.key(labelValues)] += value
+}
+
+// Write writes the gauge in the Prometheus text format to w.
+func (g *GaugeVec) Write(w io.Writer) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if err := g.header(w); err != nil {
+		return err
+	}
+	for _, k := range g.sortedKeys() {
+		if err := writeSample(w, g.name, g.labels, g.values[k], g.gauges[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GaugeFunc is a gauge without labels whose value is determined at the time it is written.
+// This is useful for expensive values (like the size of a directory) that
+// should only be determined when somebody is interested in them.
+type GaugeFunc struct {
+	vec
+	f func() (float64, bool)
+}
+
+// NewGaugeFunc will create a new gauge name with help text help.
+// f returns the current value and if the value is available.
+// If not, the gauge won't be written.
+func NewGaugeFunc(name, help string, f func() (float64, bool)) *GaugeFunc {
+	return &GaugeFunc{
+		vec: newVec(name, help, "gauge", nil),
+		f:   f,
+	}
+}
+
+// Write writes the gauge in the Prometheus text format to w.
+func (g *GaugeFunc) Write(w io.Writer) error {
+	v, ok := g.f()
+	if !ok {
+		return nil
+	}
+	if err := g.header(w); err != nil {
+		return err
+	}
+	return writeSample(w, g.name, nil, nil, v)
+}
+
+// HistogramVec is a histogram with labels.
+// A histogram counts observations (like request durations) in configurable buckets.
+type HistogramVec struct {
+	vec
+	buckets []float64
+	// counts is a map of joined label values -> count per bucket
+	counts map[string][]uint64
+	sums   map[string]float64
+	totals map[string]uint64
+}
+
+// NewHistogramVec will create a new histogram name with help text help, the upper bounds
+// of the buckets and label names labels. If buckets is empty, DefBuckets will be used.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	b := make([]float64, len(buckets))
+	copy(b, buckets)
+	sort.Float64s(b)
+
+	return &HistogramVec{
+		vec:     newVec(name, help, "histogram", labels),
+		buckets: b,
+		counts:  make(map[string][]uint64),
+		sums:    make(map[string]float64),
+		totals:  make(map[string]uint64),
+	}
+}
+
+// Observe adds the observation value to the histogram with labelValues.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	k := h.key(labelValues)
+	counts, ok := h.counts[k]
+	if !ok {
+		counts = make([]uint64, len(h.buckets))
+		h.counts[k] = counts
+	}
+	for i, upperBound := range h.buckets {
+		if value <= upperBound {
+			counts[i]++
+		}
+	}
+	h.sums[k] += value
+	h.totals[k]++
+}
+
+// Write writes the histogram in the Prometheus text format to w.
+func (h *HistogramVec) Write(w io.Writer) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if err := h.header(w); err != nil {
+		return err
+	}
+
+	labels := append(append([]string{}, h.labels...), "le")
+	for _, k := range h.sortedKeys() {
+		values := h.values[k]
+		for i, upperBound := range h.buckets {
+			bucketValues := append(append([]string{}, values...), formatFloat(upperBound))
+			if err := writeSample(w, h.name+"_bucket", labels, bucketValues, float64(h.counts[k][i])); err != nil {
+				return err
+			}
+		}
+		bucketValues := append(append([]string{}, values...), "+Inf")
+		if err := writeSample(w, h.name+"_bucket", labels, bucketValues, float64(h.totals[k])); err != nil {
+			return err
+		}
+		if err := writeSample(w, h.name+"_sum", h.labels, values, h.sums[k]); err != nil {
+			return err
+		}
+		if err := writeSample(w, h.name+"_count", h.labels, values, float64(h.totals[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSample writes a single sample line like `name{label="value"} 1` to w.
+func writeSample(w io.Writer, name string, labels, values []string, value float64) error {
+	s := name
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels))
+		for i, l := range labels {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", l, escapeLabelValue(values[i])))
+		}
+		s += "{" + strings.Join(pairs, ",") + "}"
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", s, formatFloat(value))
+	return err
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	r := strings.NewReplacer("\\", `\\`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	r := strings.NewReplacer("\\", `\\`, "\n", `\n`, "\"", `\"`)
+	return r.Replace(s)
+}
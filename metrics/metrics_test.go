@@ -0,0 +1,164 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/metrics"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	c := NewCounterVec("test_failures_total", "Number of failures.", "repository", "error_class")
+	c.Inc("symfony/console", "network")
+	c.Inc("symfony/console", "network")
+	c.Add(3, "a/\"quoted\"", "auth")
+	c.Add(-1, "symfony/console", "network")
+
+	g := NewGaugeVec("test_queue_depth", "Queue depth.\nSecond line.")
+	g.Add(2)
+	g.Add(-1)
+
+	r := NewRegistry()
+	r.MustRegister(c, g)
+
+	b := new(bytes.Buffer)
+	if err := r.Write(b); err != nil {
+		t.Fatalf("Didn't expected an error. Got %s", err)
+	}
+
+	expected := `# HELP test_failures_total Number of failures.
+# TYPE test_failures_total counter
+test_failures_total{repository="a/\"quoted\"",error_class="auth"} 3
+test_failures_total{repository="symfony/console",error_class="network"} 2
+# HELP test_queue_depth Queue depth.\nSecond line.
+# TYPE test_queue_depth gauge
+test_queue_depth 1
+`
+	if got := b.String(); got != expected {
+		t.Errorf("Got different output than expected. Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+func TestHistogramVec_Observe(t *testing.T) {
+	h := NewHistogramVec("test_duration_seconds", "Duration.", []float64{1, 0.5})
+	h.Observe(0.2)
+	h.Observe(0.7)
+	h.Observe(3)
+
+	b := new(bytes.Buffer)
+	if err := h.Write(b); err != nil {
+		t.Fatalf("Didn't expected an error. Got %s", err)
+	}
+
+	expected := []string{
+		`test_duration_seconds_bucket{le="0.5"} 1`,
+		`test_duration_seconds_bucket{le="1"} 2`,
+		`test_duration_seconds_bucket{le="+Inf"} 3`,
+		`test_duration_seconds_sum 3.9`,
+		`test_duration_seconds_count 3`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(b.String(), e+"\n") {
+			t.Errorf("Expected output to contain %q. Got:\n%s", e, b.String())
+		}
+	}
+}
+
+func TestGaugeFunc(t *testing.T) {
+	available := false
+	g := NewGaugeFunc("test_size_bytes", "Size.", func() (float64, bool) {
+		return 1024, available
+	})
+
+	b := new(bytes.Buffer)
+	g.Write(b)
+	if b.Len() != 0 {
+		t.Errorf("Expected no output for an unavailable value. Got:\n%s", b.String())
+	}
+
+	available = true
+	g.Write(b)
+	if !strings.Contains(b.String(), "test_size_bytes 1024\n") {
+		t.Errorf("Expected the value in the output. Got:\n%s", b.String())
+	}
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	c := NewCounterVec("test_requests_total", "Requests.", "code")
+	c.Inc("200")
+	r := NewRegistry()
+	r.MustRegister(c)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the Prometheus content type. Got %s", ct)
+	}
+	if !strings.Contains(w.Body.String(), `test_requests_total{code="200"} 1`) {
+		t.Errorf("Expected the counter in the response. Got:\n%s", w.Body.String())
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "perseus-metrics")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	g := NewGaugeVec("test_last_success_timestamp_seconds", "Last success.", "repository")
+	g.Set(1500000000, "symfony/console")
+	r := NewRegistry()
+	r.MustRegister(g)
+
+	path := filepath.Join(dir, "perseus.prom")
+	if err := WriteTextfile(path, r); err != nil {
+		t.Fatalf("Didn't expected an error. Got %s", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Couldn't read textfile: %s", err)
+	}
+	if !strings.Contains(string(b), `test_last_success_timestamp_seconds{repository="symfony/console"} 1.5e+09`) {
+		t.Errorf("Expected the gauge in the textfile. Got:\n%s", b)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if n := len(files); n != 1 {
+		t.Errorf("Expected no temporary files left. Got %d files", n)
+	}
+}
+
+func TestNewPackagistTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewPackagistTransport(nil)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Didn't expected an error. Got %s", err)
+	}
+	resp.Body.Close()
+
+	b := new(bytes.Buffer)
+	PackagistRequests.Write(b)
+	if !strings.Contains(b.String(), `perseus_packagist_requests_total{code="404"} 1`) {
+		t.Errorf("Expected the request in the metrics. Got:\n%s", b.String())
+	}
+
+	b.Reset()
+	PackagistRequestDuration.Write(b)
+	if !strings.Contains(b.String(), "perseus_packagist_request_duration_seconds_count 1\n") {
+		t.Errorf("Expected the latency in the metrics. Got:\n%s", b.String())
+	}
+}
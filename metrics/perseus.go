@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// Default is the registry with all perseus metrics.
+	// It is used by the "serve" command and the textfile output.
+	Default = NewRegistry()
+
+	// RepositoryLastSuccess is the unix timestamp of the last successful fetch (clone or update) per repository.
+	// Alert on this one, if a mirror goes stale.
+	RepositoryLastSuccess = NewGaugeVec("perseus_repository_last_success_timestamp_seconds", "Unix timestamp of the last successful fetch (clone or update) of a repository.", "repository")
+	// RepositoryFetchDuration is the duration of the last fetch (clone or update) per repository.
+	RepositoryFetchDuration = NewGaugeVec("perseus_repository_fetch_duration_seconds", "Duration of the last fetch (clone or update) of a repository in seconds.", "repository")
+	// RepositoryFetchFailures is the number of failed fetches (clone or update) per repository and error class.
+	RepositoryFetchFailures = NewCounterVec("perseus_repository_fetch_failures_total", "Number of failed fetches (clone or update) of a repository by error class.", "repository", "error_class")
+
+	// PackagistRequests is the number of requests to Packagist per HTTP status code.
+	PackagistRequests = NewCounterVec("perseus_packagist_requests_total", "Number of requests to Packagist by HTTP status code.", "code")
+	// PackagistRequestDuration is the latency of requests to Packagist.
+	PackagistRequestDuration = NewHistogramVec("perseus_packagist_request_duration_seconds", "Latency of requests to Packagist in seconds.", DefBuckets)
+
+	// ResolverQueueDepth is the number of packages that are waiting to be resolved.
+	ResolverQueueDepth = NewGaugeVec("perseus_resolver_queue_depth", "Number of packages that are waiting in the queue of the dependency resolver.")
+
+	// RepoDirSize is the size of the repodir in bytes.
+	// The size is determined when the metrics are written (see SetRepoDir).
+	RepoDirSize = NewGaugeFunc("perseus_repodir_size_bytes", "Size of all mirrored repositories (repodir) in bytes.", repoDirSize)
+
+	repoDirLock sync.RWMutex
+	repoDir     string
+)
+
+func init() {
+	Default.MustRegister(
+		RepositoryLastSuccess,
+		RepositoryFetchDuration,
+		RepositoryFetchFailures,
+		PackagistRequests,
+		PackagistRequestDuration,
+		ResolverQueueDepth,
+		RepoDirSize,
+	)
+}
+
+// ObserveFetchSuccess records a successful fetch (clone or update) of repository that took d.
+func ObserveFetchSuccess(repository string, d time.Duration) {
+	RepositoryFetchDuration.Set(d.Seconds(), repository)
+	RepositoryLastSuccess.Set(float64(time.Now().Unix()), repository)
+}
+
+// ObserveFetchFailure records a failed fetch (clone or update) of repository that took d.
+// errorClass is the class of the error (like "auth" or "network").
+func ObserveFetchFailure(repository string, d time.Duration, errorClass string) {
+	RepositoryFetchDuration.Set(d.Seconds(), repository)
+	RepositoryFetchFailures.Inc(repository, errorClass)
+}
+
+// SetRepoDir sets the directory for the metric RepoDirSize.
+// As long as no directory is set, the metric won't be exposed.
+func SetRepoDir(dir string) {
+	repoDirLock.Lock()
+	defer repoDirLock.Unlock()
+
+	repoDir = dir
+}
+
+// repoDirSize returns the size of all files in the repodir.
+func repoDirSize() (float64, bool) {
+	repoDirLock.RLock()
+	dir := repoDir
+	repoDirLock.RUnlock()
+
+	if len(dir) == 0 {
+		return 0, false
+	}
+
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, false
+	}
+
+	return float64(size), true
+}
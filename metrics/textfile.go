@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteTextfile writes all metrics of registry r to the file path.
+// The file can be picked up by the textfile collector of the node exporter
+// (https://github.com/prometheus/node_exporter#textfile-collector).
+//
+// The metrics are written to a temporary file first that will be renamed afterwards.
+// With this the collector never reads a half written file.
+func WriteTextfile(path string, r *Registry) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if err := r.Write(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	// ioutil.TempFile creates files with 0600.
+	// The node exporter might run with another user.
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
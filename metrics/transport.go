@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// instrumentedTransport is a http.RoundTripper that records
+// the number of requests and the latency of every request.
+type instrumentedTransport struct {
+	next     http.RoundTripper
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+// NewPackagistTransport returns a http.RoundTripper that records every request
+// in the metrics PackagistRequests and PackagistRequestDuration.
+// Requests are executed by next. If next is nil, http.DefaultTransport will be used.
+func NewPackagistTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	t := &instrumentedTransport{
+		next:     next,
+		requests: PackagistRequests,
+		duration: PackagistRequestDuration,
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+// Requests that fail without a response (like network errors) are recorded with the code "error".
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	t.duration.Observe(time.Since(start).Seconds())
+
+	code := "error"
+	if err == nil && resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	t.requests.Inc(code)
+
+	return resp, err
+}
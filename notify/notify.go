@@ -0,0 +1,184 @@
+// Package notify pushes the outcome of a run (like failed packages or moved tags)
+// to humans via webhooks, chat or email.
+//
+// A Notification is created from the run report of a command (see New).
+// Every sink implements the Notifier interface and can be limited to
+// the event types it cares about (see Filter).
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/perseus/report"
+)
+
+const (
+	// EventFailure means a package couldn't be processed
+	EventFailure = "failure"
+	// EventAdded means a new package was mirrored (initial clone)
+	EventAdded = "added"
+	// EventTagMoved means a tag was moved upstream (see downloader.TamperPolicy)
+	EventTagMoved = "tag-moved"
+	// EventForcePush means a protected branch was force pushed upstream (see downloader.TamperPolicy)
+	EventForcePush = "force-push"
+)
+
+// CheckEventType returns an error if t is no valid event type.
+func CheckEventType(t string) error {
+	switch t {
+	case EventFailure, EventAdded, EventTagMoved, EventForcePush:
+		return nil
+	}
+	return fmt.Errorf("Unknown notification event %q. Valid events are %q, %q, %q and %q", t, EventFailure, EventAdded, EventTagMoved, EventForcePush)
+}
+
+// Event reflects a single noteworthy outcome of a package during a run.
+type Event struct {
+	// Type is the type of the event (see Event* constants)
+	Type string `json:"type"`
+	// Package is the name of the package (e.g. "symfony/console")
+	Package string `json:"package"`
+	// Message describes the event (like the error of a failure)
+	Message string `json:"message,omitempty"`
+	// Ref is the name of the changed ref of tag-moved and force-push events like refs/tags/v1.0.0
+	Ref string `json:"ref,omitempty"`
+	// Old is the object the ref pointed to before the update
+	Old string `json:"old,omitempty"`
+	// New is the object the ref points to upstream
+	New string `json:"new,omitempty"`
+	// Action is the action of the tamper policy like "warn" or "block" (see downloader.TamperPolicy)
+	Action string `json:"action,omitempty"`
+	// Time is the point in time when the event happened
+	Time time.Time `json:"time"`
+}
+
+// Notification reflects all events of a single command run.
+type Notification struct {
+	// Command is the name of the command (e.g. "update")
+	Command string `json:"command"`
+	// Started is the point in time when the run started
+	Started time.Time `json:"started"`
+	// Finished is the point in time when the run finished
+	Finished time.Time `json:"finished"`
+	// Events are the events of the run in the order they happened
+	Events []*Event `json:"events"`
+}
+
+// New returns the notification of the run report r.
+// Failed packages, mirrored packages and tampered refs become events, all other entries are ignored.
+func New(r *report.Report) *Notification {
+	n := &Notification{
+		Command:  r.Command,
+		Started:  r.Started,
+		Finished: r.Finished,
+		Events:   []*Event{},
+	}
+
+	for _, e := range r.Entries() {
+		t := ""
+		switch {
+		case e.Status == report.StatusFailed:
+			t = EventFailure
+		case e.Status == report.StatusMirrored:
+			t = EventAdded
+		case e.Status == report.StatusTampered && e.Change == EventTagMoved:
+			t = EventTagMoved
+		case e.Status == report.StatusTampered && e.Change == EventForcePush:
+			t = EventForcePush
+		default:
+			continue
+		}
+		event := &Event{
+			Type:    t,
+			Package: e.Package,
+			Message: e.Reason,
+			Time:    e.Time,
+		}
+		if e.Ref != nil {
+			event.Ref = e.Ref.Name
+			event.Old = e.Ref.Old
+			event.New = e.Ref.New
+			event.Action = e.Ref.Action
+		}
+		n.Events = append(n.Events, event)
+	}
+	return n
+}
+
+// Subject returns a short summary of the notification like "perseus update: 2 failure, 1 tag-moved".
+func (n *Notification) Subject() string {
+	types := []string{}
+	counts := map[string]int{}
+	for _, e := range n.Events {
+		if counts[e.Type] == 0 {
+			types = append(types, e.Type)
+		}
+		counts[e.Type]++
+	}
+
+	l := make([]string, 0, len(types))
+	for _, t := range types {
+		l = append(l, fmt.Sprintf("%d %s", counts[t], t))
+	}
+	return fmt.Sprintf("perseus %s: %s", n.Command, strings.Join(l, ", "))
+}
+
+// Text returns the notification as plain text for humans.
+// The first line is the subject, every event is a single line.
+func (n *Notification) Text() string {
+	lines := []string{n.Subject(), ""}
+	for _, e := range n.Events {
+		line := fmt.Sprintf("* [%s] %s", e.Type, e.Package)
+		if len(e.Message) > 0 {
+			line += ": " + e.Message
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Notifier sends notifications to humans.
+type Notifier interface {
+	// Notify sends the notification n.
+	Notify(n *Notification) error
+}
+
+// filter is a Notifier that passes only events of some types to another Notifier.
+type filter struct {
+	notifier Notifier
+	types    map[string]bool
+}
+
+// Filter returns a Notifier that passes only the events of types to notifier.
+// Notifications without matching events are not sent at all.
+// Without types, notifier will be returned.
+func Filter(notifier Notifier, types ...string) Notifier {
+	if len(types) == 0 {
+		return notifier
+	}
+	f := &filter{
+		notifier: notifier,
+		types:    map[string]bool{},
+	}
+	for _, t := range types {
+		f.types[t] = true
+	}
+	return f
+}
+
+// Notify sends the matching events of n.
+func (f *filter) Notify(n *Notification) error {
+	filtered := *n
+	filtered.Events = []*Event{}
+	for _, e := range n.Events {
+		if f.types[e.Type] {
+			filtered.Events = append(filtered.Events, e)
+		}
+	}
+	if len(filtered.Events) == 0 {
+		return nil
+	}
+	return f.notifier.Notify(&filtered)
+}
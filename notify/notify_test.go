@@ -0,0 +1,108 @@
+package notify_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/notify"
+	"github.com/andygrunwald/perseus/report"
+)
+
+// recorder is a Notifier that records all notifications
+type recorder struct {
+	notifications []*Notification
+}
+
+func (r *recorder) Notify(n *Notification) error {
+	r.notifications = append(r.notifications, n)
+	return nil
+}
+
+func newTestReport() *report.Report {
+	r := report.New("update")
+	r.Failed("symfony/console", errors.New("Repository not found"))
+	r.Add("twig/twig", report.StatusMirrored, "")
+	r.Updated("monolog/monolog", []string{"refs/tags/1.0.0 tag-moved"})
+	r.RefChanged("monolog/monolog", report.StatusTampered, "tag-moved", "refs/tags/1.0.0 tag-moved", &report.Ref{Name: "refs/tags/1.0.0", Old: "0a1b2c3", New: "4d5e6f7", Action: "warn"})
+	r.RefChanged("monolog/monolog", report.StatusProtected, "tag-moved", "refs/tags/1.0.0 tag-moved (kept 0a1b2c3)", &report.Ref{Name: "refs/tags/1.0.0", Old: "0a1b2c3", New: "4d5e6f7"})
+	r.RefChanged("psr/log", report.StatusTampered, "force-push", "refs/heads/master force-push", &report.Ref{Name: "refs/heads/master", Old: "8a9b0c1", New: "2d3e4f5", Action: "block"})
+	r.Finish()
+	return r
+}
+
+func TestNew(t *testing.T) {
+	n := New(newTestReport())
+	if n.Command != "update" || n.Finished.IsZero() {
+		t.Errorf("Expected the command and the times of the run. Got %+v", n)
+	}
+
+	expected := []string{
+		"failure symfony/console",
+		"added twig/twig",
+		"tag-moved monolog/monolog",
+		"force-push psr/log",
+	}
+	if len(n.Events) != len(expected) {
+		t.Fatalf("Expected %d events. Got %d", len(expected), len(n.Events))
+	}
+	for i, e := range n.Events {
+		if s := e.Type + " " + e.Package; s != expected[i] {
+			t.Errorf("Expected event %q. Got %q", expected[i], s)
+		}
+	}
+	if m := n.Events[0].Message; m != "Repository not found" {
+		t.Errorf("Expected the error as message. Got %q", m)
+	}
+	if e := n.Events[3]; e.Ref != "refs/heads/master" || e.Old != "8a9b0c1" || e.New != "2d3e4f5" || e.Action != "block" {
+		t.Errorf("Expected the changed ref in the event. Got %+v", e)
+	}
+}
+
+func TestNotification_Text(t *testing.T) {
+	n := New(newTestReport())
+	if s := n.Subject(); s != "perseus update: 1 failure, 1 added, 1 tag-moved, 1 force-push" {
+		t.Errorf("Got unexpected subject %q", s)
+	}
+	if text := n.Text(); !strings.Contains(text, "* [failure] symfony/console: Repository not found\n") {
+		t.Errorf("Expected the failure in the text. Got %q", text)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	n := New(newTestReport())
+
+	r := &recorder{}
+	if err := Filter(r, EventTagMoved, EventForcePush).Notify(n); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.notifications) != 1 || len(r.notifications[0].Events) != 2 {
+		t.Fatalf("Expected a single notification with two events. Got %+v", r.notifications)
+	}
+	if len(n.Events) != 4 {
+		t.Errorf("Expected the original notification to be unchanged. Got %d events", len(n.Events))
+	}
+
+	// Without matching events, nothing is sent
+	r = &recorder{}
+	Filter(r, EventAdded).Notify(&Notification{Command: "gc"})
+	if len(r.notifications) != 0 {
+		t.Errorf("Expected no notification. Got %+v", r.notifications)
+	}
+
+	// Without types, all events are sent
+	r = &recorder{}
+	Filter(r).Notify(n)
+	if len(r.notifications) != 1 || len(r.notifications[0].Events) != 4 {
+		t.Errorf("Expected all events. Got %+v", r.notifications)
+	}
+}
+
+func TestCheckEventType(t *testing.T) {
+	if err := CheckEventType(EventFailure); err != nil {
+		t.Errorf("Expected %q to be valid. Got %s", EventFailure, err)
+	}
+	if err := CheckEventType("success"); err == nil {
+		t.Error("Expected \"success\" to be invalid. Got no error")
+	}
+}
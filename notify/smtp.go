@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTP is a Notifier that sends the notification as plain text email.
+type SMTP struct {
+	addr string
+	from string
+	to   []string
+	auth smtp.Auth
+}
+
+// NewSMTP returns a Notifier that sends emails via the SMTP server addr (like "smtp.company.tld:587")
+// from the address from to all addresses of to.
+// auth is optional. STARTTLS is used, if the server supports it.
+func NewSMTP(addr, from string, to []string, auth smtp.Auth) *SMTP {
+	return &SMTP{
+		addr: addr,
+		from: from,
+		to:   to,
+		auth: auth,
+	}
+}
+
+// Notify sends n as email.
+func (s *SMTP) Notify(n *Notification) error {
+	if len(s.to) == 0 {
+		return fmt.Errorf("No recipients for the notification via %s", s.addr)
+	}
+	return smtp.SendMail(s.addr, s.auth, s.from, s.to, s.message(n))
+}
+
+// message returns the email of notification n incl. all headers.
+func (s *SMTP) message(n *Notification) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", n.Subject())
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(n.Text(), "\n", "\r\n", -1))
+	return b.Bytes()
+}
@@ -0,0 +1,91 @@
+package notify_test
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/notify"
+)
+
+// smtpServer is a minimal SMTP server that accepts a single email
+type smtpServer struct {
+	listener   net.Listener
+	recipients []string
+	data       string
+	done       chan struct{}
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{listener: l, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *smtpServer) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	c := textproto.NewConn(conn)
+	c.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			c.PrintfLine("250 localhost")
+		case "RCPT":
+			s.recipients = append(s.recipients, line)
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 Go ahead")
+			b, _ := c.ReadDotBytes()
+			s.data = string(b)
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("250 OK")
+		}
+	}
+}
+
+func TestSMTP_Notify(t *testing.T) {
+	s := newSMTPServer(t)
+	defer s.listener.Close()
+
+	to := []string{"platform@company.tld", "security@company.tld"}
+	n := NewSMTP(s.listener.Addr().String(), "perseus@company.tld", to, nil)
+	if err := n.Notify(New(newTestReport())); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	<-s.done
+
+	if len(s.recipients) != 2 {
+		t.Errorf("Expected two recipients. Got %v", s.recipients)
+	}
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(s.data))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject := msg.Get("Subject"); !strings.HasPrefix(subject, "perseus update: ") {
+		t.Errorf("Expected the subject of the notification. Got %q", subject)
+	}
+	if !strings.Contains(s.data, "* [failure] symfony/console: Repository not found") {
+		t.Errorf("Expected the failure in the body. Got %q", s.data)
+	}
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout is the maximum duration of a single HTTP request of the webhook sinks
+const DefaultTimeout = 10 * time.Second
+
+// Webhook is a Notifier that sends the notification as JSON POST request to a generic webhook.
+type Webhook struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhook returns a Notifier for the webhook url.
+// If httpClient is nil, a client with DefaultTimeout will be used.
+func NewWebhook(url string, httpClient *http.Client) *Webhook {
+	return &Webhook{
+		url:        url,
+		httpClient: newHTTPClient(httpClient),
+	}
+}
+
+// Notify sends n as JSON to the webhook.
+func (w *Webhook) Notify(n *Notification) error {
+	return postJSON(w.httpClient, w.url, n)
+}
+
+// Slack is a Notifier for incoming webhooks of Slack or Mattermost.
+// The notification is sent as plain text message.
+type Slack struct {
+	url        string
+	httpClient *http.Client
+}
+
+// slackMessage is the payload of an incoming webhook of Slack or Mattermost
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// NewSlack returns a Notifier for the incoming webhook url of Slack or Mattermost.
+// If httpClient is nil, a client with DefaultTimeout will be used.
+func NewSlack(url string, httpClient *http.Client) *Slack {
+	return &Slack{
+		url:        url,
+		httpClient: newHTTPClient(httpClient),
+	}
+}
+
+// Notify sends n as message to the incoming webhook.
+func (s *Slack) Notify(n *Notification) error {
+	return postJSON(s.httpClient, s.url, &slackMessage{Text: n.Text()})
+}
+
+// newHTTPClient returns c or a client with DefaultTimeout if c is nil.
+func newHTTPClient(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return &http.Client{Timeout: DefaultTimeout}
+}
+
+// postJSON sends v as JSON POST request to url.
+// Every response status other than 2xx results in an error.
+func postJSON(c *http.Client, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook %s returned status %s", url, resp.Status)
+	}
+	return nil
+}
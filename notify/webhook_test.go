@@ -0,0 +1,57 @@
+package notify_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/notify"
+)
+
+func TestWebhook_Notify(t *testing.T) {
+	var got *Notification
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected JSON. Got %s", ct)
+		}
+		got = &Notification{}
+		json.NewDecoder(r.Body).Decode(got)
+	}))
+	defer ts.Close()
+
+	n := New(newTestReport())
+	if err := NewWebhook(ts.URL, nil).Notify(n); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if got == nil || got.Command != "update" || len(got.Events) != len(n.Events) {
+		t.Errorf("Expected the notification as JSON. Got %+v", got)
+	}
+}
+
+func TestWebhook_Notify_Error(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	if err := NewWebhook(ts.URL, nil).Notify(New(newTestReport())); err == nil {
+		t.Error("Expected an error for status 500. Got none")
+	}
+}
+
+func TestSlack_Notify(t *testing.T) {
+	got := map[string]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer ts.Close()
+
+	if err := NewSlack(ts.URL, nil).Notify(New(newTestReport())); err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if !strings.HasPrefix(got["text"], "perseus update: ") {
+		t.Errorf("Expected the notification as text. Got %+v", got)
+	}
+}
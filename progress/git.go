@@ -0,0 +1,38 @@
+package progress
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// gitProgress matches the progress lines of `git clone --progress` and `git fetch --progress` like
+// "Receiving objects:  45% (450/1000), 1.20 MiB | 500.00 KiB/s"
+var gitProgress = regexp.MustCompile(`^(?:remote: )?([A-Za-z ]+):\s+(\d+)% \(\d+/\d+\)(?:, ([\d.]+) (bytes|KiB|MiB|GiB))?`)
+
+// byteFactors are the factors of the units of git progress lines
+var byteFactors = map[string]float64{
+	"bytes": 1,
+	"KiB":   1 << 10,
+	"MiB":   1 << 20,
+	"GiB":   1 << 30,
+}
+
+// ParseGitProgress parses a single progress line of git (written to stderr with --progress).
+// It returns the phase (like "Receiving objects"), the percent of the phase and the received bytes
+// (only known during "Receiving objects", otherwise -1).
+// ok is false if line is no progress line.
+func ParseGitProgress(line string) (phase string, percent int, bytes int64, ok bool) {
+	m := gitProgress.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return "", 0, 0, false
+	}
+
+	percent, _ = strconv.Atoi(m[2])
+	bytes = -1
+	if len(m[3]) > 0 {
+		f, _ := strconv.ParseFloat(m[3], 64)
+		bytes = int64(f * byteFactors[m[4]])
+	}
+	return m[1], percent, bytes, true
+}
@@ -0,0 +1,30 @@
+package progress_test
+
+import (
+	"testing"
+
+	. "github.com/andygrunwald/perseus/progress"
+)
+
+func TestParseGitProgress(t *testing.T) {
+	tests := []struct {
+		line    string
+		phase   string
+		percent int
+		bytes   int64
+		ok      bool
+	}{
+		{"Receiving objects:  45% (450/1000), 1.50 MiB | 500.00 KiB/s", "Receiving objects", 45, 1572864, true},
+		{"Receiving objects: 100% (1000/1000), 512 bytes | 0 bytes/s, done.", "Receiving objects", 100, 512, true},
+		{"remote: Counting objects:  12% (12/100)", "Counting objects", 12, -1, true},
+		{"Resolving deltas:  80% (80/100)", "Resolving deltas", 80, -1, true},
+		{"Cloning into bare repository 'console.git'...", "", 0, 0, false},
+		{"fatal: repository not found", "", 0, 0, false},
+	}
+	for _, tt := range tests {
+		phase, percent, bytes, ok := ParseGitProgress(tt.line)
+		if phase != tt.phase || percent != tt.percent || bytes != tt.bytes || ok != tt.ok {
+			t.Errorf("ParseGitProgress(%q) = %q, %d, %d, %t. Expected %q, %d, %d, %t", tt.line, phase, percent, bytes, ok, tt.phase, tt.percent, tt.bytes, tt.ok)
+		}
+	}
+}
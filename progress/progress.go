@@ -0,0 +1,145 @@
+// Package progress streams progress events of long running commands (like "mirror" or "update")
+// to everybody who is interested (like an interactive progress view).
+//
+// Controllers and the git downloader publish events to a Bus.
+// A Tracker aggregates the events to counters, active transfers and an ETA.
+// A View renders the Tracker to a terminal or as periodic log lines.
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// EventResolved means the dependencies of a package were resolved
+	EventResolved = "resolved"
+	// EventQueued means a package was queued for a clone or update
+	EventQueued = "queued"
+	// EventStarted means the git transfer of a package started
+	EventStarted = "started"
+	// EventTransfer reports the progress of the git transfer of a package (see Event.Bytes and Event.Percent)
+	EventTransfer = "transfer"
+	// EventDone means a package was cloned or updated successfully
+	EventDone = "done"
+	// EventFailed means a package couldn't be cloned or updated
+	EventFailed = "failed"
+	// EventSkipped means a queued package was skipped (e.g. because it exists on disk)
+	EventSkipped = "skipped"
+)
+
+// Event reflects a single progress event of a package.
+type Event struct {
+	// Type is the type of the event (see Event* constants)
+	Type string
+	// Package is the name of the package (e.g. "symfony/console")
+	Package string
+	// Bytes are the bytes received so far (EventTransfer only)
+	Bytes int64
+	// Percent is the progress of the current phase of the transfer (EventTransfer only)
+	Percent int
+	// Time is the point in time when the event was published
+	Time time.Time
+}
+
+// Publisher publishes progress events.
+type Publisher interface {
+	// Publish publishes the event e.
+	Publish(e *Event)
+}
+
+// Bus delivers every published event to all subscribers.
+// Publish blocks until every subscriber received the event, so no counter gets lost.
+// It is safe for concurrent use. A nil Bus discards all events.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []chan *Event
+	closed      bool
+}
+
+// NewBus returns a new event bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe returns a channel that receives all events published afterwards.
+// The channel is closed once the bus is closed.
+func (b *Bus) Subscribe() <-chan *Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan *Event, 64)
+	if b.closed {
+		close(c)
+		return c
+	}
+	b.subscribers = append(b.subscribers, c)
+	return c
+}
+
+// Publish delivers the event e to all subscribers.
+// Events published after Close are discarded.
+func (b *Bus) Publish(e *Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for _, c := range b.subscribers {
+		c <- e
+	}
+}
+
+// Close closes the channels of all subscribers.
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, c := range b.subscribers {
+		close(c)
+	}
+}
+
+// packagePublisher is a Publisher that sets the package of every event
+type packagePublisher struct {
+	next Publisher
+	name string
+}
+
+// ForPackage returns a Publisher that publishes all events of package name to p.
+// If p is nil, nil will be returned.
+func ForPackage(p Publisher, name string) Publisher {
+	if p == nil {
+		return nil
+	}
+	return &packagePublisher{next: p, name: name}
+}
+
+// Publish publishes e for the package of the Publisher.
+func (p *packagePublisher) Publish(e *Event) {
+	e.Package = p.name
+	p.next.Publish(e)
+}
+
+// Publish publishes an event of type t for package name to p.
+// If p is nil, nothing happens.
+func Publish(p Publisher, t, name string) {
+	if p == nil {
+		return
+	}
+	p.Publish(&Event{Type: t, Package: name})
+}
@@ -0,0 +1,76 @@
+package progress_test
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/progress"
+)
+
+func TestBus_Publish(t *testing.T) {
+	b := NewBus()
+	a := b.Subscribe()
+	c := b.Subscribe()
+
+	Publish(ForPackage(b, "symfony/console"), EventStarted, "")
+	b.Close()
+	// Events after Close are discarded
+	Publish(b, EventDone, "symfony/console")
+
+	for _, events := range []<-chan *Event{a, c} {
+		got := []*Event{}
+		for e := range events {
+			got = append(got, e)
+		}
+		if len(got) != 1 {
+			t.Fatalf("Expected one event. Got %d", len(got))
+		}
+		if got[0].Type != EventStarted || got[0].Package != "symfony/console" || got[0].Time.IsZero() {
+			t.Errorf("Expected a started event of symfony/console with time. Got %+v", got[0])
+		}
+	}
+
+	if _, ok := <-b.Subscribe(); ok {
+		t.Error("Expected a closed channel for a subscription after Close")
+	}
+}
+
+func TestBus_Publish_Concurrent(t *testing.T) {
+	b := NewBus()
+	events := b.Subscribe()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				Publish(b, EventQueued, "vendor/package")
+			}
+		}()
+	}
+
+	// More events than the subscription buffer: Publish blocks until they are received
+	go func() {
+		wg.Wait()
+		b.Close()
+	}()
+	n := 0
+	for range events {
+		n++
+	}
+	if n != 1000 {
+		t.Errorf("Expected 1000 events. Got %d", n)
+	}
+}
+
+func TestPublish_Nil(t *testing.T) {
+	// Must not panic
+	var b *Bus
+	b.Publish(&Event{Type: EventDone})
+	b.Close()
+	Publish(nil, EventDone, "symfony/console")
+	if p := ForPackage(nil, "symfony/console"); p != nil {
+		t.Errorf("Expected nil publisher. Got %+v", p)
+	}
+}
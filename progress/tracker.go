@@ -0,0 +1,135 @@
+package progress
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Transfer reflects a running git transfer of a package.
+type Transfer struct {
+	// Package is the name of the package
+	Package string
+	// Bytes are the bytes received so far
+	Bytes int64
+	// Percent is the progress of the current phase of the transfer
+	Percent int
+	// Started is the point in time when the transfer started
+	Started time.Time
+}
+
+// Snapshot reflects the progress of a run at a single point in time.
+type Snapshot struct {
+	Resolved int
+	Queued   int
+	Done     int
+	Failed   int
+	Skipped  int
+	// Bytes are the bytes received by all transfers
+	Bytes int64
+	// Active are the running transfers sorted by package name
+	Active []*Transfer
+	// Elapsed is the time since the first event
+	Elapsed time.Duration
+	// ETA is the estimated time until all queued packages are processed.
+	// 0 if unknown (no package processed yet or nothing left).
+	ETA time.Duration
+}
+
+// Finished returns the number of queued packages that were processed.
+func (s *Snapshot) Finished() int {
+	return s.Done + s.Failed + s.Skipped
+}
+
+// Tracker aggregates progress events.
+// It is safe for concurrent use.
+type Tracker struct {
+	mu       sync.Mutex
+	snapshot Snapshot
+	active   map[string]*Transfer
+	// finished are the bytes of all finished transfers
+	finished int64
+	started  time.Time
+}
+
+// NewTracker returns a Tracker without events.
+func NewTracker() *Tracker {
+	return &Tracker{
+		active: map[string]*Transfer{},
+	}
+}
+
+// Apply adds the event e to the progress.
+func (t *Tracker) Apply(e *Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.started.IsZero() {
+		t.started = e.Time
+	}
+
+	s := &t.snapshot
+	switch e.Type {
+	case EventResolved:
+		s.Resolved++
+	case EventQueued:
+		s.Queued++
+	case EventStarted:
+		t.active[e.Package] = &Transfer{Package: e.Package, Started: e.Time}
+	case EventTransfer:
+		tr, ok := t.active[e.Package]
+		if !ok {
+			tr = &Transfer{Package: e.Package, Started: e.Time}
+			t.active[e.Package] = tr
+		}
+		if e.Bytes > tr.Bytes {
+			tr.Bytes = e.Bytes
+		}
+		tr.Percent = e.Percent
+	case EventDone, EventFailed, EventSkipped:
+		if tr, ok := t.active[e.Package]; ok {
+			t.finished += tr.Bytes
+			delete(t.active, e.Package)
+		}
+		switch e.Type {
+		case EventDone:
+			s.Done++
+		case EventFailed:
+			s.Failed++
+		default:
+			s.Skipped++
+		}
+	}
+}
+
+// Snapshot returns the current progress.
+// The ETA is extrapolated from the average time per processed package.
+func (t *Tracker) Snapshot() *Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.snapshot
+	s.Bytes = t.finished
+	s.Active = make([]*Transfer, 0, len(t.active))
+	for _, tr := range t.active {
+		c := *tr
+		s.Active = append(s.Active, &c)
+		s.Bytes += tr.Bytes
+	}
+	sort.Sort(transfersByPackage(s.Active))
+
+	if !t.started.IsZero() {
+		s.Elapsed = time.Since(t.started)
+	}
+	if finished, left := s.Finished(), s.Queued-s.Finished(); finished > 0 && left > 0 {
+		s.ETA = time.Duration(int64(s.Elapsed) / int64(finished) * int64(left))
+	}
+	return &s
+}
+
+// transfersByPackage sorts transfers by package name
+type transfersByPackage []*Transfer
+
+func (l transfersByPackage) Len() int           { return len(l) }
+func (l transfersByPackage) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l transfersByPackage) Less(i, j int) bool { return l[i].Package < l[j].Package }
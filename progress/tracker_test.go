@@ -0,0 +1,49 @@
+package progress_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/progress"
+)
+
+func TestTracker_Snapshot(t *testing.T) {
+	tr := NewTracker()
+	start := time.Now().Add(-10 * time.Second)
+	events := []*Event{
+		{Type: EventResolved, Package: "vendor/a", Time: start},
+		{Type: EventQueued, Package: "vendor/a", Time: start},
+		{Type: EventQueued, Package: "vendor/b", Time: start},
+		{Type: EventQueued, Package: "vendor/c", Time: start},
+		{Type: EventStarted, Package: "vendor/a", Time: start},
+		{Type: EventTransfer, Package: "vendor/a", Bytes: 1024, Percent: 50, Time: start},
+		{Type: EventDone, Package: "vendor/a", Time: start},
+		{Type: EventStarted, Package: "vendor/b", Time: start},
+		{Type: EventTransfer, Package: "vendor/b", Bytes: 512, Percent: 10, Time: start},
+	}
+	for _, e := range events {
+		tr.Apply(e)
+	}
+
+	s := tr.Snapshot()
+	if s.Resolved != 1 || s.Queued != 3 || s.Done != 1 || s.Failed != 0 || s.Skipped != 0 {
+		t.Errorf("Unexpected counters: %s", Summary(s))
+	}
+	if s.Bytes != 1536 {
+		t.Errorf("Expected 1536 bytes of finished and active transfers. Got %d", s.Bytes)
+	}
+	if len(s.Active) != 1 || s.Active[0].Package != "vendor/b" || s.Active[0].Percent != 10 {
+		t.Errorf("Expected vendor/b as only active transfer. Got %+v", s.Active)
+	}
+	// One of three packages took ~10s, so two packages left take ~20s
+	if s.ETA < 19*time.Second || s.ETA > 25*time.Second {
+		t.Errorf("Expected an ETA of ~20s. Got %s", s.ETA)
+	}
+
+	tr.Apply(&Event{Type: EventFailed, Package: "vendor/b", Time: start})
+	tr.Apply(&Event{Type: EventSkipped, Package: "vendor/c", Time: start})
+	s = tr.Snapshot()
+	if s.Finished() != 3 || len(s.Active) != 0 || s.ETA != 0 {
+		t.Errorf("Expected all packages finished without ETA. Got %s", Summary(s))
+	}
+}
@@ -0,0 +1,221 @@
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// TTYInterval is the refresh interval of the interactive view
+	TTYInterval = 200 * time.Millisecond
+	// LogInterval is the interval of the summary log lines
+	LogInterval = 30 * time.Second
+	// maxActive is the maximum number of active transfers of the interactive view
+	maxActive = 10
+)
+
+// View renders the progress of a run.
+// On a terminal, an interactive view is redrawn in place. Otherwise a summary is logged periodically.
+// Log lines need to be written via LogWriter while the interactive view is rendered.
+type View struct {
+	tracker  *Tracker
+	out      io.Writer
+	log      logrus.FieldLogger
+	tty      bool
+	interval time.Duration
+
+	mu sync.Mutex
+	// last are the lines of the last interactive rendering
+	last []string
+	// lines is the number of lines of the interactive view on the terminal
+	lines int
+}
+
+// NewView returns a View of the progress of tracker.
+// If tty is true, the interactive view is written to out every TTYInterval.
+// Otherwise a summary is logged to l every LogInterval.
+func NewView(tracker *Tracker, out io.Writer, l logrus.FieldLogger, tty bool) *View {
+	v := &View{
+		tracker:  tracker,
+		out:      out,
+		log:      l,
+		tty:      tty,
+		interval: LogInterval,
+	}
+	if tty {
+		v.interval = TTYInterval
+	}
+	return v
+}
+
+// IsTerminal returns true if f is a terminal (character device).
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// IsSameTerminal returns true if w and f are the same terminal (like stderr and stdout of an interactive shell).
+func IsSameTerminal(w io.Writer, f *os.File) bool {
+	o, ok := w.(*os.File)
+	if !ok || !IsTerminal(o) || !IsTerminal(f) {
+		return false
+	}
+	a, err := o.Stat()
+	if err != nil {
+		return false
+	}
+	b, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return os.SameFile(a, b)
+}
+
+// Run applies all events to the tracker and renders the progress until events is closed.
+// The final progress is rendered once more.
+func (v *View) Run(events <-chan *Event) {
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				v.Render()
+				return
+			}
+			v.tracker.Apply(e)
+		case <-ticker.C:
+			v.Render()
+		}
+	}
+}
+
+// Render renders the current progress once.
+func (v *View) Render() {
+	s := v.tracker.Snapshot()
+	if !v.tty {
+		v.logSummary(s)
+		return
+	}
+
+	lines := []string{Summary(s)}
+	for i, tr := range s.Active {
+		if i == maxActive {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(s.Active)-maxActive))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %-40s %3d%% %10s %6s", tr.Package, tr.Percent, FormatBytes(tr.Bytes), time.Since(tr.Started).Truncate(time.Second)))
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.last = lines
+	v.draw()
+}
+
+// LogWriter returns a writer that writes log lines to w above the interactive view.
+// Every write clears the interactive view first and redraws it below the log lines afterwards.
+// Without it, log lines garble the interactive view.
+func (v *View) LogWriter(w io.Writer) io.Writer {
+	return &logWriter{view: v, out: w}
+}
+
+// draw moves the cursor to the first line of the interactive view, clears everything below
+// and writes the last rendering. v.mu needs to be locked.
+func (v *View) draw() {
+	var b bytes.Buffer
+	v.clear(&b)
+	for _, l := range v.last {
+		b.WriteString(l + "\n")
+	}
+	io.WriteString(v.out, b.String())
+	v.lines = len(v.last)
+}
+
+// clear writes the sequence to b that removes the interactive view from the terminal. v.mu needs to be locked.
+func (v *View) clear(b *bytes.Buffer) {
+	if v.lines > 0 {
+		fmt.Fprintf(b, "\x1b[%dA", v.lines)
+	}
+	b.WriteString("\x1b[J")
+	v.lines = 0
+}
+
+// logWriter writes log lines above the interactive view (see View.LogWriter).
+type logWriter struct {
+	view *View
+	out  io.Writer
+}
+
+// Write implements io.Writer.
+func (w *logWriter) Write(p []byte) (int, error) {
+	v := w.view
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var b bytes.Buffer
+	v.clear(&b)
+	io.WriteString(v.out, b.String())
+	n, err := w.out.Write(p)
+	v.draw()
+	return n, err
+}
+
+// logSummary logs the progress s as a single log line.
+func (v *View) logSummary(s *Snapshot) {
+	if s.Queued == 0 && s.Resolved == 0 {
+		return
+	}
+	fields := logrus.Fields{
+		"resolved": s.Resolved,
+		"queued":   s.Queued,
+		"done":     s.Done,
+		"failed":   s.Failed,
+		"skipped":  s.Skipped,
+		"active":   len(s.Active),
+		"bytes":    s.Bytes,
+		"elapsed":  s.Elapsed.Truncate(time.Second).String(),
+	}
+	if s.ETA > 0 {
+		fields["eta"] = s.ETA.Truncate(time.Second).String()
+	}
+	v.log.WithFields(fields).Info("Progress")
+}
+
+// Summary returns the progress s as a single line like
+// "resolved 120 | queued 118 | done 40 | failed 1 | skipped 2 | active 4 | 1.2 MiB | ETA 2m10s".
+func Summary(s *Snapshot) string {
+	l := fmt.Sprintf("resolved %d | queued %d | done %d | failed %d | skipped %d | active %d | %s", s.Resolved, s.Queued, s.Done, s.Failed, s.Skipped, len(s.Active), FormatBytes(s.Bytes))
+	if s.ETA > 0 {
+		l += " | ETA " + s.ETA.Truncate(time.Second).String()
+	}
+	return l
+}
+
+// FormatBytes returns b in a human readable form like "1.2 MiB".
+func FormatBytes(b int64) string {
+	units := []string{"KiB", "MiB", "GiB"}
+	if b < 1024 {
+		return fmt.Sprintf("%d B", b)
+	}
+	f := float64(b)
+	unit := ""
+	for _, u := range units {
+		f /= 1024
+		unit = u
+		if f < 1024 {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f %s", f, unit)
+}
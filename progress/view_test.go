@@ -0,0 +1,114 @@
+package progress_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	. "github.com/andygrunwald/perseus/progress"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:                "0 B",
+		1023:             "1023 B",
+		1536:             "1.5 KiB",
+		5 * 1024 * 1024:  "5.0 MiB",
+		3 << 30:          "3.0 GiB",
+		2048 * (1 << 30): "2048.0 GiB",
+	}
+	for b, expected := range tests {
+		if s := FormatBytes(b); s != expected {
+			t.Errorf("FormatBytes(%d) = %q. Expected %q", b, s, expected)
+		}
+	}
+}
+
+func TestView_Run(t *testing.T) {
+	events := make(chan *Event, 4)
+	events <- &Event{Type: EventQueued, Package: "vendor/a"}
+	events <- &Event{Type: EventStarted, Package: "vendor/a"}
+	events <- &Event{Type: EventDone, Package: "vendor/a"}
+	close(events)
+
+	// Not a terminal: The summary is logged
+	var log bytes.Buffer
+	l := logrus.New()
+	l.Out = &log
+	l.Formatter = &logrus.TextFormatter{DisableColors: true}
+	var out bytes.Buffer
+	NewView(NewTracker(), &out, l, false).Run(events)
+
+	if out.Len() != 0 {
+		t.Errorf("Expected no interactive output. Got %q", out.String())
+	}
+	if s := log.String(); !strings.Contains(s, "msg=Progress") || !strings.Contains(s, "done=1") || !strings.Contains(s, "queued=1") {
+		t.Errorf("Expected a progress log line with the counters. Got %q", s)
+	}
+}
+
+func TestView_Render_Terminal(t *testing.T) {
+	tr := NewTracker()
+	tr.Apply(&Event{Type: EventQueued, Package: "vendor/a"})
+	tr.Apply(&Event{Type: EventTransfer, Package: "vendor/a", Bytes: 2048, Percent: 42})
+
+	var out bytes.Buffer
+	v := NewView(tr, &out, logrus.New(), true)
+	v.Render()
+	first := out.String()
+	if !strings.Contains(first, "queued 1") || !strings.Contains(first, "vendor/a") || !strings.Contains(first, " 42%") {
+		t.Errorf("Expected the summary and the active transfer. Got %q", first)
+	}
+
+	// The second rendering moves the cursor up to overwrite the first one
+	out.Reset()
+	v.Render()
+	if !strings.HasPrefix(out.String(), "\x1b[2A") {
+		t.Errorf("Expected the cursor to move up two lines. Got %q", out.String())
+	}
+}
+
+func TestView_LogWriter(t *testing.T) {
+	tr := NewTracker()
+	tr.Apply(&Event{Type: EventQueued, Package: "vendor/a"})
+
+	var out bytes.Buffer
+	v := NewView(tr, &out, logrus.New(), true)
+	v.Render()
+
+	// A log line clears the view, is printed above it and the view is redrawn below
+	out.Reset()
+	l := logrus.New()
+	l.Out = v.LogWriter(&out)
+	l.Formatter = &logrus.TextFormatter{DisableColors: true}
+	l.Info("Cloning")
+
+	s := out.String()
+	if !strings.HasPrefix(s, "\x1b[1A\x1b[J") {
+		t.Errorf("Expected the view to be cleared first. Got %q", s)
+	}
+	logLine, summary := strings.Index(s, "msg=Cloning"), strings.LastIndex(s, "queued 1")
+	if logLine < 0 || summary < logLine {
+		t.Errorf("Expected the log line above the redrawn view. Got %q", s)
+	}
+}
+
+func TestIsSameTerminal(t *testing.T) {
+	f, err := ioutil.TempFile("", "perseus-progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// A log file is never the terminal of the view
+	if IsSameTerminal(f, f) {
+		t.Errorf("Expected the regular file %s not to be a terminal", f.Name())
+	}
+	if IsSameTerminal(&bytes.Buffer{}, os.Stdout) {
+		t.Error("Expected a buffer not to be a terminal")
+	}
+}
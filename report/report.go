@@ -0,0 +1,231 @@
+// Package report records the outcome of every package during a single run
+// of a command (like "mirror" or "update").
+package report
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status reflects the outcome of a single package during a run.
+type Status string
+
+const (
+	// StatusMirrored means the package was mirrored (initial clone) successfully
+	StatusMirrored Status = "mirrored"
+	// StatusUpdated means the package was updated successfully
+	StatusUpdated Status = "updated"
+	// StatusSkipped means the package was skipped on purpose (e.g. because it is ignored)
+	StatusSkipped Status = "skipped"
+	// StatusFailed means the package couldn't be processed
+	StatusFailed Status = "failed"
+	// StatusVerified means all integrity checks of the mirror passed
+	StatusVerified Status = "verified"
+	// StatusRepaired means at least one integrity check of the mirror failed and the mirror was repaired
+	StatusRepaired Status = "repaired"
+	// StatusCollected means the mirror was garbage collected
+	StatusCollected Status = "collected"
+	// StatusDeduplicated means the objects of the mirror were moved to an object pool that is shared with its forks
+	StatusDeduplicated Status = "deduplicated"
+	// StatusMoved means the upstream URL of the package changed and the mirror follows the new URL
+	StatusMoved Status = "moved"
+	// StatusProtected means a ref of the mirror was deleted or rewritten upstream and the old state was kept or archived
+	StatusProtected Status = "protected"
+	// StatusTampered means a tag was moved or a protected branch was force pushed upstream (see downloader.TamperPolicy)
+	StatusTampered Status = "tampered"
+	// StatusHookFailed means a hook failed for an event of the package or the run (see hook.Hook)
+	StatusHookFailed Status = "hook-failed"
+	// StatusAbandoned means the package is abandoned upstream, but was mirrored anyway
+	StatusAbandoned Status = "abandoned"
+)
+
+// Entry reflects the outcome of a single package during a run.
+type Entry struct {
+	// Package is the name of the package (e.g. "symfony/console") or the path of the mirror
+	Package string `json:"package"`
+	// Status is the outcome of the package
+	Status Status `json:"status"`
+	// Reason describes why a package was skipped or failed
+	Reason string `json:"reason,omitempty"`
+	// Change is the kind of the ref change of protected and tampered entries like "tag-moved"
+	Change string `json:"change,omitempty"`
+	// Refs are the refs that changed upstream like "refs/tags/v1.0.0 new-tag"
+	Refs []string `json:"refs,omitempty"`
+	// Ref is the changed ref of protected and tampered entries
+	Ref *Ref `json:"ref,omitempty"`
+	// Time is the point in time when the entry was recorded
+	Time time.Time `json:"time"`
+}
+
+// Ref reflects a single ref that changed upstream.
+type Ref struct {
+	// Name is the name of the ref like refs/tags/v1.0.0
+	Name string `json:"name"`
+	// Old is the object the ref pointed to before the update
+	Old string `json:"old,omitempty"`
+	// New is the object the ref points to upstream. Empty if the ref was deleted.
+	New string `json:"new,omitempty"`
+	// Action is the action of the tamper policy like "warn" or "block" (see downloader.TamperPolicy)
+	Action string `json:"action,omitempty"`
+}
+
+// Report reflects the run report of a single command run (like "mirror" or "update").
+// Controllers record the outcome of every package they process.
+// The report is the single source of truth for everything that
+// wants to know what happened during a run (logs, notifications, etc.).
+// Report is threadsafe.
+type Report struct {
+	// Command is the name of the command this report belongs to (e.g. "mirror")
+	Command string `json:"command"`
+	// Started is the point in time when the run started
+	Started time.Time `json:"started"`
+	// Finished is the point in time when the run finished
+	Finished time.Time `json:"finished"`
+
+	lock    sync.RWMutex
+	entries []*Entry
+}
+
+// New will create a new run report for command.
+func New(command string) *Report {
+	r := &Report{
+		Command: command,
+		Started: time.Now(),
+		entries: []*Entry{},
+	}
+	return r
+}
+
+// Add records the outcome s of package p.
+// reason is optional and describes why a package was skipped or failed.
+func (r *Report) Add(p string, s Status, reason string) {
+	r.add(&Entry{
+		Package: p,
+		Status:  s,
+		Reason:  reason,
+	})
+}
+
+// add records the entry e.
+func (r *Report) add(e *Entry) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	e.Time = time.Now()
+	r.entries = append(r.entries, e)
+}
+
+// Skipped records package p as skipped with reason.
+func (r *Report) Skipped(p, reason string) {
+	r.Add(p, StatusSkipped, reason)
+}
+
+// Failed records package p as failed with error err.
+func (r *Report) Failed(p string, err error) {
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	r.Add(p, StatusFailed, reason)
+}
+
+// Updated records package p as updated with the refs that changed upstream.
+func (r *Report) Updated(p string, refs []string) {
+	r.add(&Entry{
+		Package: p,
+		Status:  StatusUpdated,
+		Refs:    refs,
+	})
+}
+
+// RefChanged records a ref change of package p with outcome s (like StatusProtected or StatusTampered).
+// change is the kind of the change (like "tag-moved"), reason describes the change and ref is the changed ref.
+func (r *Report) RefChanged(p string, s Status, change, reason string, ref *Ref) {
+	r.add(&Entry{
+		Package: p,
+		Status:  s,
+		Change:  change,
+		Reason:  reason,
+		Ref:     ref,
+	})
+}
+
+// Moved records that the upstream URL of package p changed from oldURL to newURL.
+func (r *Report) Moved(p, oldURL, newURL string) {
+	r.Add(p, StatusMoved, oldURL+" -> "+newURL)
+}
+
+// HookFailed records that hook name failed with error err for event of package p.
+// p is empty for events of the run (like "run-finished").
+func (r *Report) HookFailed(p, name, event string, err error) {
+	r.Add(p, StatusHookFailed, fmt.Sprintf("Hook %s (%s): %s", name, event, err))
+}
+
+// Abandoned records package p as abandoned upstream.
+// replacement is the package that should be used instead (optional).
+func (r *Report) Abandoned(p, replacement string) {
+	reason := ""
+	if len(replacement) > 0 {
+		reason = fmt.Sprintf("Use %s instead", replacement)
+	}
+	r.Add(p, StatusAbandoned, reason)
+}
+
+// Finish marks the run as finished.
+func (r *Report) Finish() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.Finished = time.Now()
+}
+
+// Entries returns all recorded entries in the order they were recorded.
+func (r *Report) Entries() []*Entry {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	l := make([]*Entry, len(r.entries))
+	copy(l, r.entries)
+	return l
+}
+
+// Filter returns all recorded entries with status s sorted by package name.
+func (r *Report) Filter(s Status) []*Entry {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	l := []*Entry{}
+	for _, e := range r.entries {
+		if e.Status == s {
+			l = append(l, e)
+		}
+	}
+	sort.Sort(entriesByPackage(l))
+	return l
+}
+
+// Counts returns the number of recorded entries per status.
+func (r *Report) Counts() map[Status]int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	m := map[Status]int{}
+	for _, e := range r.entries {
+		m[e.Status]++
+	}
+	return m
+}
+
+// HasFailures returns true if at least one package failed.
+func (r *Report) HasFailures() bool {
+	return r.Counts()[StatusFailed] > 0
+}
+
+// entriesByPackage sorts entries by package name
+type entriesByPackage []*Entry
+
+func (l entriesByPackage) Len() int           { return len(l) }
+func (l entriesByPackage) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l entriesByPackage) Less(i, j int) bool { return l[i].Package < l[j].Package }
@@ -0,0 +1,105 @@
+package report_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/andygrunwald/perseus/report"
+)
+
+func TestNew(t *testing.T) {
+	r := New("mirror")
+	if r.Command != "mirror" {
+		t.Errorf("Expected command mirror. Got %s", r.Command)
+	}
+	if r.Started.IsZero() {
+		t.Error("Expected a start time. Got none")
+	}
+	if n := len(r.Entries()); n != 0 {
+		t.Errorf("Expected an empty report. Got %d entries", n)
+	}
+}
+
+func TestReport_Counts(t *testing.T) {
+	r := New("mirror")
+	r.Add("symfony/console", StatusMirrored, "")
+	r.Add("twig/twig", StatusMirrored, "")
+	r.Skipped("my-vendor/package", "Matches ignore pattern \"my-vendor/*\"")
+	r.Failed("api/error", errors.New("API returns an error"))
+
+	c := r.Counts()
+	if c[StatusMirrored] != 2 || c[StatusSkipped] != 1 || c[StatusFailed] != 1 {
+		t.Errorf("Got unexpected counts: %+v", c)
+	}
+	if !r.HasFailures() {
+		t.Error("Expected failures. Got none")
+	}
+}
+
+func TestReport_Filter(t *testing.T) {
+	r := New("add")
+	r.Skipped("z/z", "reason z")
+	r.Add("symfony/console", StatusMirrored, "")
+	r.Skipped("a/a", "reason a")
+
+	l := r.Filter(StatusSkipped)
+	if n := len(l); n != 2 {
+		t.Fatalf("Expected two skipped entries. Got %d", n)
+	}
+	if l[0].Package != "a/a" || l[1].Package != "z/z" {
+		t.Errorf("Expected entries sorted by package. Got %s, %s", l[0].Package, l[1].Package)
+	}
+	if l[0].Reason != "reason a" {
+		t.Errorf("Expected reason \"reason a\". Got %s", l[0].Reason)
+	}
+}
+
+func TestReport_HasFailures_Empty(t *testing.T) {
+	r := New("update")
+	r.Add("symfony/console", StatusUpdated, "")
+	r.Finish()
+
+	if r.HasFailures() {
+		t.Error("Expected no failures. Got some")
+	}
+	if r.Finished.IsZero() {
+		t.Error("Expected a finish time. Got none")
+	}
+}
+
+func TestReport_Updated(t *testing.T) {
+	r := New("update")
+	r.Updated("symfony/console", []string{"refs/tags/v1.0.0 new-tag"})
+
+	l := r.Filter(StatusUpdated)
+	if len(l) != 1 || len(l[0].Refs) != 1 || l[0].Refs[0] != "refs/tags/v1.0.0 new-tag" {
+		t.Errorf("Expected an updated entry with the changed refs. Got %+v", l)
+	}
+}
+
+func TestReport_Abandoned(t *testing.T) {
+	r := New("mirror")
+	r.Abandoned("symfony/icu", "symfony/intl")
+	r.Abandoned("my/package", "")
+
+	l := r.Filter(StatusAbandoned)
+	if len(l) != 2 || l[0].Reason != "" || l[1].Reason != "Use symfony/intl instead" {
+		t.Errorf("Expected two abandoned entries with the replacement as reason. Got %+v", l)
+	}
+	if r.HasFailures() {
+		t.Error("Expected an abandoned package to be no failure")
+	}
+}
+
+func TestReport_HookFailed(t *testing.T) {
+	r := New("mirror")
+	r.HookFailed("symfony/console", "satis-build", "package-added", errors.New("exit status 1"))
+
+	l := r.Filter(StatusHookFailed)
+	if len(l) != 1 || l[0].Package != "symfony/console" || l[0].Reason != "Hook satis-build (package-added): exit status 1" {
+		t.Errorf("Expected a hook-failed entry with the hook and the error. Got %+v", l)
+	}
+	if r.HasFailures() {
+		t.Error("Expected a failed hook to be no failure of a package")
+	}
+}
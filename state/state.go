@@ -0,0 +1,318 @@
+// Package state records the history of every mirrored repository across runs.
+// The state is stored as a JSON file in the directory of the mirrors.
+// Concurrent runs (like a cron job and a manual update) share this file (see Store.Save).
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the state file inside the repository directory
+const FileName = ".perseus-state.json"
+
+// Repository reflects the state of a single mirrored repository.
+type Repository struct {
+	// Name is the name of the package (e.g. "symfony/console")
+	Name string `json:"name"`
+	// URL is the URL of the upstream repository
+	URL string `json:"url,omitempty"`
+	// Origin is the command that added the repository (e.g. "add" or "mirror")
+	Origin string `json:"origin,omitempty"`
+	// AddedAt is the point in time when the repository was mirrored initially
+	AddedAt time.Time `json:"added_at"`
+	// LastFetchAttempt is the point in time of the last clone or update
+	LastFetchAttempt time.Time `json:"last_fetch_attempt"`
+	// LastFetchSuccess is the point in time of the last successful clone or update
+	LastFetchSuccess time.Time `json:"last_fetch_success"`
+	// LastError is the error of the last fetch. Empty if the last fetch was successful.
+	LastError string `json:"last_error,omitempty"`
+	// RefCount is the number of refs (branches, tags, ...) after the last successful fetch
+	RefCount int `json:"ref_count"`
+	// LastVerifiedAt is the point in time of the last integrity check (see "verify" command)
+	LastVerifiedAt time.Time `json:"last_verified_at"`
+	// LastVerifyError is the error of the last integrity check. Empty if all checks passed.
+	LastVerifyError string `json:"last_verify_error,omitempty"`
+	// LastGCAt is the point in time of the last garbage collection (see "gc" command)
+	LastGCAt time.Time `json:"last_gc_at"`
+}
+
+// Store is the state of all mirrored repositories.
+// Store is threadsafe.
+type Store struct {
+	path string
+
+	lock         sync.RWMutex
+	repositories map[string]*Repository
+	// changed are the names of the repositories that were changed or removed since the store was read
+	changed map[string]bool
+}
+
+// Path returns the path of the state file for the repository directory repoDir.
+func Path(repoDir string) string {
+	return filepath.Join(repoDir, FileName)
+}
+
+// Open reads the state file path.
+// If the file doesn't exist, an empty store will be returned.
+// The file will be created with the first call of Save.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:         path,
+		repositories: map[string]*Repository{},
+		changed:      map[string]bool{},
+	}
+
+	l, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range l {
+		s.repositories[r.Name] = r
+	}
+
+	return s, nil
+}
+
+// read returns the repositories of the state file path.
+// If the file doesn't exist, no repositories will be returned.
+func read(path string) ([]*Repository, error) {
+	l := []*Repository{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Get returns a copy of the state of repository name.
+// If the repository is unknown, the second return value is false.
+func (s *Store) Get(name string) (Repository, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	r, ok := s.repositories[name]
+	if !ok {
+		return Repository{}, false
+	}
+	return *r, true
+}
+
+// List returns a copy of the state of all repositories sorted by name.
+func (s *Store) List() []Repository {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	l := make([]Repository, 0, len(s.repositories))
+	for _, r := range s.repositories {
+		l = append(l, *r)
+	}
+	Sort(l)
+	return l
+}
+
+// Sort sorts the repositories l by name.
+func Sort(l []Repository) {
+	sort.Sort(repositoriesByName(l))
+}
+
+// Added records that repository name was added by command origin from url.
+// The time of the first addition is kept, if the repository is known already.
+func (s *Store) Added(name, url, origin string, t time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := s.get(name)
+	if len(url) > 0 {
+		r.URL = url
+	}
+	if r.AddedAt.IsZero() {
+		r.AddedAt = t
+		r.Origin = origin
+	}
+}
+
+// Moved records that the upstream URL of repository name changed to url.
+func (s *Store) Moved(name, url string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.get(name).URL = url
+}
+
+// Fetched records a fetch (clone or update) of repository name at time t.
+// If err is nil, the fetch is recorded as success with refCount refs.
+// Otherwise err is recorded as the last error and the ref count is kept.
+func (s *Store) Fetched(name string, t time.Time, refCount int, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := s.get(name)
+	r.LastFetchAttempt = t
+	if err != nil {
+		r.LastError = err.Error()
+		return
+	}
+	r.LastFetchSuccess = t
+	r.LastError = ""
+	r.RefCount = refCount
+}
+
+// Remove removes the state of repository name (e.g. because the mirror was deleted).
+func (s *Store) Remove(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.repositories, name)
+	s.changed[name] = true
+}
+
+// Verified records an integrity check of repository name at time t.
+// If err is not nil, the check failed.
+func (s *Store) Verified(name string, t time.Time, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := s.get(name)
+	r.LastVerifiedAt = t
+	r.LastVerifyError = ""
+	if err != nil {
+		r.LastVerifyError = err.Error()
+	}
+}
+
+// Collected records a garbage collection of repository name at time t.
+func (s *Store) Collected(name string, t time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.get(name).LastGCAt = t
+}
+
+// Save writes the state to disk.
+// Another run might have saved the state since it was read. This is why the state file is
+// read again while holding a lock file and only the repositories changed by this store are
+// written on top of it.
+// The state is written to a temporary file first that will be renamed afterwards.
+// With this a crash during Save never leaves a half written state file.
+func (s *Store) Save() error {
+	unlock, err := lock(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.merge(); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s.List(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	// TempFile creates the file with mode 0600, but the state is readable like the mirrors
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	s.changed = map[string]bool{}
+	s.lock.Unlock()
+	return nil
+}
+
+// merge takes over the repositories of the state file that were not changed by this store.
+// The caller needs to hold the lock file.
+func (s *Store) merge() error {
+	l, err := read(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	onDisk := map[string]bool{}
+	for _, r := range l {
+		onDisk[r.Name] = true
+		if !s.changed[r.Name] {
+			s.repositories[r.Name] = r
+		}
+	}
+	// Repositories that were removed by another run
+	for name := range s.repositories {
+		if !onDisk[name] && !s.changed[name] {
+			delete(s.repositories, name)
+		}
+	}
+	return nil
+}
+
+// lock acquires an exclusive lock on the file path and waits until it is available.
+// The returned function releases the lock.
+func lock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// get returns the state of repository name.
+// If the repository is unknown, a new state will be created.
+// The repository is marked as changed (see Save).
+// The caller needs to hold the write lock.
+func (s *Store) get(name string) *Repository {
+	r, ok := s.repositories[name]
+	if !ok {
+		r = &Repository{Name: name}
+		s.repositories[name] = r
+	}
+	s.changed[name] = true
+	return r
+}
+
+// repositoriesByName sorts repositories by name
+type repositoriesByName []Repository
+
+func (l repositoriesByName) Len() int           { return len(l) }
+func (l repositoriesByName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l repositoriesByName) Less(i, j int) bool { return l[i].Name < l[j].Name }
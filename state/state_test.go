@@ -0,0 +1,131 @@
+package state_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/andygrunwald/perseus/state"
+)
+
+func TestOpen_NotExistingFile(t *testing.T) {
+	s, err := Open(filepath.Join(os.TempDir(), "perseus-state-does-not-exist.json"))
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	if n := len(s.List()); n != 0 {
+		t.Errorf("Expected an empty store. Got %d repositories", n)
+	}
+}
+
+func TestStore_AddedAndFetched(t *testing.T) {
+	s, _ := Open(filepath.Join(os.TempDir(), "perseus-state-does-not-exist.json"))
+	added := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	s.Added("symfony/console", "https://github.com/symfony/console.git", "add", added)
+	// A second addition must not overwrite the origin and the time
+	s.Added("symfony/console", "https://github.com/symfony/console.git", "mirror", added.Add(time.Hour))
+
+	success := added.Add(time.Minute)
+	s.Fetched("symfony/console", success, 42, nil)
+	failure := added.Add(2 * time.Hour)
+	s.Fetched("symfony/console", failure, 0, errors.New("Network is unreachable"))
+
+	r, ok := s.Get("symfony/console")
+	if !ok {
+		t.Fatal("Expected repository symfony/console. Got none")
+	}
+	if r.Origin != "add" || !r.AddedAt.Equal(added) {
+		t.Errorf("Expected origin add, added at %s. Got %s, %s", added, r.Origin, r.AddedAt)
+	}
+	if !r.LastFetchSuccess.Equal(success) || !r.LastFetchAttempt.Equal(failure) {
+		t.Errorf("Expected last success %s and last attempt %s. Got %s and %s", success, failure, r.LastFetchSuccess, r.LastFetchAttempt)
+	}
+	if r.LastError != "Network is unreachable" {
+		t.Errorf("Expected last error \"Network is unreachable\". Got \"%s\"", r.LastError)
+	}
+	if r.RefCount != 42 {
+		t.Errorf("Expected the ref count of the last success (42). Got %d", r.RefCount)
+	}
+}
+
+func TestStore_SaveAndOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "perseus-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := Open(Path(dir))
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	s.Added("twig/twig", "https://github.com/twigphp/Twig.git", "mirror", now)
+	s.Added("psr/log", "https://github.com/php-fig/log.git", "mirror", now)
+	s.Fetched("twig/twig", now, 12, nil)
+	if err := s.Save(); err != nil {
+		t.Fatalf("Expected no error while saving. Got %s", err)
+	}
+
+	s, err = Open(Path(dir))
+	if err != nil {
+		t.Fatalf("Expected no error. Got %s", err)
+	}
+	l := s.List()
+	if len(l) != 2 || l[0].Name != "psr/log" || l[1].Name != "twig/twig" {
+		t.Fatalf("Expected psr/log and twig/twig. Got %+v", l)
+	}
+	if l[1].RefCount != 12 || !l[1].LastFetchSuccess.Equal(now) {
+		t.Errorf("Got unexpected state for twig/twig: %+v", l[1])
+	}
+}
+
+func TestStore_Save_ConcurrentRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "perseus-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	s, _ := Open(Path(dir))
+	s.Added("twig/twig", "https://github.com/twigphp/Twig.git", "mirror", now)
+	s.Added("psr/log", "https://github.com/php-fig/log.git", "mirror", now)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two runs read the same state, but change different repositories
+	first, _ := Open(Path(dir))
+	second, _ := Open(Path(dir))
+	first.Fetched("twig/twig", now, 12, nil)
+	second.Fetched("psr/log", now, 3, nil)
+	second.Remove("twig/twig")
+	second.Added("symfony/console", "https://github.com/symfony/console.git", "add", now)
+	if err := second.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ = Open(Path(dir))
+	l := s.List()
+	if len(l) != 3 || l[0].Name != "psr/log" || l[1].Name != "symfony/console" || l[2].Name != "twig/twig" {
+		t.Fatalf("Expected the repositories of both runs. Got %+v", l)
+	}
+	if l[0].RefCount != 3 || l[2].RefCount != 12 {
+		t.Errorf("Expected the fetches of both runs. Got %+v", l)
+	}
+
+	fi, err := os.Stat(Path(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := fi.Mode().Perm(); m != 0644 {
+		t.Errorf("Expected the state file to be readable with mode 0644. Got %s", m)
+	}
+}